@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/gohornet/inx-mqtt/mqtt"
+)
+
+// logLevelRequest is the body of the log level route.
+type logLevelRequest struct {
+	// The new minimum enabled logging level (debug, info, warn, error).
+	Level string `json:"level"`
+}
+
+// logLevelResponse defines the response of the log level route.
+type logLevelResponse struct {
+	// The currently active minimum logging level.
+	Level string `json:"level"`
+}
+
+// parseLogLevel parses the given level, only accepting levels that make sense to be changed at runtime.
+func parseLogLevel(levelString string) (zapcore.Level, error) {
+	switch strings.ToLower(levelString) {
+	case "debug":
+		return zapcore.DebugLevel, nil
+	case "info":
+		return zapcore.InfoLevel, nil
+	case "warn":
+		return zapcore.WarnLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	default:
+		return zapcore.InfoLevel, fmt.Errorf("invalid log level \"%s\", allowed values: debug, info, warn, error", levelString)
+	}
+}
+
+// setupAdmin starts the admin HTTP server.
+// If auth is given, all routes require basic auth with one of its users.
+func setupAdmin(bindAddress string, server *Server, logLevel zap.AtomicLevel, auth *mqtt.AuthAllowBasicAuth) {
+
+	e := echo.New()
+	e.HideBanner = true
+	e.Use(middleware.Recover())
+	if auth != nil {
+		e.Use(middleware.BasicAuth(func(username string, password string, _ echo.Context) (bool, error) {
+			return auth.Authenticate([]byte(username), []byte(password)), nil
+		}))
+	}
+
+	e.GET("/loglevel", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, &logLevelResponse{Level: logLevel.Level().String()})
+	})
+
+	e.POST("/loglevel", func(c echo.Context) error {
+		request := &logLevelRequest{}
+		if err := c.Bind(request); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid request: %s", err))
+		}
+
+		level, err := parseLogLevel(request.Level)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// the atomic level is shared by all loggers, so this applies to the broker and event loggers as well
+		logLevel.SetLevel(level)
+
+		return c.JSON(http.StatusOK, &logLevelResponse{Level: logLevel.Level().String()})
+	})
+
+	e.GET("/throughput", func(c echo.Context) error {
+		if server.MQTTBroker == nil {
+			return echo.NewHTTPError(http.StatusServiceUnavailable, "broker not started yet")
+		}
+
+		return c.JSON(http.StatusOK, server.MQTTBroker.ThroughputStats())
+	})
+
+	e.GET("/topics", func(c echo.Context) error {
+		if server.MQTTBroker == nil {
+			return echo.NewHTTPError(http.StatusServiceUnavailable, "broker not started yet")
+		}
+
+		return c.JSON(http.StatusOK, server.MQTTBroker.TopicStats())
+	})
+
+	e.GET("/clients", func(c echo.Context) error {
+		if server.MQTTBroker == nil {
+			return echo.NewHTTPError(http.StatusServiceUnavailable, "broker not started yet")
+		}
+
+		return c.JSON(http.StatusOK, server.MQTTBroker.Clients())
+	})
+
+	e.GET("/listeners", func(c echo.Context) error {
+		if server.MQTTBroker == nil {
+			return echo.NewHTTPError(http.StatusServiceUnavailable, "broker not started yet")
+		}
+
+		return c.JSON(http.StatusOK, server.MQTTBroker.Listeners())
+	})
+
+	go func() {
+		if err := e.Start(bindAddress); err != nil {
+			if !errors.Is(err, http.ErrServerClosed) {
+				panic(err)
+			}
+		}
+	}()
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -14,13 +15,16 @@ import (
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/pkg/errors"
 	flag "github.com/spf13/pflag"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
 
 	"github.com/gohornet/inx-mqtt/mqtt"
 
 	"github.com/iotaledger/hive.go/configuration"
+	"github.com/iotaledger/hive.go/logger"
 	inx "github.com/iotaledger/inx/go"
 )
 
@@ -33,15 +37,26 @@ var (
 
 const (
 	APIRoute = "mqtt/v1"
+
+	// configFilePath is the path of the config file, it is loaded again to reload the TCP auth users.
+	configFilePath = "config.json"
 )
 
 func main() {
 	fmt.Printf(">>>>> Starting %s v%s <<<<<\n", AppName, Version)
 
-	config, err := loadConfigFile("config.json")
+	config, err := loadConfigFile(configFilePath)
+	if err != nil {
+		panic(err)
+	}
+
+	// the atomic level is shared by all loggers, so it can be changed at runtime via the admin API
+	logLevel := zap.NewAtomicLevel()
+	rootLogger, err := logger.NewRootLoggerFromConfiguration(config, logLevel)
 	if err != nil {
 		panic(err)
 	}
+	log := rootLogger.Named(AppName)
 
 	conn, err := grpc.Dial(config.String(CfgINXAddress),
 		grpc.WithChainUnaryInterceptor(grpc_retry.UnaryClientInterceptor(), grpc_prometheus.UnaryClientInterceptor),
@@ -57,40 +72,172 @@ func main() {
 	}
 	defer conn.Close()
 
+	messageExpiry, err := parseMessageExpiry(config.StringMap(CfgMQTTMessageExpiry))
+	if err != nil {
+		panic(err)
+	}
+
+	topicPublishOptions, err := parseTopicPublishOptions(config.StringMap(CfgMQTTPublishQoS), config.Strings(CfgMQTTPublishRetainedTopics))
+	if err != nil {
+		panic(err)
+	}
+
+	tcpListeners, err := parseTCPListeners(config.StringMap(CfgMQTTTCPAdditionalListeners))
+	if err != nil {
+		panic(err)
+	}
+
+	throughputWindows, err := parseDurations(CfgMQTTThroughputWindows, config.Strings(CfgMQTTThroughputWindows))
+	if err != nil {
+		panic(err)
+	}
+
+	topicHooks := httpPostTopicHooks(config.StringMap(CfgMQTTTopicHooksHTTPPost), config.Duration(CfgMQTTTopicHooksHTTPTimeout))
+
+	var publishFilter PublishFilter
+	if config.Bool(CfgMQTTPublishFilterIncludedOnly) {
+		publishFilter = PublishFilterIncludedOnly
+	}
+
+	var endedTopicMessage []byte
+	if message := config.String(CfgMQTTCloseEndedTopicsMessage); message != "" {
+		endedTopicMessage = []byte(message)
+	}
+
 	client := inx.NewINXClient(conn)
-	server, err := NewServer(client,
+	server, err := NewServer(log, client,
+		[]ServerOption{
+			WithOutputTopicGranularity(OutputTopicGranularity(config.String(CfgMQTTOutputTopicGranularity))),
+			WithTransactionBalanceEnabled(config.Bool(CfgMQTTTransactionBalanceEnabled)),
+			WithMonotonicMilestoneTimestamps(config.Bool(CfgMQTTMonotonicMilestoneTimestamps)),
+			WithVerifyMilestoneSignatures(config.Bool(CfgMQTTVerifyMilestoneSignatures)),
+			WithPayloadFormat(PayloadFormat(config.String(CfgMQTTPayloadFormat))),
+			WithDeduplicateOutputs(config.Bool(CfgMQTTDeduplicateOutputs)),
+			WithOutputBatchingEnabled(config.Bool(CfgMQTTOutputBatchingEnabled)),
+			WithDecodeOutputs(config.Bool(CfgMQTTDecodeOutputs)),
+			WithPublishTimestamps(config.Bool(CfgMQTTPublishTimestamps)),
+			WithPublishFilter(publishFilter),
+		},
 		mqtt.WithBufferSize(config.Int(CfgMQTTBufferSize)),
 		mqtt.WithBufferBlockSize(config.Int(CfgMQTTBufferBlockSize)),
+		mqtt.WithSlowClientPolicy(mqtt.SlowClientPolicy(config.String(CfgMQTTSlowClientPolicy))),
+		mqtt.WithFirehosePolicy(mqtt.FirehosePolicy(config.String(CfgMQTTFirehosePolicy))),
+		mqtt.WithFirehoseTopics(config.Strings(CfgMQTTFirehoseTopics)),
+		mqtt.WithFirehoseBackpressureThreshold(config.Int(CfgMQTTFirehoseBackpressureThreshold)),
+		mqtt.WithFirehoseSampleRate(config.Int(CfgMQTTFirehoseSampleRate)),
 		mqtt.WithTopicCleanupThreshold(config.Int(CfgMQTTTopicCleanupThreshold)),
+		mqtt.WithMaxTopicManagerSize(config.Int(CfgMQTTMaxTopicManagerSize)),
+		mqtt.WithTopicPrefix(config.String(CfgMQTTTopicPrefix)),
+		mqtt.WithMaxClients(config.Int(CfgMQTTLimitsMaxClients)),
+		mqtt.WithMaxConnectionsPerIP(config.Int(CfgMQTTLimitsMaxConnectionsPerIP)),
+		mqtt.WithMaxSubscriptionsPerClient(config.Int(CfgMQTTLimitsMaxSubscriptionsPerClient)),
+		mqtt.WithMaxMessagesPerSecondPerClient(config.Int(CfgMQTTLimitsMaxMessagesPerSecondPerClient)),
+		mqtt.WithMaxRetainedMessages(config.Int(CfgMQTTMaxRetainedMessages)),
+		mqtt.WithRetainUpdateInterval(config.Duration(CfgMQTTRetainUpdateInterval)),
+		mqtt.WithRetainedStorePath(config.String(CfgMQTTRetainedStorePath)),
+		mqtt.WithTopicPublishOptions(topicPublishOptions),
+		mqtt.WithSubscriptionFilterFilePath(config.String(CfgMQTTSubscriptionFilterFilePath)),
+		mqtt.WithMessageExpiry(messageExpiry),
+		mqtt.WithAckTimeout(config.Duration(CfgMQTTAckTimeout)),
+		mqtt.WithAckTimeoutMaxRetransmissions(config.Int(CfgMQTTAckTimeoutMaxRetransmissions)),
+		mqtt.WithThroughputWindows(throughputWindows),
+		mqtt.WithClientEventLogSampleRate(config.Int(CfgMQTTClientEventLogSampleRate)),
+		mqtt.WithBatchWindow(config.Duration(CfgMQTTOutputBatchingWindow)),
+		mqtt.WithBatchMaxSize(config.Int(CfgMQTTOutputBatchingMaxSize)),
+		mqtt.WithPublishCoalesceWindow(config.Duration(CfgMQTTPublishCoalesceWindow)),
+		mqtt.WithPublishCoalesceTopics(config.Strings(CfgMQTTPublishCoalesceTopics)),
+		mqtt.WithTopicHooks(topicHooks),
+		mqtt.WithTopicHookWorkers(config.Int(CfgMQTTTopicHooksWorkers)),
+		mqtt.WithTopicHookQueueSize(config.Int(CfgMQTTTopicHooksQueueSize)),
+		mqtt.WithBridgeEnabled(config.Bool(CfgMQTTBridgeEnabled)),
+		mqtt.WithBridgeURL(config.String(CfgMQTTBridgeURL)),
+		mqtt.WithBridgeUsername(config.String(CfgMQTTBridgeUsername)),
+		mqtt.WithBridgePassword(config.String(CfgMQTTBridgePassword)),
+		mqtt.WithBridgeTopics(config.Strings(CfgMQTTBridgeTopics)),
+		mqtt.WithBridgeOfflineQueuePath(config.String(CfgMQTTBridgeOfflineQueuePath)),
+		mqtt.WithBridgeOfflineQueueMaxSize(config.Int64(CfgMQTTBridgeOfflineQueueMaxSize)),
+		mqtt.WithBridgeOfflineQueueOverflowPolicy(mqtt.BridgeOverflowPolicy(config.String(CfgMQTTBridgeOfflineQueueOverflowPolicy))),
+		mqtt.WithHealthBindAddress(config.String(CfgMQTTHealthBindAddress)),
+		mqtt.WithHealthReadyFunc(func() bool {
+			state := conn.GetState()
+			if state == connectivity.Idle {
+				// a lost connection is only reestablished on the next call, so the probe triggers it
+				conn.Connect()
+			}
+			return state == connectivity.Ready
+		}),
+		mqtt.WithIdleConnectionReaperEnabled(config.Bool(CfgMQTTIdleConnectionReaperEnabled)),
+		mqtt.WithIdleConnectionTimeout(config.Duration(CfgMQTTIdleConnectionReaperTimeout)),
+		mqtt.WithIdleConnectionCheckInterval(config.Duration(CfgMQTTIdleConnectionReaperCheckInterval)),
+		mqtt.WithMaxKeepAlive(config.Duration(CfgMQTTKeepAliveMax)),
+		mqtt.WithReadIdleTimeout(config.Duration(CfgMQTTKeepAliveReadIdleTimeout)),
+		mqtt.WithCloseEndedTopics(config.Bool(CfgMQTTCloseEndedTopicsEnabled)),
+		mqtt.WithEndedTopicMessage(endedTopicMessage),
 		mqtt.WithWebsocketEnabled(config.Bool(CfgMQTTWebsocketEnabled)),
 		mqtt.WithWebsocketBindAddress(config.String(CfgMQTTWebsocketBindAddress)),
+		mqtt.WithWebsocketTLSEnabled(config.Bool(CfgMQTTWebsocketTLSEnabled)),
+		mqtt.WithWebsocketTLSBindAddress(config.String(CfgMQTTWebsocketTLSBindAddress)),
+		mqtt.WithWebsocketTLSCertificatePath(config.String(CfgMQTTWebsocketTLSCertificatePath)),
+		mqtt.WithWebsocketTLSPrivateKeyPath(config.String(CfgMQTTWebsocketTLSPrivateKeyPath)),
+		mqtt.WithWebsocketCompressionEnabled(config.Bool(CfgMQTTWebsocketCompressionEnabled)),
+		mqtt.WithWebsocketCompressionLevel(config.Int(CfgMQTTWebsocketCompressionLevel)),
 		mqtt.WithTCPEnabled(config.Bool(CfgMQTTTCPEnabled)),
 		mqtt.WithTCPBindAddress(config.String(CfgMQTTTCPBindAddress)),
+		mqtt.WithTCPProxyProtocolEnabled(config.Bool(CfgMQTTTCPProxyProtocolEnabled)),
+		mqtt.WithTCPListeners(tcpListeners),
+		mqtt.WithUnixSocketEnabled(config.Bool(CfgMQTTUnixSocketEnabled)),
+		mqtt.WithUnixSocketPath(config.String(CfgMQTTUnixSocketPath)),
 		mqtt.WithTCPAuthEnabled(config.Bool(CfgMQTTTCPAuthEnabled)),
 		mqtt.WithTCPAuthPasswordSalt(config.String(CfgMQTTTCPAuthPasswordSalt)),
 		mqtt.WithTCPAuthUsers(config.StringMap(CfgMQTTTCPAuthUsers)),
+		mqtt.WithTCPAuthACLFilePath(config.String(CfgMQTTTCPAuthACLFilePath)),
+		mqtt.WithTCPAuthJWTKeyPath(config.String(CfgMQTTTCPAuthJWTKeyPath)),
+		mqtt.WithTCPAuthJWTIssuer(config.String(CfgMQTTTCPAuthJWTIssuer)),
+		mqtt.WithTCPAuthJWTAudience(config.String(CfgMQTTTCPAuthJWTAudience)),
 		mqtt.WithTCPTLSEnabled(config.Bool(CfgMQTTTCPTLSEnabled)),
 		mqtt.WithTCPTLSCertificatePath(config.String(CfgMQTTTCPTLSCertificatePath)),
 		mqtt.WithTCPTLSPrivateKeyPath(config.String(CfgMQTTTCPTLSPrivateKeyPath)),
+		mqtt.WithTCPTLSClientAuthEnabled(config.Bool(CfgMQTTTCPTLSClientAuthEnabled)),
+		mqtt.WithTCPTLSClientCAPath(config.String(CfgMQTTTCPTLSClientCAPath)),
 	)
 	if err != nil {
 		panic(err)
 	}
 
+	if config.Bool(CfgPrometheusEnabled) {
+		setupPrometheus(
+			config.String(CfgPrometheusBindAddress),
+			server,
+			config.Bool(CfgPrometheusGoMetrics),
+			config.Bool(CfgPrometheusProcessMetrics),
+		)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
-		fmt.Println("Starting MQTT broker...")
+		log.Info("Starting MQTT broker...")
 		if err := server.Start(ctx); err != nil {
 			panic(err)
 		}
 	}()
+	// startup failures panic, so the broker is always ready afterwards
+	<-server.Ready()
+	log.Info("MQTT broker started")
 
-	if config.Bool(CfgPrometheusEnabled) {
-		setupPrometheus(
-			config.String(CfgPrometheusBindAddress),
+	if config.Bool(CfgAdminEnabled) {
+		var adminAuth *mqtt.AuthAllowBasicAuth
+		if config.Bool(CfgAdminAuthEnabled) {
+			adminAuth, err = mqtt.NewAuthAllowUsers(config.String(CfgAdminAuthPasswordSalt), config.StringMap(CfgAdminAuthUsers))
+			if err != nil {
+				panic(fmt.Errorf("enabling admin auth failed: %w", err))
+			}
+		}
+
+		setupAdmin(
+			config.String(CfgAdminBindAddress),
 			server,
-			config.Bool(CfgPrometheusGoMetrics),
-			config.Bool(CfgPrometheusProcessMetrics),
+			logLevel,
+			adminAuth,
 		)
 	}
 
@@ -112,12 +259,41 @@ func main() {
 			Port:  uint32(port),
 		}
 
-		fmt.Println("Registering API route...")
+		log.Info("Registering API route...")
 		if _, err := client.RegisterAPIRoute(context.Background(), apiReq); err != nil {
 			panic(fmt.Errorf("failed to register API route via INX: %w", err))
 		}
 	}
 
+	reloadTLSCertificate := config.Bool(CfgMQTTTCPTLSEnabled) && config.Bool(CfgMQTTTCPTLSReloadOnSIGHUP)
+	reloadAuthUsers := config.Bool(CfgMQTTTCPAuthEnabled) && config.Bool(CfgMQTTTCPAuthReloadOnSIGHUP)
+	if reloadTLSCertificate || reloadAuthUsers {
+		reloadChan := make(chan os.Signal, 1)
+		signal.Notify(reloadChan, syscall.SIGHUP)
+		go func() {
+			for range reloadChan {
+				if server.MQTTBroker == nil {
+					continue
+				}
+				if reloadTLSCertificate {
+					if err := server.MQTTBroker.ReloadTLSCertificate(); err != nil {
+						log.Errorf("keeping the previous TCP TLS certificate: %s", err)
+					}
+				}
+				if reloadAuthUsers {
+					reloadedConfig, err := reloadConfigFile(configFilePath)
+					if err != nil {
+						log.Errorf("keeping the previous TCP auth users: %s", err)
+						continue
+					}
+					if err := server.MQTTBroker.ReloadUsers(reloadedConfig.StringMap(CfgMQTTTCPAuthUsers), reloadedConfig.String(CfgMQTTTCPAuthPasswordSalt)); err != nil {
+						log.Errorf("keeping the previous TCP auth users: %s", err)
+					}
+				}
+			}
+		}()
+	}
+
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 	done := make(chan bool, 1)
@@ -133,22 +309,148 @@ func main() {
 	cancel()
 
 	// shutdown the broker
-	server.Close()
+	if shutdownTimeout := config.Duration(CfgMQTTShutdownTimeout); shutdownTimeout > 0 {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		_, _ = server.Shutdown(shutdownCtx)
+		shutdownCancel()
+	} else {
+		server.Close()
+	}
 
 	if apiReq != nil {
-		fmt.Println("Removing API route...")
+		log.Info("Removing API route...")
 		if _, err := client.UnregisterAPIRoute(context.Background(), apiReq); err != nil {
 			panic(fmt.Errorf("failed to remove API route via INX: %w", err))
 		}
 	}
 
-	fmt.Println("exiting")
+	log.Info("exiting")
 }
 
 func retryBackoff(_ uint) time.Duration {
 	return 2 * time.Second
 }
 
+// parseMessageExpiry parses the expiry durations per topic prefix.
+func parseMessageExpiry(messageExpiry map[string]string) (map[string]time.Duration, error) {
+	result := make(map[string]time.Duration, len(messageExpiry))
+	for prefix, expiry := range messageExpiry {
+		duration, err := time.ParseDuration(expiry)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s for topic prefix \"%s\" failed: %w", CfgMQTTMessageExpiry, prefix, err)
+		}
+		result[prefix] = duration
+	}
+	return result, nil
+}
+
+// parseTopicPublishOptions parses the QoS per topic and combines it with the retained topics.
+// Topics with publish options that are not retained are published with QoS 0 if no QoS is given.
+func parseTopicPublishOptions(topicQoS map[string]string, retainedTopics []string) (map[string]*mqtt.PublishOptions, error) {
+	result := make(map[string]*mqtt.PublishOptions, len(topicQoS)+len(retainedTopics))
+	for topic, qos := range topicQoS {
+		parsed, err := strconv.ParseUint(qos, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s for topic \"%s\" failed: %w", CfgMQTTPublishQoS, topic, err)
+		}
+		result[topic] = &mqtt.PublishOptions{QoS: byte(parsed)}
+	}
+
+	for _, topic := range retainedTopics {
+		if _, has := result[topic]; !has {
+			result[topic] = &mqtt.PublishOptions{}
+		}
+		result[topic].Retain = true
+	}
+
+	return result, nil
+}
+
+// parseTCPListeners parses the features per bind address of the additional TCP listeners.
+// The listeners are sorted by their bind address, so they keep their listener IDs across restarts.
+func parseTCPListeners(listenerFeatures map[string]string) ([]*mqtt.TCPListenerConfig, error) {
+	bindAddresses := make([]string, 0, len(listenerFeatures))
+	for bindAddress := range listenerFeatures {
+		bindAddresses = append(bindAddresses, bindAddress)
+	}
+	sort.Strings(bindAddresses)
+
+	result := make([]*mqtt.TCPListenerConfig, 0, len(bindAddresses))
+	for _, bindAddress := range bindAddresses {
+		listenerConfig := &mqtt.TCPListenerConfig{BindAddress: bindAddress}
+
+		features := strings.TrimSpace(listenerFeatures[bindAddress])
+		if features != "" {
+			for _, feature := range strings.Split(features, "+") {
+				switch strings.TrimSpace(feature) {
+				case "auth":
+					listenerConfig.AuthEnabled = true
+				case "tls":
+					listenerConfig.TLSEnabled = true
+				case "proxyProtocol":
+					listenerConfig.ProxyProtocolEnabled = true
+				default:
+					return nil, fmt.Errorf("parsing %s for bind address \"%s\" failed: unknown feature \"%s\"", CfgMQTTTCPAdditionalListeners, bindAddress, feature)
+				}
+			}
+		}
+		result = append(result, listenerConfig)
+	}
+
+	return result, nil
+}
+
+// parseDurations parses the durations of the given config key.
+func parseDurations(key string, durations []string) ([]time.Duration, error) {
+	result := make([]time.Duration, 0, len(durations))
+	for _, duration := range durations {
+		parsed, err := time.ParseDuration(duration)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s failed: %w", key, err)
+		}
+		result = append(result, parsed)
+	}
+	return result, nil
+}
+
+// httpPostTopicHooks creates the HTTP POST topic hooks for the given topic filters and URLs.
+func httpPostTopicHooks(httpPostHooks map[string]string, timeout time.Duration) []*mqtt.TopicHook {
+	filters := make([]string, 0, len(httpPostHooks))
+	for filter := range httpPostHooks {
+		filters = append(filters, filter)
+	}
+	sort.Strings(filters)
+
+	topicHooks := make([]*mqtt.TopicHook, 0, len(filters))
+	for _, filter := range filters {
+		topicHooks = append(topicHooks, &mqtt.TopicHook{
+			Name:   "http-post " + httpPostHooks[filter],
+			Filter: filter,
+			Hook:   mqtt.NewHTTPPostTopicHook(httpPostHooks[filter], timeout),
+		})
+	}
+	return topicHooks
+}
+
+// reloadConfigFile loads the config file again while the application is running.
+// The command line flags are parsed into a new flag set, so they still override the values of the file.
+func reloadConfigFile(filePath string) (*configuration.Configuration, error) {
+	config := configuration.New()
+	if err := config.LoadFile(filePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("loading config file failed: %w", err)
+	}
+
+	fs := flagSet()
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return nil, fmt.Errorf("parsing flags failed: %w", err)
+	}
+
+	if err := config.LoadFlagSet(fs); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
 func loadConfigFile(filePath string) (*configuration.Configuration, error) {
 	config := configuration.New()
 	if err := config.LoadFile(filePath); err != nil && !errors.Is(err, os.ErrNotExist) {
@@ -9,28 +9,14 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/gohornet/inx-mqtt/mqtt"
 )
 
 var (
-	mqttBrokerAppInfo             *prometheus.GaugeVec
-	mqttBrokerStarted             prometheus.Gauge
-	mqttBrokerUptime              prometheus.Gauge
-	mqttBrokerBytesRecv           prometheus.Gauge
-	mqttBrokerBytesSent           prometheus.Gauge
-	mqttBrokerClientsConnected    prometheus.Gauge
-	mqttBrokerClientsDisconnected prometheus.Gauge
-	mqttBrokerClientsMax          prometheus.Gauge
-	mqttBrokerClientsTotal        prometheus.Gauge
-	mqttBrokerConnectionsTotal    prometheus.Gauge
-	mqttBrokerMessagesRecv        prometheus.Gauge
-	mqttBrokerMessagesSent        prometheus.Gauge
-	mqttBrokerPublishDropped      prometheus.Gauge
-	mqttBrokerPublishRecv         prometheus.Gauge
-	mqttBrokerPublishSent         prometheus.Gauge
-	mqttBrokerRetained            prometheus.Gauge
-	mqttBrokerInflight            prometheus.Gauge
-	mqttBrokerSubscriptions       prometheus.Gauge
-	mqttBrokerTopicsManagerSize   prometheus.Gauge
+	mqttBrokerAppInfo *prometheus.GaugeVec
+
+	mqttBrokerMilestoneSignatureVerificationFailures prometheus.Gauge
 )
 
 func registerNewMQTTBrokerGaugeVec(registry *prometheus.Registry, name string, labelNames []string, help string) *prometheus.GaugeVec {
@@ -61,24 +47,16 @@ func setupPrometheus(bindAddress string, server *Server, enableGoMetrics bool, e
 
 	registry := prometheus.NewRegistry()
 	mqttBrokerAppInfo = registerNewMQTTBrokerGaugeVec(registry, "app_info", []string{"name", "version", "broker_version"}, "The current version of the server.")
-	mqttBrokerStarted = registerNewMQTTBrokerGauge(registry, "started", "The time the server started in unix seconds.")
-	mqttBrokerUptime = registerNewMQTTBrokerGauge(registry, "uptime", "The number of seconds the server has been online.")
-	mqttBrokerBytesRecv = registerNewMQTTBrokerGauge(registry, "bytes_recv", "The total number of bytes received in all packets.")
-	mqttBrokerBytesSent = registerNewMQTTBrokerGauge(registry, "bytes_sent", "The total number of bytes sent to clients.")
-	mqttBrokerClientsConnected = registerNewMQTTBrokerGauge(registry, "clients_connected", "The number of currently connected clients.")
-	mqttBrokerClientsDisconnected = registerNewMQTTBrokerGauge(registry, "clients_disconnected", "The number of disconnected non-cleansession clients.")
-	mqttBrokerClientsMax = registerNewMQTTBrokerGauge(registry, "clients_max", "The maximum number of clients that have been concurrently connected.")
-	mqttBrokerClientsTotal = registerNewMQTTBrokerGauge(registry, "clients_total", "The sum of all clients, connected and disconnected.")
-	mqttBrokerConnectionsTotal = registerNewMQTTBrokerGauge(registry, "connections_total", "The sum number of clients which have ever connected.")
-	mqttBrokerMessagesRecv = registerNewMQTTBrokerGauge(registry, "messages_recv", "The total number of packets received.")
-	mqttBrokerMessagesSent = registerNewMQTTBrokerGauge(registry, "messages_sent", "The total number of packets sent.")
-	mqttBrokerPublishDropped = registerNewMQTTBrokerGauge(registry, "publish_dropped", "The number of in-flight publish messages which were dropped.")
-	mqttBrokerPublishRecv = registerNewMQTTBrokerGauge(registry, "publish_recv", "The total number of received publish packets.")
-	mqttBrokerPublishSent = registerNewMQTTBrokerGauge(registry, "publish_sent", "The total number of sent publish packets.")
-	mqttBrokerRetained = registerNewMQTTBrokerGauge(registry, "retained", "The number of messages currently retained.")
-	mqttBrokerInflight = registerNewMQTTBrokerGauge(registry, "inflight", "The number of messages currently in-flight.")
-	mqttBrokerSubscriptions = registerNewMQTTBrokerGauge(registry, "subscriptions", "The total number of filter subscriptions.")
-	mqttBrokerTopicsManagerSize = registerNewMQTTBrokerGauge(registry, "topics_manager_size", "The number of active topics in the topics manager.")
+	mqttBrokerMilestoneSignatureVerificationFailures = registerNewMQTTBrokerGauge(registry, "milestone_signature_verification_failures", "The total number of milestones that were dropped because of invalid signatures.")
+
+	mqttBrokerAppInfo.With(prometheus.Labels{
+		"name":           AppName,
+		"version":        Version,
+		"broker_version": mqtt.BrokerVersion,
+	}).Set(1)
+
+	// the broker metrics are registered as soon as the broker was created
+	server.metricsRegistry = registry
 
 	if enableGoMetrics {
 		registry.MustRegister(collectors.NewGoCollector())
@@ -93,11 +71,14 @@ func setupPrometheus(bindAddress string, server *Server, enableGoMetrics bool, e
 
 	e.GET("/metrics", func(c echo.Context) error {
 
-		server.collectMQTTBroker()
+		server.collectMetrics()
 
 		handler := promhttp.HandlerFor(
 			registry,
 			promhttp.HandlerOpts{
+				// the OpenMetrics format is only served if the scraper requests it via the Accept header
+				// ("application/openmetrics-text"), otherwise the Prometheus text format is used.
+				// Features that are exclusive to OpenMetrics (e.g. exemplars) are only exposed in that format.
 				EnableOpenMetrics: true,
 			},
 		)
@@ -114,28 +95,6 @@ func setupPrometheus(bindAddress string, server *Server, enableGoMetrics bool, e
 	}()
 }
 
-func (s *Server) collectMQTTBroker() {
-	mqttBrokerAppInfo.With(prometheus.Labels{
-		"name":           AppName,
-		"version":        Version,
-		"broker_version": s.MQTTBroker.SystemInfo().Version,
-	}).Set(1)
-	mqttBrokerStarted.Set(float64(s.MQTTBroker.SystemInfo().Started))
-	mqttBrokerUptime.Set(float64(s.MQTTBroker.SystemInfo().Uptime))
-	mqttBrokerBytesRecv.Set(float64(s.MQTTBroker.SystemInfo().BytesRecv))
-	mqttBrokerBytesSent.Set(float64(s.MQTTBroker.SystemInfo().BytesSent))
-	mqttBrokerClientsConnected.Set(float64(s.MQTTBroker.SystemInfo().ClientsConnected))
-	mqttBrokerClientsDisconnected.Set(float64(s.MQTTBroker.SystemInfo().ClientsDisconnected))
-	mqttBrokerClientsMax.Set(float64(s.MQTTBroker.SystemInfo().ClientsMax))
-	mqttBrokerClientsTotal.Set(float64(s.MQTTBroker.SystemInfo().ClientsTotal))
-	mqttBrokerConnectionsTotal.Set(float64(s.MQTTBroker.SystemInfo().ConnectionsTotal))
-	mqttBrokerMessagesRecv.Set(float64(s.MQTTBroker.SystemInfo().MessagesRecv))
-	mqttBrokerMessagesSent.Set(float64(s.MQTTBroker.SystemInfo().MessagesSent))
-	mqttBrokerPublishDropped.Set(float64(s.MQTTBroker.SystemInfo().PublishDropped))
-	mqttBrokerPublishRecv.Set(float64(s.MQTTBroker.SystemInfo().PublishRecv))
-	mqttBrokerPublishSent.Set(float64(s.MQTTBroker.SystemInfo().PublishSent))
-	mqttBrokerRetained.Set(float64(s.MQTTBroker.SystemInfo().Retained))
-	mqttBrokerInflight.Set(float64(s.MQTTBroker.SystemInfo().Inflight))
-	mqttBrokerSubscriptions.Set(float64(s.MQTTBroker.SystemInfo().Subscriptions))
-	mqttBrokerTopicsManagerSize.Set(float64(s.MQTTBroker.TopicsManagerSize()))
+func (s *Server) collectMetrics() {
+	mqttBrokerMilestoneSignatureVerificationFailures.Set(float64(s.MilestoneSignatureVerificationFailures()))
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync"
+)
+
+// monotonicMilestoneTimestamp is the last corrected timestamp of a milestone topic.
+type monotonicMilestoneTimestamp struct {
+	index     uint32
+	timestamp uint32
+}
+
+// monotonicMilestoneTimestamps corrects the milestone timestamps per topic to be monotonically increasing.
+// The timestamp of a milestone is set by the coordinator, so in edge cases (e.g. clock adjustments of the coordinator)
+// a milestone may have an older timestamp than the previous one. The corrected timestamp is clamped to at least
+// the corrected timestamp of the previous milestone, so it only differs from the raw timestamp in these cases.
+type monotonicMilestoneTimestamps struct {
+	lastTimestamps     map[string]*monotonicMilestoneTimestamp
+	lastTimestampsLock sync.Mutex
+}
+
+// Correct returns the monotonic timestamp of the milestone on the topic.
+// Milestones that are older than the last milestone of the topic (e.g. on republishing) are not corrected.
+func (m *monotonicMilestoneTimestamps) Correct(topic string, index uint32, timestamp uint32) uint32 {
+	m.lastTimestampsLock.Lock()
+	defer m.lastTimestampsLock.Unlock()
+
+	last, has := m.lastTimestamps[topic]
+	if !has {
+		m.lastTimestamps[topic] = &monotonicMilestoneTimestamp{index: index, timestamp: timestamp}
+		return timestamp
+	}
+
+	switch {
+	case index < last.index:
+		return timestamp
+	case index == last.index:
+		return last.timestamp
+	}
+
+	if timestamp < last.timestamp {
+		timestamp = last.timestamp
+	}
+	last.index = index
+	last.timestamp = timestamp
+
+	return timestamp
+}
+
+func newMonotonicMilestoneTimestamps() *monotonicMilestoneTimestamps {
+	return &monotonicMilestoneTimestamps{
+		lastTimestamps: make(map[string]*monotonicMilestoneTimestamp),
+	}
+}
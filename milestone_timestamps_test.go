@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMonotonicMilestoneTimestamps(t *testing.T) {
+	type milestone struct {
+		topic     string
+		index     uint32
+		timestamp uint32
+		expected  uint32
+	}
+
+	tests := []struct {
+		name       string
+		milestones []milestone
+	}{
+		{"increasing timestamps are not corrected", []milestone{
+			{topicMilestoneInfoLatest, 1, 100, 100},
+			{topicMilestoneInfoLatest, 2, 110, 110},
+			{topicMilestoneInfoLatest, 3, 120, 120},
+		}},
+		{"out-of-order timestamp is clamped to the previous timestamp", []milestone{
+			{topicMilestoneInfoLatest, 1, 100, 100},
+			{topicMilestoneInfoLatest, 2, 90, 100},
+			{topicMilestoneInfoLatest, 3, 95, 100},
+			{topicMilestoneInfoLatest, 4, 120, 120},
+		}},
+		{"equal timestamps are not corrected", []milestone{
+			{topicMilestoneInfoLatest, 1, 100, 100},
+			{topicMilestoneInfoLatest, 2, 100, 100},
+		}},
+		{"republished milestone keeps its corrected timestamp", []milestone{
+			{topicMilestoneInfoLatest, 1, 100, 100},
+			{topicMilestoneInfoLatest, 2, 90, 100},
+			{topicMilestoneInfoLatest, 2, 90, 100},
+		}},
+		{"older milestone is not corrected", []milestone{
+			{topicMilestoneInfoLatest, 5, 100, 100},
+			{topicMilestoneInfoLatest, 3, 80, 80},
+			{topicMilestoneInfoLatest, 6, 90, 100},
+		}},
+		{"topics are corrected independently", []milestone{
+			{topicMilestoneInfoLatest, 2, 100, 100},
+			{topicMilestoneInfoConfirmed, 1, 90, 90},
+			{topicMilestoneInfoLatest, 3, 95, 100},
+			{topicMilestoneInfoConfirmed, 2, 100, 100},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timestamps := newMonotonicMilestoneTimestamps()
+			for _, ms := range test.milestones {
+				if got := timestamps.Correct(ms.topic, ms.index, ms.timestamp); got != ms.expected {
+					t.Fatalf("Correct(%s, %d, %d) = %d, expected %d", ms.topic, ms.index, ms.timestamp, got, ms.expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/iotaledger/hive.go/serializer/v2"
+	inx "github.com/iotaledger/inx/go"
+	iotago "github.com/iotaledger/iota.go/v3"
+)
+
+// milestoneSignatureVerifier verifies the signatures of milestones against the milestone public keys of the node configuration.
+type milestoneSignatureVerifier struct {
+	protocolParameters *iotago.ProtocolParameters
+	// the minimum amount of valid signatures of a milestone.
+	signatureThreshold int
+	keyRanges          []*inx.MilestoneKeyRange
+
+	// verificationFailures is the amount of milestones that failed the signature verification.
+	verificationFailures uint64
+}
+
+// applicablePublicKeys returns the milestone public keys that are valid for the milestone index.
+// A key range with the same start and end index is valid for all milestones after the start index.
+func (v *milestoneSignatureVerifier) applicablePublicKeys(index uint32) iotago.MilestonePublicKeySet {
+	publicKeys := make(iotago.MilestonePublicKeySet)
+	for _, keyRange := range v.keyRanges {
+		if keyRange.GetStartIndex() > index {
+			continue
+		}
+		if keyRange.GetEndIndex() < index && keyRange.GetStartIndex() != keyRange.GetEndIndex() {
+			continue
+		}
+
+		var publicKey iotago.MilestonePublicKey
+		copy(publicKey[:], keyRange.GetPublicKey())
+		publicKeys[publicKey] = struct{}{}
+	}
+
+	return publicKeys
+}
+
+// Verify verifies the signatures of the milestone contained in the message.
+// The message is deserialized with validation, because the signature verification relies on it.
+func (v *milestoneSignatureVerifier) Verify(msg *inx.RawMessage) error {
+	err := v.verify(msg)
+	if err != nil {
+		atomic.AddUint64(&v.verificationFailures, 1)
+	}
+
+	return err
+}
+
+func (v *milestoneSignatureVerifier) verify(msg *inx.RawMessage) error {
+	message, err := msg.UnwrapMessage(serializer.DeSeriModePerformValidation, v.protocolParameters)
+	if err != nil {
+		return fmt.Errorf("deserializing milestone message failed: %w", err)
+	}
+
+	milestone, ok := message.Payload.(*iotago.Milestone)
+	if !ok {
+		return fmt.Errorf("message does not contain a milestone")
+	}
+
+	if err := milestone.VerifySignatures(v.signatureThreshold, v.applicablePublicKeys(milestone.Index)); err != nil {
+		return fmt.Errorf("verifying signatures of milestone %d failed: %w", milestone.Index, err)
+	}
+
+	return nil
+}
+
+// VerificationFailures returns the amount of milestones that failed the signature verification.
+func (v *milestoneSignatureVerifier) VerificationFailures() uint64 {
+	return atomic.LoadUint64(&v.verificationFailures)
+}
+
+func newMilestoneSignatureVerifier(nodeConfig *inx.NodeConfiguration) *milestoneSignatureVerifier {
+	return &milestoneSignatureVerifier{
+		protocolParameters: nodeConfig.UnwrapProtocolParameters(),
+		signatureThreshold: int(nodeConfig.GetMilestonePublicKeyCount()),
+		keyRanges:          nodeConfig.GetMilestoneKeyRanges(),
+	}
+}
@@ -0,0 +1,116 @@
+package mqtt
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// minAckTimeoutCheckInterval is the minimum interval in which the in-flight messages are checked for ACK timeouts.
+	// The underlying broker tracks the time a message was sent in seconds, so a shorter interval is pointless.
+	minAckTimeoutCheckInterval = 1 * time.Second
+)
+
+// ackTimeoutMonitor retransmits QoS messages that were not acknowledged by connected clients within the ACK timeout.
+// If a message is still not acknowledged after the maximum amount of retransmissions,
+// the client is treated as dead and disconnected, so zombie sessions do not consume resources indefinitely.
+type ackTimeoutMonitor struct {
+	checkInterval time.Duration
+
+	// the IDs of the connected clients.
+	clientIDs     map[string]struct{}
+	clientIDsLock sync.Mutex
+
+	// checkFunc retransmits the timed out messages of the client and returns true if the client was disconnected.
+	checkFunc func(clientID string) bool
+
+	// ackTimeoutDisconnects is the amount of clients that were disconnected because of ACK timeouts.
+	ackTimeoutDisconnects uint64
+
+	shutdownChan chan struct{}
+	shutdownOnce sync.Once
+	shutdownWG   sync.WaitGroup
+}
+
+// Add adds a connected client to the monitored clients.
+func (m *ackTimeoutMonitor) Add(clientID string) {
+	m.clientIDsLock.Lock()
+	defer m.clientIDsLock.Unlock()
+
+	m.clientIDs[clientID] = struct{}{}
+}
+
+// Remove removes a disconnected client from the monitored clients.
+func (m *ackTimeoutMonitor) Remove(clientID string) {
+	m.clientIDsLock.Lock()
+	defer m.clientIDsLock.Unlock()
+
+	delete(m.clientIDs, clientID)
+}
+
+// AckTimeoutDisconnects returns the amount of clients that were disconnected because of ACK timeouts.
+func (m *ackTimeoutMonitor) AckTimeoutDisconnects() uint64 {
+	return atomic.LoadUint64(&m.ackTimeoutDisconnects)
+}
+
+// Start starts the periodic check for ACK timeouts.
+func (m *ackTimeoutMonitor) Start() {
+	m.shutdownWG.Add(1)
+	go func() {
+		defer m.shutdownWG.Done()
+
+		ticker := time.NewTicker(m.checkInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.shutdownChan:
+				return
+			case <-ticker.C:
+				m.check()
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic check for ACK timeouts.
+func (m *ackTimeoutMonitor) Stop() {
+	m.shutdownOnce.Do(func() {
+		close(m.shutdownChan)
+	})
+	m.shutdownWG.Wait()
+}
+
+// check retransmits the timed out messages of all connected clients.
+func (m *ackTimeoutMonitor) check() {
+	m.clientIDsLock.Lock()
+	clientIDs := make([]string, 0, len(m.clientIDs))
+	for clientID := range m.clientIDs {
+		clientIDs = append(clientIDs, clientID)
+	}
+	m.clientIDsLock.Unlock()
+
+	for _, clientID := range clientIDs {
+		if !m.checkFunc(clientID) {
+			continue
+		}
+
+		atomic.AddUint64(&m.ackTimeoutDisconnects, 1)
+		m.Remove(clientID)
+	}
+}
+
+func newAckTimeoutMonitor(ackTimeout time.Duration, checkFunc func(clientID string) bool) *ackTimeoutMonitor {
+	checkInterval := ackTimeout / 2
+	if checkInterval < minAckTimeoutCheckInterval {
+		checkInterval = minAckTimeoutCheckInterval
+	}
+
+	return &ackTimeoutMonitor{
+		checkInterval: checkInterval,
+		clientIDs:     make(map[string]struct{}),
+		checkFunc:     checkFunc,
+		shutdownChan:  make(chan struct{}),
+	}
+}
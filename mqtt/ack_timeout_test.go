@@ -0,0 +1,65 @@
+package mqtt
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+func TestAckTimeoutDisconnectsClientWithoutPuback(t *testing.T) {
+	broker, address := newTestBroker(t,
+		WithAckTimeout(1*time.Second),
+		WithAckTimeoutMaxRetransmissions(1),
+	)
+
+	// the client never acknowledges the messages it receives
+	conn := connectRawTestClient(t, address, "zombie", 0)
+	subscribeRawTestClient(t, conn, "milestones", 1)
+
+	// a client that acknowledges its messages stays connected, paho only acknowledges messages that were handled
+	client := mustConnectTestClient(t, address, "healthy")
+	subscribeTestClient(t, client, "milestones", 1, func(paho.Client, paho.Message) {})
+
+	if err := broker.SendWithOptions("milestones", []byte("1"), 1, false); err != nil {
+		t.Fatalf("sending message failed: %s", err)
+	}
+
+	published, ok := readRawTestPacket(t, conn).(*packets.PublishPacket)
+	if !ok || published.Dup {
+		t.Fatal("expected the initial publish")
+	}
+
+	retransmitted, ok := readRawTestPacket(t, conn).(*packets.PublishPacket)
+	if !ok || !retransmitted.Dup || retransmitted.MessageID != published.MessageID {
+		t.Fatal("expected a retransmission of the unacknowledged message flagged as duplicate")
+	}
+
+	// after the maximum amount of retransmissions, the client is disconnected
+	_ = conn.SetReadDeadline(time.Now().Add(3 * testTimeout))
+	if _, err := packets.ReadPacket(conn); !errors.Is(err, io.EOF) && !isConnectionReset(err) {
+		t.Fatalf("expected the connection to be closed, got %v", err)
+	}
+
+	waitFor(t, testTimeout, func() bool { return broker.AckTimeoutDisconnects() > 0 }, "the disconnect was not counted")
+
+	// give the next check the chance to disconnect the other client
+	time.Sleep(2 * minAckTimeoutCheckInterval)
+
+	if disconnects := broker.AckTimeoutDisconnects(); disconnects != 1 {
+		t.Fatalf("expected 1 disconnect, got %d", disconnects)
+	}
+	if !client.IsConnectionOpen() {
+		t.Fatal("expected the client that acknowledges its messages to stay connected")
+	}
+}
+
+// isConnectionReset returns true if the error is caused by the remote side closing the connection.
+func isConnectionReset(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && !opErr.Timeout()
+}
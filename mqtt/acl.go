@@ -0,0 +1,174 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ACLRules define which topic filters are allowed or denied.
+type ACLRules struct {
+	// Allow is the list of MQTT topic filters (wildcards allowed) that are allowed.
+	Allow []string `json:"allow"`
+	// Deny is the list of MQTT topic filters (wildcards allowed) that are denied.
+	Deny []string `json:"deny"`
+}
+
+// UserACL defines the permissions of a user.
+type UserACL struct {
+	// Subscribe are the rules for the topic filters the user subscribes to.
+	Subscribe ACLRules `json:"subscribe"`
+	// Publish are the rules for the topics the user publishes on.
+	Publish ACLRules `json:"publish"`
+}
+
+// ACL defines per-user permissions to subscribe to and publish on topics.
+// Deny rules take precedence over allow rules. A subscription is denied if any topic it matches is denied,
+// and it is only allowed by an allow rule if the rule matches all topics of the subscription.
+// Subscriptions that match no rule are allowed, unless DefaultDeny is set.
+// Publishing is only allowed by explicit allow rules, because clients are not allowed to write by default.
+type ACL struct {
+	// DefaultDeny defines whether subscriptions that match no rule are denied.
+	DefaultDeny bool `json:"defaultDeny"`
+	// Users maps the usernames to their permissions.
+	Users map[string]*UserACL `json:"users"`
+}
+
+// LoadACLFile loads and validates an ACL from a JSON file.
+func LoadACLFile(filePath string) (*ACL, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ACL file (%s): %w", filePath, err)
+	}
+
+	acl := &ACL{}
+	if err := json.Unmarshal(data, acl); err != nil {
+		return nil, fmt.Errorf("unable to parse ACL file (%s): %w", filePath, err)
+	}
+
+	if err := acl.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ACL file (%s): %w", filePath, err)
+	}
+
+	return acl, nil
+}
+
+// Validate checks that all rules are valid MQTT topic filters.
+func (a *ACL) Validate() error {
+	for user, userACL := range a.Users {
+		if userACL == nil {
+			return fmt.Errorf("no rules given for user %s", user)
+		}
+
+		for _, rules := range []struct {
+			name  string
+			rules []string
+		}{
+			{"subscribe allow", userACL.Subscribe.Allow},
+			{"subscribe deny", userACL.Subscribe.Deny},
+			{"publish allow", userACL.Publish.Allow},
+			{"publish deny", userACL.Publish.Deny},
+		} {
+			for _, filter := range rules.rules {
+				if err := validateTopicFilter(filter); err != nil {
+					return fmt.Errorf("invalid %s rule \"%s\" for user %s: %w", rules.name, filter, user, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Allows returns true if the user has the permission to subscribe to the topic filter (write = false),
+// or to publish on the topic (write = true).
+func (a *ACL) Allows(user string, topic string, write bool) bool {
+	userACL, has := a.Users[user]
+	if !has {
+		return !write && !a.DefaultDeny
+	}
+
+	rules := userACL.Subscribe
+	if write {
+		rules = userACL.Publish
+	}
+
+	for _, filter := range rules.Deny {
+		if topicFiltersOverlap(filter, topic) {
+			return false
+		}
+	}
+
+	for _, filter := range rules.Allow {
+		if topicFilterCovers(filter, topic) {
+			return true
+		}
+	}
+
+	return !write && !a.DefaultDeny
+}
+
+// topicFilterCovers returns true if every topic that matches the filter also matches the covering filter.
+// For a concrete topic, this is the same as topicMatchesFilter.
+func topicFilterCovers(coveringFilter string, filter string) bool {
+	if strings.HasPrefix(filter, "$") && (strings.HasPrefix(coveringFilter, topicWildcardSingle) || strings.HasPrefix(coveringFilter, topicWildcardMultiple)) {
+		return false
+	}
+
+	coveringLevels := strings.Split(coveringFilter, topicLevelSeparator)
+	filterLevels := strings.Split(filter, topicLevelSeparator)
+
+	for i, coveringLevel := range coveringLevels {
+		if coveringLevel == topicWildcardMultiple {
+			return true
+		}
+
+		if i >= len(filterLevels) {
+			return false
+		}
+
+		switch coveringLevel {
+		case topicWildcardSingle:
+			// "+" covers every level except "#"
+			if filterLevels[i] == topicWildcardMultiple {
+				return false
+			}
+		default:
+			if coveringLevel != filterLevels[i] {
+				return false
+			}
+		}
+	}
+
+	return len(coveringLevels) == len(filterLevels)
+}
+
+// topicFiltersOverlap returns true if at least one topic matches both filters.
+func topicFiltersOverlap(filterA string, filterB string) bool {
+	// wildcards at the first level never match system topics
+	isWildcard := func(level string) bool {
+		return level == topicWildcardSingle || level == topicWildcardMultiple
+	}
+
+	levelsA := strings.Split(filterA, topicLevelSeparator)
+	levelsB := strings.Split(filterB, topicLevelSeparator)
+
+	if (isWildcard(levelsA[0]) && strings.HasPrefix(levelsB[0], "$")) || (isWildcard(levelsB[0]) && strings.HasPrefix(levelsA[0], "$")) {
+		return false
+	}
+
+	for i := 0; i < len(levelsA) || i < len(levelsB); i++ {
+		switch {
+		case i < len(levelsA) && levelsA[i] == topicWildcardMultiple, i < len(levelsB) && levelsB[i] == topicWildcardMultiple:
+			// "#" also matches the parent level
+			return true
+		case i >= len(levelsA), i >= len(levelsB):
+			return false
+		case levelsA[i] != topicWildcardSingle && levelsB[i] != topicWildcardSingle && levelsA[i] != levelsB[i]:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,171 @@
+package mqtt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestACL(defaultDeny bool) *ACL {
+	return &ACL{
+		DefaultDeny: defaultDeny,
+		Users: map[string]*UserACL{
+			"wallet": {
+				Subscribe: ACLRules{
+					Allow: []string{"outputs/#", "milestone-info/+"},
+					Deny:  []string{"outputs/unlock/+/+/spent"},
+				},
+			},
+			"publisher": {
+				Subscribe: ACLRules{
+					Deny: []string{"#"},
+				},
+				Publish: ACLRules{
+					Allow: []string{"events/+"},
+					Deny:  []string{"events/internal"},
+				},
+			},
+		},
+	}
+}
+
+func TestACLAllows(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultDeny bool
+		user        string
+		topic       string
+		write       bool
+		expected    bool
+	}{
+		{"allowed topic", false, "wallet", "outputs/0x01", false, true},
+		{"allowed single-level wildcard topic", false, "wallet", "milestone-info/latest", false, true},
+		{"subscription covered by an allow rule", false, "wallet", "outputs/nfts/+", false, true},
+		{"subscription matching a denied topic", false, "wallet", "outputs/#", false, false},
+		{"subscription overlapping a denied topic", false, "wallet", "outputs/unlock/address/+/#", false, false},
+		{"denied topic", false, "wallet", "outputs/unlock/address/iota1qp/spent", false, false},
+		{"topic without rule", false, "wallet", "messages", false, true},
+		{"topic without rule with default deny", true, "wallet", "messages", false, false},
+		{"subscription only partly covered with default deny", true, "wallet", "milestone-info/#", false, false},
+		{"allowed topic with default deny", true, "wallet", "milestone-info/confirmed", false, true},
+		{"unknown user", false, "guest", "messages", false, true},
+		{"unknown user with default deny", true, "guest", "messages", false, false},
+		{"deny all subscriptions", false, "publisher", "messages", false, false},
+		{"deny all doesn't match system topics", false, "publisher", "$SYS/node/syncstatus", false, true},
+		{"allowed publish", false, "publisher", "events/new", true, true},
+		{"denied publish", false, "publisher", "events/internal", true, false},
+		{"publish without rule", false, "publisher", "messages", true, false},
+		{"publish of a user without publish rules", false, "wallet", "outputs/0x01", true, false},
+		{"publish of an unknown user", false, "guest", "messages", true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			acl := newTestACL(test.defaultDeny)
+			if err := acl.Validate(); err != nil {
+				t.Fatalf("invalid ACL: %s", err)
+			}
+
+			if got := acl.Allows(test.user, test.topic, test.write); got != test.expected {
+				t.Fatalf("Allows(%s, %s, %v) = %v, expected %v", test.user, test.topic, test.write, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestTopicFilterCovers(t *testing.T) {
+	tests := []struct {
+		coveringFilter string
+		filter         string
+		expected       bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/b", "a/b/c", false},
+		{"a/+", "a/b", true},
+		{"a/+", "a/+", true},
+		{"a/+", "a/#", false},
+		{"a/+", "a", false},
+		{"a/+", "a/b/c", false},
+		{"a/b", "a/+", false},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"a/#", "a/+/c", true},
+		{"a/#", "a/#", true},
+		{"a/#", "b/c", false},
+		{"+/b", "a/b", true},
+		{"#", "a/b", true},
+		{"#", "$SYS/a", false},
+		{"+/a", "$SYS/a", false},
+		{"$SYS/#", "$SYS/a", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.coveringFilter+" covers "+test.filter, func(t *testing.T) {
+			if got := topicFilterCovers(test.coveringFilter, test.filter); got != test.expected {
+				t.Fatalf("topicFilterCovers(%s, %s) = %v, expected %v", test.coveringFilter, test.filter, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestTopicFiltersOverlap(t *testing.T) {
+	tests := []struct {
+		filterA  string
+		filterB  string
+		expected bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/+", "a/b", true},
+		{"a/+", "+/b", true},
+		{"a/+", "b/+", false},
+		{"a/+", "a/b/c", false},
+		{"a/+", "a", false},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"a/#", "+/b", true},
+		{"a/b/#", "a/c/#", false},
+		{"#", "a/b", true},
+		{"#", "$SYS/a", false},
+		{"+/a", "$SYS/a", false},
+		{"$SYS/#", "$SYS/+", true},
+		{"$SYS/a", "+/a", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.filterA+" overlaps "+test.filterB, func(t *testing.T) {
+			if got := topicFiltersOverlap(test.filterA, test.filterB); got != test.expected {
+				t.Fatalf("topicFiltersOverlap(%s, %s) = %v, expected %v", test.filterA, test.filterB, got, test.expected)
+			}
+			// the overlap is symmetric
+			if got := topicFiltersOverlap(test.filterB, test.filterA); got != test.expected {
+				t.Fatalf("topicFiltersOverlap(%s, %s) = %v, expected %v", test.filterB, test.filterA, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestLoadACLFile(t *testing.T) {
+	dir := t.TempDir()
+
+	validPath := filepath.Join(dir, "acl.json")
+	if err := os.WriteFile(validPath, []byte(`{"defaultDeny": true, "users": {"wallet": {"subscribe": {"allow": ["outputs/#"]}}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	acl, err := LoadACLFile(validPath)
+	if err != nil {
+		t.Fatalf("loading ACL failed: %s", err)
+	}
+	if !acl.Allows("wallet", "outputs/0x01", false) || acl.Allows("wallet", "messages", false) {
+		t.Fatal("unexpected permissions of the loaded ACL")
+	}
+
+	invalidPath := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalidPath, []byte(`{"users": {"wallet": {"subscribe": {"deny": ["outputs/#/spent"]}}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadACLFile(invalidPath); err == nil {
+		t.Fatal("expected an error for an invalid topic filter")
+	}
+}
@@ -1,11 +1,18 @@
 package mqtt
 
 import (
+	"bytes"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/mochi-co/mqtt/server/listeners/auth"
 
 	"github.com/iotaledger/hive.go/basicauth"
+	"github.com/iotaledger/hive.go/logger"
 )
 
 // AuthAllowEveryone allows everyone, but without write permission.
@@ -23,50 +30,104 @@ func (a *AuthAllowEveryone) ACL(user []byte, topic string, write bool) bool {
 }
 
 // AuthAllowBasicAuth allows users that authenticate with basic auth, but without write permission.
+// If an ACL is set, the permissions of the users are checked against the ACL instead.
+// The users can be replaced at runtime, clients that are connected already stay connected.
 type AuthAllowBasicAuth struct {
-	Salt  []byte
-	Users map[string][]byte
+	// Salt and Users must only be changed with ReplaceUsers once the controller is in use.
+	Salt      []byte
+	Users     map[string][]byte
+	usersLock sync.RWMutex
+	// Permissions defines the per-user permissions (optional).
+	Permissions *ACL
 }
 
 func NewAuthAllowUsers(passwordSaltHex string, users map[string]string) (*AuthAllowBasicAuth, error) {
+	passwordSalt, usersWithHashedPasswords, err := parseAuthUsers(passwordSaltHex, users)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthAllowBasicAuth{
+		Users: usersWithHashedPasswords,
+		Salt:  passwordSalt,
+	}, nil
+}
+
+// parseAuthUsers decodes the hex encoded password salt and the hex encoded scrypt hashes of the passwords of the users.
+func parseAuthUsers(passwordSaltHex string, users map[string]string) ([]byte, map[string][]byte, error) {
 
 	if len(passwordSaltHex) != 64 {
-		return nil, errors.New("password salt must be 64 (hex encoded) in length")
+		return nil, nil, errors.New("password salt must be 64 (hex encoded) in length")
 	}
 
 	passwordSalt, err := hex.DecodeString(passwordSaltHex)
 	if err != nil {
-		return nil, fmt.Errorf("parsing password salt failed: %w", err)
+		return nil, nil, fmt.Errorf("parsing password salt failed: %w", err)
 	}
 
 	usersWithHashedPasswords := make(map[string][]byte)
 	for user, passwordHashHex := range users {
 		if len(passwordHashHex) != 64 {
-			return nil, fmt.Errorf("password hash for user %s must be 64 (hex encoded scrypt hash) in length", user)
+			return nil, nil, fmt.Errorf("password hash for user %s must be 64 (hex encoded scrypt hash) in length", user)
 		}
 
 		password, err := hex.DecodeString(passwordHashHex)
 		if err != nil {
-			return nil, fmt.Errorf("parsing password hash for user %s failed: %w", user, err)
+			return nil, nil, fmt.Errorf("parsing password hash for user %s failed: %w", user, err)
 		}
 
 		usersWithHashedPasswords[user] = password
 	}
 
-	return &AuthAllowBasicAuth{
-		Users: usersWithHashedPasswords,
-		Salt:  passwordSalt,
-	}, nil
+	return passwordSalt, usersWithHashedPasswords, nil
+}
+
+// ReplaceUsers atomically replaces the password salt and the users that are allowed to connect.
+// The previous users stay in place if the new ones are invalid, or if the ACL contains a user that is not part of them.
+func (a *AuthAllowBasicAuth) ReplaceUsers(passwordSaltHex string, users map[string]string) error {
+	passwordSalt, usersWithHashedPasswords, err := parseAuthUsers(passwordSaltHex, users)
+	if err != nil {
+		return err
+	}
+
+	if a.Permissions != nil {
+		for user := range a.Permissions.Users {
+			if _, has := usersWithHashedPasswords[user]; !has {
+				return fmt.Errorf("unknown user %s in ACL", user)
+			}
+		}
+	}
+
+	a.usersLock.Lock()
+	defer a.usersLock.Unlock()
+
+	a.Salt = passwordSalt
+	a.Users = usersWithHashedPasswords
+
+	return nil
+}
+
+// UsersCount returns the amount of users that are allowed to connect.
+func (a *AuthAllowBasicAuth) UsersCount() int {
+	a.usersLock.RLock()
+	defer a.usersLock.RUnlock()
+
+	return len(a.Users)
 }
 
 // Authenticate returns true if a username and password are acceptable.
 func (a *AuthAllowBasicAuth) Authenticate(user, password []byte) bool {
+	a.usersLock.RLock()
+	hashedPassword, exists := a.Users[string(user)]
+	salt := a.Salt
+	a.usersLock.RUnlock()
+
 	// If the user exists in the auth users map, and the password is correct,
 	// then they can connect to the server.
-	if hashedPassword, exists := a.Users[string(user)]; exists {
+	if exists {
 
 		// error is ignored because it returns false in case it can't be derived
-		if valid, _ := basicauth.VerifyPassword(password, a.Salt, hashedPassword); valid {
+		if valid, _ := basicauth.VerifyPassword(password, salt, hashedPassword); valid {
 			return true
 		}
 	}
@@ -76,6 +137,143 @@ func (a *AuthAllowBasicAuth) Authenticate(user, password []byte) bool {
 
 // ACL returns true if a user has access permissions to read or write on a topic.
 func (a *AuthAllowBasicAuth) ACL(user []byte, topic string, write bool) bool {
+	if a.Permissions != nil {
+		return a.Permissions.Allows(string(user), topic, write)
+	}
+
+	// clients are not allowed to write
+	return !write
+}
+
+const (
+	// JWTUsername is the username of clients that pass a JWT as password.
+	JWTUsername = "jwt"
+)
+
+// AuthJWT allows clients that authenticate with a valid JWT as password and JWTUsername as username, but without write permission.
+// The signature, expiry, issuer and audience of the token are verified on connect, the token must contain an expiry.
+// Clients stay connected after their token expired.
+type AuthJWT struct {
+	key      interface{}
+	methods  []string
+	issuer   string
+	audience string
+}
+
+// NewAuthJWT creates an auth controller for JWTs.
+// The key is either a PEM encoded RSA, ECDSA or Ed25519 public key, in which case only the matching signing methods are accepted,
+// or a secret for HMAC signed tokens.
+func NewAuthJWT(publicKeyOrSecret []byte, issuer string, audience string) (*AuthJWT, error) {
+	if len(publicKeyOrSecret) == 0 {
+		return nil, errors.New("no JWT public key or secret given")
+	}
+	if issuer == "" || audience == "" {
+		return nil, errors.New("JWT issuer and audience must be given")
+	}
+
+	a := &AuthJWT{
+		issuer:   issuer,
+		audience: audience,
+	}
+
+	if !bytes.Contains(publicKeyOrSecret, []byte("-----BEGIN")) {
+		a.key = publicKeyOrSecret
+		a.methods = []string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodHS384.Alg(), jwt.SigningMethodHS512.Alg()}
+		return a, nil
+	}
+
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(publicKeyOrSecret); err == nil {
+		a.key = key
+		a.methods = []string{
+			jwt.SigningMethodRS256.Alg(), jwt.SigningMethodRS384.Alg(), jwt.SigningMethodRS512.Alg(),
+			jwt.SigningMethodPS256.Alg(), jwt.SigningMethodPS384.Alg(), jwt.SigningMethodPS512.Alg(),
+		}
+		return a, nil
+	}
+
+	if key, err := jwt.ParseECPublicKeyFromPEM(publicKeyOrSecret); err == nil {
+		a.key = key
+		a.methods = []string{jwt.SigningMethodES256.Alg(), jwt.SigningMethodES384.Alg(), jwt.SigningMethodES512.Alg()}
+		return a, nil
+	}
+
+	if key, err := jwt.ParseEdPublicKeyFromPEM(publicKeyOrSecret); err == nil {
+		a.key = key
+		a.methods = []string{jwt.SigningMethodEdDSA.Alg()}
+		return a, nil
+	}
+
+	return nil, errors.New("parsing JWT public key failed: no RSA, ECDSA or Ed25519 public key (PEM)")
+}
+
+// Authenticate returns true if a username and password are acceptable.
+func (a *AuthJWT) Authenticate(user, password []byte) bool {
+	if string(user) != JWTUsername {
+		return false
+	}
+
+	parser := &jwt.Parser{ValidMethods: a.methods}
+	claims := jwt.MapClaims{}
+	if _, err := parser.ParseWithClaims(string(password), claims, func(_ *jwt.Token) (interface{}, error) {
+		return a.key, nil
+	}); err != nil {
+		return false
+	}
+
+	// the claims validation of the parser doesn't require an expiry
+	return claims.VerifyExpiresAt(time.Now().Unix(), true) &&
+		claims.VerifyIssuer(a.issuer, true) &&
+		claims.VerifyAudience(a.audience, true)
+}
+
+// ACL returns true if a user has access permissions to read or write on a topic.
+func (a *AuthJWT) ACL(user []byte, topic string, write bool) bool {
 	// clients are not allowed to write
 	return !write
 }
+
+// AuthTopicManagerLimit rejects subscriptions to new topics if the topic manager reached its maximum size.
+// The underlying broker answers rejected subscriptions with a SUBACK failure. All other checks are passed to the wrapped controller.
+type AuthTopicManagerLimit struct {
+	auth.Controller
+	topicManager *topicManager
+	// topicPrefix is the prefix that is stripped from the topics before they are checked against the topic manager.
+	topicPrefix string
+}
+
+// ACL returns true if a user has access permissions to read or write on a topic.
+func (a *AuthTopicManagerLimit) ACL(user []byte, topic string, write bool) bool {
+	if !write {
+		// subscriptions outside of the namespace of the prefix are not tracked by the topic manager
+		if managedTopic, ok := unprefixTopic(a.topicPrefix, topic); ok && !a.topicManager.AllowsSubscription(managedTopic) {
+			return false
+		}
+	}
+
+	return a.Controller.ACL(user, topic, write)
+}
+
+// AuthSubscriptionValidator rejects subscriptions to topic filters the validator returns an error for.
+// The underlying broker answers rejected subscriptions with a SUBACK failure. All other checks are passed to the wrapped controller.
+type AuthSubscriptionValidator struct {
+	auth.Controller
+	log      *logger.Logger
+	validate func(topic string) error
+	// topicPrefix is the prefix that is stripped from the topics before they are validated.
+	topicPrefix string
+}
+
+// ACL returns true if a user has access permissions to read or write on a topic.
+func (a *AuthSubscriptionValidator) ACL(user []byte, topic string, write bool) bool {
+	if !write {
+		// subscriptions outside of the namespace of the prefix never reach the subscribe handler
+		if managedTopic, ok := unprefixTopic(a.topicPrefix, topic); ok {
+			if err := a.validate(managedTopic); err != nil {
+				a.log.Debugf("rejected subscription to %s: %s", topic, err)
+				return false
+			}
+		}
+	}
+
+	return a.Controller.ACL(user, topic, write)
+}
@@ -0,0 +1,284 @@
+package mqtt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/golang-jwt/jwt"
+
+	"github.com/iotaledger/hive.go/basicauth"
+)
+
+const (
+	testJWTIssuer   = "test-issuer"
+	testJWTAudience = "test-audience"
+)
+
+// testJWTKey is a key to sign tokens with and the key material AuthJWT verifies them with.
+type testJWTKey struct {
+	name         string
+	method       jwt.SigningMethod
+	signingKey   interface{}
+	verifyingKey []byte
+	// otherSigningKey is a key of the same type that AuthJWT doesn't know.
+	otherSigningKey interface{}
+}
+
+func newTestJWTKeys(t *testing.T) []*testJWTKey {
+	t.Helper()
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating EC key failed: %s", err)
+	}
+	ecPublicKey, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	if err != nil {
+		t.Fatalf("encoding EC public key failed: %s", err)
+	}
+	ecPublicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: ecPublicKey})
+
+	otherECKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating EC key failed: %s", err)
+	}
+
+	secret := []byte("a secret that is shared with the token issuer")
+
+	return []*testJWTKey{
+		{
+			name:            "HMAC",
+			method:          jwt.SigningMethodHS256,
+			signingKey:      secret,
+			verifyingKey:    secret,
+			otherSigningKey: []byte("another secret"),
+		},
+		{
+			name:            "EC",
+			method:          jwt.SigningMethodES256,
+			signingKey:      ecKey,
+			verifyingKey:    ecPublicKeyPEM,
+			otherSigningKey: otherECKey,
+		},
+	}
+}
+
+func signTestJWT(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("signing token failed: %s", err)
+	}
+
+	return token
+}
+
+// tamperJWT replaces the claims of the token without updating the signature.
+func tamperJWT(t *testing.T, token string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	tampered := strings.Split(signTestJWT(t, jwt.SigningMethodHS256, []byte("unused"), claims), ".")
+
+	return parts[0] + "." + tampered[1] + "." + parts[2]
+}
+
+func TestAuthJWT(t *testing.T) {
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss": testJWTIssuer,
+			"aud": testJWTAudience,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	for _, key := range newTestJWTKeys(t) {
+		key := key
+
+		authJWT, err := NewAuthJWT(key.verifyingKey, testJWTIssuer, testJWTAudience)
+		if err != nil {
+			t.Fatalf("creating %s auth failed: %s", key.name, err)
+		}
+
+		tests := []struct {
+			name     string
+			username string
+			token    func() string
+			expected bool
+		}{
+			{"valid token", JWTUsername, func() string {
+				return signTestJWT(t, key.method, key.signingKey, validClaims())
+			}, true},
+			{"expired token", JWTUsername, func() string {
+				claims := validClaims()
+				claims["exp"] = time.Now().Add(-time.Minute).Unix()
+				return signTestJWT(t, key.method, key.signingKey, claims)
+			}, false},
+			{"token without expiry", JWTUsername, func() string {
+				claims := validClaims()
+				delete(claims, "exp")
+				return signTestJWT(t, key.method, key.signingKey, claims)
+			}, false},
+			{"tampered claims", JWTUsername, func() string {
+				token := signTestJWT(t, key.method, key.signingKey, validClaims())
+				claims := validClaims()
+				claims["exp"] = time.Now().Add(24 * time.Hour).Unix()
+				return tamperJWT(t, token, claims)
+			}, false},
+			{"signed with another key", JWTUsername, func() string {
+				return signTestJWT(t, key.method, key.otherSigningKey, validClaims())
+			}, false},
+			{"wrong issuer", JWTUsername, func() string {
+				claims := validClaims()
+				claims["iss"] = "another-issuer"
+				return signTestJWT(t, key.method, key.signingKey, claims)
+			}, false},
+			{"wrong audience", JWTUsername, func() string {
+				claims := validClaims()
+				claims["aud"] = "another-audience"
+				return signTestJWT(t, key.method, key.signingKey, claims)
+			}, false},
+			{"unsigned token", JWTUsername, func() string {
+				return signTestJWT(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, validClaims())
+			}, false},
+			{"wrong username", "admin", func() string {
+				return signTestJWT(t, key.method, key.signingKey, validClaims())
+			}, false},
+		}
+
+		for _, test := range tests {
+			test := test
+			t.Run(key.name+"/"+test.name, func(t *testing.T) {
+				if got := authJWT.Authenticate([]byte(test.username), []byte(test.token())); got != test.expected {
+					t.Fatalf("Authenticate = %v, expected %v", got, test.expected)
+				}
+			})
+		}
+	}
+}
+
+func TestAuthJWTRejectsHMACTokenSignedWithThePublicKey(t *testing.T) {
+	keys := newTestJWTKeys(t)
+	ecKey := keys[1]
+
+	authJWT, err := NewAuthJWT(ecKey.verifyingKey, testJWTIssuer, testJWTAudience)
+	if err != nil {
+		t.Fatalf("creating auth failed: %s", err)
+	}
+
+	// the public key is known to everyone, so it must never be accepted as HMAC secret
+	token := signTestJWT(t, jwt.SigningMethodHS256, ecKey.verifyingKey, jwt.MapClaims{
+		"iss": testJWTIssuer,
+		"aud": testJWTAudience,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	if authJWT.Authenticate([]byte(JWTUsername), []byte(token)) {
+		t.Fatal("expected the HMAC token to be rejected")
+	}
+}
+
+func TestAuthJWTACL(t *testing.T) {
+	authJWT, err := NewAuthJWT([]byte("secret"), testJWTIssuer, testJWTAudience)
+	if err != nil {
+		t.Fatalf("creating auth failed: %s", err)
+	}
+
+	if !authJWT.ACL([]byte(JWTUsername), "milestones", false) {
+		t.Fatal("expected read access")
+	}
+	if authJWT.ACL([]byte(JWTUsername), "milestones", true) {
+		t.Fatal("expected no write access")
+	}
+}
+
+// testPasswordHash returns the hex encoded scrypt hash of the password with the hex encoded salt.
+func testPasswordHash(t *testing.T, password string, passwordSaltHex string) string {
+	t.Helper()
+
+	salt, err := hex.DecodeString(passwordSaltHex)
+	if err != nil {
+		t.Fatalf("decoding password salt failed: %s", err)
+	}
+	hash, err := basicauth.DerivePasswordKey([]byte(password), salt)
+	if err != nil {
+		t.Fatalf("deriving password hash failed: %s", err)
+	}
+
+	return hex.EncodeToString(hash)
+}
+
+func TestReloadUsersKeepsConnectedClients(t *testing.T) {
+	const (
+		passwordSalt    = "0101010101010101010101010101010101010101010101010101010101010101"
+		newPasswordSalt = "0202020202020202020202020202020202020202020202020202020202020202"
+	)
+
+	broker, address := newTestBroker(t,
+		WithTCPAuthEnabled(true),
+		WithTCPAuthPasswordSalt(passwordSalt),
+		WithTCPAuthUsers(map[string]string{"alice": testPasswordHash(t, "alice-password", passwordSalt)}),
+	)
+
+	connectUser := func(clientID string, username string, password string) (paho.Client, error) {
+		return connectTestClient(t, newTestClientOptions("tcp://"+address, clientID).SetUsername(username).SetPassword(password))
+	}
+
+	alice, err := connectUser("alice-before", "alice", "alice-password")
+	if err != nil {
+		t.Fatalf("connecting alice failed: %s", err)
+	}
+	received := make(chan string, 10)
+	subscribeTestClient(t, alice, "milestones", 0, func(_ paho.Client, message paho.Message) {
+		received <- string(message.Payload())
+	})
+
+	// alice is replaced by bob, and the salt changes with the users
+	if err := broker.ReloadUsers(map[string]string{"bob": testPasswordHash(t, "bob-password", newPasswordSalt)}, newPasswordSalt); err != nil {
+		t.Fatalf("reloading users failed: %s", err)
+	}
+
+	// the client that authenticated before the reload stays connected and keeps receiving messages
+	if err := broker.SendWithOptions("milestones", []byte("after reload"), 0, false); err != nil {
+		t.Fatalf("sending message failed: %s", err)
+	}
+	select {
+	case payload := <-received:
+		if payload != "after reload" {
+			t.Fatalf("unexpected payload %s", payload)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("the connected client did not receive the message after the reload")
+	}
+	if !alice.IsConnectionOpen() {
+		t.Fatal("expected the connected client to stay connected")
+	}
+
+	// new connections are authenticated with the new users
+	if _, err := connectUser("alice-after", "alice", "alice-password"); err == nil {
+		t.Fatal("expected the removed user to be rejected")
+	}
+	if _, err := connectUser("bob", "bob", "bob-password"); err != nil {
+		t.Fatalf("connecting the new user failed: %s", err)
+	}
+
+	// invalid users are rejected and the previous users are kept
+	if err := broker.ReloadUsers(map[string]string{"carol": "invalid"}, newPasswordSalt); err == nil {
+		t.Fatal("expected an error for an invalid password hash")
+	}
+	if _, err := connectUser("bob-after-invalid-reload", "bob", "bob-password"); err != nil {
+		t.Fatalf("connecting the kept user failed: %s", err)
+	}
+	for _, listener := range broker.Listeners() {
+		if listener.Type == ListenerTypeTCP && listener.AuthUsers != 1 {
+			t.Fatalf("expected 1 user of the TCP listener, got %d", listener.AuthUsers)
+		}
+	}
+}
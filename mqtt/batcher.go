@@ -0,0 +1,126 @@
+package mqtt
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// clientBatch is the pending batch of messages of a client.
+type clientBatch struct {
+	payloads [][]byte
+	// the timer that flushes the batch after the batch window.
+	timer *time.Timer
+}
+
+// messageBatcher coalesces the messages destined for a client within a time window into a single message.
+// The message payloads must be JSON, they are delivered as a JSON array.
+type messageBatcher struct {
+	window  time.Duration
+	maxSize int
+
+	batches     map[string]*clientBatch
+	batchesLock sync.Mutex
+
+	// deliverFunc delivers the batched payload to the client.
+	deliverFunc func(clientID string, payload []byte)
+}
+
+// Add adds a message payload to the batch of the client.
+// The batch is delivered after the batch window, or immediately if the maximum batch size is reached.
+func (m *messageBatcher) Add(clientID string, payload []byte) {
+	m.batchesLock.Lock()
+
+	batch, has := m.batches[clientID]
+	if !has {
+		batch = &clientBatch{
+			timer: time.AfterFunc(m.window, func() { m.flush(clientID) }),
+		}
+		m.batches[clientID] = batch
+	}
+	batch.payloads = append(batch.payloads, payload)
+
+	if len(batch.payloads) < m.maxSize {
+		m.batchesLock.Unlock()
+		return
+	}
+
+	batch.timer.Stop()
+	delete(m.batches, clientID)
+	m.batchesLock.Unlock()
+
+	m.deliverFunc(clientID, encodeBatch(batch.payloads))
+}
+
+// flush delivers the pending batch of the client.
+func (m *messageBatcher) flush(clientID string) {
+	m.batchesLock.Lock()
+	batch, has := m.batches[clientID]
+	if !has {
+		m.batchesLock.Unlock()
+		return
+	}
+	delete(m.batches, clientID)
+	m.batchesLock.Unlock()
+
+	m.deliverFunc(clientID, encodeBatch(batch.payloads))
+}
+
+// FlushAll delivers the pending batches of all clients immediately.
+func (m *messageBatcher) FlushAll() {
+	m.batchesLock.Lock()
+	batches := m.batches
+	m.batches = make(map[string]*clientBatch)
+	m.batchesLock.Unlock()
+
+	for clientID, batch := range batches {
+		batch.timer.Stop()
+		m.deliverFunc(clientID, encodeBatch(batch.payloads))
+	}
+}
+
+// Remove drops the pending batch of the client.
+func (m *messageBatcher) Remove(clientID string) {
+	m.batchesLock.Lock()
+	defer m.batchesLock.Unlock()
+
+	if batch, has := m.batches[clientID]; has {
+		batch.timer.Stop()
+		delete(m.batches, clientID)
+	}
+}
+
+// Stop drops all pending batches.
+func (m *messageBatcher) Stop() {
+	m.batchesLock.Lock()
+	defer m.batchesLock.Unlock()
+
+	for clientID, batch := range m.batches {
+		batch.timer.Stop()
+		delete(m.batches, clientID)
+	}
+}
+
+// encodeBatch encodes the JSON payloads as a JSON array.
+func encodeBatch(payloads [][]byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, payload := range payloads {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(payload)
+	}
+	buf.WriteByte(']')
+
+	return buf.Bytes()
+}
+
+func newMessageBatcher(window time.Duration, maxSize int, deliverFunc func(clientID string, payload []byte)) *messageBatcher {
+	return &messageBatcher{
+		window:      window,
+		maxSize:     maxSize,
+		batches:     make(map[string]*clientBatch),
+		deliverFunc: deliverFunc,
+	}
+}
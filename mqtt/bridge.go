@@ -0,0 +1,340 @@
+package mqtt
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/iotaledger/hive.go/logger"
+)
+
+const (
+	// bridgeQueueSize is the maximum amount of queued messages that were not forwarded to the upstream broker yet.
+	bridgeQueueSize = 1000
+	// bridgeConnectRetryInterval is the interval in which the initial connection to the upstream broker is retried.
+	bridgeConnectRetryInterval = 5 * time.Second
+	// bridgeMaxReconnectInterval is the maximum backoff between reconnection attempts after the connection was lost.
+	bridgeMaxReconnectInterval = 1 * time.Minute
+	// bridgeDisconnectQuiesce is the time in milliseconds the bridge waits for pending work when disconnecting.
+	bridgeDisconnectQuiesce = 250
+	// bridgeFlushBatchSize is the maximum amount of messages of the offline queue that are sent before waiting for their acknowledgements.
+	bridgeFlushBatchSize = 100
+	// bridgeFlushTimeout is the maximum time the bridge waits for the acknowledgement of a message of the offline queue.
+	bridgeFlushTimeout = 10 * time.Second
+	// bridgeFlushRetryInterval is the interval in which a failed flush of the offline queue is retried while the bridge is connected.
+	bridgeFlushRetryInterval = 5 * time.Second
+)
+
+// bridgeMessage is a queued message that is forwarded to the upstream broker.
+type bridgeMessage struct {
+	topic   string
+	payload []byte
+	qos     byte
+	retain  bool
+}
+
+// bridge forwards the messages published on topics that match its filters to an upstream MQTT broker.
+// The messages are forwarded asynchronously, so the bridge never blocks the local delivery.
+// If the queue is full, messages are dropped.
+//
+// Without an offline queue, QoS 0 messages are dropped while the bridge is reconnecting,
+// QoS > 0 messages are kept in memory and sent after the connection was reestablished.
+//
+// With an offline queue, all messages are written to disk while the upstream broker is unreachable,
+// and the queue is flushed once the connection was reestablished, also after a restart of the node.
+// The messages are flushed in the order they were queued, and new messages are queued behind them until the queue is empty,
+// so the upstream broker receives the messages in the order they were published locally.
+// A queued message is only removed after the upstream broker acknowledged it (QoS > 0) or it was written to the connection (QoS 0),
+// so the flush guarantees at-least-once delivery for QoS > 0 messages: if the connection is lost or the node stops
+// before the acknowledgement, the message is sent again on the next flush, and the upstream broker may receive it twice.
+// The next flush starts again at the first message that was not acknowledged, so duplicates are possible,
+// but a message is never delivered for the first time after a message that was queued later.
+// If the queue reaches its maximum size, the overflow policy decides whether the oldest or the newest messages are dropped.
+type bridge struct {
+	log     *logger.Logger
+	client  paho.Client
+	filters []string
+	// topicPrefix is prepended to the forwarded topics, so the topics of several nodes don't collide on the upstream broker.
+	topicPrefix string
+	queue       chan *bridgeMessage
+	// offlineQueue keeps the messages on disk while the upstream broker is unreachable (optional).
+	offlineQueue *bridgeOfflineQueue
+	// flushChan signals that the connection was established and the offline queue can be flushed.
+	flushChan chan struct{}
+
+	// droppedMessages is the amount of messages that were dropped because the queue was full.
+	droppedMessages uint64
+	// failedMessages is the amount of messages that could not be handed over to the upstream connection.
+	failedMessages uint64
+
+	shutdownChan chan struct{}
+	shutdownOnce sync.Once
+	shutdownWG   sync.WaitGroup
+}
+
+// Forward queues the message if the topic matches one of the filters of the bridge.
+// The filters match the topics without the prefix, the message is forwarded on the prefixed topic.
+func (br *bridge) Forward(topic string, payload []byte, qos byte, retain bool) {
+	if !br.Matches(topic) {
+		return
+	}
+
+	select {
+	case br.queue <- &bridgeMessage{topic: prefixTopic(br.topicPrefix, topic), payload: payload, qos: qos, retain: retain}:
+	default:
+		atomic.AddUint64(&br.droppedMessages, 1)
+	}
+}
+
+// Matches returns true if the topic matches one of the filters of the bridge.
+func (br *bridge) Matches(topic string) bool {
+	for _, filter := range br.filters {
+		if topicMatchesFilter(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// DroppedMessages returns the amount of messages that were dropped because the queue was full.
+func (br *bridge) DroppedMessages() uint64 {
+	return atomic.LoadUint64(&br.droppedMessages)
+}
+
+// FailedMessages returns the amount of messages that could not be handed over to the upstream connection.
+func (br *bridge) FailedMessages() uint64 {
+	return atomic.LoadUint64(&br.failedMessages)
+}
+
+// OfflineQueuedMessages returns the amount of messages in the offline queue.
+func (br *bridge) OfflineQueuedMessages() int {
+	if br.offlineQueue == nil {
+		return 0
+	}
+	return br.offlineQueue.Len()
+}
+
+// OfflineDroppedMessages returns the amount of messages that were dropped because the offline queue was full.
+func (br *bridge) OfflineDroppedMessages() uint64 {
+	if br.offlineQueue == nil {
+		return 0
+	}
+	return br.offlineQueue.DroppedMessages()
+}
+
+// Start connects to the upstream broker in the background and starts forwarding the queued messages.
+func (br *bridge) Start() {
+	// the connection is retried until it succeeds, so the token is not waited for
+	br.client.Connect()
+
+	br.shutdownWG.Add(1)
+	go func() {
+		defer br.shutdownWG.Done()
+
+		flushTicker := time.NewTicker(bridgeFlushRetryInterval)
+		defer flushTicker.Stop()
+
+		for {
+			select {
+			case <-br.shutdownChan:
+				return
+			case msg := <-br.queue:
+				br.handle(msg)
+			case <-br.flushChan:
+				br.flush()
+			case <-flushTicker.C:
+				br.flush()
+			}
+		}
+	}()
+}
+
+// handle publishes the message, or appends it to the offline queue if the upstream broker is unreachable
+// or older messages are still queued.
+func (br *bridge) handle(msg *bridgeMessage) {
+	if br.offlineQueue == nil {
+		br.publish(msg)
+		return
+	}
+
+	if br.offlineQueue.Len() == 0 && br.client.IsConnectionOpen() {
+		br.publish(msg)
+		return
+	}
+
+	br.pushOffline(msg)
+	if br.client.IsConnectionOpen() {
+		br.triggerFlush()
+	}
+}
+
+// pushOffline appends the message to the offline queue.
+func (br *bridge) pushOffline(msg *bridgeMessage) {
+	if err := br.offlineQueue.Push(msg); err != nil {
+		atomic.AddUint64(&br.failedMessages, 1)
+		br.log.Warnf("queueing topic %s for the upstream broker failed: %s", msg.topic, err)
+	}
+}
+
+// pushQueuedOffline moves the messages of the in-memory queue to the offline queue, so they are kept behind the older messages.
+func (br *bridge) pushQueuedOffline() {
+	for {
+		select {
+		case msg := <-br.queue:
+			br.pushOffline(msg)
+		default:
+			return
+		}
+	}
+}
+
+// triggerFlush signals the bridge to flush the offline queue.
+func (br *bridge) triggerFlush() {
+	select {
+	case br.flushChan <- struct{}{}:
+	default:
+	}
+}
+
+// flush sends the messages of the offline queue to the upstream broker in batches, in the order they were queued.
+// A message is removed from the queue after the upstream broker acknowledged it, the flush stops at the first
+// message that was not acknowledged and is retried later.
+func (br *bridge) flush() {
+	if br.offlineQueue == nil {
+		return
+	}
+
+	for br.offlineQueue.Len() > 0 && br.client.IsConnectionOpen() {
+		select {
+		case <-br.shutdownChan:
+			return
+		default:
+		}
+
+		// the messages that arrived in the meantime are queued behind the offline messages to keep the order
+		br.pushQueuedOffline()
+
+		messages, sequences, err := br.offlineQueue.Peek(bridgeFlushBatchSize)
+		if err != nil {
+			br.log.Warnf("reading the offline queue of the bridge failed: %s", err)
+			return
+		}
+
+		tokens := make([]paho.Token, len(messages))
+		for i, msg := range messages {
+			tokens[i] = br.client.Publish(msg.topic, msg.qos, msg.retain, msg.payload)
+		}
+
+		for i, token := range tokens {
+			if !token.WaitTimeout(bridgeFlushTimeout) {
+				br.log.Debugf("flushing topic %s to the upstream broker timed out", messages[i].topic)
+				return
+			}
+			if err := token.Error(); err != nil {
+				br.log.Debugf("flushing topic %s to the upstream broker failed: %s", messages[i].topic, err)
+				return
+			}
+			if err := br.offlineQueue.Remove(sequences[i]); err != nil {
+				br.log.Warnf("removing a flushed message from the offline queue of the bridge failed: %s", err)
+				return
+			}
+		}
+	}
+}
+
+// publish hands the message over to the upstream connection.
+// The acknowledgements of QoS > 0 messages are not waited for, so a slow upstream broker doesn't limit the throughput.
+func (br *bridge) publish(msg *bridgeMessage) {
+	token := br.client.Publish(msg.topic, msg.qos, msg.retain, msg.payload)
+
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			atomic.AddUint64(&br.failedMessages, 1)
+			br.log.Debugf("forwarding topic %s to the upstream broker failed: %s", msg.topic, err)
+		}
+	default:
+	}
+}
+
+// Stop stops forwarding messages and disconnects from the upstream broker.
+// Queued messages are dropped, unless there is an offline queue, which keeps them until the next start.
+func (br *bridge) Stop() {
+	br.shutdownOnce.Do(func() {
+		close(br.shutdownChan)
+	})
+	br.shutdownWG.Wait()
+
+	if br.offlineQueue != nil {
+		br.pushQueuedOffline()
+	}
+
+	br.client.Disconnect(bridgeDisconnectQuiesce)
+}
+
+// bridgeClientID returns a random client ID, so multiple nodes can bridge to the same upstream broker.
+func bridgeClientID() (string, error) {
+	randomBytes := make([]byte, 8)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+
+	return "inx-mqtt-bridge-" + hex.EncodeToString(randomBytes), nil
+}
+
+func newBridge(log *logger.Logger, url string, username string, password string, filters []string, topicPrefix string, offlineQueue *bridgeOfflineQueue) (*bridge, error) {
+	if url == "" {
+		return nil, errors.New("no URL given")
+	}
+	if len(filters) == 0 {
+		return nil, errors.New("no topics given")
+	}
+	for _, filter := range filters {
+		if err := validateTopicFilter(filter); err != nil {
+			return nil, fmt.Errorf("invalid topic filter \"%s\": %w", filter, err)
+		}
+	}
+
+	clientID, err := bridgeClientID()
+	if err != nil {
+		return nil, fmt.Errorf("generating client ID failed: %w", err)
+	}
+
+	br := &bridge{
+		log:          log,
+		filters:      filters,
+		topicPrefix:  topicPrefix,
+		queue:        make(chan *bridgeMessage, bridgeQueueSize),
+		offlineQueue: offlineQueue,
+		flushChan:    make(chan struct{}, 1),
+		shutdownChan: make(chan struct{}),
+	}
+
+	clientOpts := paho.NewClientOptions().
+		AddBroker(url).
+		SetClientID(clientID).
+		SetUsername(username).
+		SetPassword(password).
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetMaxReconnectInterval(bridgeMaxReconnectInterval).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(bridgeConnectRetryInterval).
+		SetOnConnectHandler(func(_ paho.Client) {
+			log.Infof("bridge connected to upstream broker %s", url)
+			br.triggerFlush()
+		}).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Warnf("bridge lost connection to upstream broker %s: %s", url, err)
+		})
+
+	br.client = paho.NewClient(clientOpts)
+
+	return br, nil
+}
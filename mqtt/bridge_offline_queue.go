@@ -0,0 +1,252 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// bridgeOfflineQueueFileSuffix is the suffix of the files of the queued messages in the offline queue directory.
+	bridgeOfflineQueueFileSuffix = ".msg"
+)
+
+// BridgeOverflowPolicy defines which messages are dropped if the offline queue of the bridge is full.
+type BridgeOverflowPolicy string
+
+const (
+	// BridgeOverflowPolicyDropOldest removes the oldest queued messages to make room for new messages.
+	BridgeOverflowPolicyDropOldest BridgeOverflowPolicy = "drop-oldest"
+	// BridgeOverflowPolicyDropNewest drops new messages until there is enough room in the queue again.
+	BridgeOverflowPolicyDropNewest BridgeOverflowPolicy = "drop-newest"
+)
+
+// validateBridgeOverflowPolicy checks that the overflow policy of the offline queue is known.
+func validateBridgeOverflowPolicy(policy BridgeOverflowPolicy) error {
+	switch policy {
+	case BridgeOverflowPolicyDropOldest, BridgeOverflowPolicyDropNewest:
+		return nil
+	default:
+		return fmt.Errorf("invalid bridge overflow policy \"%s\", allowed values: %s, %s", policy, BridgeOverflowPolicyDropOldest, BridgeOverflowPolicyDropNewest)
+	}
+}
+
+// bridgeOfflineMessage is the content of the file of a queued message.
+type bridgeOfflineMessage struct {
+	Topic   string `json:"topic"`
+	Payload []byte `json:"payload"`
+	QoS     byte   `json:"qos"`
+	Retain  bool   `json:"retain"`
+}
+
+// encodeBridgeOfflineMessage returns the content of the file of the queued message.
+func encodeBridgeOfflineMessage(msg *bridgeMessage) ([]byte, error) {
+	return json.Marshal(&bridgeOfflineMessage{Topic: msg.topic, Payload: msg.payload, QoS: msg.qos, Retain: msg.retain})
+}
+
+// bridgeOfflineEntry is a queued message in the offline queue.
+type bridgeOfflineEntry struct {
+	sequence uint64
+	size     int64
+}
+
+// bridgeOfflineQueue is a bounded queue of bridge messages on disk, which keeps the messages while the upstream broker is unreachable.
+// Every message is stored in its own file in the queue directory, named by its sequence number,
+// so the order of the messages survives restarts and the oldest messages can be removed without rewriting the queue.
+// The size of the queue is the size of its files.
+type bridgeOfflineQueue struct {
+	dir            string
+	maxSize        int64
+	overflowPolicy BridgeOverflowPolicy
+
+	// entries are the queued messages, ordered from the oldest to the newest.
+	entries      []*bridgeOfflineEntry
+	size         int64
+	nextSequence uint64
+	lock         sync.Mutex
+
+	// droppedMessages is the amount of messages that were dropped because the queue was full.
+	droppedMessages uint64
+}
+
+// filePath returns the path of the file of the queued message with the given sequence number.
+func (q *bridgeOfflineQueue) filePath(sequence uint64) string {
+	// the sequence numbers are padded, so the files are listed in the order of the queue
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", sequence, bridgeOfflineQueueFileSuffix))
+}
+
+// Push appends the message to the queue. If the queue is full, the overflow policy decides whether
+// the oldest messages are removed or the message is dropped.
+func (q *bridgeOfflineQueue) Push(msg *bridgeMessage) error {
+	data, err := encodeBridgeOfflineMessage(msg)
+	if err != nil {
+		return fmt.Errorf("encoding queued message failed: %w", err)
+	}
+	size := int64(len(data))
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if size > q.maxSize || (q.overflowPolicy == BridgeOverflowPolicyDropNewest && q.size+size > q.maxSize) {
+		atomic.AddUint64(&q.droppedMessages, 1)
+		return nil
+	}
+
+	for q.size+size > q.maxSize {
+		if err := q.removeWithoutLocking(q.entries[0].sequence); err != nil {
+			return err
+		}
+		atomic.AddUint64(&q.droppedMessages, 1)
+	}
+
+	sequence := q.nextSequence
+	// the file is written to a temporary file and renamed, so an interrupted write never leaves a truncated message behind
+	tmpPath := q.filePath(sequence) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("writing queued message failed: %w", err)
+	}
+	if err := os.Rename(tmpPath, q.filePath(sequence)); err != nil {
+		return fmt.Errorf("writing queued message failed: %w", err)
+	}
+
+	q.nextSequence++
+	q.entries = append(q.entries, &bridgeOfflineEntry{sequence: sequence, size: size})
+	q.size += size
+
+	return nil
+}
+
+// Peek returns up to the given amount of the oldest queued messages and their sequence numbers, without removing them.
+func (q *bridgeOfflineQueue) Peek(count int) ([]*bridgeMessage, []uint64, error) {
+	q.lock.Lock()
+	entries := q.entries
+	if len(entries) > count {
+		entries = entries[:count]
+	}
+	entries = append([]*bridgeOfflineEntry{}, entries...)
+	q.lock.Unlock()
+
+	messages := make([]*bridgeMessage, 0, len(entries))
+	sequences := make([]uint64, 0, len(entries))
+	for _, entry := range entries {
+		data, err := os.ReadFile(q.filePath(entry.sequence))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// the file was removed from the outside, so the message is not queued anymore
+				if err := q.Remove(entry.sequence); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			return nil, nil, fmt.Errorf("reading queued message failed: %w", err)
+		}
+
+		msg := &bridgeOfflineMessage{}
+		if err := json.Unmarshal(data, msg); err != nil {
+			// a corrupted message would block the queue forever, so it is dropped
+			if err := q.Remove(entry.sequence); err != nil {
+				return nil, nil, err
+			}
+			atomic.AddUint64(&q.droppedMessages, 1)
+			continue
+		}
+
+		messages = append(messages, &bridgeMessage{topic: msg.Topic, payload: msg.Payload, qos: msg.QoS, retain: msg.Retain})
+		sequences = append(sequences, entry.sequence)
+	}
+
+	return messages, sequences, nil
+}
+
+// Remove removes the queued message with the given sequence number, it is a no-op if the message is not queued anymore.
+func (q *bridgeOfflineQueue) Remove(sequence uint64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.removeWithoutLocking(sequence)
+}
+
+func (q *bridgeOfflineQueue) removeWithoutLocking(sequence uint64) error {
+	index := sort.Search(len(q.entries), func(i int) bool { return q.entries[i].sequence >= sequence })
+	if index == len(q.entries) || q.entries[index].sequence != sequence {
+		return nil
+	}
+
+	if err := os.Remove(q.filePath(sequence)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing queued message failed: %w", err)
+	}
+
+	q.size -= q.entries[index].size
+	q.entries = append(q.entries[:index], q.entries[index+1:]...)
+
+	return nil
+}
+
+// Len returns the amount of queued messages.
+func (q *bridgeOfflineQueue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return len(q.entries)
+}
+
+// DroppedMessages returns the amount of messages that were dropped because the queue was full.
+func (q *bridgeOfflineQueue) DroppedMessages() uint64 {
+	return atomic.LoadUint64(&q.droppedMessages)
+}
+
+// openBridgeOfflineQueue opens the offline queue in the given directory, the messages queued before a restart are kept.
+func openBridgeOfflineQueue(dir string, maxSize int64, overflowPolicy BridgeOverflowPolicy) (*bridgeOfflineQueue, error) {
+	if maxSize <= 0 {
+		return nil, errors.New("the maximum size of the offline queue must be positive")
+	}
+	if err := validateBridgeOverflowPolicy(overflowPolicy); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating offline queue directory failed: %w", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading offline queue directory failed: %w", err)
+	}
+
+	q := &bridgeOfflineQueue{
+		dir:            dir,
+		maxSize:        maxSize,
+		overflowPolicy: overflowPolicy,
+	}
+
+	// the directory entries are sorted by name, which is the order of the queue
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, bridgeOfflineQueueFileSuffix) {
+			continue
+		}
+
+		sequence, err := strconv.ParseUint(strings.TrimSuffix(name, bridgeOfflineQueueFileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			return nil, fmt.Errorf("reading offline queue directory failed: %w", err)
+		}
+
+		q.entries = append(q.entries, &bridgeOfflineEntry{sequence: sequence, size: info.Size()})
+		q.size += info.Size()
+		q.nextSequence = sequence + 1
+	}
+
+	return q, nil
+}
@@ -0,0 +1,246 @@
+package mqtt
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/mochi-co/mqtt/server"
+	"github.com/mochi-co/mqtt/server/events"
+	"github.com/mochi-co/mqtt/server/listeners"
+	"github.com/mochi-co/mqtt/server/listeners/auth"
+
+	"github.com/iotaledger/hive.go/logger"
+)
+
+// upstreamBroker is an MQTT broker that records the topics of the messages it received from the bridge.
+type upstreamBroker struct {
+	lock   sync.Mutex
+	topics []string
+}
+
+func (u *upstreamBroker) received() []string {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	return append([]string{}, u.topics...)
+}
+
+// start starts an upstream broker on the address, the broker is closed at the end of the test or by calling the returned function.
+func (u *upstreamBroker) start(t *testing.T, address string) func() {
+	t.Helper()
+
+	server := mqtt.New()
+	server.Events.OnMessage = func(_ events.Client, pk events.Packet) (events.Packet, error) {
+		u.lock.Lock()
+		defer u.lock.Unlock()
+
+		u.topics = append(u.topics, pk.TopicName)
+		return pk, nil
+	}
+
+	if err := server.AddListener(listeners.NewTCP("upstream", address), &listeners.Config{Auth: &auth.Allow{}}); err != nil {
+		t.Fatalf("adding upstream listener failed: %s", err)
+	}
+	if err := server.Serve(); err != nil {
+		t.Fatalf("starting upstream broker failed: %s", err)
+	}
+
+	var closeOnce sync.Once
+	closeFunc := func() { closeOnce.Do(func() { _ = server.Close() }) }
+	t.Cleanup(closeFunc)
+
+	return closeFunc
+}
+
+// waitFor polls the condition until it is true or the timeout is reached.
+func waitFor(t *testing.T, timeout time.Duration, condition func() bool, message string) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// queuedTopics returns the topics of all messages in the offline queue in their order.
+func queuedTopics(t *testing.T, q *bridgeOfflineQueue) []string {
+	t.Helper()
+
+	messages, _, err := q.Peek(q.Len())
+	if err != nil {
+		t.Fatalf("reading offline queue failed: %s", err)
+	}
+
+	topics := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		topics = append(topics, msg.topic)
+	}
+
+	return topics
+}
+
+func TestBridgeOfflineQueueOverflowPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   BridgeOverflowPolicy
+		expected string
+	}{
+		{"drop-oldest keeps the newest messages", BridgeOverflowPolicyDropOldest, "[t/2 t/3 t/4]"},
+		{"drop-newest keeps the oldest messages", BridgeOverflowPolicyDropNewest, "[t/0 t/1 t/2]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg := &bridgeMessage{topic: "t/0", payload: []byte("payload"), qos: 1}
+			data, err := encodeBridgeOfflineMessage(msg)
+			if err != nil {
+				t.Fatalf("encoding message failed: %s", err)
+			}
+
+			// there is room for three messages of the same size
+			q, err := openBridgeOfflineQueue(t.TempDir(), 3*int64(len(data)), test.policy)
+			if err != nil {
+				t.Fatalf("opening offline queue failed: %s", err)
+			}
+
+			for i := 0; i < 5; i++ {
+				if err := q.Push(&bridgeMessage{topic: fmt.Sprintf("t/%d", i), payload: []byte("payload"), qos: 1}); err != nil {
+					t.Fatalf("pushing message %d failed: %s", i, err)
+				}
+			}
+
+			if got := fmt.Sprint(queuedTopics(t, q)); got != test.expected {
+				t.Fatalf("queued topics %s, expected %s", got, test.expected)
+			}
+			if dropped := q.DroppedMessages(); dropped != 2 {
+				t.Fatalf("expected 2 dropped messages, got %d", dropped)
+			}
+			if q.size > q.maxSize {
+				t.Fatalf("queue size %d exceeds the maximum %d", q.size, q.maxSize)
+			}
+		})
+	}
+}
+
+func TestBridgeOfflineQueueMessageLargerThanMaxSize(t *testing.T) {
+	q, err := openBridgeOfflineQueue(t.TempDir(), 16, BridgeOverflowPolicyDropOldest)
+	if err != nil {
+		t.Fatalf("opening offline queue failed: %s", err)
+	}
+
+	if err := q.Push(&bridgeMessage{topic: "t", payload: make([]byte, 64)}); err != nil {
+		t.Fatalf("pushing message failed: %s", err)
+	}
+
+	if q.Len() != 0 || q.DroppedMessages() != 1 {
+		t.Fatalf("expected the message to be dropped, queued %d, dropped %d", q.Len(), q.DroppedMessages())
+	}
+}
+
+func TestBridgeOfflineQueueReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	q, err := openBridgeOfflineQueue(dir, 1024*1024, BridgeOverflowPolicyDropOldest)
+	if err != nil {
+		t.Fatalf("opening offline queue failed: %s", err)
+	}
+	for i := 0; i < 12; i++ {
+		if err := q.Push(&bridgeMessage{topic: fmt.Sprintf("t/%d", i), payload: []byte{byte(i)}, qos: 2, retain: i%2 == 0}); err != nil {
+			t.Fatalf("pushing message %d failed: %s", i, err)
+		}
+	}
+
+	_, sequences, err := q.Peek(1)
+	if err != nil {
+		t.Fatalf("reading offline queue failed: %s", err)
+	}
+	if err := q.Remove(sequences[0]); err != nil {
+		t.Fatalf("removing message failed: %s", err)
+	}
+
+	reopened, err := openBridgeOfflineQueue(dir, 1024*1024, BridgeOverflowPolicyDropOldest)
+	if err != nil {
+		t.Fatalf("reopening offline queue failed: %s", err)
+	}
+
+	// "t/10" and "t/11" must stay behind "t/9", even though they are sorted before it as strings
+	expected := "[t/1 t/2 t/3 t/4 t/5 t/6 t/7 t/8 t/9 t/10 t/11]"
+	if got := fmt.Sprint(queuedTopics(t, reopened)); got != expected {
+		t.Fatalf("queued topics %s, expected %s", got, expected)
+	}
+	if reopened.size != q.size {
+		t.Fatalf("reopened queue size %d, expected %d", reopened.size, q.size)
+	}
+
+	messages, _, err := reopened.Peek(1)
+	if err != nil {
+		t.Fatalf("reading offline queue failed: %s", err)
+	}
+	if msg := messages[0]; msg.qos != 2 || msg.retain || len(msg.payload) != 1 || msg.payload[0] != 1 {
+		t.Fatalf("unexpected queued message %+v", msg)
+	}
+
+	// new messages are appended behind the messages queued before the restart
+	if err := reopened.Push(&bridgeMessage{topic: "t/12"}); err != nil {
+		t.Fatalf("pushing message failed: %s", err)
+	}
+	if topics := queuedTopics(t, reopened); topics[len(topics)-1] != "t/12" {
+		t.Fatalf("expected t/12 at the end of the queue, got %s", topics)
+	}
+}
+
+func TestBridgeOfflineQueueInvalidSettings(t *testing.T) {
+	if _, err := openBridgeOfflineQueue(t.TempDir(), 0, BridgeOverflowPolicyDropOldest); err == nil {
+		t.Fatal("expected an error for a maximum size of 0")
+	}
+	if _, err := openBridgeOfflineQueue(t.TempDir(), 1024, BridgeOverflowPolicy("block")); err == nil {
+		t.Fatal("expected an error for an unknown overflow policy")
+	}
+}
+
+func TestBridgeFlushesOfflineQueueOnReconnect(t *testing.T) {
+	address := freeAddress(t)
+	upstream := &upstreamBroker{}
+	closeUpstream := upstream.start(t, address)
+
+	q, err := openBridgeOfflineQueue(t.TempDir(), 1024*1024, BridgeOverflowPolicyDropOldest)
+	if err != nil {
+		t.Fatalf("opening offline queue failed: %s", err)
+	}
+
+	br, err := newBridge(logger.NewNopLogger(), "tcp://"+address, "", "", []string{"bridged/#"}, "node/", q)
+	if err != nil {
+		t.Fatalf("creating bridge failed: %s", err)
+	}
+	br.Start()
+	t.Cleanup(br.Stop)
+
+	waitFor(t, testTimeout, br.client.IsConnectionOpen, "bridge did not connect")
+
+	br.Forward("bridged/0", []byte("0"), 1, false)
+	waitFor(t, testTimeout, func() bool { return len(upstream.received()) == 1 }, "message was not forwarded while connected")
+
+	closeUpstream()
+	waitFor(t, testTimeout, func() bool { return !br.client.IsConnectionOpen() }, "bridge did not notice the lost connection")
+
+	for i := 1; i <= 5; i++ {
+		br.Forward(fmt.Sprintf("bridged/%d", i), []byte{byte(i)}, 1, false)
+	}
+	waitFor(t, testTimeout, func() bool { return br.OfflineQueuedMessages() == 5 }, "messages were not queued while disconnected")
+
+	upstream.start(t, address)
+	waitFor(t, 3*testTimeout, func() bool { return br.OfflineQueuedMessages() == 0 }, "offline queue was not flushed after reconnecting")
+
+	br.Forward("bridged/6", []byte("6"), 1, false)
+
+	expected := "[node/bridged/0 node/bridged/1 node/bridged/2 node/bridged/3 node/bridged/4 node/bridged/5 node/bridged/6]"
+	waitFor(t, testTimeout, func() bool { return len(upstream.received()) == 7 }, "not all messages were forwarded")
+	if got := fmt.Sprint(upstream.received()); got != expected {
+		t.Fatalf("upstream received %s, expected %s", got, expected)
+	}
+}
@@ -1,131 +1,1983 @@
 package mqtt
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	mqtt "github.com/mochi-co/mqtt/server"
+	"github.com/mochi-co/mqtt/server/events"
 	"github.com/mochi-co/mqtt/server/listeners"
 	"github.com/mochi-co/mqtt/server/listeners/auth"
 	"github.com/mochi-co/mqtt/server/system"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/iotaledger/hive.go/logger"
+)
+
+const (
+	// BrokerVersion is the version of the underlying broker.
+	BrokerVersion = mqtt.Version
+
+	// sysTopicPrefix is the prefix of the broker system topics.
+	sysTopicPrefix = "$SYS/"
+	// sysTopicTemplate is a system topic the underlying broker publishes on its own.
+	// Its retained message is used as a template to create packets for custom system topics.
+	sysTopicTemplate = "$SYS/broker/version"
+
+	// listenerIDWebsocket is the ID of the websocket listener.
+	listenerIDWebsocket = "ws1"
+	// listenerIDWebsocketTLS is the ID of the secure websocket listener.
+	listenerIDWebsocketTLS = "wss1"
+	// listenerIDTCP is the ID of the TCP listener.
+	listenerIDTCP = "t1"
+	// listenerIDTCPPrefix is the prefix of the IDs of the additional TCP listeners, they are numbered after the TCP listener.
+	listenerIDTCPPrefix = "t"
+	// listenerIDUnixSocket is the ID of the unix socket listener.
+	listenerIDUnixSocket = "unix1"
+
+	// ListenerTypeWebsocket is the type of websocket listeners.
+	ListenerTypeWebsocket = "websocket"
+	// ListenerTypeTCP is the type of TCP listeners.
+	ListenerTypeTCP = "tcp"
+	// ListenerTypeUnixSocket is the type of unix socket listeners.
+	ListenerTypeUnixSocket = "unix"
+
+	// ListenerAuthModeAllowEveryone is the auth mode of listeners that allow every client.
+	ListenerAuthModeAllowEveryone = "allow-everyone"
+	// ListenerAuthModeUsers is the auth mode of listeners that only allow the configured users.
+	ListenerAuthModeUsers = "users"
+	// ListenerAuthModeJWT is the auth mode of listeners that only allow clients with a valid JWT.
+	ListenerAuthModeJWT = "jwt"
+)
+
+// ListenerInfo describes an active listener of the broker.
+// It does not contain any sensitive auth details like passwords or salts.
+type ListenerInfo struct {
+	// The ID of the listener.
+	ID string `json:"id"`
+	// The type of the listener (websocket, tcp or unix).
+	Type string `json:"type"`
+	// The bind address (or socket path) the listener listens on.
+	BindAddress string `json:"bindAddress"`
+	// Whether TLS is enabled for the listener.
+	TLSEnabled bool `json:"tlsEnabled"`
+	// Whether the clients need to present a valid TLS client certificate.
+	TLSClientAuthEnabled bool `json:"tlsClientAuthEnabled"`
+	// Whether the real client addresses are read from the PROXY protocol header sent by a load balancer.
+	ProxyProtocolEnabled bool `json:"proxyProtocolEnabled"`
+	// Whether the permessage-deflate compression is negotiated with the websocket clients.
+	CompressionEnabled bool `json:"compressionEnabled"`
+	// The auth mode of the listener (allow-everyone, users or jwt).
+	AuthMode string `json:"authMode"`
+	// The amount of users that are allowed to connect if the auth mode is "users".
+	AuthUsers int `json:"authUsers,omitempty"`
+}
+
+// ClientInfo describes a connected client of the broker.
+type ClientInfo struct {
+	// The client ID.
+	ID string `json:"id"`
+	// The ID of the listener the client is connected to.
+	Listener string `json:"listener"`
+	// The topic filters the client subscribed to, sorted alphabetically.
+	Subscriptions []string `json:"subscriptions"`
+}
+
+var (
+	// ErrSysTopicsNotReady is returned if a system topic is published before the broker was started.
+	ErrSysTopicsNotReady = errors.New("system topics are not ready yet")
+	// ErrAckTimeout is the reason of a disconnect if the client did not acknowledge a message after the maximum amount of retransmissions.
+	ErrAckTimeout = errors.New("ack timeout")
+	// ErrInvalidQoS is returned if a message is published with a QoS other than 0, 1 or 2.
+	ErrInvalidQoS = errors.New("invalid QoS")
+	// ErrTLSNotEnabled is returned if the TLS certificate is reloaded, but TLS is not enabled.
+	ErrTLSNotEnabled = errors.New("TCP TLS is not enabled")
+	// ErrUsersAuthNotEnabled is returned if the users are reloaded, but the TCP listeners don't authenticate users.
+	ErrUsersAuthNotEnabled = errors.New("TCP auth with users is not enabled")
+	// ErrIdleConnection is the reason of a disconnect if the client was idle for too long.
+	ErrIdleConnection = errors.New("idle connection reaped")
+	// ErrShuttingDown is the reason of a rejected connection or a disconnect if a client connects while the broker is shutting down.
+	ErrShuttingDown = errors.New("broker is shutting down")
+	// ErrTooManyClients is the reason of a rejected connection if the maximum amount of clients is connected.
+	ErrTooManyClients = errors.New("too many clients")
+	// ErrTooManyConnections is the reason of a rejected connection if the remote IP of the client has too many connections.
+	ErrTooManyConnections = errors.New("too many connections from the same IP")
+	// ErrSlowClient is the reason of a disconnect if the outgoing buffer of the client was saturated.
+	ErrSlowClient = errors.New("outgoing buffer of client saturated")
+)
+
+const (
+	// shutdownDrainCheckInterval is the interval in which the queued messages are checked during a graceful shutdown.
+	shutdownDrainCheckInterval = 50 * time.Millisecond
 )
 
 // Broker is a simple mqtt publisher abstraction.
+//
+// The underlying broker only supports MQTT 3.1 and 3.1.1. MQTT 5.0 clients are answered with the return code
+// 0x01 (unacceptable protocol version) and disconnected, so clients that support both versions can fall back to 3.1.1.
+// MQTT 5.0 features like reason codes, user properties and subscription identifiers are therefore not available.
+// Subscriptions denied by the ACL, the subscription validator, the topic manager limit or the per-client limit are answered with
+// the generic SUBACK failure code 0x80.
 type Broker struct {
+	log          *logger.Logger
 	broker       *mqtt.Server
 	opts         *BrokerOptions
 	topicManager *topicManager
+	onSubscribe  OnSubscribeHandler
+	// topicPrefix is the prefix of the topics on the underlying broker including the level separator, empty if no prefix is set.
+	topicPrefix string
+
+	// subscriptionFilter defines the topics that are subscribed internally (optional).
+	subscriptionFilter *SubscriptionFilter
+
+	retainedManager   *retainedManager
+	retainedThrottler *retainedThrottler
+	// retainedStore persists the retained messages across restarts (optional).
+	retainedStore RetainedStore
+
+	// idleConnectionReaper disconnects idle clients without subscriptions (optional).
+	idleConnectionReaper *idleConnectionReaper
+	// messageExpirer drops expired messages that are queued for clients (optional).
+	messageExpirer *messageExpirer
+
+	// ackTimeoutMonitor retransmits unacknowledged messages and disconnects dead clients (optional).
+	ackTimeoutMonitor *ackTimeoutMonitor
+
+	// messageBatcher coalesces the messages for clients subscribed to the batch delivery topic (optional).
+	messageBatcher *messageBatcher
+	// publishCoalescer only publishes the latest message per coalesced topic within a time window (optional).
+	publishCoalescer *publishCoalescer
+
+	throughputTracker *throughputTracker
+	// publishLatency is the histogram of the time it took to publish the messages to the subscribers.
+	publishLatency prometheus.Histogram
+
+	// topicHookExecutor calls the topic hooks after publishing (optional).
+	topicHookExecutor *topicHookExecutor
+
+	// bridge forwards the messages on the bridge topics to an upstream broker (optional).
+	bridge *bridge
+
+	// slowClientGuard applies the slow client policy to clients with a saturated outgoing buffer (optional).
+	slowClientGuard *slowClientGuard
+
+	// eventCallbacks passes the client and publish events to the callbacks of the user (optional).
+	eventCallbacks *eventCallbacks
+
+	// clientLimiter limits the connections, subscriptions and messages of single clients (optional).
+	clientLimiter *clientLimiter
+	// clientCap limits the amount of connected clients of the whole broker (optional).
+	clientCap *clientCap
+
+	// listeners are the active listeners of the broker.
+	listeners []*ListenerInfo
+
+	// tlsCertificate is the reloadable certificate of the TCP listeners (optional).
+	tlsCertificate *tlsCertificateHolder
+	// tcpUsersAuth is the auth controller with the reloadable users of the TCP listeners (optional).
+	tcpUsersAuth *AuthAllowBasicAuth
+
+	// healthServer exposes the state of the broker for liveness and readiness probes (optional).
+	healthServer *healthServer
+	// serving is set after the broker started serving and cleared when it is stopped.
+	serving uint32
+	// readyChan is closed once all listeners are bound and serving.
+	readyChan chan struct{}
+	// shuttingDown is set while the broker is shut down gracefully, new connections are rejected.
+	// It is shared with the listener wrappers, which reject the connections before CONNACK.
+	shuttingDown *uint32
+	stopOnce     sync.Once
+	stopErr      error
+}
+
+// tcpListenerEntry is a TCP listener config with the ID of its listener.
+type tcpListenerEntry struct {
+	*TCPListenerConfig
+	id string
 }
 
 // NewBroker creates a new broker.
-func NewBroker(onSubscribe OnSubscribeHandler, onUnsubscribe OnUnsubscribeHandler, brokerOpts *BrokerOptions) (*Broker, error) {
+func NewBroker(log *logger.Logger, onSubscribe OnSubscribeHandler, onUnsubscribe OnUnsubscribeHandler, brokerOpts *BrokerOptions) (_ *Broker, err error) {
+
+	if !brokerOpts.WebsocketEnabled && !brokerOpts.WebsocketTLSEnabled && !brokerOpts.TCPEnabled && len(brokerOpts.TCPListeners) == 0 && !brokerOpts.UnixSocketEnabled {
+		return nil, configError(ErrNoListenerEnabled, errors.New("at least websocket, secure websocket, TCP or unix socket must be enabled"))
+	}
+
+	if brokerOpts.IdleConnectionReaperEnabled && (brokerOpts.IdleConnectionTimeout <= 0 || brokerOpts.IdleConnectionCheckInterval <= 0) {
+		return nil, configError(ErrInvalidConfig, errors.New("idle connection timeout and check interval must be greater than zero if the idle connection reaper is enabled"))
+	}
+
+	for topic, publishOptions := range brokerOpts.TopicPublishOptions {
+		if err := validateQoS(publishOptions.QoS); err != nil {
+			return nil, configError(ErrInvalidConfig, fmt.Errorf("invalid publish options for topic %s: %w", topic, err))
+		}
+	}
+
+	var topicPrefix string
+	if brokerOpts.TopicPrefix != "" {
+		if err := validateTopicPrefix(brokerOpts.TopicPrefix); err != nil {
+			return nil, configError(ErrInvalidConfig, fmt.Errorf("invalid topic prefix \"%s\": %w", brokerOpts.TopicPrefix, err))
+		}
+		topicPrefix = brokerOpts.TopicPrefix + topicLevelSeparator
+	}
+
+	var subscriptionFilter *SubscriptionFilter
+	if brokerOpts.SubscriptionFilterFilePath != "" {
+		var err error
+		subscriptionFilter, err = LoadSubscriptionFilterFile(brokerOpts.SubscriptionFilterFilePath)
+		if err != nil {
+			return nil, configError(ErrInvalidConfig, fmt.Errorf("loading subscription filter failed: %w", err))
+		}
+	}
+
+	t := newTopicManager(onSubscribe, onUnsubscribe, brokerOpts.TopicCleanupThreshold, brokerOpts.MaxTopicManagerSize)
 
-	if !brokerOpts.WebsocketEnabled && !brokerOpts.TCPEnabled {
-		return nil, errors.New("at least websocket or TCP must be enabled")
+	if brokerOpts.MaxClients < 0 {
+		return nil, configError(ErrInvalidConfig, errors.New("the maximum amount of clients must not be negative"))
 	}
 
+	var listenerInfos []*ListenerInfo
+	var tlsCertificate *tlsCertificateHolder
+
 	broker := mqtt.NewServer(&mqtt.Options{
 		BufferSize:      brokerOpts.BufferSize,
 		BufferBlockSize: brokerOpts.BufferBlockSize,
 	})
 
+	var maxClientsCap *clientCap
+	if brokerOpts.MaxClients > 0 {
+		// the cap is shared by all listeners
+		maxClientsCap = newClientCap(brokerOpts.MaxClients, broker.System)
+	}
+
+	// wrapAuth wraps the auth controller of a listener to reject invalid subscriptions and to enforce the maximum size of the topic manager
+	wrapAuth := func(controller auth.Controller) auth.Controller {
+		if brokerOpts.MaxTopicManagerSize != 0 {
+			controller = &AuthTopicManagerLimit{Controller: controller, topicManager: t, topicPrefix: topicPrefix}
+		}
+		if brokerOpts.SubscriptionValidator != nil {
+			// invalid subscriptions are rejected before they are counted against the maximum size
+			controller = &AuthSubscriptionValidator{Controller: controller, log: log, validate: brokerOpts.SubscriptionValidator, topicPrefix: topicPrefix}
+		}
+		return controller
+	}
+
+	if brokerOpts.MaxConnectionsPerIP < 0 || brokerOpts.MaxSubscriptionsPerClient < 0 || brokerOpts.MaxMessagesPerSecondPerClient < 0 {
+		return nil, configError(ErrInvalidConfig, errors.New("client limits must not be negative"))
+	}
+	var clientLimiter *clientLimiter
+	if brokerOpts.MaxConnectionsPerIP > 0 || brokerOpts.MaxSubscriptionsPerClient > 0 || brokerOpts.MaxMessagesPerSecondPerClient > 0 {
+		clientLimiter = newClientLimiter(brokerOpts.MaxConnectionsPerIP, brokerOpts.MaxSubscriptionsPerClient, brokerOpts.MaxMessagesPerSecondPerClient, broker)
+	}
+
+	if brokerOpts.MaxKeepAlive < 0 || brokerOpts.ReadIdleTimeout < 0 {
+		return nil, configError(ErrInvalidConfig, errors.New("maximum keep-alive and read idle timeout must not be negative"))
+	}
+	shuttingDown := new(uint32)
+	// wrapListener wraps a listener to enforce the client limits and the keep-alive limits on its connections,
+	// and to reject new connections during a graceful shutdown
+	wrapListener := func(listener listeners.Listener) listeners.Listener {
+		if brokerOpts.MaxKeepAlive != 0 || brokerOpts.ReadIdleTimeout != 0 {
+			listener = &keepAliveListener{Listener: listener, maxKeepAlive: brokerOpts.MaxKeepAlive, readIdleTimeout: brokerOpts.ReadIdleTimeout}
+		}
+		if maxClientsCap != nil || clientLimiter != nil {
+			// connections above the limits are rejected before they reach the other wrappers
+			listener = &clientLimitListener{Listener: listener, clientCap: maxClientsCap, limiter: clientLimiter}
+		}
+		// connections during a shutdown are rejected before they count against the client limits
+		return &shutdownListener{Listener: listener, shuttingDown: shuttingDown}
+	}
+
+	defer func() {
+		if err != nil {
+			// release the sockets of the listeners that were already bound
+			for _, listenerInfo := range listenerInfos {
+				broker.Listeners.Close(listenerInfo.ID, func(string) {})
+			}
+		}
+	}()
+
+	websocketCompression := websocketCompression{
+		enabled: brokerOpts.WebsocketCompressionEnabled,
+		level:   brokerOpts.WebsocketCompressionLevel,
+	}
+	if websocketCompression.enabled {
+		if err := validateWebsocketCompressionLevel(websocketCompression.level); err != nil {
+			return nil, configError(ErrInvalidConfig, err)
+		}
+	}
+
 	if brokerOpts.WebsocketEnabled {
 		// check websocket bind address
 		_, _, err := net.SplitHostPort(brokerOpts.WebsocketBindAddress)
 		if err != nil {
-			return nil, fmt.Errorf("parsing websocket bind address (%s) failed: %w", brokerOpts.WebsocketBindAddress, err)
+			return nil, configError(ErrInvalidConfig, fmt.Errorf("parsing websocket bind address (%s) failed: %w", brokerOpts.WebsocketBindAddress, err))
 		}
 
-		ws := listeners.NewWebsocket("ws1", brokerOpts.WebsocketBindAddress)
-		if err := broker.AddListener(ws, &listeners.Config{
-			Auth: &AuthAllowEveryone{},
+		ws := newWebsocketListener(listenerIDWebsocket, brokerOpts.WebsocketBindAddress, nil, websocketCompression)
+		if err := broker.AddListener(wrapListener(ws), &listeners.Config{
+			Auth: wrapAuth(&AuthAllowEveryone{}),
 			TLS:  nil,
 		}); err != nil {
-			return nil, fmt.Errorf("adding websocket listener failed: %w", err)
+			return nil, listenerError(fmt.Errorf("adding websocket listener failed: %w", err))
+		}
+
+		listenerInfos = append(listenerInfos, &ListenerInfo{
+			ID:          listenerIDWebsocket,
+			Type:        ListenerTypeWebsocket,
+			BindAddress: brokerOpts.WebsocketBindAddress,
+			TLSEnabled:  false,
+			AuthMode:    ListenerAuthModeAllowEveryone,
+
+			CompressionEnabled: websocketCompression.enabled,
+		})
+	}
+
+	if brokerOpts.WebsocketTLSEnabled {
+		// check secure websocket bind address
+		_, _, err := net.SplitHostPort(brokerOpts.WebsocketTLSBindAddress)
+		if err != nil {
+			return nil, configError(ErrInvalidConfig, fmt.Errorf("parsing secure websocket bind address (%s) failed: %w", brokerOpts.WebsocketTLSBindAddress, err))
+		}
+
+		if brokerOpts.WebsocketEnabled && brokerOpts.WebsocketTLSBindAddress == brokerOpts.WebsocketBindAddress {
+			return nil, configError(ErrInvalidConfig, fmt.Errorf("websocket and secure websocket can't use the same bind address (%s)", brokerOpts.WebsocketBindAddress))
+		}
+
+		wsTLSSettings, err := NewTLSSettings(brokerOpts.WebsocketTLSCertificatePath, brokerOpts.WebsocketTLSPrivateKeyPath)
+		if err != nil {
+			return nil, configError(ErrTLSConfig, fmt.Errorf("Enabling websocket TLS failed: %w", err))
+		}
+
+		wsTLSCertificate, err := tls.X509KeyPair(wsTLSSettings.Certificate, wsTLSSettings.PrivateKey)
+		if err != nil {
+			return nil, configError(ErrTLSConfig, fmt.Errorf("Enabling websocket TLS failed: %w", err))
+		}
+
+		wss := newWebsocketListener(listenerIDWebsocketTLS, brokerOpts.WebsocketTLSBindAddress, &tls.Config{
+			Certificates: []tls.Certificate{wsTLSCertificate},
+		}, websocketCompression)
+		if err := broker.AddListener(wrapListener(wss), &listeners.Config{
+			Auth: wrapAuth(&AuthAllowEveryone{}),
+			TLS:  wsTLSSettings,
+		}); err != nil {
+			return nil, listenerError(fmt.Errorf("adding secure websocket listener failed: %w", err))
 		}
+
+		listenerInfos = append(listenerInfos, &ListenerInfo{
+			ID:          listenerIDWebsocketTLS,
+			Type:        ListenerTypeWebsocket,
+			BindAddress: brokerOpts.WebsocketTLSBindAddress,
+			TLSEnabled:  true,
+			AuthMode:    ListenerAuthModeAllowEveryone,
+
+			CompressionEnabled: websocketCompression.enabled,
+		})
 	}
 
+	// the single TCP bind address is a shorthand for the first TCP listener
+	var tcpListeners []*tcpListenerEntry
 	if brokerOpts.TCPEnabled {
+		tcpListeners = append(tcpListeners, &tcpListenerEntry{
+			id: listenerIDTCP,
+			TCPListenerConfig: &TCPListenerConfig{
+				BindAddress:          brokerOpts.TCPBindAddress,
+				AuthEnabled:          brokerOpts.TCPAuthEnabled,
+				TLSEnabled:           brokerOpts.TCPTLSEnabled,
+				ProxyProtocolEnabled: brokerOpts.TCPProxyProtocolEnabled,
+			},
+		})
+	}
+	for i, listenerConfig := range brokerOpts.TCPListeners {
+		tcpListeners = append(tcpListeners, &tcpListenerEntry{
+			id:                fmt.Sprintf("%s%d", listenerIDTCPPrefix, i+2),
+			TCPListenerConfig: listenerConfig,
+		})
+	}
+
+	// the unix socket listener uses the same auth as the TCP listener
+	tcpAuthNeeded := brokerOpts.TCPAuthEnabled && (brokerOpts.TCPEnabled || brokerOpts.UnixSocketEnabled)
+	tcpTLSNeeded := false
+	for _, tcpListener := range tcpListeners {
+		tcpAuthNeeded = tcpAuthNeeded || tcpListener.AuthEnabled
+		tcpTLSNeeded = tcpTLSNeeded || tcpListener.TLSEnabled
+	}
+
+	var tcpAuthController auth.Controller = &AuthAllowEveryone{}
+	// tcpUsersAuth is the auth controller of the TCP listeners if the users auth mode is used, its users can be reloaded
+	var tcpUsersAuth *AuthAllowBasicAuth
+	tcpAuthMode := ListenerAuthModeAllowEveryone
+	if tcpAuthNeeded && brokerOpts.TCPAuthJWTKeyPath != "" {
+		if brokerOpts.TCPAuthACLFilePath != "" {
+			return nil, configError(ErrAuthConfig, errors.New("Enabling TCP JWT Authentication failed: the ACL file is only supported for users"))
+		}
+
+		jwtKey, err := os.ReadFile(brokerOpts.TCPAuthJWTKeyPath)
+		if err != nil {
+			return nil, configError(ErrAuthConfig, fmt.Errorf("Enabling TCP JWT Authentication failed: unable to read key file (%s): %w", brokerOpts.TCPAuthJWTKeyPath, err))
+		}
+
+		// secrets are often written with a trailing newline
+		jwtAuth, err := NewAuthJWT(bytes.TrimSpace(jwtKey), brokerOpts.TCPAuthJWTIssuer, brokerOpts.TCPAuthJWTAudience)
+		if err != nil {
+			return nil, configError(ErrAuthConfig, fmt.Errorf("Enabling TCP JWT Authentication failed: %w", err))
+		}
+
+		tcpAuthController = jwtAuth
+		tcpAuthMode = ListenerAuthModeJWT
+	} else if tcpAuthNeeded {
+		basicAuth, err := NewAuthAllowUsers(brokerOpts.TCPAuthPasswordSalt, brokerOpts.TCPAuthUsers)
+		if err != nil {
+			return nil, configError(ErrAuthConfig, fmt.Errorf("Enabling TCP Authentication failed: %w", err))
+		}
+
+		if brokerOpts.TCPAuthACLFilePath != "" {
+			acl, err := LoadACLFile(brokerOpts.TCPAuthACLFilePath)
+			if err != nil {
+				return nil, configError(ErrAuthConfig, fmt.Errorf("loading TCP ACL failed: %w", err))
+			}
+
+			for user := range acl.Users {
+				if _, has := brokerOpts.TCPAuthUsers[user]; !has {
+					return nil, configError(ErrAuthConfig, fmt.Errorf("loading TCP ACL failed: unknown user %s", user))
+				}
+			}
+			basicAuth.Permissions = acl
+		}
+
+		tcpAuthController = basicAuth
+		tcpUsersAuth = basicAuth
+		tcpAuthMode = ListenerAuthModeUsers
+	}
+
+	// all TCP listeners with TLS share the reloadable certificate
+	var tcpTLSConfig *tls.Config
+	if tcpTLSNeeded {
+		var err error
+		tlsCertificate, err = newTLSCertificateHolder(brokerOpts.TCPTLSCertificatePath, brokerOpts.TCPTLSPrivateKeyPath)
+		if err != nil {
+			return nil, configError(ErrTLSConfig, fmt.Errorf("Enabling TCP TLS failed: %w", err))
+		}
+
+		tcpTlsClientCAPath := ""
+		if brokerOpts.TCPTLSClientAuthEnabled {
+			tcpTlsClientCAPath = brokerOpts.TCPTLSClientCAPath
+		}
+
+		tcpTLSConfig, err = newTLSConfig(tlsCertificate, tcpTlsClientCAPath)
+		if err != nil {
+			return nil, configError(ErrTLSConfig, fmt.Errorf("Enabling TCP TLS client authentication failed: %w", err))
+		}
+	} else if brokerOpts.TCPTLSClientAuthEnabled && len(tcpListeners) > 0 {
+		return nil, configError(ErrTLSConfig, errors.New("TCP TLS must be enabled if TCP TLS client authentication is enabled"))
+	}
+
+	tcpBindAddresses := make(map[string]string, len(tcpListeners))
+	for _, tcpListener := range tcpListeners {
 		// check tcp bind address
-		_, _, err := net.SplitHostPort(brokerOpts.TCPBindAddress)
+		_, _, err := net.SplitHostPort(tcpListener.BindAddress)
 		if err != nil {
-			return nil, fmt.Errorf("parsing TCP bind address (%s) failed: %w", brokerOpts.TCPBindAddress, err)
+			return nil, configError(ErrInvalidConfig, fmt.Errorf("parsing bind address (%s) of TCP listener %s failed: %w", tcpListener.BindAddress, tcpListener.id, err))
+		}
+
+		if otherID, has := tcpBindAddresses[tcpListener.BindAddress]; has {
+			return nil, configError(ErrInvalidConfig, fmt.Errorf("TCP listeners %s and %s can't use the same bind address (%s)", otherID, tcpListener.id, tcpListener.BindAddress))
+		}
+		tcpBindAddresses[tcpListener.BindAddress] = tcpListener.id
+
+		var tcp listeners.Listener = listeners.NewTCP(tcpListener.id, tcpListener.BindAddress)
+		if tcpListener.TLSEnabled || tcpListener.ProxyProtocolEnabled {
+			// the TCP listener of the underlying broker doesn't support the PROXY protocol,
+			// and the certificate of the TCP listener of the underlying broker can't be reloaded
+			var tlsConfig *tls.Config
+			if tcpListener.TLSEnabled {
+				tlsConfig = tcpTLSConfig
+			}
+			tcp = newTCPListener(tcpListener.id, tcpListener.BindAddress, tlsConfig, tcpListener.ProxyProtocolEnabled)
+		}
+
+		var authController auth.Controller = &AuthAllowEveryone{}
+		authMode := ListenerAuthModeAllowEveryone
+		if tcpListener.AuthEnabled {
+			authController = tcpAuthController
+			authMode = tcpAuthMode
 		}
 
-		tcp := listeners.NewTCP("t1", brokerOpts.TCPBindAddress)
+		if err := broker.AddListener(wrapListener(tcp), &listeners.Config{
+			Auth: wrapAuth(authController),
+			TLS:  nil,
+		}); err != nil {
+			return nil, listenerError(fmt.Errorf("adding TCP listener %s (%s) failed: %w", tcpListener.id, tcpListener.BindAddress, err))
+		}
+
+		tcpListenerInfo := &ListenerInfo{
+			ID:          tcpListener.id,
+			Type:        ListenerTypeTCP,
+			BindAddress: tcpListener.BindAddress,
+			TLSEnabled:  tcpListener.TLSEnabled,
+			AuthMode:    authMode,
+
+			TLSClientAuthEnabled: tcpListener.TLSEnabled && brokerOpts.TCPTLSClientAuthEnabled,
+			ProxyProtocolEnabled: tcpListener.ProxyProtocolEnabled,
+		}
+		if authMode == ListenerAuthModeUsers {
+			tcpListenerInfo.AuthUsers = len(brokerOpts.TCPAuthUsers)
+		}
+		listenerInfos = append(listenerInfos, tcpListenerInfo)
+	}
 
-		var tcpAuthController auth.Controller
+	if brokerOpts.UnixSocketEnabled {
+		if brokerOpts.UnixSocketPath == "" {
+			return nil, configError(ErrInvalidConfig, errors.New("unix socket path must be set if the unix socket is enabled"))
+		}
+
+		var authController auth.Controller = &AuthAllowEveryone{}
+		authMode := ListenerAuthModeAllowEveryone
 		if brokerOpts.TCPAuthEnabled {
-			var err error
-			tcpAuthController, err = NewAuthAllowUsers(brokerOpts.TCPAuthPasswordSalt, brokerOpts.TCPAuthUsers)
-			if err != nil {
-				return nil, fmt.Errorf("Enabling TCP Authentication failed: %w", err)
+			authController = tcpAuthController
+			authMode = tcpAuthMode
+		}
+
+		if err := broker.AddListener(wrapListener(newUnixListener(listenerIDUnixSocket, brokerOpts.UnixSocketPath)), &listeners.Config{
+			Auth: wrapAuth(authController),
+			TLS:  nil,
+		}); err != nil {
+			return nil, listenerError(fmt.Errorf("adding unix socket listener failed: %w", err))
+		}
+
+		unixListenerInfo := &ListenerInfo{
+			ID:          listenerIDUnixSocket,
+			Type:        ListenerTypeUnixSocket,
+			BindAddress: brokerOpts.UnixSocketPath,
+			TLSEnabled:  false,
+			AuthMode:    authMode,
+		}
+		if authMode == ListenerAuthModeUsers {
+			unixListenerInfo.AuthUsers = len(brokerOpts.TCPAuthUsers)
+		}
+		listenerInfos = append(listenerInfos, unixListenerInfo)
+	}
+
+	throughputTracker, err := newThroughputTracker(brokerOpts.ThroughputWindows)
+	if err != nil {
+		return nil, configError(ErrInvalidConfig, fmt.Errorf("invalid throughput windows: %w", err))
+	}
+
+	b := &Broker{
+		log:          log,
+		broker:       broker,
+		opts:         brokerOpts,
+		topicManager: t,
+		onSubscribe:  onSubscribe,
+		topicPrefix:  topicPrefix,
+
+		clientCap:          maxClientsCap,
+		subscriptionFilter: subscriptionFilter,
+		throughputTracker:  throughputTracker,
+		publishLatency:     newPublishLatencyHistogram(),
+		listeners:          listenerInfos,
+		tlsCertificate:     tlsCertificate,
+		tcpUsersAuth:       tcpUsersAuth,
+		readyChan:          make(chan struct{}),
+		shuttingDown:       shuttingDown,
+	}
+
+	if brokerOpts.IdleConnectionReaperEnabled {
+		b.idleConnectionReaper = newIdleConnectionReaper(brokerOpts.IdleConnectionTimeout, brokerOpts.IdleConnectionCheckInterval, b.reapIdleClient)
+	}
+
+	if brokerOpts.AckTimeoutMaxRetransmissions > 0 {
+		if brokerOpts.AckTimeout <= 0 {
+			return nil, configError(ErrInvalidConfig, errors.New("ack timeout must be greater than zero if the maximum amount of retransmissions is set"))
+		}
+		b.ackTimeoutMonitor = newAckTimeoutMonitor(brokerOpts.AckTimeout, b.checkAckTimeouts)
+	}
+
+	if len(brokerOpts.MessageExpiry) > 0 {
+		b.messageExpirer, err = newMessageExpirer(brokerOpts.MessageExpiry, b.expireQueuedMessages)
+		if err != nil {
+			return nil, configError(ErrInvalidConfig, fmt.Errorf("invalid message expiry: %w", err))
+		}
+	}
+
+	if brokerOpts.BatchDeliveryTopic != "" {
+		if brokerOpts.BatchWindow <= 0 || brokerOpts.BatchMaxSize <= 0 {
+			return nil, configError(ErrInvalidConfig, errors.New("batch window and maximum batch size must be greater than zero if the batch delivery topic is set"))
+		}
+		b.messageBatcher = newMessageBatcher(brokerOpts.BatchWindow, brokerOpts.BatchMaxSize, b.deliverBatch)
+	}
+
+	if brokerOpts.PublishCoalesceWindow < 0 {
+		return nil, configError(ErrInvalidConfig, errors.New("publish coalesce window must not be negative"))
+	}
+	if brokerOpts.PublishCoalesceWindow > 0 {
+		if len(brokerOpts.PublishCoalesceTopics) == 0 {
+			return nil, configError(ErrInvalidConfig, errors.New("publish coalesce topics must be given if the publish coalesce window is set"))
+		}
+		b.publishCoalescer = newPublishCoalescer(brokerOpts.PublishCoalesceWindow, brokerOpts.PublishCoalesceTopics)
+	}
+
+	if len(brokerOpts.TopicHooks) > 0 {
+		if brokerOpts.TopicHookWorkers <= 0 || brokerOpts.TopicHookQueueSize <= 0 {
+			return nil, configError(ErrInvalidConfig, errors.New("topic hook workers and queue size must be greater than zero if topic hooks are given"))
+		}
+		for _, hook := range brokerOpts.TopicHooks {
+			if err := validateTopicFilter(hook.Filter); err != nil {
+				return nil, configError(ErrInvalidConfig, fmt.Errorf("invalid filter \"%s\" of topic hook %s: %w", hook.Filter, hook.Name, err))
+			}
+			if hook.Hook == nil {
+				return nil, configError(ErrInvalidConfig, fmt.Errorf("topic hook %s has no hook function", hook.Name))
 			}
-		} else {
-			tcpAuthController = &AuthAllowEveryone{}
 		}
+		b.topicHookExecutor = newTopicHookExecutor(log, brokerOpts.TopicHooks, brokerOpts.TopicHookWorkers, brokerOpts.TopicHookQueueSize)
+	}
 
-		var tls *listeners.TLS
-		if brokerOpts.TCPTLSEnabled {
-			var err error
-			tls, err = NewTLSSettings(brokerOpts.TCPTLSCertificatePath, brokerOpts.TCPTLSPrivateKeyPath)
+	if brokerOpts.BridgeEnabled {
+		var offlineQueue *bridgeOfflineQueue
+		if brokerOpts.BridgeOfflineQueuePath != "" {
+			offlineQueue, err = openBridgeOfflineQueue(brokerOpts.BridgeOfflineQueuePath, brokerOpts.BridgeOfflineQueueMaxSize, brokerOpts.BridgeOfflineQueueOverflowPolicy)
 			if err != nil {
-				return nil, fmt.Errorf("Enabling TCP TLS failed: %w", err)
+				return nil, configError(ErrInvalidConfig, fmt.Errorf("invalid bridge offline queue settings: %w", err))
 			}
 		}
 
-		if err := broker.AddListener(tcp, &listeners.Config{
-			Auth: tcpAuthController,
-			TLS:  tls,
-		}); err != nil {
-			return nil, fmt.Errorf("adding TCP listener failed: %w", err)
+		b.bridge, err = newBridge(log, brokerOpts.BridgeURL, brokerOpts.BridgeUsername, brokerOpts.BridgePassword, brokerOpts.BridgeTopics, topicPrefix, offlineQueue)
+		if err != nil {
+			return nil, configError(ErrInvalidConfig, fmt.Errorf("invalid bridge settings: %w", err))
 		}
 	}
 
-	t := newTopicManager(onSubscribe, onUnsubscribe, brokerOpts.TopicCleanupThreshold)
+	if err := validateSlowClientPolicy(brokerOpts.SlowClientPolicy); err != nil {
+		return nil, configError(ErrInvalidConfig, err)
+	}
+	if err := validateFirehosePolicy(brokerOpts.FirehosePolicy, brokerOpts.FirehoseBackpressureThreshold, brokerOpts.FirehoseSampleRate); err != nil {
+		return nil, configError(ErrInvalidConfig, err)
+	}
+	var firehose *firehoseGuard
+	if brokerOpts.FirehosePolicy != FirehosePolicyNone && len(brokerOpts.FirehoseTopics) > 0 {
+		firehose = newFirehoseGuard(brokerOpts.FirehosePolicy, brokerOpts.FirehoseTopics, brokerOpts.BufferSize, brokerOpts.FirehoseBackpressureThreshold, brokerOpts.FirehoseSampleRate)
+	}
+	if brokerOpts.SlowClientPolicy != SlowClientPolicyBlock || firehose != nil {
+		b.slowClientGuard = newSlowClientGuard(brokerOpts.SlowClientPolicy, brokerOpts.BufferSize, firehose, b.clientQueuedBytes, b.disconnectSlowClient)
+	}
+
+	if brokerOpts.OnClientConnect != nil || brokerOpts.OnClientDisconnect != nil || brokerOpts.OnMessagePublished != nil {
+		b.eventCallbacks = newEventCallbacks(brokerOpts.OnClientConnect, brokerOpts.OnClientDisconnect, brokerOpts.OnMessagePublished)
+	}
+
+	b.clientLimiter = clientLimiter
 
 	// bind the broker events to the topic manager to track the subscriptions
 	broker.Events.OnTopicSubscribe = func(filter string, client string, qos byte) {
-		t.Subscribe(filter)
+		b.touchClient(client)
+
+		// the topic manager tracks the topics without the prefix, filters outside of the namespace have no source
+		if topic, ok := unprefixTopic(topicPrefix, filter); ok {
+			t.Subscribe(topic)
+			if brokerOpts.OnClientSubscribe != nil {
+				brokerOpts.OnClientSubscribe(topic)
+			}
+		}
 	}
 
 	broker.Events.OnTopicUnsubscribe = func(filter string, client string) {
-		t.Unsubscribe(filter)
+		b.touchClient(client)
+		if topic, ok := unprefixTopic(topicPrefix, filter); ok {
+			t.Unsubscribe(topic)
+		}
 	}
 
-	return &Broker{
-		broker:       broker,
-		opts:         brokerOpts,
-		topicManager: t,
-	}, nil
+	broker.Events.OnMessage = func(cl events.Client, pk events.Packet) (events.Packet, error) {
+		b.touchClient(cl.ID)
+		return pk, nil
+	}
+
+	// successful connects and regular disconnects are sampled and logged as info,
+	// failures and evictions are always logged as warnings
+	connectLogSampler := newLogSampler(brokerOpts.ClientEventLogSampleRate)
+	disconnectLogSampler := newLogSampler(brokerOpts.ClientEventLogSampleRate)
+
+	broker.Events.OnConnect = func(cl events.Client, pk events.Packet) {
+		if b.clientLimiter != nil {
+			b.clientLimiter.ClientConnected(cl.ID)
+		}
+
+		if atomic.LoadUint32(b.shuttingDown) == 1 {
+			// the connection was accepted by the listener right before the shutdown started
+			if client, ok := broker.Clients.Get(cl.ID); ok {
+				client.Stop(ErrShuttingDown)
+			}
+			return
+		}
+
+		b.touchClient(cl.ID)
+		if b.messageExpirer != nil {
+			b.messageExpirer.Track(cl.ID)
+		}
+		if b.ackTimeoutMonitor != nil {
+			b.ackTimeoutMonitor.Add(cl.ID)
+		}
+		if b.eventCallbacks != nil {
+			b.eventCallbacks.ClientConnected(cl.ID, cl.Remote)
+		}
+		if connectLogSampler.Sample() {
+			log.Infof("client connected: %s (%s) on listener %s", cl.ID, cl.Remote, cl.Listener)
+		}
+	}
+
+	broker.Events.OnDisconnect = func(cl events.Client, err error) {
+		if b.idleConnectionReaper != nil {
+			b.idleConnectionReaper.Remove(cl.ID)
+		}
+		if b.ackTimeoutMonitor != nil {
+			b.ackTimeoutMonitor.Remove(cl.ID)
+		}
+		if b.messageBatcher != nil {
+			b.messageBatcher.Remove(cl.ID)
+		}
+		if b.slowClientGuard != nil {
+			b.slowClientGuard.Remove(cl.ID)
+		}
+		if b.eventCallbacks != nil {
+			b.eventCallbacks.ClientDisconnected(cl.ID, err)
+		}
+		b.unsubscribeCleanSession(cl.ID)
+
+		switch {
+		case isWriteTimeout(err):
+			b.publishEviction(cl.ID, EvictionReasonWriteTimeout)
+		case isReadTimeout(err):
+			b.publishEviction(cl.ID, EvictionReasonKeepAliveTimeout)
+		case errors.Is(err, ErrIdleConnection):
+			b.publishEviction(cl.ID, EvictionReasonIdle)
+		case errors.Is(err, ErrAckTimeout):
+			b.publishEviction(cl.ID, EvictionReasonAckTimeout)
+		case errors.Is(err, ErrSlowClient):
+			b.publishEviction(cl.ID, EvictionReasonSlowClient)
+		}
+
+		if err != nil {
+			log.Warnf("client disconnected: %s (%s), error: %s", cl.ID, cl.Remote, err)
+			return
+		}
+		if disconnectLogSampler.Sample() {
+			log.Infof("client disconnected: %s (%s)", cl.ID, cl.Remote)
+		}
+	}
+
+	broker.Events.OnError = func(cl events.Client, err error) {
+		log.Warnf("client error: %s (%s), error: %s", cl.ID, cl.Remote, err)
+	}
+
+	b.retainedManager = newRetainedManager(b.clearRetained, brokerOpts.MaxRetainedMessages)
+	if brokerOpts.RetainUpdateInterval > 0 {
+		b.retainedThrottler = newRetainedThrottler(brokerOpts.RetainUpdateInterval, b.publishRetained, b.sendWithoutRetain, b.updateRetained)
+	}
+
+	b.retainedStore = brokerOpts.RetainedStore
+	if b.retainedStore == nil && brokerOpts.RetainedStorePath != "" {
+		b.retainedStore = NewFileRetainedStore(brokerOpts.RetainedStorePath)
+	}
+	if b.retainedStore != nil {
+		b.restoreRetained()
+	}
+
+	if brokerOpts.HealthBindAddress != "" {
+		readyFunc := b.IsHealthy
+		if brokerOpts.HealthReadyFunc != nil {
+			readyFunc = func() bool {
+				return b.IsHealthy() && brokerOpts.HealthReadyFunc()
+			}
+		}
+
+		b.healthServer = newHealthServer(log, brokerOpts.HealthBindAddress, b.IsHealthy, readyFunc)
+	}
+
+	return b, nil
 }
 
 // Start the broker.
 func (b *Broker) Start() error {
-	return b.broker.Serve()
-}
+	if b.healthServer != nil {
+		// the probes fail until the broker is serving
+		if err := b.healthServer.Start(); err != nil {
+			return fmt.Errorf("starting health server failed: %w", err)
+		}
+	}
 
-// Stop the broker.
-func (b *Broker) Stop() error {
-	return b.broker.Close()
+	if b.subscriptionFilter != nil && b.onSubscribe != nil {
+		// the internal subscriptions are never removed, so the sources of the included topics stay alive
+		for _, pattern := range b.subscriptionFilter.Include {
+			b.log.Infof("subscribing internally to %s", pattern)
+			b.onSubscribe(pattern)
+		}
+	}
+
+	if b.idleConnectionReaper != nil {
+		b.idleConnectionReaper.Start()
+	}
+	if b.messageExpirer != nil {
+		b.messageExpirer.Start()
+	}
+	if b.ackTimeoutMonitor != nil {
+		b.ackTimeoutMonitor.Start()
+	}
+	if b.topicHookExecutor != nil {
+		b.topicHookExecutor.Start()
+	}
+	if b.slowClientGuard != nil {
+		b.slowClientGuard.Start()
+	}
+	if b.eventCallbacks != nil {
+		b.eventCallbacks.Start()
+	}
+	if b.bridge != nil {
+		if b.onSubscribe != nil {
+			// the bridge topics are never unsubscribed, so the messages are forwarded without local subscribers
+			for _, filter := range b.bridge.filters {
+				b.log.Infof("subscribing internally to %s for the bridge", filter)
+				b.onSubscribe(filter)
+			}
+		}
+		b.bridge.Start()
+	}
+
+	if err := b.broker.Serve(); err != nil {
+		return err
+	}
+	// the listeners are bound in NewBroker and serve in the background after Serve returned
+	atomic.StoreUint32(&b.serving, 1)
+	close(b.readyChan)
+
+	return nil
 }
 
-// SystemInfo returns the metrics of the broker.
-func (b *Broker) SystemInfo() *system.Info {
-	return b.broker.System
+// Ready returns a channel that is closed once all listeners are bound and serving.
+// Bind failures are returned by NewBroker and other startup failures by Start, in both cases the channel is never closed.
+func (b *Broker) Ready() <-chan struct{} {
+	return b.readyChan
 }
 
-func (b *Broker) HasSubscribers(topic string) bool {
-	return b.topicManager.hasSubscribers(topic)
+// IsHealthy returns true if the broker is serving and not shutting down.
+func (b *Broker) IsHealthy() bool {
+	return atomic.LoadUint32(&b.serving) == 1 && atomic.LoadUint32(b.shuttingDown) == 0
 }
 
-// Send publishes a message.
+// Stop the broker.
+func (b *Broker) Stop() error {
+	b.stopOnce.Do(func() {
+		atomic.StoreUint32(&b.serving, 0)
+		if b.idleConnectionReaper != nil {
+			b.idleConnectionReaper.Stop()
+		}
+		if b.messageExpirer != nil {
+			b.messageExpirer.Stop()
+		}
+		if b.ackTimeoutMonitor != nil {
+			b.ackTimeoutMonitor.Stop()
+		}
+		if b.publishCoalescer != nil {
+			b.publishCoalescer.Stop()
+		}
+		if b.messageBatcher != nil {
+			b.messageBatcher.Stop()
+		}
+		if b.topicHookExecutor != nil {
+			b.topicHookExecutor.Stop()
+		}
+		if b.slowClientGuard != nil {
+			b.slowClientGuard.Stop()
+		}
+		if b.bridge != nil {
+			b.bridge.Stop()
+		}
+		if b.retainedThrottler != nil {
+			b.retainedThrottler.Stop()
+		}
+		if b.retainedStore != nil {
+			b.persistRetained()
+		}
+		b.stopErr = b.broker.Close()
+		if b.eventCallbacks != nil {
+			// stopped after the broker, so the disconnects of the closed clients are passed to the callback
+			b.eventCallbacks.Stop()
+		}
+		if b.healthServer != nil {
+			b.healthServer.Stop()
+		}
+	})
+
+	return b.stopErr
+}
+
+// Shutdown stops the broker gracefully.
+// New connections are rejected and the pending batches are delivered immediately. Then it waits until the messages
+// queued for the connected clients were written and the in-flight messages were acknowledged, or until the context is done.
+// Afterwards the broker is stopped. It returns the amount of in-flight messages that were not acknowledged in time,
+// QoS 0 messages that were not written yet are not counted.
+func (b *Broker) Shutdown(ctx context.Context) (int, error) {
+	atomic.StoreUint32(b.shuttingDown, 1)
+
+	// the coalesced messages are flushed first, because they may be added to the batches
+	if b.publishCoalescer != nil {
+		b.publishCoalescer.FlushAll()
+	}
+	if b.messageBatcher != nil {
+		b.messageBatcher.FlushAll()
+	}
+
+	ticker := time.NewTicker(shutdownDrainCheckInterval)
+	defer ticker.Stop()
+
+	undelivered, drained := b.pendingMessages()
+	for !drained {
+		select {
+		case <-ctx.Done():
+			b.log.Warnf("shutting down with %d undelivered in-flight messages", undelivered)
+			return undelivered, b.Stop()
+		case <-ticker.C:
+			undelivered, drained = b.pendingMessages()
+		}
+	}
+
+	return 0, b.Stop()
+}
+
+// pendingMessages returns the amount of in-flight messages of the connected clients,
+// and whether all messages were written and acknowledged.
+func (b *Broker) pendingMessages() (int, bool) {
+	inflight := 0
+	drained := true
+
+	for _, listener := range b.listeners {
+		for _, client := range b.broker.Clients.GetByListener(listener.ID) {
+			if atomic.LoadUint32(&client.State.Done) == 1 {
+				continue
+			}
+
+			if n := client.Inflight.Len(); n > 0 {
+				inflight += n
+				drained = false
+			}
+
+			if tail, head := client.W.GetPos(); head != tail {
+				drained = false
+			}
+		}
+	}
+
+	return inflight, drained
+}
+
+// SystemInfo returns the metrics of the broker.
+func (b *Broker) SystemInfo() *system.Info {
+	return b.broker.System
+}
+
+// HasSubscribers returns true if a subscription of a client matches the topic, either exactly or with wildcards,
+// or if the topic is subscribed by the subscription filter or forwarded by the bridge.
+func (b *Broker) HasSubscribers(topic string) bool {
+	return b.topicManager.hasSubscribers(topic) || b.hasInternalSubscribers(topic)
+}
+
+// HasExactSubscribers is like HasSubscribers, but only subscriptions of clients with a topic filter
+// that equals the topic are taken into account, wildcard subscriptions of clients are not matched.
+func (b *Broker) HasExactSubscribers(topic string) bool {
+	return b.topicManager.hasExactSubscribers(topic) || b.hasInternalSubscribers(topic)
+}
+
+// hasInternalSubscribers returns true if the topic is subscribed by the subscription filter or forwarded by the bridge.
+func (b *Broker) hasInternalSubscribers(topic string) bool {
+	if b.subscriptionFilter != nil && b.subscriptionFilter.Matches(topic) {
+		return true
+	}
+
+	return b.bridge != nil && b.bridge.Matches(topic)
+}
+
+// Send publishes a message.
+// If publish options are configured for the topic, the message is published with these options.
+// Messages on coalesced topics are published at the end of the coalesce window, if no newer message replaced them.
 func (b *Broker) Send(topic string, payload []byte) error {
-	return b.broker.Publish(topic, payload, false)
+	if b.publishCoalescer != nil && b.publishCoalescer.Matches(topic) {
+		b.publishCoalescer.Add(topic, func() {
+			if err := b.send(topic, payload); err != nil {
+				b.log.Debugf("sending coalesced topic %s failed: %s", topic, err)
+			}
+		})
+		return nil
+	}
+
+	return b.send(topic, payload)
+}
+
+// send publishes a message immediately, see Send.
+func (b *Broker) send(topic string, payload []byte) error {
+	if publishOptions, has := b.opts.TopicPublishOptions[topic]; has {
+		return b.SendWithOptions(topic, payload, publishOptions.QoS, publishOptions.Retain)
+	}
+
+	b.trackPublish(topic, payload)
+
+	start := time.Now()
+	var err error
+	switch {
+	case strings.HasPrefix(topic, sysTopicPrefix):
+		err = b.sendSys(topic, payload)
+	case b.slowClientGuard != nil || b.opts.CloseEndedTopics:
+		// the underlying broker blocks on saturated clients, so the message is written directly to apply the slow client policy.
+		// The underlying broker also publishes asynchronously, so the last message of an ended topic could arrive
+		// after its subscriptions were removed.
+		// The subscribers receive the message with the QoS of their subscription, like from the underlying broker.
+		err = b.writeToSubscribers(topic, payload, 2)
+	default:
+		// the underlying broker publishes asynchronously, so only queueing the message is measured
+		err = b.broker.Publish(b.prefixTopic(topic), payload, false)
+	}
+	if err != nil {
+		return err
+	}
+	b.publishLatency.Observe(time.Since(start).Seconds())
+	b.afterPublish(topic, payload, 0, false)
+
+	return nil
+}
+
+// SendWithOptions publishes a message with the given QoS and optionally stores it as the retained message of the topic.
+// Following the MQTT spec, every subscriber receives the message with the lower QoS of the
+// publish QoS and its subscription. If a retain update interval is configured, the retained message
+// is refreshed at most once per interval like in SendRetained, while the message itself is still published every time.
+func (b *Broker) SendWithOptions(topic string, payload []byte, qos byte, retain bool) error {
+	if err := validateQoS(qos); err != nil {
+		return err
+	}
+
+	b.trackPublish(topic, payload)
+
+	if retain {
+		if err := b.retain(topic, payload); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	if err := b.writeToSubscribers(topic, payload, qos); err != nil {
+		return err
+	}
+	b.publishLatency.Observe(time.Since(start).Seconds())
+
+	b.afterPublish(topic, payload, qos, retain)
+
+	return nil
+}
+
+// sendWithoutRetain publishes a message like Send, but never updates the retained message of the topic.
+// It is used for the live publishes of the retain update throttler, which updates the retained message itself.
+func (b *Broker) sendWithoutRetain(topic string, payload []byte) error {
+	if publishOptions, has := b.opts.TopicPublishOptions[topic]; has {
+		return b.SendWithOptions(topic, payload, publishOptions.QoS, false)
+	}
+	return b.Send(topic, payload)
+}
+
+// writeToSubscribers writes a message to the subscribed clients directly,
+// every subscriber receives the message with the lower QoS of the given QoS and its subscription.
+func (b *Broker) writeToSubscribers(topic string, payload []byte, qos byte) error {
+	for clientID, subscriptionQoS := range b.broker.Topics.Subscribers(b.prefixTopic(topic)) {
+		deliveryQoS := qos
+		if subscriptionQoS < deliveryQoS {
+			deliveryQoS = subscriptionQoS
+		}
+
+		if err := b.writeToClient(clientID, topic, payload, deliveryQoS); err != nil {
+			if errors.Is(err, ErrSysTopicsNotReady) {
+				return err
+			}
+			b.log.Debugf("sending topic %s to client %s failed: %s", topic, clientID, err)
+		}
+	}
+
+	return nil
+}
+
+// trackPublish counts a message published on the topic for the throughput and the topic statistics,
+// and passes the publish event to the callback.
+func (b *Broker) trackPublish(topic string, payload []byte) {
+	b.throughputTracker.Track(topic)
+	b.topicManager.Published(topic)
+	if b.eventCallbacks != nil {
+		b.eventCallbacks.MessagePublished(topic, len(payload))
+	}
+}
+
+// prefixTopic returns the topic on the underlying broker.
+func (b *Broker) prefixTopic(topic string) string {
+	return prefixTopic(b.topicPrefix, topic)
+}
+
+// validateQoS checks that the QoS is 0, 1 or 2.
+func validateQoS(qos byte) error {
+	if qos > 2 {
+		return fmt.Errorf("%w %d, allowed values: 0, 1, 2", ErrInvalidQoS, qos)
+	}
+	return nil
+}
+
+// sendSys publishes a message on a system topic.
+// The underlying broker refuses to publish system topics via its public API,
+// so the packet is written to the subscribed clients directly (QoS 0).
+func (b *Broker) sendSys(topic string, payload []byte) error {
+	templates := b.broker.Topics.Messages(sysTopicTemplate)
+	if len(templates) == 0 {
+		return ErrSysTopicsNotReady
+	}
+
+	pk := templates[0].PublishCopy()
+	pk.FixedHeader.Retain = false
+	pk.TopicName = topic
+	pk.Payload = payload
+
+	for clientID := range b.broker.Topics.Subscribers(topic) {
+		client, ok := b.broker.Clients.Get(clientID)
+		if !ok {
+			continue
+		}
+
+		if err := b.writePacket(clientID, topic, pk.TopicName, pk.Payload, func() error {
+			_, err := client.WritePacket(pk)
+			return err
+		}); err != nil {
+			b.log.Debugf("sending system topic %s to client %s failed: %s", topic, clientID, err)
+		}
+	}
+
+	return nil
+}
+
+// SendDeduplicated publishes a message on all given topics, but every client receives the message at most once,
+// even if several of its subscriptions match the topics. The QoS of the first matching subscription is used.
+func (b *Broker) SendDeduplicated(topics []string, payload []byte) error {
+	return b.sendToSubscribers(topics, payload, true, false)
+}
+
+// SendBatchable publishes a JSON message on all given topics.
+// Clients that are subscribed to the batch delivery topic receive the message at most once as part of
+// a batch on the batch delivery topic instead. The other clients receive the message per matching subscription,
+// or at most once if deduplicate is set.
+func (b *Broker) SendBatchable(topics []string, payload []byte, deduplicate bool) error {
+	return b.sendToSubscribers(topics, payload, deduplicate, b.messageBatcher != nil)
+}
+
+// sendToSubscribers writes a message on all given topics to the subscribed clients directly.
+// The coalesced topics are passed to the publish coalescer and written at the end of the coalesce window.
+func (b *Broker) sendToSubscribers(topics []string, payload []byte, deduplicate bool, batch bool) error {
+	if b.publishCoalescer != nil {
+		immediateTopics := make([]string, 0, len(topics))
+		for _, topic := range topics {
+			if !b.publishCoalescer.Matches(topic) {
+				immediateTopics = append(immediateTopics, topic)
+				continue
+			}
+
+			topic := topic
+			b.publishCoalescer.Add(topic, func() {
+				if err := b.writeToTopicSubscribers([]string{topic}, payload, deduplicate, batch); err != nil {
+					b.log.Debugf("sending coalesced topic %s failed: %s", topic, err)
+				}
+			})
+		}
+		topics = immediateTopics
+	}
+
+	return b.writeToTopicSubscribers(topics, payload, deduplicate, batch)
+}
+
+// writeToTopicSubscribers writes a message on all given topics to the subscribed clients immediately, see sendToSubscribers.
+func (b *Broker) writeToTopicSubscribers(topics []string, payload []byte, deduplicate bool, batch bool) error {
+	delivered := make(map[string]struct{})
+	for _, topic := range topics {
+		b.trackPublish(topic, payload)
+
+		for clientID, qos := range b.broker.Topics.Subscribers(b.prefixTopic(topic)) {
+			if _, has := delivered[clientID]; has && (deduplicate || batch) {
+				continue
+			}
+
+			if batch && b.isSubscribedToBatchDeliveryTopic(clientID) {
+				// batched clients receive every message at most once
+				delivered[clientID] = struct{}{}
+				b.messageBatcher.Add(clientID, payload)
+				continue
+			}
+
+			if deduplicate {
+				delivered[clientID] = struct{}{}
+			}
+
+			if err := b.writeToClient(clientID, topic, payload, qos); err != nil {
+				if errors.Is(err, ErrSysTopicsNotReady) {
+					return err
+				}
+				b.log.Debugf("sending topic %s to client %s failed: %s", topic, clientID, err)
+			}
+		}
+
+		b.afterPublish(topic, payload, 0, false)
+	}
+
+	return nil
+}
+
+// isSubscribedToBatchDeliveryTopic returns true if the client wants to receive the messages in batches.
+func (b *Broker) isSubscribedToBatchDeliveryTopic(clientID string) bool {
+	client, ok := b.broker.Clients.Get(clientID)
+	if !ok {
+		return false
+	}
+
+	client.RLock()
+	defer client.RUnlock()
+
+	_, subscribed := client.Subscriptions[b.prefixTopic(b.opts.BatchDeliveryTopic)]
+	return subscribed
+}
+
+// deliverBatch writes the batched payload to the client on the batch delivery topic.
+func (b *Broker) deliverBatch(clientID string, payload []byte) {
+	client, ok := b.broker.Clients.Get(clientID)
+	if !ok {
+		return
+	}
+
+	client.RLock()
+	qos, subscribed := client.Subscriptions[b.prefixTopic(b.opts.BatchDeliveryTopic)]
+	client.RUnlock()
+
+	if !subscribed {
+		// the client unsubscribed in the meantime
+		return
+	}
+
+	b.trackPublish(b.opts.BatchDeliveryTopic, payload)
+
+	if err := b.writeToClient(clientID, b.opts.BatchDeliveryTopic, payload, qos); err != nil {
+		b.log.Debugf("sending batch to client %s failed: %s", clientID, err)
+	}
+}
+
+// writeToClient writes a message to the client with the given QoS.
+// QoS messages are tracked as in-flight, so they get redelivered like the messages published by the underlying broker.
+func (b *Broker) writeToClient(clientID string, topic string, payload []byte, qos byte) error {
+	templates := b.broker.Topics.Messages(sysTopicTemplate)
+	if len(templates) == 0 {
+		return ErrSysTopicsNotReady
+	}
+
+	client, ok := b.broker.Clients.Get(clientID)
+	if !ok {
+		return nil
+	}
+
+	pk := templates[0].PublishCopy()
+	pk.FixedHeader.Retain = false
+	pk.TopicName = b.prefixTopic(topic)
+	pk.Payload = payload
+
+	if qos > 0 {
+		pk.FixedHeader.Qos = qos
+		pk.PacketID = uint16(client.NextPacketID())
+
+		inflight, _ := client.Inflight.Get(pk.PacketID)
+		inflight.Packet = pk
+		inflight.Sent = time.Now().Unix()
+		inflight.Resends = 0
+		if client.Inflight.Set(pk.PacketID, inflight) {
+			atomic.AddInt64(&b.broker.System.Inflight, 1)
+		}
+	}
+
+	return b.writePacket(clientID, topic, pk.TopicName, pk.Payload, func() error {
+		_, err := client.WritePacket(pk)
+		return err
+	})
+}
+
+// writePacket writes a publish packet on the topic (without the prefix) to the client by calling writeFunc.
+// If a slow client policy is configured, it is applied if the packet doesn't fit into the outgoing buffer of the client.
+// If a firehose policy is configured, it is applied to the packets on the firehose topics if the client is under backpressure.
+func (b *Broker) writePacket(clientID string, topic string, topicName string, payload []byte, writeFunc func() error) error {
+	if b.slowClientGuard == nil {
+		return writeFunc()
+	}
+	return b.slowClientGuard.Write(clientID, topic, publishPacketOverhead+len(topicName)+len(payload), writeFunc)
+}
+
+// clientQueuedBytes returns the amount of bytes in the outgoing buffer of the client, false if the client is not connected.
+func (b *Broker) clientQueuedBytes(clientID string) (int, bool) {
+	client, ok := b.broker.Clients.Get(clientID)
+	if !ok || atomic.LoadUint32(&client.State.Done) != 0 || client.W == nil {
+		return 0, false
+	}
+	return client.W.CapDelta(), true
+}
+
+// disconnectSlowClient disconnects a client whose outgoing buffer is saturated.
+// It returns false if the client was already disconnected.
+func (b *Broker) disconnectSlowClient(clientID string) bool {
+	client, ok := b.broker.Clients.Get(clientID)
+	if !ok || atomic.LoadUint32(&client.State.Done) != 0 {
+		return false
+	}
+
+	client.Stop(ErrSlowClient)
+	return true
+}
+
+// SendRetained publishes a message and stores it as the retained message of the topic.
+// If the maximum amount of retained messages is exceeded, the retained messages
+// of the least recently updated topics are removed.
+// If a retain update interval is configured, the retained message is refreshed
+// at most once per interval, while the message itself is still published every time.
+func (b *Broker) SendRetained(topic string, payload []byte) error {
+	if b.retainedThrottler != nil {
+		return b.retainedThrottler.Send(topic, payload)
+	}
+	return b.publishRetained(topic, payload)
+}
+
+// publishRetained publishes a message and stores it as the retained message of the topic.
+func (b *Broker) publishRetained(topic string, payload []byte) error {
+	b.trackPublish(topic, payload)
+
+	if b.slowClientGuard != nil {
+		// the underlying broker blocks on saturated clients, so the message is written directly to apply the slow client policy
+		if err := b.updateRetained(topic, payload); err != nil {
+			return err
+		}
+		if err := b.writeToSubscribers(topic, payload, 2); err != nil {
+			return err
+		}
+		b.afterPublish(topic, payload, 0, true)
+
+		return nil
+	}
+
+	return b.retainedManager.Retain(topic, func() error {
+		if err := b.broker.Publish(b.prefixTopic(topic), payload, true); err != nil {
+			return err
+		}
+		b.afterPublish(topic, payload, 0, true)
+
+		return nil
+	})
+}
+
+// afterPublish queues the invocations of the topic hooks that match the published topic,
+// and forwards the message to the upstream broker if the topic is bridged.
+func (b *Broker) afterPublish(topic string, payload []byte, qos byte, retain bool) {
+	if b.topicHookExecutor != nil {
+		b.topicHookExecutor.Fire(topic, payload)
+	}
+	if b.bridge != nil {
+		b.bridge.Forward(topic, payload, qos, retain)
+	}
+}
+
+// retain stores a message as the retained message of the topic without publishing it to the subscribers,
+// throttled by the retain update interval if configured.
+func (b *Broker) retain(topic string, payload []byte) error {
+	if b.retainedThrottler != nil {
+		return b.retainedThrottler.Retain(topic, payload)
+	}
+	return b.updateRetained(topic, payload)
+}
+
+// updateRetained stores a message as the retained message of the topic without publishing it to the subscribers.
+func (b *Broker) updateRetained(topic string, payload []byte) error {
+	return b.retainedManager.Retain(topic, func() error {
+		templates := b.broker.Topics.Messages(sysTopicTemplate)
+		if len(templates) == 0 {
+			return ErrSysTopicsNotReady
+		}
+
+		pk := templates[0].PublishCopy()
+		pk.FixedHeader.Retain = true
+		pk.TopicName = b.prefixTopic(topic)
+		pk.Payload = payload
+		atomic.AddInt64(&b.broker.System.Retained, b.broker.Topics.RetainMessage(pk))
+
+		return nil
+	})
+}
+
+// clearRetained removes the retained message of a topic without publishing an empty message to the subscribers.
+func (b *Broker) clearRetained(topic string) {
+	for _, pk := range b.broker.Topics.Messages(b.prefixTopic(topic)) {
+		// an empty payload removes the retained message
+		pk.Payload = nil
+		atomic.AddInt64(&b.broker.System.Retained, b.broker.Topics.RetainMessage(pk))
+	}
+}
+
+// restoreRetained stores the retained messages of the retained store in the underlying broker.
+// It is called before the broker serves, so new subscribers receive the restored messages immediately.
+// If the store can't be loaded, the broker starts without retained messages.
+func (b *Broker) restoreRetained() {
+	messages, err := b.retainedStore.Load()
+	if err != nil {
+		b.log.Warnf("restoring retained messages failed, starting without retained messages: %s", err)
+		return
+	}
+
+	if b.opts.MaxRetainedMessages > 0 && len(messages) > b.opts.MaxRetainedMessages {
+		// only the most recently updated topics are restored, so no topic is evicted during the restore
+		messages = messages[len(messages)-b.opts.MaxRetainedMessages:]
+	}
+
+	// the system topic template is not available before the broker serves, so the first message is published
+	// to the underlying broker (there are no subscribers yet) and its retained packet is the template for the others
+	var templateTopic string
+	var restored int
+	for _, message := range messages {
+		if message == nil || message.Topic == "" || strings.HasPrefix(message.Topic, sysTopicPrefix) || len(message.Payload) == 0 {
+			continue
+		}
+
+		if err := b.retainedManager.Retain(message.Topic, func() error {
+			if templateTopic == "" {
+				if err := b.broker.Publish(b.prefixTopic(message.Topic), message.Payload, true); err != nil {
+					return err
+				}
+				templateTopic = b.prefixTopic(message.Topic)
+
+				return nil
+			}
+
+			templates := b.broker.Topics.Messages(templateTopic)
+			if len(templates) == 0 {
+				return fmt.Errorf("retained message of topic \"%s\" not found", templateTopic)
+			}
+
+			pk := templates[0].PublishCopy()
+			pk.FixedHeader.Retain = true
+			pk.TopicName = b.prefixTopic(message.Topic)
+			pk.Payload = message.Payload
+			atomic.AddInt64(&b.broker.System.Retained, b.broker.Topics.RetainMessage(pk))
+
+			return nil
+		}); err != nil {
+			b.log.Warnf("restoring retained message of topic \"%s\" failed: %s", message.Topic, err)
+			continue
+		}
+		restored++
+	}
+
+	b.log.Infof("restored %d retained messages", restored)
+}
+
+// persistRetained saves the retained messages of the underlying broker in the retained store.
+func (b *Broker) persistRetained() {
+	topics := b.retainedManager.Topics()
+
+	messages := make([]*RetainedMessage, 0, len(topics))
+	for _, topic := range topics {
+		retained := b.broker.Topics.Messages(b.prefixTopic(topic))
+		if len(retained) == 0 {
+			continue
+		}
+
+		messages = append(messages, &RetainedMessage{
+			Topic:   topic,
+			Payload: retained[0].Payload,
+		})
+	}
+
+	if err := b.retainedStore.Save(messages); err != nil {
+		b.log.Warnf("persisting retained messages failed: %s", err)
+		return
+	}
+
+	b.log.Infof("persisted %d retained messages", len(messages))
+}
+
+// checkAckTimeouts retransmits the messages the client did not acknowledge within the ACK timeout.
+// If a message exceeded the maximum amount of retransmissions, the client is disconnected and true is returned.
+func (b *Broker) checkAckTimeouts(clientID string) bool {
+	client, ok := b.broker.Clients.Get(clientID)
+	if !ok || atomic.LoadUint32(&client.State.Done) != 0 {
+		return false
+	}
+
+	now := time.Now()
+
+	// the inflight map of the underlying broker is only copied under its lock,
+	// since it is modified concurrently by the publishes and acknowledgements
+	client.Inflight.RLock()
+	inflightPacketIDs := make([]uint16, 0, len(client.Inflight.GetAll()))
+	for packetID := range client.Inflight.GetAll() {
+		inflightPacketIDs = append(inflightPacketIDs, packetID)
+	}
+	client.Inflight.RUnlock()
+
+	for _, packetID := range inflightPacketIDs {
+		inflight, ok := client.Inflight.Get(packetID)
+		if !ok || now.Sub(time.Unix(inflight.Sent, 0)) < b.opts.AckTimeout {
+			continue
+		}
+
+		if inflight.Resends >= b.opts.AckTimeoutMaxRetransmissions {
+			client.Stop(ErrAckTimeout)
+			return true
+		}
+
+		if inflight.Packet.TopicName != "" {
+			// only publish packets have a topic and are flagged as duplicates
+			inflight.Packet.FixedHeader.Dup = true
+		}
+		inflight.Resends++
+		inflight.Sent = now.Unix()
+		client.Inflight.Set(packetID, inflight)
+
+		if _, err := client.WritePacket(inflight.Packet); err != nil {
+			b.log.Debugf("retransmitting message %d to client %s failed: %s", packetID, clientID, err)
+		}
+	}
+
+	return false
+}
+
+// AckTimeoutDisconnects returns the amount of clients that were disconnected because they did not acknowledge messages.
+func (b *Broker) AckTimeoutDisconnects() uint64 {
+	if b.ackTimeoutMonitor == nil {
+		return 0
+	}
+	return b.ackTimeoutMonitor.AckTimeoutDisconnects()
+}
+
+// ReloadTLSCertificate reloads the certificate and private key of the TCP listener from the configured files.
+// New connections use the new certificate, existing connections stay connected.
+// If the new certificate fails to load, the previous certificate is kept and the error is returned.
+func (b *Broker) ReloadTLSCertificate() error {
+	if b.tlsCertificate == nil {
+		return ErrTLSNotEnabled
+	}
+
+	if err := b.tlsCertificate.Load(); err != nil {
+		return fmt.Errorf("reloading TCP TLS certificate failed: %w", err)
+	}
+
+	b.log.Info("reloaded TCP TLS certificate")
+	return nil
+}
+
+// ReloadUsers replaces the users and the password salt of the TCP listeners that authenticate users.
+// New connections are authenticated with the new users, existing connections stay connected.
+// If the new users are invalid, the previous users are kept and the error is returned.
+func (b *Broker) ReloadUsers(users map[string]string, passwordSaltHex string) error {
+	if b.tcpUsersAuth == nil {
+		return ErrUsersAuthNotEnabled
+	}
+
+	if err := b.tcpUsersAuth.ReplaceUsers(passwordSaltHex, users); err != nil {
+		return fmt.Errorf("reloading TCP auth users failed: %w", err)
+	}
+
+	b.log.Infof("reloaded TCP auth users (%d users)", len(users))
+	return nil
+}
+
+// Listeners returns the active listeners of the broker.
+func (b *Broker) Listeners() []ListenerInfo {
+	listeners := make([]ListenerInfo, 0, len(b.listeners))
+	for _, listener := range b.listeners {
+		info := *listener
+		if info.AuthMode == ListenerAuthModeUsers && b.tcpUsersAuth != nil {
+			// the users may have been reloaded since the listener was added
+			info.AuthUsers = b.tcpUsersAuth.UsersCount()
+		}
+		listeners = append(listeners, info)
+	}
+	return listeners
+}
+
+// Clients returns the connected clients of the broker and their subscriptions, sorted by the client ID.
+// The subscriptions are the topic filters of the application, the topic prefix is removed.
+func (b *Broker) Clients() []ClientInfo {
+	infos := make([]ClientInfo, 0)
+	for _, listener := range b.listeners {
+		for _, client := range b.broker.Clients.GetByListener(listener.ID) {
+			client.RLock()
+			subscriptions := make([]string, 0, len(client.Subscriptions))
+			for filter := range client.Subscriptions {
+				topic, _ := unprefixTopic(b.topicPrefix, filter)
+				subscriptions = append(subscriptions, topic)
+			}
+			client.RUnlock()
+			sort.Strings(subscriptions)
+
+			infos = append(infos, ClientInfo{
+				ID:            client.ID,
+				Listener:      client.Listener,
+				Subscriptions: subscriptions,
+			})
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+
+	return infos
+}
+
+// ThroughputStats returns the published messages per second per topic category over the configured time windows.
+func (b *Broker) ThroughputStats() []*ThroughputWindowStats {
+	return b.throughputTracker.Stats()
+}
+
+// RetainedTopicsSize returns the amount of topics the broker stores a retained message for.
+func (b *Broker) RetainedTopicsSize() int {
+	return b.retainedManager.Size()
 }
 
 // TopicsManagerSize returns the size of the underlying map of the topics manager.
 func (b *Broker) TopicsManagerSize() int {
 	return b.topicManager.Size()
 }
+
+// TopicStats returns the current subscriber count and the published messages of every subscribed topic.
+// The map is a snapshot, so it can be read without blocking the broker.
+func (b *Broker) TopicStats() map[string]TopicStat {
+	return b.topicManager.Stats()
+}
+
+// RejectedSubscriptions returns the amount of subscriptions that were rejected because the topics manager reached its maximum size.
+func (b *Broker) RejectedSubscriptions() uint64 {
+	return b.topicManager.RejectedSubscriptions()
+}
+
+// DroppedTopicHookInvocations returns the amount of topic hook invocations that were dropped because the queue was full.
+func (b *Broker) DroppedTopicHookInvocations() uint64 {
+	if b.topicHookExecutor == nil {
+		return 0
+	}
+	return b.topicHookExecutor.DroppedInvocations()
+}
+
+// FailedTopicHookInvocations returns the amount of topic hook invocations that returned an error or panicked.
+func (b *Broker) FailedTopicHookInvocations() uint64 {
+	if b.topicHookExecutor == nil {
+		return 0
+	}
+	return b.topicHookExecutor.FailedInvocations()
+}
+
+// DroppedBridgeMessages returns the amount of messages that were not forwarded to the upstream broker because the queue was full.
+func (b *Broker) DroppedBridgeMessages() uint64 {
+	if b.bridge == nil {
+		return 0
+	}
+	return b.bridge.DroppedMessages()
+}
+
+// SlowClientDroppedMessages returns the amount of messages that were dropped because the outgoing buffer of the client was saturated.
+func (b *Broker) SlowClientDroppedMessages() uint64 {
+	if b.slowClientGuard == nil {
+		return 0
+	}
+	return b.slowClientGuard.DroppedMessages()
+}
+
+// FirehoseDroppedMessages returns the amount of messages on the firehose topics that were dropped because the client was under backpressure.
+func (b *Broker) FirehoseDroppedMessages() uint64 {
+	if b.slowClientGuard == nil {
+		return 0
+	}
+	return b.slowClientGuard.FirehoseDroppedMessages()
+}
+
+// SlowClientDisconnects returns the amount of clients that were disconnected because their outgoing buffer was saturated.
+func (b *Broker) SlowClientDisconnects() uint64 {
+	if b.slowClientGuard == nil {
+		return 0
+	}
+	return b.slowClientGuard.DisconnectedClients()
+}
+
+// DroppedEventCallbacks returns the amount of events that were not passed to the callbacks because the queue was full.
+func (b *Broker) DroppedEventCallbacks() uint64 {
+	if b.eventCallbacks == nil {
+		return 0
+	}
+	return b.eventCallbacks.DroppedEvents()
+}
+
+// CoalescedMessages returns the amount of messages that were not published because a newer message
+// on the same topic replaced them within the coalesce window.
+func (b *Broker) CoalescedMessages() uint64 {
+	if b.publishCoalescer == nil {
+		return 0
+	}
+	return b.publishCoalescer.CoalescedMessages()
+}
+
+// FailedBridgeMessages returns the amount of messages that could not be forwarded to the upstream broker.
+func (b *Broker) FailedBridgeMessages() uint64 {
+	if b.bridge == nil {
+		return 0
+	}
+	return b.bridge.FailedMessages()
+}
+
+// OfflineQueuedBridgeMessages returns the amount of messages in the offline queue of the bridge.
+func (b *Broker) OfflineQueuedBridgeMessages() int {
+	if b.bridge == nil {
+		return 0
+	}
+	return b.bridge.OfflineQueuedMessages()
+}
+
+// OfflineDroppedBridgeMessages returns the amount of messages that were dropped because the offline queue of the bridge was full.
+func (b *Broker) OfflineDroppedBridgeMessages() uint64 {
+	if b.bridge == nil {
+		return 0
+	}
+	return b.bridge.OfflineDroppedMessages()
+}
+
+// touchClient marks the client as active for the idle connection reaper.
+func (b *Broker) touchClient(clientID string) {
+	if b.idleConnectionReaper != nil {
+		b.idleConnectionReaper.Touch(clientID)
+	}
+}
+
+// reapIdleClient disconnects the client if it has no subscriptions.
+// It returns true if the client was disconnected.
+func (b *Broker) reapIdleClient(clientID string) bool {
+	client, ok := b.broker.Clients.Get(clientID)
+	if !ok {
+		return false
+	}
+
+	client.RLock()
+	subscriptions := len(client.Subscriptions)
+	client.RUnlock()
+
+	if subscriptions > 0 {
+		return false
+	}
+
+	client.Stop(ErrIdleConnection)
+	return true
+}
+
+// unsubscribeCleanSession removes all subscriptions of a disconnected client with a clean session.
+// The underlying broker only removes them once a client with the same ID connects again,
+// so the topic manager would keep counting the subscribers of clients that are gone.
+func (b *Broker) unsubscribeCleanSession(clientID string) {
+	client, ok := b.broker.Clients.Get(clientID)
+	// a client that connected with the same ID already replaced the disconnected client
+	if !ok || !client.CleanSession || atomic.LoadUint32(&client.State.Done) == 0 {
+		return
+	}
+
+	client.Lock()
+	filters := make([]string, 0, len(client.Subscriptions))
+	for filter := range client.Subscriptions {
+		filters = append(filters, filter)
+		delete(client.Subscriptions, filter)
+	}
+	client.Unlock()
+
+	for _, filter := range filters {
+		b.removeSubscription(clientID, filter)
+	}
+}
+
+// removeSubscription removes the subscription of the client on the given (prefixed) filter from the topic index,
+// and updates the subscription counts. The subscription must already be deleted from the client.
+func (b *Broker) removeSubscription(clientID string, filter string) {
+	if !b.broker.Topics.Unsubscribe(filter, clientID) {
+		return
+	}
+	atomic.AddInt64(&b.broker.System.Subscriptions, -1)
+
+	if topic, ok := unprefixTopic(b.topicPrefix, filter); ok {
+		b.topicManager.Unsubscribe(topic)
+	}
+}
+
+// OnSourceEnded is called by the application if nothing will be published on the topic anymore,
+// e.g. because the output of the topic was spent. If CloseEndedTopics is enabled, the clients that subscribed
+// to exactly this topic receive the ended topic message (if configured) and are unsubscribed from the topic.
+// Subscriptions with wildcards that match the topic are kept, because they still receive messages of other topics.
+func (b *Broker) OnSourceEnded(topic string) {
+	if !b.opts.CloseEndedTopics || !b.topicManager.hasExactSubscribers(topic) {
+		return
+	}
+
+	if b.publishCoalescer != nil {
+		// the last message of the topic is delivered before the subscriptions are removed
+		b.publishCoalescer.Flush(topic)
+	}
+
+	filter := b.prefixTopic(topic)
+
+	unsubscribed := 0
+	for clientID := range b.broker.Topics.Subscribers(filter) {
+		client, ok := b.broker.Clients.Get(clientID)
+		if !ok {
+			continue
+		}
+
+		client.RLock()
+		qos, subscribed := client.Subscriptions[filter]
+		client.RUnlock()
+
+		if !subscribed {
+			// the client only matches the topic with a wildcard subscription
+			continue
+		}
+
+		if b.opts.EndedTopicMessage != nil && atomic.LoadUint32(&client.State.Done) == 0 {
+			if err := b.writeToClient(clientID, topic, b.opts.EndedTopicMessage, qos); err != nil {
+				b.log.Debugf("sending ended topic %s to client %s failed: %s", topic, clientID, err)
+			}
+		}
+
+		client.Lock()
+		_, subscribed = client.Subscriptions[filter]
+		delete(client.Subscriptions, filter)
+		client.Unlock()
+
+		if !subscribed {
+			// the client unsubscribed in the meantime
+			continue
+		}
+
+		b.removeSubscription(clientID, filter)
+		unsubscribed++
+	}
+
+	if unsubscribed > 0 {
+		b.log.Debugf("unsubscribed %d clients from ended topic %s", unsubscribed, topic)
+	}
+}
+
+// ClientLimitConnectionIPs returns the amount of remote IPs with open connections.
+// The IPs are only tracked if the maximum amount of connections per IP is set.
+func (b *Broker) ClientLimitConnectionIPs() int {
+	if b.clientLimiter == nil {
+		return 0
+	}
+	return b.clientLimiter.ConnectionIPs()
+}
+
+// ClientLimitRejectedClients returns the amount of connections that were refused because the maximum amount of clients was connected.
+func (b *Broker) ClientLimitRejectedClients() uint64 {
+	if b.clientCap == nil {
+		return 0
+	}
+	return b.clientCap.RejectedClients()
+}
+
+// ClientLimitRejectedConnections returns the amount of connections that were rejected because the remote IP had too many connections.
+func (b *Broker) ClientLimitRejectedConnections() uint64 {
+	if b.clientLimiter == nil {
+		return 0
+	}
+	return b.clientLimiter.RejectedConnections()
+}
+
+// ClientLimitRejectedSubscriptions returns the amount of subscriptions that were rejected because the client had too many subscriptions.
+func (b *Broker) ClientLimitRejectedSubscriptions() uint64 {
+	if b.clientLimiter == nil {
+		return 0
+	}
+	return b.clientLimiter.RejectedSubscriptions()
+}
+
+// ClientLimitRejectedMessages returns the amount of published messages that were dropped because the client exceeded the message rate.
+func (b *Broker) ClientLimitRejectedMessages() uint64 {
+	if b.clientLimiter == nil {
+		return 0
+	}
+	return b.clientLimiter.RejectedMessages()
+}
+
+// ReapedConnections returns the amount of idle connections that were disconnected by the idle connection reaper.
+func (b *Broker) ReapedConnections() uint64 {
+	if b.idleConnectionReaper == nil {
+		return 0
+	}
+	return b.idleConnectionReaper.ReapedConnections()
+}
+
+// expireQueuedMessages drops the queued messages of the client that exceeded the expiry of their topic.
+// It returns the amount of dropped messages and whether the client still has a persistent session.
+func (b *Broker) expireQueuedMessages(clientID string) (int, bool) {
+	client, ok := b.broker.Clients.Get(clientID)
+	if !ok || client.CleanSession {
+		return 0, false
+	}
+
+	now := time.Now()
+
+	// the inflight map of the underlying broker is only copied under its lock,
+	// since it is modified by the retransmissions on reconnects
+	client.Inflight.RLock()
+	inflightPacketIDs := make(map[uint16]struct{}, len(client.Inflight.GetAll()))
+	for packetID := range client.Inflight.GetAll() {
+		inflightPacketIDs[packetID] = struct{}{}
+	}
+	client.Inflight.RUnlock()
+	b.messageExpirer.ForgetAcknowledged(clientID, inflightPacketIDs)
+
+	expired := 0
+	for packetID := range inflightPacketIDs {
+		inflight, ok := client.Inflight.Get(packetID)
+		if !ok {
+			continue
+		}
+
+		// the expiries are configured for the topics without the prefix
+		topic, _ := unprefixTopic(b.topicPrefix, inflight.Packet.TopicName)
+		expiry, has := b.messageExpirer.ExpiryForTopic(topic)
+		if !has {
+			continue
+		}
+
+		// the sent time is reset on every retransmission, so the expiry is measured from the time the message was queued
+		queued := b.messageExpirer.QueuedSince(clientID, packetID, inflight.Packet.TopicName, inflight.Sent, inflight.Resends)
+		if now.Sub(time.Unix(queued, 0)) < expiry {
+			continue
+		}
+
+		if client.Inflight.Delete(packetID) {
+			atomic.AddInt64(&b.broker.System.Inflight, -1)
+			expired++
+		}
+		b.messageExpirer.ForgetMessage(clientID, packetID)
+	}
+
+	return expired, true
+}
+
+// ExpiredMessages returns the amount of queued messages that were dropped because they expired.
+func (b *Broker) ExpiredMessages() uint64 {
+	if b.messageExpirer == nil {
+		return 0
+	}
+	return b.messageExpirer.ExpiredMessages()
+}
@@ -0,0 +1,75 @@
+package mqtt
+
+import (
+	"errors"
+	"syscall"
+)
+
+// The kinds of the errors returned by NewBroker, they can be checked with errors.Is.
+// ErrNoListenerEnabled, ErrTLSConfig and ErrAuthConfig are configuration errors and also match ErrInvalidConfig.
+var (
+	// ErrInvalidConfig is returned if the broker options are invalid.
+	ErrInvalidConfig = errors.New("invalid broker config")
+	// ErrNoListenerEnabled is returned if no listener is enabled in the broker options.
+	ErrNoListenerEnabled = errors.New("no listener enabled")
+	// ErrTLSConfig is returned if the TLS settings are invalid, or the certificates can't be loaded.
+	ErrTLSConfig = errors.New("invalid TLS config")
+	// ErrAuthConfig is returned if the auth settings are invalid, or the users, keys or ACL can't be loaded.
+	ErrAuthConfig = errors.New("invalid auth config")
+	// ErrBindAddressInUse is returned if a listener can't be bound because its address is in use.
+	// Unlike the configuration errors, this error may be transient.
+	ErrBindAddressInUse = errors.New("bind address in use")
+	// ErrListenerFailed is returned if a listener can't be bound for any other reason.
+	ErrListenerFailed = errors.New("listener failed")
+)
+
+// BrokerError is an error returned by NewBroker.
+// The message is the message of the wrapped error, the cause can be accessed with errors.As or errors.Unwrap.
+type BrokerError struct {
+	// Kind is one of the error kinds above.
+	Kind error
+	// Err is the error with the human-readable message and the cause.
+	Err error
+}
+
+// Error returns the message of the wrapped error.
+func (e *BrokerError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *BrokerError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if the target is the kind of the error,
+// or if the target is ErrInvalidConfig and the kind is a configuration error.
+func (e *BrokerError) Is(target error) bool {
+	if target == e.Kind {
+		return true
+	}
+
+	if target == ErrInvalidConfig {
+		switch e.Kind {
+		case ErrNoListenerEnabled, ErrTLSConfig, ErrAuthConfig:
+			return true
+		}
+	}
+
+	return false
+}
+
+// configError returns the error of invalid broker options with the given kind.
+func configError(kind error, err error) error {
+	return &BrokerError{Kind: kind, Err: err}
+}
+
+// listenerError returns the error of a listener that can't be bound.
+func listenerError(err error) error {
+	kind := ErrListenerFailed
+	if errors.Is(err, syscall.EADDRINUSE) {
+		kind = ErrBindAddressInUse
+	}
+
+	return &BrokerError{Kind: kind, Err: err}
+}
@@ -1,23 +1,235 @@
 package mqtt
 
+import (
+	"time"
+)
+
 // BrokerOptions are options around the broker.
 type BrokerOptions struct {
 	// BufferSize is the size of the client buffers in bytes.
+	// The underlying broker allocates the buffers of all clients from a single pool,
+	// so the buffer sizes apply to the clients of all listeners and can't be set per listener.
 	BufferSize int
 	// BufferBlockSize is the size per client buffer R/W block in bytes.
 	BufferBlockSize int
+	// SlowClientPolicy defines what happens to messages for a client whose outgoing buffer (of the buffer size) is saturated.
+	// The policy applies to all messages published by this broker on the application and system topics, regardless of their QoS.
+	// QoS 0 messages that are dropped are lost. QoS 1 and 2 messages are tracked as in-flight before they are dropped,
+	// so they are redelivered by the ACK timeout retransmissions or when the client resumes its session.
+	// The retained messages the underlying broker sends to new subscribers are not covered by the policy.
+	SlowClientPolicy SlowClientPolicy
+	// FirehosePolicy defines what happens to messages on the firehose topics for a client under backpressure.
+	// The firehose topics (e.g. "messages") carry every message of the node, so they are degraded first under load:
+	// a client is under backpressure if its outgoing buffer is filled above the backpressure threshold,
+	// the firehose messages for the client are then dropped or sampled, independent of the slow client policy.
+	// The remaining buffer is kept for the other topics, so their subscribers are protected over the firehose subscribers
+	// and stay fully served, until the buffer is saturated and the slow client policy applies to all messages.
+	FirehosePolicy FirehosePolicy
+	// FirehoseTopics are the topic filters of the firehose topics (without the topic prefix).
+	FirehoseTopics []string
+	// FirehoseBackpressureThreshold is the fill level of the outgoing buffer of a client in percent,
+	// above which the firehose policy applies (1-100).
+	FirehoseBackpressureThreshold int
+	// FirehoseSampleRate is the ratio of the firehose messages that are delivered to a client under backpressure
+	// by the sample policy, e.g. 10 delivers every 10th message.
+	FirehoseSampleRate int
 	// TopicCleanupThreshold the number of deleted topics that trigger a garbage collection of the topic manager.
 	TopicCleanupThreshold int
+	// MaxTopicManagerSize is the maximum amount of distinct subscribed topics (0 = unlimited).
+	// Subscriptions to new topics beyond are rejected with a SUBACK failure, subscriptions to existing topics are still accepted.
+	// This is a last-resort guard against running out of memory because of a runaway subscription cardinality.
+	MaxTopicManagerSize int
+	// TopicPrefix is the namespace the topics are published in, e.g. "mainnet" publishes "mainnet/milestones/latest" (optional).
+	// Subscriptions are tracked without the prefix, subscriptions outside of the namespace don't reach the topic manager.
+	// System topics are never prefixed. The rules of the ACL file match the prefixed topics, as they are seen by the clients.
+	TopicPrefix string
+
+	// MaxClients is the maximum amount of concurrently connected clients across all listeners (0 = unlimited).
+	// Connections beyond are refused before they are authenticated with the CONNACK return code 0x03 (server unavailable).
+	MaxClients int
+	// MaxConnectionsPerIP is the maximum amount of connections per remote IP (0 = unlimited).
+	// Connections beyond are refused before they are authenticated with the CONNACK return code 0x03 (server unavailable).
+	MaxConnectionsPerIP int
+	// MaxSubscriptionsPerClient is the maximum amount of subscriptions per client (0 = unlimited).
+	// Subscriptions beyond are refused with a SUBACK failure.
+	MaxSubscriptionsPerClient int
+	// MaxMessagesPerSecondPerClient is the maximum rate of messages a client can publish, excess messages are dropped (0 = unlimited).
+	MaxMessagesPerSecondPerClient int
+	// MaxRetainedMessages is the maximum amount of retained messages the broker stores (0 = unlimited).
+	// If the limit is exceeded, the retained messages of the least recently updated topics are removed.
+	// Topics with a high cardinality like "outputs/{outputId}" or "message-metadata/{messageId}"
+	// are the most likely to hit the cap if they are published as retained messages.
+	MaxRetainedMessages int
+	// RetainUpdateInterval is the minimum interval between updates of the retained message of a topic (0 = disabled).
+	// The messages are still published to the subscribers on every update, but the retained message
+	// may lag behind the live messages by up to the interval.
+	RetainUpdateInterval time.Duration
+	// RetainedStorePath is the path to the file the retained messages are persisted in across restarts (optional).
+	// The retained messages are saved on shutdown and restored in NewBroker before the broker serves.
+	// If the file is corrupt or unreadable, a warning is logged and the broker starts without retained messages.
+	RetainedStorePath string
+	// RetainedStore persists the retained messages across restarts (optional).
+	// It takes precedence over the RetainedStorePath, which uses a file based store.
+	RetainedStore RetainedStore
+	// TopicPublishOptions are the QoS and retain options per topic the messages are published with.
+	// Messages on topics without options are published with QoS 0 and without retain,
+	// which means that subscribers receive them with the QoS of their subscription.
+	TopicPublishOptions map[string]*PublishOptions
+	// SubscriptionFilterFilePath is the path to a JSON file with include and exclude topic filters
+	// that are subscribed internally by the broker (optional).
+	// Exclude patterns take precedence over include patterns.
+	SubscriptionFilterFilePath string
+	// SubscriptionValidator checks the topic filters of client subscriptions (without the topic prefix) before they are tracked (optional).
+	// Subscriptions it returns an error for are rejected with a SUBACK failure and never reach the subscribe handler.
+	SubscriptionValidator func(topic string) error
+	// OnClientSubscribe is called for every accepted subscription of a client (without the topic prefix), while the
+	// subscribe handler of the broker is only called for the first subscriber of a topic (optional).
+	// It is called synchronously by the broker, so it must not block.
+	OnClientSubscribe OnSubscribeHandler
+
+	// MessageExpiry is the expiry per topic prefix of the messages that are queued for clients, the most specific prefix wins.
+	// Expired messages are dropped, so reconnecting clients do not receive outdated events.
+	// The expiry only applies to the inflight list of persistent sessions, i.e. QoS > 0 messages for clients that connected
+	// without clean session and did not acknowledge them yet. Messages for clean sessions, QoS 0 messages and retained messages never expire.
+	// The expiry is enforced by the broker, it is not sent to the clients as a MQTT 5.0 message expiry interval.
+	MessageExpiry map[string]time.Duration
+
+	// AckTimeout is the duration after which a QoS message that was not acknowledged by a connected client is retransmitted.
+	AckTimeout time.Duration
+	// AckTimeoutMaxRetransmissions is the amount of retransmissions of an unacknowledged message
+	// after which the client is treated as dead and disconnected (0 = disabled).
+	AckTimeoutMaxRetransmissions int
+
+	// ThroughputWindows are the sliding time windows over which the publish rates per topic category are tracked.
+	// The windows must be multiples of one second and must not exceed one hour.
+	ThroughputWindows []time.Duration
+
+	// ClientEventLogSampleRate defines that only one out of every N successful connects and regular disconnects
+	// of clients is logged as info (1 = log every event).
+	// Disconnects with errors, client errors and evictions are never sampled out and are logged as warnings.
+	ClientEventLogSampleRate int
+
+	// BatchDeliveryTopic is the topic on which clients receive the messages of their other subscriptions
+	// coalesced into batches ("" = disabled). Only messages sent with SendBatchable are batched.
+	// This changes the delivery semantics for the subscribed clients: every message is delivered at most once
+	// as part of a JSON array, instead of once per matching subscription, so clients need to support batches.
+	BatchDeliveryTopic string
+	// BatchWindow is the time window in which the messages for a client are coalesced into one batch.
+	BatchWindow time.Duration
+	// BatchMaxSize is the maximum amount of messages in a batch, full batches are delivered immediately.
+	BatchMaxSize int
+
+	// PublishCoalesceWindow is the time window in which the messages on a coalesced topic are buffered,
+	// only the latest message of the window is published (0 = disabled).
+	PublishCoalesceWindow time.Duration
+	// PublishCoalesceTopics are the topic filters of the topics that represent a current state and are coalesced.
+	// Messages on other topics are published immediately. Coalesced messages are published per topic,
+	// so they are not deduplicated with the messages of other topics.
+	PublishCoalesceTopics []string
+
+	// TopicHooks are called asynchronously after a message was published on a topic that matches their topic filter.
+	// The hooks are fire-and-forget, they run on a bounded worker pool and never block the publish path.
+	// If the queue is full, invocations are dropped, failed invocations are not retried.
+	TopicHooks []*TopicHook
+	// TopicHookWorkers is the amount of workers that call the topic hooks.
+	TopicHookWorkers int
+	// TopicHookQueueSize is the maximum amount of queued topic hook invocations.
+	TopicHookQueueSize int
+
+	// BridgeEnabled defines whether to forward the messages published on the bridge topics to an upstream MQTT broker.
+	// The bridge topics are subscribed internally, so their sources stay alive without local subscribers.
+	BridgeEnabled bool
+	// BridgeURL is the URL of the upstream MQTT broker (e.g. tcp://example.com:1883, ssl://example.com:8883 or ws://example.com:1888).
+	BridgeURL string
+	// BridgeUsername is the username used to connect to the upstream MQTT broker (optional).
+	BridgeUsername string
+	// BridgePassword is the password used to connect to the upstream MQTT broker (optional).
+	BridgePassword string
+	// BridgeTopics are the MQTT topic filters (wildcards allowed) of the topics that are forwarded to the upstream MQTT broker.
+	BridgeTopics []string
+	// BridgeOfflineQueuePath is the directory of the offline queue, which keeps the forwarded messages on disk
+	// while the upstream MQTT broker is unreachable (empty = disabled).
+	// The queue is flushed in order after the connection was reestablished, with at-least-once delivery of QoS > 0 messages.
+	BridgeOfflineQueuePath string
+	// BridgeOfflineQueueMaxSize is the maximum size of the messages in the offline queue in bytes.
+	BridgeOfflineQueueMaxSize int64
+	// BridgeOfflineQueueOverflowPolicy defines whether the oldest or the newest messages are dropped if the offline queue is full.
+	BridgeOfflineQueueOverflowPolicy BridgeOverflowPolicy
+
+	// OnClientConnect is called after a client connected successfully (optional).
+	// The event callbacks are called in order on a separate goroutine, so they never block the broker.
+	// If the callbacks can't keep up, events are dropped.
+	OnClientConnect ClientConnectFunc
+	// OnClientDisconnect is called after a client disconnected (optional).
+	OnClientDisconnect ClientDisconnectFunc
+	// OnMessagePublished is called after the broker published a message on a topic (optional).
+	OnMessagePublished MessagePublishedFunc
+
+	// HealthBindAddress is the bind address of the HTTP server for liveness ("/health") and readiness ("/ready") probes ("" = disabled).
+	HealthBindAddress string
+	// HealthReadyFunc reports whether the source of the published messages is ready (optional).
+	// The broker is only ready if it is healthy and the function returns true.
+	HealthReadyFunc func() bool
+
+	// IdleConnectionReaperEnabled defines whether to disconnect clients without subscriptions that are idle for too long.
+	IdleConnectionReaperEnabled bool
+	// IdleConnectionTimeout is the duration after which a client without subscriptions and without any activity
+	// (connect, subscribe, unsubscribe, publish) is disconnected. Keepalive pings do not count as activity.
+	IdleConnectionTimeout time.Duration
+	// IdleConnectionCheckInterval is the interval in which the connections are checked for being idle.
+	IdleConnectionCheckInterval time.Duration
+
+	// MaxKeepAlive is the maximum keep-alive of the clients (0 = no limit). Clients that negotiated a longer or no keep-alive
+	// are disconnected after 1.5 times this duration without any incoming traffic, like clients that exceed their own keep-alive.
+	MaxKeepAlive time.Duration
+	// ReadIdleTimeout is the maximum duration without any incoming traffic from a client (0 = no limit).
+	// Unlike the IdleConnectionTimeout of the idle connection reaper, this also disconnects clients with subscriptions, pings count as traffic.
+	ReadIdleTimeout time.Duration
+
+	// CloseEndedTopics defines whether the clients subscribed to a topic are unsubscribed from it,
+	// once the application reports that nothing will be published on the topic anymore (see Broker.OnSourceEnded).
+	CloseEndedTopics bool
+	// EndedTopicMessage is the message that is sent to the unsubscribed clients on the ended topic (nil = no message).
+	EndedTopicMessage []byte
 
 	// WebsocketEnabled defines whether to enable the websocket connection of the MQTT broker.
 	WebsocketEnabled bool
 	// WebsocketBindAddress the websocket bind address on which the MQTT broker listens on.
 	WebsocketBindAddress string
 
+	// WebsocketTLSEnabled defines whether to enable the secure websocket (wss) connection of the MQTT broker.
+	WebsocketTLSEnabled bool
+	// WebsocketTLSBindAddress the secure websocket bind address on which the MQTT broker listens on.
+	WebsocketTLSBindAddress string
+	// WebsocketTLSCertificatePath is the path to the certificate file (x509 PEM) for secure websocket connections.
+	WebsocketTLSCertificatePath string
+	// WebsocketTLSPrivateKeyPath is the path to the private key file (x509 PEM) for secure websocket connections.
+	WebsocketTLSPrivateKeyPath string
+	// WebsocketCompressionEnabled defines whether the permessage-deflate compression is negotiated with the websocket clients
+	// (websocket and secure websocket). Clients that don't support the compression receive uncompressed messages.
+	WebsocketCompressionEnabled bool
+	// WebsocketCompressionLevel is the flate compression level of the messages written to the websocket clients
+	// (-2 = huffman only, 0 = no compression, 1 = best speed to 9 = best compression).
+	WebsocketCompressionLevel int
+
 	// TCPEnabled defines whether to enable the TCP connection of the MQTT broker.
 	TCPEnabled bool
 	// TCPBindAddress the TCP bind address on which the MQTT broker listens on.
 	TCPBindAddress string
+	// TCPProxyProtocolEnabled defines whether the TCP connections start with a PROXY protocol (v1 or v2) header
+	// that contains the real client address, e.g. if the broker runs behind a load balancer.
+	// Connections without a valid header are rejected.
+	TCPProxyProtocolEnabled bool
+	// TCPListeners are additional TCP listeners on other bind addresses, e.g. a plaintext listener without auth
+	// on an internal interface next to a public listener with TLS and auth (optional).
+	// They share the TCP auth and TLS settings, but enable them separately.
+	TCPListeners []*TCPListenerConfig
+
+	// UnixSocketEnabled defines whether to enable the unix socket connection of the MQTT broker.
+	// The unix socket connection uses the same auth settings as the TCP connection.
+	UnixSocketEnabled bool
+	// UnixSocketPath is the path of the unix socket on which the MQTT broker listens on.
+	UnixSocketPath string
 
 	// TCPAuthEnabled defines whether to enable auth for TCP connections.
 	TCPAuthEnabled bool
@@ -25,6 +237,15 @@ type BrokerOptions struct {
 	TCPAuthPasswordSalt string
 	// TCPAuthUsers is the list of allowed users with their password+salt as a scrypt hash.
 	TCPAuthUsers map[string]string
+	// TCPAuthACLFilePath is the path to a JSON file with the per-user permissions of the TCP users (optional).
+	TCPAuthACLFilePath string
+	// TCPAuthJWTKeyPath is the path to a file with the PEM encoded public key or the HMAC secret the JWTs of the clients are verified with.
+	// If set, clients authenticate with the username "jwt" and a JWT as password instead of the users (optional).
+	TCPAuthJWTKeyPath string
+	// TCPAuthJWTIssuer is the issuer that must match the "iss" claim of the JWTs.
+	TCPAuthJWTIssuer string
+	// TCPAuthJWTAudience is the audience that must be contained in the "aud" claim of the JWTs.
+	TCPAuthJWTAudience string
 
 	// TCPTLSEnabled defines whether to enable TLS for TCP connections.
 	TCPTLSEnabled bool
@@ -32,22 +253,115 @@ type BrokerOptions struct {
 	TCPTLSCertificatePath string
 	// TCPTLSPrivateKeyPath is the path to the private key file (x509 PEM) for TCP connections with TLS.
 	TCPTLSPrivateKeyPath string
+	// TCPTLSClientAuthEnabled defines whether TCP clients with TLS need to present a certificate signed by one of the client CAs.
+	TCPTLSClientAuthEnabled bool
+	// TCPTLSClientCAPath is the path to the file with the CA certificates (x509 PEM) the client certificates are verified with.
+	TCPTLSClientCAPath string
+}
+
+// TCPListenerConfig defines an additional TCP listener of the broker.
+type TCPListenerConfig struct {
+	// BindAddress is the TCP bind address on which the listener listens on.
+	BindAddress string
+	// AuthEnabled defines whether the clients of the listener authenticate with the TCP auth settings.
+	AuthEnabled bool
+	// TLSEnabled defines whether the listener uses TLS with the TCP TLS settings.
+	TLSEnabled bool
+	// ProxyProtocolEnabled defines whether the connections of the listener start with a PROXY protocol header.
+	ProxyProtocolEnabled bool
+}
+
+// PublishOptions define how the messages of a topic are published.
+type PublishOptions struct {
+	// QoS is the maximum QoS the messages are delivered with (0, 1 or 2).
+	QoS byte
+	// Retain defines whether the last message is stored as the retained message of the topic.
+	// Retained messages are sent to new subscribers with QoS 0 by the underlying broker.
+	Retain bool
 }
 
 var defaultBrokerOpts = []BrokerOption{
 	WithBufferSize(0),
 	WithBufferBlockSize(0),
+	WithSlowClientPolicy(SlowClientPolicyBlock),
+	WithFirehosePolicy(FirehosePolicyNone),
+	WithFirehoseTopics(nil),
+	WithFirehoseBackpressureThreshold(50),
+	WithFirehoseSampleRate(10),
 	WithTopicCleanupThreshold(10000),
+	WithMaxTopicManagerSize(0),
+	WithTopicPrefix(""),
+	WithMaxClients(0),
+	WithMaxConnectionsPerIP(0),
+	WithMaxSubscriptionsPerClient(0),
+	WithMaxMessagesPerSecondPerClient(0),
+	WithMaxRetainedMessages(10000),
+	WithRetainUpdateInterval(0),
+	WithRetainedStorePath(""),
+	WithRetainedStore(nil),
+	WithTopicPublishOptions(map[string]*PublishOptions{}),
+	WithSubscriptionFilterFilePath(""),
+	WithSubscriptionValidator(nil),
+	WithOnClientSubscribe(nil),
+	WithMessageExpiry(map[string]time.Duration{}),
+	WithAckTimeout(10 * time.Second),
+	WithAckTimeoutMaxRetransmissions(0),
+	WithThroughputWindows([]time.Duration{1 * time.Minute, 5 * time.Minute, 15 * time.Minute}),
+	WithClientEventLogSampleRate(1),
+	WithBatchDeliveryTopic(""),
+	WithBatchWindow(1 * time.Second),
+	WithBatchMaxSize(100),
+	WithPublishCoalesceWindow(0),
+	WithPublishCoalesceTopics(nil),
+	WithTopicHooks(nil),
+	WithTopicHookWorkers(4),
+	WithTopicHookQueueSize(1000),
+	WithBridgeEnabled(false),
+	WithBridgeURL(""),
+	WithBridgeUsername(""),
+	WithBridgePassword(""),
+	WithBridgeTopics(nil),
+	WithBridgeOfflineQueuePath(""),
+	WithBridgeOfflineQueueMaxSize(64 * 1024 * 1024),
+	WithBridgeOfflineQueueOverflowPolicy(BridgeOverflowPolicyDropOldest),
+	WithOnClientConnect(nil),
+	WithOnClientDisconnect(nil),
+	WithOnMessagePublished(nil),
+	WithHealthBindAddress(""),
+	WithHealthReadyFunc(nil),
+	WithIdleConnectionReaperEnabled(false),
+	WithIdleConnectionTimeout(5 * time.Minute),
+	WithIdleConnectionCheckInterval(30 * time.Second),
+	WithMaxKeepAlive(0),
+	WithReadIdleTimeout(0),
+	WithCloseEndedTopics(false),
+	WithEndedTopicMessage(nil),
 	WithWebsocketEnabled(true),
 	WithWebsocketBindAddress("localhost:1888"),
+	WithWebsocketTLSEnabled(false),
+	WithWebsocketTLSBindAddress("localhost:1889"),
+	WithWebsocketTLSCertificatePath(""),
+	WithWebsocketTLSPrivateKeyPath(""),
+	WithWebsocketCompressionEnabled(false),
+	WithWebsocketCompressionLevel(1),
 	WithTCPEnabled(false),
 	WithTCPBindAddress("localhost:1883"),
+	WithTCPProxyProtocolEnabled(false),
+	WithTCPListeners(nil),
+	WithUnixSocketEnabled(false),
+	WithUnixSocketPath(""),
 	WithTCPAuthEnabled(false),
 	WithTCPAuthPasswordSalt("0000000000000000000000000000000000000000000000000000000000000000"),
 	WithTCPAuthUsers(map[string]string{}),
+	WithTCPAuthACLFilePath(""),
+	WithTCPAuthJWTKeyPath(""),
+	WithTCPAuthJWTIssuer(""),
+	WithTCPAuthJWTAudience(""),
 	WithTCPTLSEnabled(false),
 	WithTCPTLSCertificatePath(""),
 	WithTCPTLSPrivateKeyPath(""),
+	WithTCPTLSClientAuthEnabled(false),
+	WithTCPTLSClientCAPath(""),
 }
 
 // applies the given BrokerOption.
@@ -80,6 +394,41 @@ func WithBufferBlockSize(bufferBlockSize int) BrokerOption {
 	}
 }
 
+// WithSlowClientPolicy sets what happens to messages for a client whose outgoing buffer is saturated.
+func WithSlowClientPolicy(slowClientPolicy SlowClientPolicy) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.SlowClientPolicy = slowClientPolicy
+	}
+}
+
+// WithFirehosePolicy sets what happens to messages on the firehose topics for a client under backpressure.
+func WithFirehosePolicy(firehosePolicy FirehosePolicy) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.FirehosePolicy = firehosePolicy
+	}
+}
+
+// WithFirehoseTopics sets the topic filters of the firehose topics.
+func WithFirehoseTopics(firehoseTopics []string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.FirehoseTopics = firehoseTopics
+	}
+}
+
+// WithFirehoseBackpressureThreshold sets the fill level of the outgoing buffer in percent above which the firehose policy applies.
+func WithFirehoseBackpressureThreshold(firehoseBackpressureThreshold int) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.FirehoseBackpressureThreshold = firehoseBackpressureThreshold
+	}
+}
+
+// WithFirehoseSampleRate sets the ratio of the firehose messages that are delivered to a client under backpressure.
+func WithFirehoseSampleRate(firehoseSampleRate int) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.FirehoseSampleRate = firehoseSampleRate
+	}
+}
+
 // WithTopicCleanupThreshold sets the number of deleted topics that trigger a garbage collection of the topic manager.
 func WithTopicCleanupThreshold(topicCleanupThreshold int) BrokerOption {
 	return func(options *BrokerOptions) {
@@ -87,6 +436,335 @@ func WithTopicCleanupThreshold(topicCleanupThreshold int) BrokerOption {
 	}
 }
 
+// WithMaxTopicManagerSize sets the maximum amount of distinct subscribed topics.
+func WithMaxTopicManagerSize(maxTopicManagerSize int) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.MaxTopicManagerSize = maxTopicManagerSize
+	}
+}
+
+// WithTopicPrefix sets the namespace the topics are published in.
+func WithTopicPrefix(topicPrefix string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.TopicPrefix = topicPrefix
+	}
+}
+
+// WithMaxClients sets the maximum amount of concurrently connected clients across all listeners.
+func WithMaxClients(maxClients int) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.MaxClients = maxClients
+	}
+}
+
+// WithMaxConnectionsPerIP sets the maximum amount of connections per remote IP.
+func WithMaxConnectionsPerIP(maxConnectionsPerIP int) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.MaxConnectionsPerIP = maxConnectionsPerIP
+	}
+}
+
+// WithMaxSubscriptionsPerClient sets the maximum amount of subscriptions per client.
+func WithMaxSubscriptionsPerClient(maxSubscriptionsPerClient int) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.MaxSubscriptionsPerClient = maxSubscriptionsPerClient
+	}
+}
+
+// WithMaxMessagesPerSecondPerClient sets the maximum rate of messages a client can publish.
+func WithMaxMessagesPerSecondPerClient(maxMessagesPerSecondPerClient int) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.MaxMessagesPerSecondPerClient = maxMessagesPerSecondPerClient
+	}
+}
+
+// WithMaxRetainedMessages sets the maximum amount of retained messages the broker stores (0 = unlimited).
+func WithMaxRetainedMessages(maxRetainedMessages int) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.MaxRetainedMessages = maxRetainedMessages
+	}
+}
+
+// WithRetainUpdateInterval sets the minimum interval between updates of the retained message of a topic (0 = disabled).
+func WithRetainUpdateInterval(retainUpdateInterval time.Duration) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.RetainUpdateInterval = retainUpdateInterval
+	}
+}
+
+// WithRetainedStorePath sets the path to the file the retained messages are persisted in across restarts.
+func WithRetainedStorePath(retainedStorePath string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.RetainedStorePath = retainedStorePath
+	}
+}
+
+// WithRetainedStore sets the store the retained messages are persisted in across restarts.
+func WithRetainedStore(retainedStore RetainedStore) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.RetainedStore = retainedStore
+	}
+}
+
+// WithTopicPublishOptions sets the QoS and retain options per topic the messages are published with.
+func WithTopicPublishOptions(topicPublishOptions map[string]*PublishOptions) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.TopicPublishOptions = topicPublishOptions
+	}
+}
+
+// WithSubscriptionFilterFilePath sets the path to a JSON file with topic filters that are subscribed internally by the broker.
+func WithSubscriptionFilterFilePath(subscriptionFilterFilePath string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.SubscriptionFilterFilePath = subscriptionFilterFilePath
+	}
+}
+
+// WithSubscriptionValidator sets the function that checks the topic filters of client subscriptions.
+func WithSubscriptionValidator(subscriptionValidator func(topic string) error) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.SubscriptionValidator = subscriptionValidator
+	}
+}
+
+// WithOnClientSubscribe sets the function that is called for every accepted subscription of a client.
+func WithOnClientSubscribe(onClientSubscribe OnSubscribeHandler) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.OnClientSubscribe = onClientSubscribe
+	}
+}
+
+// WithMessageExpiry sets the expiry per topic prefix of the messages that are queued for clients.
+func WithMessageExpiry(messageExpiry map[string]time.Duration) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.MessageExpiry = messageExpiry
+	}
+}
+
+// WithAckTimeout sets the duration after which an unacknowledged QoS message is retransmitted.
+func WithAckTimeout(ackTimeout time.Duration) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.AckTimeout = ackTimeout
+	}
+}
+
+// WithAckTimeoutMaxRetransmissions sets the amount of retransmissions after which a client that does not acknowledge a message is disconnected.
+func WithAckTimeoutMaxRetransmissions(ackTimeoutMaxRetransmissions int) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.AckTimeoutMaxRetransmissions = ackTimeoutMaxRetransmissions
+	}
+}
+
+// WithThroughputWindows sets the sliding time windows over which the publish rates per topic category are tracked.
+func WithThroughputWindows(throughputWindows []time.Duration) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.ThroughputWindows = throughputWindows
+	}
+}
+
+// WithClientEventLogSampleRate sets that only one out of every N successful connects and regular disconnects is logged.
+func WithClientEventLogSampleRate(clientEventLogSampleRate int) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.ClientEventLogSampleRate = clientEventLogSampleRate
+	}
+}
+
+// WithBatchDeliveryTopic sets the topic on which clients receive their messages coalesced into batches.
+func WithBatchDeliveryTopic(batchDeliveryTopic string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.BatchDeliveryTopic = batchDeliveryTopic
+	}
+}
+
+// WithBatchWindow sets the time window in which the messages for a client are coalesced into one batch.
+func WithBatchWindow(batchWindow time.Duration) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.BatchWindow = batchWindow
+	}
+}
+
+// WithBatchMaxSize sets the maximum amount of messages in a batch.
+func WithBatchMaxSize(batchMaxSize int) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.BatchMaxSize = batchMaxSize
+	}
+}
+
+// WithPublishCoalesceWindow sets the time window in which the messages on a coalesced topic are buffered.
+func WithPublishCoalesceWindow(publishCoalesceWindow time.Duration) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.PublishCoalesceWindow = publishCoalesceWindow
+	}
+}
+
+// WithPublishCoalesceTopics sets the topic filters of the topics that are coalesced.
+func WithPublishCoalesceTopics(publishCoalesceTopics []string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.PublishCoalesceTopics = publishCoalesceTopics
+	}
+}
+
+// WithTopicHooks sets the hooks that are called after a message was published on a matching topic.
+func WithTopicHooks(topicHooks []*TopicHook) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.TopicHooks = topicHooks
+	}
+}
+
+// WithTopicHookWorkers sets the amount of workers that call the topic hooks.
+func WithTopicHookWorkers(topicHookWorkers int) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.TopicHookWorkers = topicHookWorkers
+	}
+}
+
+// WithTopicHookQueueSize sets the maximum amount of queued topic hook invocations.
+func WithTopicHookQueueSize(topicHookQueueSize int) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.TopicHookQueueSize = topicHookQueueSize
+	}
+}
+
+// WithBridgeEnabled sets whether to forward the messages published on the bridge topics to an upstream MQTT broker.
+func WithBridgeEnabled(bridgeEnabled bool) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.BridgeEnabled = bridgeEnabled
+	}
+}
+
+// WithBridgeURL sets the URL of the upstream MQTT broker.
+func WithBridgeURL(bridgeURL string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.BridgeURL = bridgeURL
+	}
+}
+
+// WithBridgeUsername sets the username used to connect to the upstream MQTT broker.
+func WithBridgeUsername(bridgeUsername string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.BridgeUsername = bridgeUsername
+	}
+}
+
+// WithBridgePassword sets the password used to connect to the upstream MQTT broker.
+func WithBridgePassword(bridgePassword string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.BridgePassword = bridgePassword
+	}
+}
+
+// WithBridgeTopics sets the topic filters of the topics that are forwarded to the upstream MQTT broker.
+func WithBridgeTopics(bridgeTopics []string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.BridgeTopics = bridgeTopics
+	}
+}
+
+// WithBridgeOfflineQueuePath sets the directory of the offline queue of the bridge.
+func WithBridgeOfflineQueuePath(bridgeOfflineQueuePath string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.BridgeOfflineQueuePath = bridgeOfflineQueuePath
+	}
+}
+
+// WithBridgeOfflineQueueMaxSize sets the maximum size of the messages in the offline queue of the bridge in bytes.
+func WithBridgeOfflineQueueMaxSize(bridgeOfflineQueueMaxSize int64) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.BridgeOfflineQueueMaxSize = bridgeOfflineQueueMaxSize
+	}
+}
+
+// WithBridgeOfflineQueueOverflowPolicy sets whether the oldest or the newest messages are dropped if the offline queue of the bridge is full.
+func WithBridgeOfflineQueueOverflowPolicy(bridgeOfflineQueueOverflowPolicy BridgeOverflowPolicy) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.BridgeOfflineQueueOverflowPolicy = bridgeOfflineQueueOverflowPolicy
+	}
+}
+
+// WithOnClientConnect sets the callback that is called after a client connected successfully.
+func WithOnClientConnect(onClientConnect ClientConnectFunc) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.OnClientConnect = onClientConnect
+	}
+}
+
+// WithOnClientDisconnect sets the callback that is called after a client disconnected.
+func WithOnClientDisconnect(onClientDisconnect ClientDisconnectFunc) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.OnClientDisconnect = onClientDisconnect
+	}
+}
+
+// WithOnMessagePublished sets the callback that is called after the broker published a message on a topic.
+func WithOnMessagePublished(onMessagePublished MessagePublishedFunc) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.OnMessagePublished = onMessagePublished
+	}
+}
+
+// WithHealthBindAddress sets the bind address of the HTTP server for liveness and readiness probes.
+func WithHealthBindAddress(healthBindAddress string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.HealthBindAddress = healthBindAddress
+	}
+}
+
+// WithHealthReadyFunc sets the function that reports whether the source of the published messages is ready.
+func WithHealthReadyFunc(healthReadyFunc func() bool) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.HealthReadyFunc = healthReadyFunc
+	}
+}
+
+// WithIdleConnectionReaperEnabled sets whether to disconnect clients without subscriptions that are idle for too long.
+func WithIdleConnectionReaperEnabled(idleConnectionReaperEnabled bool) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.IdleConnectionReaperEnabled = idleConnectionReaperEnabled
+	}
+}
+
+// WithIdleConnectionTimeout sets the duration after which an idle client without subscriptions is disconnected.
+func WithIdleConnectionTimeout(idleConnectionTimeout time.Duration) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.IdleConnectionTimeout = idleConnectionTimeout
+	}
+}
+
+// WithIdleConnectionCheckInterval sets the interval in which the connections are checked for being idle.
+func WithIdleConnectionCheckInterval(idleConnectionCheckInterval time.Duration) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.IdleConnectionCheckInterval = idleConnectionCheckInterval
+	}
+}
+
+// WithMaxKeepAlive sets the maximum keep-alive of the clients.
+func WithMaxKeepAlive(maxKeepAlive time.Duration) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.MaxKeepAlive = maxKeepAlive
+	}
+}
+
+// WithReadIdleTimeout sets the maximum duration without any incoming traffic from a client.
+func WithReadIdleTimeout(readIdleTimeout time.Duration) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.ReadIdleTimeout = readIdleTimeout
+	}
+}
+
+// WithCloseEndedTopics sets whether the clients subscribed to an ended topic are unsubscribed from it.
+func WithCloseEndedTopics(closeEndedTopics bool) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.CloseEndedTopics = closeEndedTopics
+	}
+}
+
+// WithEndedTopicMessage sets the message that is sent to the unsubscribed clients on an ended topic.
+func WithEndedTopicMessage(endedTopicMessage []byte) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.EndedTopicMessage = endedTopicMessage
+	}
+}
+
 // WithWebsocketEnabled sets whether to enable the websocket connection of the MQTT broker.
 func WithWebsocketEnabled(websocketEnabled bool) BrokerOption {
 	return func(options *BrokerOptions) {
@@ -101,6 +779,48 @@ func WithWebsocketBindAddress(websocketBindAddress string) BrokerOption {
 	}
 }
 
+// WithWebsocketTLSEnabled sets whether to enable the secure websocket (wss) connection of the MQTT broker.
+func WithWebsocketTLSEnabled(websocketTlsEnabled bool) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.WebsocketTLSEnabled = websocketTlsEnabled
+	}
+}
+
+// WithWebsocketTLSBindAddress sets the secure websocket bind address on which the MQTT broker listens on.
+func WithWebsocketTLSBindAddress(websocketTlsBindAddress string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.WebsocketTLSBindAddress = websocketTlsBindAddress
+	}
+}
+
+// WithWebsocketTLSCertificatePath sets the path to the certificate file (x509 PEM) for secure websocket connections.
+func WithWebsocketTLSCertificatePath(websocketTlsCertificatePath string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.WebsocketTLSCertificatePath = websocketTlsCertificatePath
+	}
+}
+
+// WithWebsocketTLSPrivateKeyPath sets the path to the private key file (x509 PEM) for secure websocket connections.
+func WithWebsocketTLSPrivateKeyPath(websocketTlsPrivateKeyPath string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.WebsocketTLSPrivateKeyPath = websocketTlsPrivateKeyPath
+	}
+}
+
+// WithWebsocketCompressionEnabled sets whether the permessage-deflate compression is negotiated with the websocket clients.
+func WithWebsocketCompressionEnabled(websocketCompressionEnabled bool) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.WebsocketCompressionEnabled = websocketCompressionEnabled
+	}
+}
+
+// WithWebsocketCompressionLevel sets the flate compression level of the messages written to the websocket clients.
+func WithWebsocketCompressionLevel(websocketCompressionLevel int) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.WebsocketCompressionLevel = websocketCompressionLevel
+	}
+}
+
 // WithTCPEnabled sets whether to enable the TCP connection of the MQTT broker.
 func WithTCPEnabled(tcpEnabled bool) BrokerOption {
 	return func(options *BrokerOptions) {
@@ -115,6 +835,34 @@ func WithTCPBindAddress(tcpBindAddress string) BrokerOption {
 	}
 }
 
+// WithTCPProxyProtocolEnabled sets whether the TCP connections start with a PROXY protocol header.
+func WithTCPProxyProtocolEnabled(tcpProxyProtocolEnabled bool) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.TCPProxyProtocolEnabled = tcpProxyProtocolEnabled
+	}
+}
+
+// WithTCPListeners sets the additional TCP listeners on other bind addresses.
+func WithTCPListeners(tcpListeners []*TCPListenerConfig) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.TCPListeners = tcpListeners
+	}
+}
+
+// WithUnixSocketEnabled sets whether to enable the unix socket connection of the MQTT broker.
+func WithUnixSocketEnabled(unixSocketEnabled bool) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.UnixSocketEnabled = unixSocketEnabled
+	}
+}
+
+// WithUnixSocketPath sets the path of the unix socket on which the MQTT broker listens on.
+func WithUnixSocketPath(unixSocketPath string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.UnixSocketPath = unixSocketPath
+	}
+}
+
 // WithTCPAuthEnabled sets whether to enable auth for TCP connections.
 func WithTCPAuthEnabled(tcpAuthEnabled bool) BrokerOption {
 	return func(options *BrokerOptions) {
@@ -136,6 +884,34 @@ func WithTCPAuthUsers(tcpAuthUsers map[string]string) BrokerOption {
 	}
 }
 
+// WithTCPAuthACLFilePath sets the path to a JSON file with the per-user permissions of the TCP users.
+func WithTCPAuthACLFilePath(tcpAuthACLFilePath string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.TCPAuthACLFilePath = tcpAuthACLFilePath
+	}
+}
+
+// WithTCPAuthJWTKeyPath sets the path to a file with the public key or the secret the JWTs of the clients are verified with.
+func WithTCPAuthJWTKeyPath(tcpAuthJWTKeyPath string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.TCPAuthJWTKeyPath = tcpAuthJWTKeyPath
+	}
+}
+
+// WithTCPAuthJWTIssuer sets the issuer that must match the "iss" claim of the JWTs.
+func WithTCPAuthJWTIssuer(tcpAuthJWTIssuer string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.TCPAuthJWTIssuer = tcpAuthJWTIssuer
+	}
+}
+
+// WithTCPAuthJWTAudience sets the audience that must be contained in the "aud" claim of the JWTs.
+func WithTCPAuthJWTAudience(tcpAuthJWTAudience string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.TCPAuthJWTAudience = tcpAuthJWTAudience
+	}
+}
+
 // WithTCPTLSEnabled sets whether to enable TLS for TCP connections.
 func WithTCPTLSEnabled(tcpTlsEnabled bool) BrokerOption {
 	return func(options *BrokerOptions) {
@@ -156,3 +932,17 @@ func WithTCPTLSPrivateKeyPath(tcpTlsPrivateKeyPath string) BrokerOption {
 		options.TCPTLSPrivateKeyPath = tcpTlsPrivateKeyPath
 	}
 }
+
+// WithTCPTLSClientAuthEnabled sets whether TCP clients with TLS need to present a valid client certificate.
+func WithTCPTLSClientAuthEnabled(tcpTlsClientAuthEnabled bool) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.TCPTLSClientAuthEnabled = tcpTlsClientAuthEnabled
+	}
+}
+
+// WithTCPTLSClientCAPath sets the path to the file with the CA certificates (x509 PEM) the client certificates are verified with.
+func WithTCPTLSClientCAPath(tcpTlsClientCAPath string) BrokerOption {
+	return func(options *BrokerOptions) {
+		options.TCPTLSClientCAPath = tcpTlsClientCAPath
+	}
+}
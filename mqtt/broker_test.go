@@ -0,0 +1,186 @@
+package mqtt
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+
+	"github.com/iotaledger/hive.go/logger"
+)
+
+// testTimeout is the maximum time the tests wait for an operation of a client or the broker.
+const testTimeout = 5 * time.Second
+
+// freeAddress returns a local TCP address that is currently not in use.
+func freeAddress(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("finding a free port failed: %s", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	return listener.Addr().String()
+}
+
+// newTestBrokerWithOptions creates and starts a broker with the given options, the broker is stopped at the end of the test.
+func newTestBrokerWithOptions(t *testing.T, opts ...BrokerOption) *Broker {
+	t.Helper()
+
+	brokerOpts := &BrokerOptions{}
+	brokerOpts.ApplyOnDefault(append([]BrokerOption{WithWebsocketEnabled(false)}, opts...)...)
+
+	broker, err := NewBroker(logger.NewNopLogger(), func(string) {}, func(string) {}, brokerOpts)
+	if err != nil {
+		t.Fatalf("creating broker failed: %s", err)
+	}
+	if err := broker.Start(); err != nil {
+		t.Fatalf("starting broker failed: %s", err)
+	}
+	t.Cleanup(func() { _ = broker.Stop() })
+
+	return broker
+}
+
+// newTestBroker creates and starts a broker with a TCP listener on a free local port and returns the broker and the address.
+func newTestBroker(t *testing.T, opts ...BrokerOption) (*Broker, string) {
+	t.Helper()
+
+	address := freeAddress(t)
+	broker := newTestBrokerWithOptions(t, append([]BrokerOption{WithTCPEnabled(true), WithTCPBindAddress(address)}, opts...)...)
+
+	return broker, address
+}
+
+// newTestClientOptions returns the options of a test client that connects to the broker at the given URL.
+func newTestClientOptions(brokerURL string, clientID string) *paho.ClientOptions {
+	return paho.NewClientOptions().
+		AddBroker(brokerURL).
+		SetClientID(clientID).
+		// paho retries refused connections with MQTT 3.1 if no protocol version is set
+		SetProtocolVersion(4).
+		SetAutoReconnect(false).
+		SetConnectRetry(false).
+		SetConnectTimeout(testTimeout)
+}
+
+// connectTestClient connects a client with the given options, the client is disconnected at the end of the test.
+func connectTestClient(t *testing.T, opts *paho.ClientOptions) (paho.Client, error) {
+	t.Helper()
+
+	client := paho.NewClient(opts)
+	token := client.Connect()
+	if !token.WaitTimeout(testTimeout) {
+		return nil, fmt.Errorf("connect timed out")
+	}
+	if err := token.Error(); err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() { client.Disconnect(0) })
+
+	return client, nil
+}
+
+// mustConnectTestClient connects a client to the TCP listener at the given address and fails the test on errors.
+func mustConnectTestClient(t *testing.T, address string, clientID string) paho.Client {
+	t.Helper()
+
+	client, err := connectTestClient(t, newTestClientOptions("tcp://"+address, clientID))
+	if err != nil {
+		t.Fatalf("connecting client %s failed: %s", clientID, err)
+	}
+
+	return client
+}
+
+// subscribeTestClient subscribes the client to the topic filter and returns the SUBACK return code.
+func subscribeTestClient(t *testing.T, client paho.Client, filter string, qos byte, handler paho.MessageHandler) byte {
+	t.Helper()
+
+	token := client.Subscribe(filter, qos, handler)
+	if !token.WaitTimeout(testTimeout) {
+		t.Fatalf("subscribing to %s timed out", filter)
+	}
+	if err := token.Error(); err != nil {
+		t.Fatalf("subscribing to %s failed: %s", filter, err)
+	}
+
+	subscribeToken, ok := token.(*paho.SubscribeToken)
+	if !ok {
+		t.Fatalf("unexpected token type %T", token)
+	}
+
+	return subscribeToken.Result()[filter]
+}
+
+// connectRawTestClient connects to the TCP listener at the given address with a raw connection,
+// so the test controls which packets the client sends. The connection is closed at the end of the test.
+func connectRawTestClient(t *testing.T, address string, clientID string, keepAlive uint16) net.Conn {
+	t.Helper()
+
+	return connectRawTestSession(t, address, clientID, keepAlive, true)
+}
+
+// connectRawTestSession is like connectRawTestClient, but the session of the client is kept if cleanSession is false.
+func connectRawTestSession(t *testing.T, address string, clientID string, keepAlive uint16, cleanSession bool) net.Conn {
+	t.Helper()
+
+	conn, err := net.DialTimeout("tcp", address, testTimeout)
+	if err != nil {
+		t.Fatalf("connecting to %s failed: %s", address, err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	connect := packets.NewControlPacket(packets.Connect).(*packets.ConnectPacket)
+	connect.ProtocolName = "MQTT"
+	connect.ProtocolVersion = 4
+	connect.CleanSession = cleanSession
+	connect.ClientIdentifier = clientID
+	connect.Keepalive = keepAlive
+	if err := connect.Write(conn); err != nil {
+		t.Fatalf("writing CONNECT failed: %s", err)
+	}
+
+	connack, ok := readRawTestPacket(t, conn).(*packets.ConnackPacket)
+	if !ok || connack.ReturnCode != packets.Accepted {
+		t.Fatalf("connection of client %s was not accepted", clientID)
+	}
+
+	return conn
+}
+
+// subscribeRawTestClient subscribes the raw connection to the topic filter and fails the test if the subscription is rejected.
+func subscribeRawTestClient(t *testing.T, conn net.Conn, filter string, qos byte) {
+	t.Helper()
+
+	subscribe := packets.NewControlPacket(packets.Subscribe).(*packets.SubscribePacket)
+	subscribe.MessageID = 1
+	subscribe.Topics = []string{filter}
+	subscribe.Qoss = []byte{qos}
+	if err := subscribe.Write(conn); err != nil {
+		t.Fatalf("writing SUBSCRIBE failed: %s", err)
+	}
+
+	suback, ok := readRawTestPacket(t, conn).(*packets.SubackPacket)
+	if !ok || len(suback.ReturnCodes) != 1 || suback.ReturnCodes[0] != qos {
+		t.Fatalf("subscription to %s was not accepted", filter)
+	}
+}
+
+// readRawTestPacket reads the next packet of the raw connection and fails the test on errors.
+func readRawTestPacket(t *testing.T, conn net.Conn) packets.ControlPacket {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(testTimeout))
+	packet, err := packets.ReadPacket(conn)
+	if err != nil {
+		t.Fatalf("reading packet failed: %s", err)
+	}
+
+	return packet
+}
@@ -0,0 +1,342 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	mqtt "github.com/mochi-co/mqtt/server"
+	"github.com/mochi-co/mqtt/server/listeners"
+	"github.com/mochi-co/mqtt/server/listeners/auth"
+	"github.com/mochi-co/mqtt/server/system"
+)
+
+const (
+	// rejectConnectionTimeout is the time a rejected client has to send its CONNECT packet before the connection is closed.
+	rejectConnectionTimeout = 5 * time.Second
+
+	packetTypeConnect = 1
+	packetTypeConnack = 2
+	// connackServerUnavailable is the CONNACK return code if the broker can't accept the connection.
+	connackServerUnavailable = 0x03
+	// maxRemainingLength is the maximum value of the remaining length of an MQTT packet.
+	maxRemainingLength = 268435455
+)
+
+// clientCap limits the amount of concurrently connected clients of the whole broker.
+type clientCap struct {
+	maxClients int64
+	// system contains the amount of connected clients of the underlying broker.
+	system *system.Info
+
+	rejectedClients uint64
+}
+
+// Allow returns false if the maximum amount of clients is connected.
+// The check is not atomic with the connection, so concurrent connections may exceed the maximum slightly.
+func (c *clientCap) Allow() bool {
+	if atomic.LoadInt64(&c.system.ClientsConnected) < c.maxClients {
+		return true
+	}
+
+	atomic.AddUint64(&c.rejectedClients, 1)
+	return false
+}
+
+// RejectedClients returns the amount of connections that were refused because the maximum amount of clients was connected.
+func (c *clientCap) RejectedClients() uint64 {
+	return atomic.LoadUint64(&c.rejectedClients)
+}
+
+func newClientCap(maxClients int, system *system.Info) *clientCap {
+	return &clientCap{
+		maxClients: int64(maxClients),
+		system:     system,
+	}
+}
+
+// clientLimiter limits the resources a single client (or IP) can use on the broker (0 = unlimited).
+// The connections are limited by the clientLimitListener before the CONNECT packet is processed,
+// the subscriptions and messages are limited by an AuthPerClientLimit controller per connection.
+type clientLimiter struct {
+	maxConnectionsPerIP           int
+	maxSubscriptionsPerClient     int
+	maxMessagesPerSecondPerClient int
+
+	// server is the underlying broker, it is used to look up the subscriptions of the clients.
+	server *mqtt.Server
+
+	// connectionsPerIP is the amount of open connections per remote IP.
+	connectionsPerIP map[string]int
+	lock             sync.Mutex
+
+	rejectedConnections   uint64
+	rejectedSubscriptions uint64
+	rejectedMessages      uint64
+}
+
+// remoteIP returns the IP of the remote address of a client.
+// Remote addresses without a port (e.g. of unix sockets) are used as they are.
+func remoteIP(remote string) string {
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		return remote
+	}
+
+	return host
+}
+
+// connRemote returns the remote address of a connection in the same format as the underlying broker.
+func connRemote(conn net.Conn) string {
+	if conn.RemoteAddr() == nil {
+		return ""
+	}
+
+	return conn.RemoteAddr().String()
+}
+
+// AddConnection tracks a new connection of the remote address,
+// and returns false if the remote IP exceeds the maximum amount of connections.
+// Every added connection needs to be removed with RemoveConnection, even if it was rejected.
+func (l *clientLimiter) AddConnection(remote string) bool {
+	if l.maxConnectionsPerIP == 0 {
+		return true
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	ip := remoteIP(remote)
+	l.connectionsPerIP[ip]++
+
+	if l.connectionsPerIP[ip] > l.maxConnectionsPerIP {
+		atomic.AddUint64(&l.rejectedConnections, 1)
+		return false
+	}
+
+	return true
+}
+
+// RemoveConnection removes a connection of the remote address.
+func (l *clientLimiter) RemoveConnection(remote string) {
+	if l.maxConnectionsPerIP == 0 {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	ip := remoteIP(remote)
+	if l.connectionsPerIP[ip] <= 1 {
+		delete(l.connectionsPerIP, ip)
+		return
+	}
+	l.connectionsPerIP[ip]--
+}
+
+// ClientConnected passes the ID of a connected client to the controller of its connection,
+// because the controller only gets the username in the ACL checks.
+func (l *clientLimiter) ClientConnected(clientID string) {
+	client, ok := l.server.Clients.Get(clientID)
+	if !ok {
+		return
+	}
+
+	if controller, ok := client.AC.(*AuthPerClientLimit); ok {
+		controller.setClientID(clientID)
+	}
+}
+
+// AllowSubscription returns false if the client must not subscribe to another topic filter.
+// Subscribing again to a filter the client is already subscribed to is always allowed, it only replaces the QoS.
+func (l *clientLimiter) AllowSubscription(clientID string, filter string) bool {
+	if l.maxSubscriptionsPerClient == 0 {
+		return true
+	}
+
+	client, ok := l.server.Clients.Get(clientID)
+	if !ok {
+		return true
+	}
+
+	client.RLock()
+	_, subscribed := client.Subscriptions[filter]
+	subscriptions := len(client.Subscriptions)
+	client.RUnlock()
+
+	if subscribed || subscriptions < l.maxSubscriptionsPerClient {
+		return true
+	}
+
+	atomic.AddUint64(&l.rejectedSubscriptions, 1)
+	return false
+}
+
+// AllowMessage returns false if the message limiter of a connection exceeds the maximum amount of published messages per second.
+func (l *clientLimiter) AllowMessage(messageLimiter *rate.Limiter) bool {
+	if messageLimiter == nil || messageLimiter.Allow() {
+		return true
+	}
+
+	atomic.AddUint64(&l.rejectedMessages, 1)
+	return false
+}
+
+// newConnectionController wraps the controller of a listener with the limits of a single connection.
+func (l *clientLimiter) newConnectionController(controller auth.Controller) *AuthPerClientLimit {
+	a := &AuthPerClientLimit{
+		Controller: controller,
+		limiter:    l,
+	}
+	if l.maxMessagesPerSecondPerClient > 0 {
+		a.messageLimiter = rate.NewLimiter(rate.Limit(l.maxMessagesPerSecondPerClient), l.maxMessagesPerSecondPerClient)
+	}
+
+	return a
+}
+
+// ConnectionIPs returns the amount of remote IPs with open connections.
+// Only tracked if the maximum amount of connections per IP is set.
+func (l *clientLimiter) ConnectionIPs() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return len(l.connectionsPerIP)
+}
+
+// RejectedConnections returns the amount of connections that were rejected because the remote IP had too many connections.
+func (l *clientLimiter) RejectedConnections() uint64 {
+	return atomic.LoadUint64(&l.rejectedConnections)
+}
+
+// RejectedSubscriptions returns the amount of subscriptions that were rejected because the client had too many subscriptions.
+func (l *clientLimiter) RejectedSubscriptions() uint64 {
+	return atomic.LoadUint64(&l.rejectedSubscriptions)
+}
+
+// RejectedMessages returns the amount of published messages that were dropped because the client exceeded the message rate.
+func (l *clientLimiter) RejectedMessages() uint64 {
+	return atomic.LoadUint64(&l.rejectedMessages)
+}
+
+func newClientLimiter(maxConnectionsPerIP int, maxSubscriptionsPerClient int, maxMessagesPerSecondPerClient int, server *mqtt.Server) *clientLimiter {
+	return &clientLimiter{
+		maxConnectionsPerIP:           maxConnectionsPerIP,
+		maxSubscriptionsPerClient:     maxSubscriptionsPerClient,
+		maxMessagesPerSecondPerClient: maxMessagesPerSecondPerClient,
+		server:                        server,
+		connectionsPerIP:              make(map[string]int),
+	}
+}
+
+// AuthPerClientLimit enforces the subscription and message limits of the client of a single connection.
+// A new instance wraps the controller of the listener for every connection, so the message rate is limited per connection,
+// and a client that takes over its session from another connection doesn't share the state of the old connection.
+// The underlying broker answers rejected subscriptions with a SUBACK failure and drops rejected messages.
+// The checks of the wrapped controller are applied first, so only allowed subscriptions and messages count against the limits.
+type AuthPerClientLimit struct {
+	auth.Controller
+	limiter *clientLimiter
+	// messageLimiter is the token bucket of the published messages of the connection (nil = unlimited).
+	messageLimiter *rate.Limiter
+
+	// clientID is the ID of the client of the connection, it is set once the client is connected.
+	clientID     string
+	clientIDLock sync.RWMutex
+}
+
+func (a *AuthPerClientLimit) setClientID(clientID string) {
+	a.clientIDLock.Lock()
+	defer a.clientIDLock.Unlock()
+
+	a.clientID = clientID
+}
+
+// ACL returns true if a user has access permissions to read or write on a topic.
+func (a *AuthPerClientLimit) ACL(user []byte, topic string, write bool) bool {
+	if !a.Controller.ACL(user, topic, write) {
+		return false
+	}
+
+	if write {
+		return a.limiter.AllowMessage(a.messageLimiter)
+	}
+
+	a.clientIDLock.RLock()
+	clientID := a.clientID
+	a.clientIDLock.RUnlock()
+
+	return a.limiter.AllowSubscription(clientID, topic)
+}
+
+// clientLimitListener wraps a listener of the underlying broker to enforce the client limits on its connections.
+type clientLimitListener struct {
+	listeners.Listener
+	// clientCap limits the amount of connected clients of the whole broker (optional).
+	clientCap *clientCap
+	// limiter limits the connections per IP and the resources of single clients (optional).
+	limiter *clientLimiter
+}
+
+// Serve starts waiting for new connections of the wrapped listener. Connections above the maximum amount of clients
+// or the maximum amount of connections per IP are rejected before they are authenticated,
+// all other connections get their own controller with the client limits.
+func (l *clientLimitListener) Serve(establish listeners.EstablishFunc) {
+	l.Listener.Serve(func(id string, conn net.Conn, ac auth.Controller) error {
+		// the cap is checked first, so refused clients don't cause expensive password checks
+		if l.clientCap != nil && !l.clientCap.Allow() {
+			rejectConnection(conn)
+			return ErrTooManyClients
+		}
+
+		if l.limiter == nil {
+			return establish(id, conn, ac)
+		}
+
+		remote := connRemote(conn)
+
+		// the connection is counted until it is closed, the establish callback only returns after the client disconnected
+		defer l.limiter.RemoveConnection(remote)
+		if !l.limiter.AddConnection(remote) {
+			rejectConnection(conn)
+			return ErrTooManyConnections
+		}
+
+		return establish(id, conn, l.limiter.newConnectionController(ac))
+	})
+}
+
+// rejectConnection reads the CONNECT packet of a client and answers it with the return code 0x03 (server unavailable),
+// so the client can tell the rejection apart from a network error and back off. The connection is closed afterwards.
+func rejectConnection(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	if err := conn.SetDeadline(time.Now().Add(rejectConnectionTimeout)); err != nil {
+		return
+	}
+
+	// the CONNECT packet is read completely, otherwise the unread data may reset the connection before the client received the CONNACK
+	reader := bufio.NewReader(conn)
+	header, err := reader.ReadByte()
+	if err != nil || header>>4 != packetTypeConnect {
+		return
+	}
+
+	// the remaining length uses the same variable length encoding as unsigned varints, with at most 4 bytes
+	remainingLength, err := binary.ReadUvarint(reader)
+	if err != nil || remainingLength > maxRemainingLength {
+		return
+	}
+
+	if _, err := io.CopyN(io.Discard, reader, int64(remainingLength)); err != nil {
+		return
+	}
+
+	_, _ = conn.Write([]byte{packetTypeConnack << 4, 2, 0, connackServerUnavailable})
+}
@@ -0,0 +1,118 @@
+package mqtt
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+
+	"github.com/mochi-co/mqtt/server/listeners/auth"
+)
+
+func TestClientCapMaxClients(t *testing.T) {
+	broker, address := newTestBroker(t, WithMaxClients(1))
+
+	mustConnectTestClient(t, address, "first")
+
+	// refused clients get the return code 0x03 (server unavailable) instead of 0x04 (bad username or password)
+	if _, err := connectTestClient(t, newTestClientOptions("tcp://"+address, "second")); !errors.Is(err, packets.ErrorRefusedServerUnavailable) {
+		t.Fatalf("expected the connection to be refused as server unavailable, got %v", err)
+	}
+	if rejected := broker.ClientLimitRejectedClients(); rejected != 1 {
+		t.Fatalf("expected 1 rejected client, got %d", rejected)
+	}
+}
+
+func TestClientLimitMaxConnectionsPerIP(t *testing.T) {
+	broker, address := newTestBroker(t, WithMaxConnectionsPerIP(1))
+
+	first := mustConnectTestClient(t, address, "first")
+
+	// the second connection is refused before it is authenticated
+	if _, err := connectTestClient(t, newTestClientOptions("tcp://"+address, "second")); !errors.Is(err, packets.ErrorRefusedServerUnavailable) {
+		t.Fatalf("expected the connection to be refused as server unavailable, got %v", err)
+	}
+	if rejected := broker.ClientLimitRejectedConnections(); rejected != 1 {
+		t.Fatalf("expected 1 rejected connection, got %d", rejected)
+	}
+
+	// the slot is released once the first client disconnected
+	first.Disconnect(0)
+	deadline := time.Now().Add(testTimeout)
+	for broker.ClientLimitConnectionIPs() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the connection of the disconnected client is still counted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mustConnectTestClient(t, address, "third")
+}
+
+func TestClientLimitMaxSubscriptionsPerClient(t *testing.T) {
+	broker, address := newTestBroker(t, WithMaxSubscriptionsPerClient(1))
+
+	client := mustConnectTestClient(t, address, "client")
+
+	if code := subscribeTestClient(t, client, "milestones", 1, nil); code != 1 {
+		t.Fatalf("expected the first subscription to be granted with QoS 1, got 0x%02x", code)
+	}
+	if code := subscribeTestClient(t, client, "messages", 1, nil); code != 0x80 {
+		t.Fatalf("expected the subscription above the limit to be refused, got 0x%02x", code)
+	}
+	// subscribing again to the same filter doesn't add a subscription
+	if code := subscribeTestClient(t, client, "milestones", 0, nil); code != 0 {
+		t.Fatalf("expected the repeated subscription to be granted with QoS 0, got 0x%02x", code)
+	}
+
+	if rejected := broker.ClientLimitRejectedSubscriptions(); rejected != 1 {
+		t.Fatalf("expected 1 rejected subscription, got %d", rejected)
+	}
+	if broker.topicManager.hasSubscribers("messages") {
+		t.Fatal("the refused subscription must not be tracked")
+	}
+}
+
+func TestClientLimitMessageLimiterPerConnection(t *testing.T) {
+	limiter := newClientLimiter(0, 0, 1, nil)
+
+	// a client that takes over its session gets a new controller, it doesn't share the bucket of the old connection
+	oldConnection := limiter.newConnectionController(&auth.Allow{})
+	newConnection := limiter.newConnectionController(&auth.Allow{})
+
+	if !oldConnection.ACL(nil, "topic", true) {
+		t.Fatal("expected the first message of the old connection to be allowed")
+	}
+	if oldConnection.ACL(nil, "topic", true) {
+		t.Fatal("expected the second message of the old connection to be dropped")
+	}
+	if !newConnection.ACL(nil, "topic", true) {
+		t.Fatal("expected the first message of the new connection to be allowed")
+	}
+	if rejected := limiter.RejectedMessages(); rejected != 1 {
+		t.Fatalf("expected 1 rejected message, got %d", rejected)
+	}
+}
+
+func TestRejectConnection(t *testing.T) {
+	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
+
+	go rejectConnection(server)
+
+	// CONNECT with protocol name "MQTT", level 4, clean session, keep-alive 60 and client ID "c"
+	connect := []byte{0x10, 13, 0, 4, 'M', 'Q', 'T', 'T', 4, 0x02, 0, 60, 0, 1, 'c'}
+	if _, err := client.Write(connect); err != nil {
+		t.Fatalf("writing the CONNECT packet failed: %s", err)
+	}
+
+	connack := make([]byte, 4)
+	if _, err := client.Read(connack); err != nil {
+		t.Fatalf("reading the CONNACK packet failed: %s", err)
+	}
+	if expected := []byte{0x20, 2, 0, connackServerUnavailable}; !bytes.Equal(connack, expected) {
+		t.Fatalf("unexpected CONNACK %x, expected %x", connack, expected)
+	}
+}
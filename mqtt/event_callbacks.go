@@ -0,0 +1,128 @@
+package mqtt
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// eventCallbackQueueSize is the maximum amount of queued events that were not passed to the callbacks yet.
+	eventCallbackQueueSize = 1000
+)
+
+// ClientConnectFunc is called after a client connected successfully.
+type ClientConnectFunc func(clientID string, remoteAddr string)
+
+// ClientDisconnectFunc is called after a client disconnected, err is nil for regular disconnects.
+type ClientDisconnectFunc func(clientID string, err error)
+
+// MessagePublishedFunc is called after the broker published a message on a topic.
+type MessagePublishedFunc func(topic string, size int)
+
+// eventCallbacks passes the broker events to the callbacks of the user.
+// The callbacks are called in order on a single goroutine, so slow callbacks never block the broker.
+// If the queue is full, events are dropped.
+type eventCallbacks struct {
+	onClientConnect    ClientConnectFunc
+	onClientDisconnect ClientDisconnectFunc
+	onMessagePublished MessagePublishedFunc
+	queue              chan func()
+
+	// droppedEvents is the amount of events that were dropped because the queue was full.
+	droppedEvents uint64
+
+	shutdownChan chan struct{}
+	shutdownOnce sync.Once
+	shutdownWG   sync.WaitGroup
+}
+
+// dispatch queues the call of a callback.
+func (e *eventCallbacks) dispatch(call func()) {
+	select {
+	case e.queue <- call:
+	default:
+		atomic.AddUint64(&e.droppedEvents, 1)
+	}
+}
+
+// ClientConnected queues the connect event of a client.
+func (e *eventCallbacks) ClientConnected(clientID string, remoteAddr string) {
+	if e.onClientConnect == nil {
+		return
+	}
+
+	e.dispatch(func() {
+		e.onClientConnect(clientID, remoteAddr)
+	})
+}
+
+// ClientDisconnected queues the disconnect event of a client.
+func (e *eventCallbacks) ClientDisconnected(clientID string, err error) {
+	if e.onClientDisconnect == nil {
+		return
+	}
+
+	e.dispatch(func() {
+		e.onClientDisconnect(clientID, err)
+	})
+}
+
+// MessagePublished queues the event of a published message.
+func (e *eventCallbacks) MessagePublished(topic string, size int) {
+	if e.onMessagePublished == nil {
+		return
+	}
+
+	e.dispatch(func() {
+		e.onMessagePublished(topic, size)
+	})
+}
+
+// DroppedEvents returns the amount of events that were dropped because the queue was full.
+func (e *eventCallbacks) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&e.droppedEvents)
+}
+
+// Start starts passing the queued events to the callbacks.
+func (e *eventCallbacks) Start() {
+	e.shutdownWG.Add(1)
+	go func() {
+		defer e.shutdownWG.Done()
+
+		for {
+			select {
+			case <-e.shutdownChan:
+				// the events that were queued before the shutdown are still passed to the callbacks,
+				// so the disconnects of the clients closed by the broker are not lost
+				for {
+					select {
+					case call := <-e.queue:
+						call()
+					default:
+						return
+					}
+				}
+			case call := <-e.queue:
+				call()
+			}
+		}
+	}()
+}
+
+// Stop passes the queued events to the callbacks and stops.
+func (e *eventCallbacks) Stop() {
+	e.shutdownOnce.Do(func() {
+		close(e.shutdownChan)
+	})
+	e.shutdownWG.Wait()
+}
+
+func newEventCallbacks(onClientConnect ClientConnectFunc, onClientDisconnect ClientDisconnectFunc, onMessagePublished MessagePublishedFunc) *eventCallbacks {
+	return &eventCallbacks{
+		onClientConnect:    onClientConnect,
+		onClientDisconnect: onClientDisconnect,
+		onMessagePublished: onMessagePublished,
+		queue:              make(chan func(), eventCallbackQueueSize),
+		shutdownChan:       make(chan struct{}),
+	}
+}
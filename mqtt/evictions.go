@@ -0,0 +1,93 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+)
+
+const (
+	// topicSysEvictions is the system topic on which the evictions of slow, dead and idle clients are published.
+	topicSysEvictions = "$SYS/evictions"
+
+	// EvictionReasonWriteTimeout is the reason of an eviction if writing to the client timed out.
+	EvictionReasonWriteTimeout = "write-timeout"
+	// EvictionReasonAckTimeout is the reason of an eviction if the client did not acknowledge a message after the maximum amount of retransmissions.
+	EvictionReasonAckTimeout = "ack-timeout"
+	// EvictionReasonSlowClient is the reason of an eviction if the outgoing buffer of the client was saturated.
+	EvictionReasonSlowClient = "slow-client"
+	// EvictionReasonKeepAliveTimeout is the reason of an eviction if the client sent nothing within its keep-alive timeout.
+	EvictionReasonKeepAliveTimeout = "keep-alive-timeout"
+	// EvictionReasonIdle is the reason of an eviction if the client was reaped by the idle connection reaper.
+	EvictionReasonIdle = "idle"
+)
+
+// clientEvictionPayload defines the payload of the client evictions topic.
+type clientEvictionPayload struct {
+	// The ID of the evicted client.
+	ClientID string `json:"clientId"`
+	// The reason why the client was evicted.
+	Reason string `json:"reason"`
+	// The amount of bytes that were still queued in the outgoing buffer of the client.
+	QueuedBytes int `json:"queuedBytes"`
+	// The amount of QoS messages that were still in-flight for the client.
+	InflightMessages int `json:"inflightMessages"`
+}
+
+// isWriteTimeout returns true if the client was stopped because writing to its connection timed out.
+func isWriteTimeout(err error) bool {
+	// the underlying broker prefixes the errors of the client writer
+	return isConnectionTimeout(err, "writer:")
+}
+
+// isReadTimeout returns true if the client was stopped because it sent nothing until the read deadline of its connection,
+// which is derived from the keep-alive of the client.
+func isReadTimeout(err error) bool {
+	// the underlying broker prefixes the errors of the client reader
+	return isConnectionTimeout(err, "reader:")
+}
+
+// isConnectionTimeout returns true if the error is a timeout of the connection with the given prefix of the underlying broker.
+func isConnectionTimeout(err error, prefix string) bool {
+	if err == nil {
+		return false
+	}
+
+	if !strings.HasPrefix(err.Error(), prefix) {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// publishEviction publishes the eviction of a client on the evictions system topic if someone is subscribed.
+func (b *Broker) publishEviction(clientID string, reason string) {
+	b.log.Warnf("client evicted: %s, reason: %s", clientID, reason)
+
+	if !b.HasSubscribers(topicSysEvictions) {
+		return
+	}
+
+	payload := &clientEvictionPayload{
+		ClientID: clientID,
+		Reason:   reason,
+	}
+
+	if client, ok := b.broker.Clients.Get(clientID); ok {
+		if client.W != nil {
+			payload.QueuedBytes = client.W.CapDelta()
+		}
+		payload.InflightMessages = client.Inflight.Len()
+	}
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	if err := b.Send(topicSysEvictions, jsonPayload); err != nil {
+		b.log.Debugf("publishing eviction of client %s failed: %s", clientID, err)
+	}
+}
@@ -0,0 +1,74 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/iotaledger/hive.go/logger"
+)
+
+const (
+	// healthShutdownTimeout is the maximum duration to wait for running health requests when the broker is stopped.
+	healthShutdownTimeout = 1 * time.Second
+)
+
+// healthServer exposes the state of the broker for liveness and readiness probes.
+// "/health" returns 200 while the broker is serving, "/ready" additionally requires the source of the messages to be ready.
+// Both return 503 otherwise.
+type healthServer struct {
+	log         *logger.Logger
+	bindAddress string
+	server      *http.Server
+}
+
+// statusHandler answers with 200 if the check succeeds, or with 503 otherwise.
+func statusHandler(check func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if !check() {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(http.StatusText(http.StatusOK)))
+	}
+}
+
+// Start binds the health server to the address and serves the health requests in the background.
+func (h *healthServer) Start() error {
+	listener, err := net.Listen("tcp", h.bindAddress)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := h.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			h.log.Errorf("health server stopped: %s", err)
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops the health server.
+func (h *healthServer) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), healthShutdownTimeout)
+	defer cancel()
+
+	_ = h.server.Shutdown(ctx)
+}
+
+func newHealthServer(log *logger.Logger, bindAddress string, healthy func() bool, ready func() bool) *healthServer {
+	mux := http.NewServeMux()
+	mux.Handle("/health", statusHandler(healthy))
+	mux.Handle("/ready", statusHandler(ready))
+
+	return &healthServer{
+		log:         log,
+		bindAddress: bindAddress,
+		server:      &http.Server{Handler: mux},
+	}
+}
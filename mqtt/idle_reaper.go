@@ -0,0 +1,111 @@
+package mqtt
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// idleConnectionReaper periodically disconnects clients that have no subscriptions
+// and showed no activity (connect, subscribe, unsubscribe, publish) for longer than the idle timeout.
+// This reclaims the resources of clients that connect but never subscribe, like scanners or probes.
+type idleConnectionReaper struct {
+	idleTimeout   time.Duration
+	checkInterval time.Duration
+
+	lastActivity     map[string]time.Time
+	lastActivityLock sync.Mutex
+
+	// reapFunc disconnects the client if it has no subscriptions and returns true if the client was disconnected.
+	reapFunc func(clientID string) bool
+
+	// reapedConnections is the amount of connections that were disconnected by the reaper.
+	reapedConnections uint64
+
+	shutdownChan chan struct{}
+	shutdownOnce sync.Once
+	shutdownWG   sync.WaitGroup
+}
+
+// Touch marks the client as active.
+func (r *idleConnectionReaper) Touch(clientID string) {
+	r.lastActivityLock.Lock()
+	defer r.lastActivityLock.Unlock()
+
+	r.lastActivity[clientID] = time.Now()
+}
+
+// Remove stops tracking the activity of the client.
+func (r *idleConnectionReaper) Remove(clientID string) {
+	r.lastActivityLock.Lock()
+	defer r.lastActivityLock.Unlock()
+
+	delete(r.lastActivity, clientID)
+}
+
+// ReapedConnections returns the amount of connections that were disconnected by the reaper.
+func (r *idleConnectionReaper) ReapedConnections() uint64 {
+	return atomic.LoadUint64(&r.reapedConnections)
+}
+
+// Start starts the periodic scan for idle connections.
+func (r *idleConnectionReaper) Start() {
+	r.shutdownWG.Add(1)
+	go func() {
+		defer r.shutdownWG.Done()
+
+		ticker := time.NewTicker(r.checkInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.shutdownChan:
+				return
+			case <-ticker.C:
+				r.reap()
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic scan for idle connections.
+func (r *idleConnectionReaper) Stop() {
+	r.shutdownOnce.Do(func() {
+		close(r.shutdownChan)
+	})
+	r.shutdownWG.Wait()
+}
+
+// reap disconnects all clients that were idle for longer than the idle timeout.
+func (r *idleConnectionReaper) reap() {
+	idleSince := time.Now().Add(-r.idleTimeout)
+
+	// collect the candidates first, so the lock is not held while disconnecting clients
+	var idleClientIDs []string
+	r.lastActivityLock.Lock()
+	for clientID, lastActivity := range r.lastActivity {
+		if lastActivity.Before(idleSince) {
+			idleClientIDs = append(idleClientIDs, clientID)
+		}
+	}
+	r.lastActivityLock.Unlock()
+
+	for _, clientID := range idleClientIDs {
+		if !r.reapFunc(clientID) {
+			continue
+		}
+
+		atomic.AddUint64(&r.reapedConnections, 1)
+		r.Remove(clientID)
+	}
+}
+
+func newIdleConnectionReaper(idleTimeout time.Duration, checkInterval time.Duration, reapFunc func(clientID string) bool) *idleConnectionReaper {
+	return &idleConnectionReaper{
+		idleTimeout:   idleTimeout,
+		checkInterval: checkInterval,
+		lastActivity:  make(map[string]time.Time),
+		reapFunc:      reapFunc,
+		shutdownChan:  make(chan struct{}),
+	}
+}
@@ -0,0 +1,88 @@
+package mqtt
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/mochi-co/mqtt/server/listeners"
+	"github.com/mochi-co/mqtt/server/listeners/auth"
+)
+
+// keepAliveListener wraps a listener of the underlying broker to enforce the keep-alive limits on its connections.
+type keepAliveListener struct {
+	listeners.Listener
+	maxKeepAlive    time.Duration
+	readIdleTimeout time.Duration
+}
+
+// Serve starts waiting for new connections of the wrapped listener, and calls the establish connection callback
+// with the connection wrapped to enforce the keep-alive limits.
+func (l *keepAliveListener) Serve(establish listeners.EstablishFunc) {
+	l.Listener.Serve(func(id string, conn net.Conn, ac auth.Controller) error {
+		return establish(id, newKeepAliveConn(conn, l.maxKeepAlive, l.readIdleTimeout), ac)
+	})
+}
+
+// keepAliveConn is a connection that limits the time without incoming traffic from the client.
+// The underlying broker sets the deadline of the connection to 1.5 times the negotiated keep-alive
+// after every received packet and after every write, and disables the deadline if the client negotiated no keep-alive.
+// This connection measures the read deadline from the last received data instead,
+// so messages sent to a client that vanished without a DISCONNECT don't keep the connection alive,
+// and caps the deadline by the maximum keep-alive and the read idle timeout.
+type keepAliveConn struct {
+	net.Conn
+	// maxTimeout is the maximum duration without incoming traffic (0 = no limit).
+	maxTimeout time.Duration
+	// lastRead is the time of the last received data in unix nanoseconds.
+	lastRead int64
+}
+
+// Read reads data from the connection and records the time of the received data.
+func (c *keepAliveConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		atomic.StoreInt64(&c.lastRead, time.Now().UnixNano())
+	}
+	return n, err
+}
+
+// SetDeadline sets the read deadline relative to the last received data and the write deadline relative to now,
+// both capped by the maximum duration without incoming traffic.
+func (c *keepAliveConn) SetDeadline(t time.Time) error {
+	now := time.Now()
+
+	var timeout time.Duration
+	if !t.IsZero() {
+		timeout = t.Sub(now)
+	}
+	if c.maxTimeout > 0 && (timeout <= 0 || timeout > c.maxTimeout) {
+		timeout = c.maxTimeout
+	}
+
+	if timeout <= 0 {
+		return c.Conn.SetDeadline(time.Time{})
+	}
+
+	lastRead := time.Unix(0, atomic.LoadInt64(&c.lastRead))
+	if err := c.Conn.SetReadDeadline(lastRead.Add(timeout)); err != nil {
+		return err
+	}
+	return c.Conn.SetWriteDeadline(now.Add(timeout))
+}
+
+// newKeepAliveConn wraps the connection to enforce the keep-alive limits.
+// The maximum keep-alive is applied with the same 1.5 tolerance as the negotiated keep-alive,
+// the read idle timeout is the absolute maximum duration without incoming traffic (0 = no limit for both).
+func newKeepAliveConn(conn net.Conn, maxKeepAlive time.Duration, readIdleTimeout time.Duration) *keepAliveConn {
+	maxTimeout := maxKeepAlive + maxKeepAlive/2
+	if readIdleTimeout > 0 && (maxTimeout == 0 || readIdleTimeout < maxTimeout) {
+		maxTimeout = readIdleTimeout
+	}
+
+	return &keepAliveConn{
+		Conn:       conn,
+		maxTimeout: maxTimeout,
+		lastRead:   time.Now().UnixNano(),
+	}
+}
@@ -0,0 +1,66 @@
+package mqtt
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+func TestKeepAliveReapsClientWithoutPingreq(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []BrokerOption
+		keepAlive uint16
+	}{
+		{"negotiated keep-alive", []BrokerOption{WithMaxKeepAlive(time.Minute)}, 1},
+		{"no keep-alive capped by the maximum keep-alive", []BrokerOption{WithMaxKeepAlive(1 * time.Second)}, 0},
+		{"keep-alive capped by the read idle timeout", []BrokerOption{WithReadIdleTimeout(1 * time.Second)}, 60},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			broker, address := newTestBroker(t, test.opts...)
+
+			// the silent client only receives messages, which must not keep its connection alive
+			silent := connectRawTestClient(t, address, "silent", test.keepAlive)
+			subscribeRawTestClient(t, silent, "milestones", 0)
+			subscribed := time.Now()
+
+			// the pinging client sends a PINGREQ more often than its keep-alive requires
+			pinging := connectRawTestClient(t, address, "pinging", test.keepAlive)
+
+			isStopped := func(clientID string) bool {
+				client, ok := broker.broker.Clients.Get(clientID)
+				return !ok || atomic.LoadUint32(&client.State.Done) != 0
+			}
+
+			ticker := time.NewTicker(200 * time.Millisecond)
+			defer ticker.Stop()
+			timeout := time.After(testTimeout)
+
+			for !isStopped("silent") {
+				select {
+				case <-ticker.C:
+					if err := packets.NewControlPacket(packets.Pingreq).Write(pinging); err != nil {
+						t.Fatalf("writing PINGREQ failed: %s", err)
+					}
+					_ = broker.SendWithOptions("milestones", []byte("milestone"), 0, false)
+				case <-timeout:
+					t.Fatal("the client without PINGREQ was not reaped")
+				}
+			}
+
+			if elapsed := time.Since(subscribed); elapsed < 500*time.Millisecond {
+				t.Fatalf("client was reaped after %s, before its keep-alive timeout", elapsed)
+			}
+			if client, ok := broker.broker.Clients.Get("silent"); ok && !isReadTimeout(client.StopCause()) {
+				t.Fatalf("expected the client to be reaped by a read timeout, got %v", client.StopCause())
+			}
+			if isStopped("pinging") {
+				t.Fatal("expected the pinging client to stay connected")
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package mqtt
+
+import (
+	"sync/atomic"
+)
+
+// logSampler allows to log only one out of every N events.
+type logSampler struct {
+	rate    uint64
+	counter uint64
+}
+
+// Sample returns true if the current event should be logged.
+// The first event is always logged, afterwards every N-th event.
+func (s *logSampler) Sample() bool {
+	if s.rate <= 1 {
+		return true
+	}
+
+	return (atomic.AddUint64(&s.counter, 1)-1)%s.rate == 0
+}
+
+func newLogSampler(rate int) *logSampler {
+	if rate < 1 {
+		rate = 1
+	}
+
+	return &logSampler{
+		rate: uint64(rate),
+	}
+}
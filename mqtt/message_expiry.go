@@ -0,0 +1,219 @@
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// minMessageExpiryCheckInterval is the minimum interval in which the queued messages are checked for expiry.
+	// The underlying broker tracks the time a message was queued in seconds, so a shorter interval is pointless.
+	minMessageExpiryCheckInterval = 1 * time.Second
+)
+
+// topicExpiry is the expiry of the messages published on topics with the given prefix.
+type topicExpiry struct {
+	prefix string
+	expiry time.Duration
+}
+
+// queuedMessage is a message in the inflight list of a client, as it was seen by the last expiry check.
+type queuedMessage struct {
+	topic   string
+	sent    int64
+	resends int
+	// queued is the unix time the message was first seen in the inflight list.
+	queued int64
+}
+
+// messageExpirer drops messages that are queued for clients after the expiry configured for the topic prefix has passed,
+// so that reconnecting clients do not get a flood of outdated events.
+// Only the inflight list of persistent sessions is checked, which holds the QoS > 0 messages that were not acknowledged yet.
+//
+// The underlying broker only supports MQTT 3.1.1, so the expiry is not sent to the clients
+// as a MQTT 5.0 message expiry interval, but enforced by the broker itself.
+// The expiry is checked periodically, so messages may be delivered up to the check interval after they expired.
+//
+// The underlying broker resets the sent time of an inflight message on every retransmission (on every reconnect and on ACK timeouts),
+// so the expiry is measured from the time the message was first seen in the inflight list instead.
+// A message that is retransmitted before the first check is measured from its first retransmission,
+// so its expiry may be extended by up to the check interval once.
+type messageExpirer struct {
+	// the expiries sorted by descending prefix length, so the most specific prefix matches first.
+	expiries      []*topicExpiry
+	checkInterval time.Duration
+
+	// the IDs of the clients with a persistent session, whose messages are queued while they are disconnected.
+	clientIDs     map[string]struct{}
+	clientIDsLock sync.Mutex
+
+	// the inflight messages of the tracked clients by packet ID.
+	// They are only accessed by the expireFunc, which is called by the check goroutine.
+	queuedMessages map[string]map[uint16]*queuedMessage
+
+	// expireFunc drops the expired messages of the client and returns the amount of dropped messages.
+	// It returns false if the client has no session anymore, so it does not need to be checked again.
+	expireFunc func(clientID string) (int, bool)
+
+	// expiredMessages is the amount of queued messages that were dropped because they expired.
+	expiredMessages uint64
+
+	shutdownChan chan struct{}
+	shutdownOnce sync.Once
+	shutdownWG   sync.WaitGroup
+}
+
+// ExpiryForTopic returns the expiry of the messages published on the topic and whether an expiry is configured.
+func (e *messageExpirer) ExpiryForTopic(topic string) (time.Duration, bool) {
+	for _, expiry := range e.expiries {
+		if strings.HasPrefix(topic, expiry.prefix) {
+			return expiry.expiry, true
+		}
+	}
+
+	return 0, false
+}
+
+// Track adds a client with a persistent session to the clients whose queued messages are checked for expiry.
+func (e *messageExpirer) Track(clientID string) {
+	e.clientIDsLock.Lock()
+	defer e.clientIDsLock.Unlock()
+
+	e.clientIDs[clientID] = struct{}{}
+}
+
+// QueuedSince returns the unix time the message with the packet ID was first seen in the inflight list of the client.
+// A packet ID that is reused for a new message is detected by the sent time and the amount of retransmissions,
+// since the sent time only changes on retransmissions.
+func (e *messageExpirer) QueuedSince(clientID string, packetID uint16, topic string, sent int64, resends int) int64 {
+	messages, has := e.queuedMessages[clientID]
+	if !has {
+		messages = make(map[uint16]*queuedMessage)
+		e.queuedMessages[clientID] = messages
+	}
+
+	message, has := messages[packetID]
+	if has && message.topic == topic && (resends > message.resends || (resends == message.resends && sent == message.sent)) {
+		message.sent = sent
+		message.resends = resends
+		return message.queued
+	}
+
+	messages[packetID] = &queuedMessage{topic: topic, sent: sent, resends: resends, queued: sent}
+	return sent
+}
+
+// ForgetMessage removes a message that was dropped from the inflight list of the client.
+func (e *messageExpirer) ForgetMessage(clientID string, packetID uint16) {
+	delete(e.queuedMessages[clientID], packetID)
+}
+
+// ForgetAcknowledged removes the messages of the client that are not in the inflight list anymore.
+func (e *messageExpirer) ForgetAcknowledged(clientID string, inflightPacketIDs map[uint16]struct{}) {
+	for packetID := range e.queuedMessages[clientID] {
+		if _, inflight := inflightPacketIDs[packetID]; !inflight {
+			delete(e.queuedMessages[clientID], packetID)
+		}
+	}
+}
+
+// expire drops the expired messages of all tracked clients.
+func (e *messageExpirer) expire() {
+	e.clientIDsLock.Lock()
+	clientIDs := make([]string, 0, len(e.clientIDs))
+	for clientID := range e.clientIDs {
+		clientIDs = append(clientIDs, clientID)
+	}
+	e.clientIDsLock.Unlock()
+
+	for _, clientID := range clientIDs {
+		expired, hasSession := e.expireFunc(clientID)
+		atomic.AddUint64(&e.expiredMessages, uint64(expired))
+
+		if !hasSession {
+			delete(e.queuedMessages, clientID)
+
+			e.clientIDsLock.Lock()
+			delete(e.clientIDs, clientID)
+			e.clientIDsLock.Unlock()
+		}
+	}
+}
+
+// ExpiredMessages returns the amount of queued messages that were dropped because they expired.
+func (e *messageExpirer) ExpiredMessages() uint64 {
+	return atomic.LoadUint64(&e.expiredMessages)
+}
+
+// Start starts the periodic check for expired messages.
+func (e *messageExpirer) Start() {
+	e.shutdownWG.Add(1)
+	go func() {
+		defer e.shutdownWG.Done()
+
+		ticker := time.NewTicker(e.checkInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-e.shutdownChan:
+				return
+			case <-ticker.C:
+				e.expire()
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic check for expired messages.
+func (e *messageExpirer) Stop() {
+	e.shutdownOnce.Do(func() {
+		close(e.shutdownChan)
+	})
+	e.shutdownWG.Wait()
+}
+
+func newMessageExpirer(messageExpiry map[string]time.Duration, expireFunc func(clientID string) (int, bool)) (*messageExpirer, error) {
+	if len(messageExpiry) == 0 {
+		return nil, errors.New("no message expiry given")
+	}
+
+	expiries := make([]*topicExpiry, 0, len(messageExpiry))
+	checkInterval := time.Duration(0)
+	for prefix, expiry := range messageExpiry {
+		if prefix == "" {
+			return nil, errors.New("empty topic prefix for message expiry")
+		}
+		if expiry <= 0 {
+			return nil, fmt.Errorf("message expiry for topic prefix \"%s\" must be greater than zero", prefix)
+		}
+
+		expiries = append(expiries, &topicExpiry{prefix: prefix, expiry: expiry})
+
+		if checkInterval == 0 || expiry/2 < checkInterval {
+			checkInterval = expiry / 2
+		}
+	}
+
+	sort.Slice(expiries, func(i, j int) bool {
+		return len(expiries[i].prefix) > len(expiries[j].prefix)
+	})
+
+	if checkInterval < minMessageExpiryCheckInterval {
+		checkInterval = minMessageExpiryCheckInterval
+	}
+
+	return &messageExpirer{
+		expiries:       expiries,
+		checkInterval:  checkInterval,
+		clientIDs:      make(map[string]struct{}),
+		queuedMessages: make(map[string]map[uint16]*queuedMessage),
+		expireFunc:     expireFunc,
+		shutdownChan:   make(chan struct{}),
+	}, nil
+}
@@ -0,0 +1,126 @@
+package mqtt
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+func TestMessageExpiryDropsExpiredQueuedMessages(t *testing.T) {
+	broker, address := newTestBroker(t, WithMessageExpiry(map[string]time.Duration{"outputs/": 1 * time.Second}))
+
+	// the subscriptions of the persistent session are kept while the client is disconnected
+	opts := newTestClientOptions("tcp://"+address, "persistent").SetCleanSession(false)
+	client, err := connectTestClient(t, opts)
+	if err != nil {
+		t.Fatalf("connecting client failed: %s", err)
+	}
+	subscribeTestClient(t, client, "outputs/#", 1, nil)
+	subscribeTestClient(t, client, "milestones", 1, nil)
+	client.Disconnect(0)
+
+	isDisconnected := func() bool {
+		existing, ok := broker.broker.Clients.Get("persistent")
+		return ok && atomic.LoadUint32(&existing.State.Done) == 1
+	}
+	waitFor(t, testTimeout, isDisconnected, "the client was not disconnected")
+
+	for _, topic := range []string{"outputs/0x01", "milestones"} {
+		if err := broker.SendWithOptions(topic, []byte(topic), 1, false); err != nil {
+			t.Fatalf("sending %s failed: %s", topic, err)
+		}
+	}
+
+	// every reconnect retransmits the unacknowledged messages and resets their sent time,
+	// which must not restart the expiry of a session that keeps reconnecting without acknowledging.
+	// The reconnects are more frequent than the expiry, but less than the maximum retransmissions of the underlying broker.
+	for i := 0; i < 4; i++ {
+		time.Sleep(600 * time.Millisecond)
+
+		conn := connectRawTestSession(t, address, "persistent", 0, false)
+		for {
+			published, ok := readRawTestPacket(t, conn).(*packets.PublishPacket)
+			if !ok {
+				t.Fatal("expected the retransmitted messages")
+			}
+			if published.TopicName == "milestones" {
+				break
+			}
+		}
+		_ = conn.Close()
+
+		waitFor(t, testTimeout, isDisconnected, "the client was not disconnected")
+	}
+
+	// only the message on the topic with an expiry is dropped from the inflight list of the session
+	if expired := broker.ExpiredMessages(); expired != 1 {
+		t.Fatalf("expected the queued message to expire despite the retransmissions, got %d expired messages", expired)
+	}
+
+	var receivedLock sync.Mutex
+	var received []string
+	opts = newTestClientOptions("tcp://"+address, "persistent").
+		SetCleanSession(false).
+		SetDefaultPublishHandler(func(_ paho.Client, message paho.Message) {
+			receivedLock.Lock()
+			defer receivedLock.Unlock()
+
+			received = append(received, message.Topic())
+		})
+	if _, err := connectTestClient(t, opts); err != nil {
+		t.Fatalf("reconnecting client failed: %s", err)
+	}
+
+	waitFor(t, testTimeout, func() bool {
+		receivedLock.Lock()
+		defer receivedLock.Unlock()
+
+		return len(received) > 0
+	}, "the queued message without expiry was not redelivered")
+
+	// give an expired message the chance to arrive
+	time.Sleep(200 * time.Millisecond)
+
+	receivedLock.Lock()
+	defer receivedLock.Unlock()
+	if len(received) != 1 || received[0] != "milestones" {
+		t.Fatalf("expected only the milestones message to be redelivered, got %v", received)
+	}
+}
+
+func TestMessageExpiryForTopic(t *testing.T) {
+	expirer, err := newMessageExpirer(map[string]time.Duration{
+		"outputs/":      time.Minute,
+		"outputs/nfts/": time.Hour,
+	}, func(string) (int, bool) { return 0, true })
+	if err != nil {
+		t.Fatalf("creating message expirer failed: %s", err)
+	}
+
+	tests := []struct {
+		topic    string
+		expiry   time.Duration
+		expected bool
+	}{
+		{"outputs/0x01", time.Minute, true},
+		{"outputs/nfts/0x01", time.Hour, true},
+		{"milestones", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.topic, func(t *testing.T) {
+			expiry, has := expirer.ExpiryForTopic(test.topic)
+			if has != test.expected || expiry != test.expiry {
+				t.Fatalf("ExpiryForTopic(%s) = %s, %v, expected %s, %v", test.topic, expiry, has, test.expiry, test.expected)
+			}
+		})
+	}
+
+	if expirer.checkInterval != 30*time.Second {
+		t.Fatalf("expected the check interval to be half of the shortest expiry, got %s", expirer.checkInterval)
+	}
+}
@@ -0,0 +1,170 @@
+package mqtt
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "iota"
+	metricsSubsystem = "mqtt_broker"
+)
+
+// brokerMetric is a single gauge of the broker metrics.
+type brokerMetric struct {
+	desc      *prometheus.Desc
+	valueFunc func() float64
+}
+
+// brokerMetricsCollector exports the statistics of the broker as Prometheus metrics.
+// The values are read on every scrape, so no background goroutine is needed to keep them up to date.
+type brokerMetricsCollector struct {
+	metrics []*brokerMetric
+	// collectors are the metrics that are updated by the broker itself, e.g. histograms.
+	collectors []prometheus.Collector
+}
+
+// Describe sends the descriptors of all metrics to the channel.
+func (c *brokerMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, metric := range c.metrics {
+		ch <- metric.desc
+	}
+	for _, collector := range c.collectors {
+		collector.Describe(ch)
+	}
+}
+
+// Collect sends the current values of all metrics to the channel.
+func (c *brokerMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, metric := range c.metrics {
+		ch <- prometheus.MustNewConstMetric(metric.desc, prometheus.GaugeValue, metric.valueFunc())
+	}
+	for _, collector := range c.collectors {
+		collector.Collect(ch)
+	}
+}
+
+// newPublishLatencyHistogram returns the histogram of the time it takes to publish a message to the subscribers.
+// The buckets range from 100µs to about 1.6s, since publishing is usually much faster than the default buckets.
+// Exemplars that link slow publishes to traces are not attached, since there is no tracing integration yet.
+func newPublishLatencyHistogram() prometheus.Histogram {
+	return prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "publish_latency_seconds",
+		Help:      "The time it took to publish a message to the subscribers in seconds.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8),
+	})
+}
+
+func newBrokerMetricsCollector(b *Broker) *brokerMetricsCollector {
+	info := b.broker.System
+
+	gauge := func(name string, help string, valueFunc func() float64) *brokerMetric {
+		return &brokerMetric{
+			desc:      prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, metricsSubsystem, name), help, nil, nil),
+			valueFunc: valueFunc,
+		}
+	}
+	systemGauge := func(name string, help string, value *int64) *brokerMetric {
+		return gauge(name, help, func() float64 { return float64(atomic.LoadInt64(value)) })
+	}
+
+	return &brokerMetricsCollector{
+		metrics: []*brokerMetric{
+			systemGauge("started", "The time the server started in unix seconds.", &info.Started),
+			systemGauge("uptime", "The number of seconds the server has been online.", &info.Uptime),
+			systemGauge("bytes_recv", "The total number of bytes received in all packets.", &info.BytesRecv),
+			systemGauge("bytes_sent", "The total number of bytes sent to clients.", &info.BytesSent),
+			systemGauge("clients_connected", "The number of currently connected clients.", &info.ClientsConnected),
+			systemGauge("clients_disconnected", "The number of disconnected non-cleansession clients.", &info.ClientsDisconnected),
+			systemGauge("clients_max", "The maximum number of clients that have been concurrently connected.", &info.ClientsMax),
+			systemGauge("clients_total", "The sum of all clients, connected and disconnected.", &info.ClientsTotal),
+			systemGauge("connections_total", "The sum number of clients which have ever connected.", &info.ConnectionsTotal),
+			systemGauge("messages_recv", "The total number of packets received.", &info.MessagesRecv),
+			systemGauge("messages_sent", "The total number of packets sent.", &info.MessagesSent),
+			systemGauge("publish_dropped", "The number of in-flight publish messages which were dropped.", &info.PublishDropped),
+			systemGauge("publish_recv", "The total number of received publish packets.", &info.PublishRecv),
+			systemGauge("publish_sent", "The total number of sent publish packets.", &info.PublishSent),
+			systemGauge("retained", "The number of messages currently retained.", &info.Retained),
+			systemGauge("inflight", "The number of messages currently in-flight.", &info.Inflight),
+			systemGauge("subscriptions", "The total number of filter subscriptions.", &info.Subscriptions),
+			gauge("topics_manager_size", "The number of active topics in the topics manager.", func() float64 {
+				return float64(b.TopicsManagerSize())
+			}),
+			gauge("rejected_subscriptions", "The total number of subscriptions to new topics that were rejected because the topics manager reached its maximum size.", func() float64 {
+				return float64(b.RejectedSubscriptions())
+			}),
+			gauge("client_limit_connection_ips", "The number of remote IPs with open connections, tracked if the connections per IP are limited.", func() float64 {
+				return float64(b.ClientLimitConnectionIPs())
+			}),
+			gauge("client_limit_rejected_clients", "The total number of connections that were refused because the maximum amount of clients was connected.", func() float64 {
+				return float64(b.ClientLimitRejectedClients())
+			}),
+			gauge("client_limit_rejected_connections", "The total number of connections that were rejected because the remote IP had too many connections.", func() float64 {
+				return float64(b.ClientLimitRejectedConnections())
+			}),
+			gauge("client_limit_rejected_subscriptions", "The total number of subscriptions that were rejected because the client had too many subscriptions.", func() float64 {
+				return float64(b.ClientLimitRejectedSubscriptions())
+			}),
+			gauge("client_limit_rejected_messages", "The total number of published messages that were dropped because the client exceeded the message rate.", func() float64 {
+				return float64(b.ClientLimitRejectedMessages())
+			}),
+			gauge("retained_topics", "The number of topics the node published a retained message for.", func() float64 {
+				return float64(b.RetainedTopicsSize())
+			}),
+			gauge("reaped_connections", "The total number of idle connections that were disconnected by the idle connection reaper.", func() float64 {
+				return float64(b.ReapedConnections())
+			}),
+			gauge("expired_messages", "The total number of queued messages that were dropped because they expired.", func() float64 {
+				return float64(b.ExpiredMessages())
+			}),
+			gauge("ack_timeout_disconnects", "The total number of clients that were disconnected because they did not acknowledge messages.", func() float64 {
+				return float64(b.AckTimeoutDisconnects())
+			}),
+			gauge("topic_hooks_dropped", "The total number of topic hook invocations that were dropped because the queue was full.", func() float64 {
+				return float64(b.DroppedTopicHookInvocations())
+			}),
+			gauge("topic_hooks_failed", "The total number of topic hook invocations that failed.", func() float64 {
+				return float64(b.FailedTopicHookInvocations())
+			}),
+			gauge("slow_client_dropped_messages", "The total number of messages that were dropped because the outgoing buffer of the client was saturated.", func() float64 {
+				return float64(b.SlowClientDroppedMessages())
+			}),
+			gauge("firehose_dropped_messages", "The total number of messages on the firehose topics that were dropped because the outgoing buffer of the client was filled above the backpressure threshold.", func() float64 {
+				return float64(b.FirehoseDroppedMessages())
+			}),
+			gauge("slow_client_disconnects", "The total number of clients that were disconnected because their outgoing buffer was saturated.", func() float64 {
+				return float64(b.SlowClientDisconnects())
+			}),
+			gauge("event_callbacks_dropped", "The total number of client and publish events that were not passed to the callbacks because the queue was full.", func() float64 {
+				return float64(b.DroppedEventCallbacks())
+			}),
+			gauge("coalesced_messages", "The total number of messages that were not published because a newer message on the same topic replaced them within the coalesce window.", func() float64 {
+				return float64(b.CoalescedMessages())
+			}),
+			gauge("bridge_dropped", "The total number of messages that were not forwarded to the upstream broker because the bridge queue was full.", func() float64 {
+				return float64(b.DroppedBridgeMessages())
+			}),
+			gauge("bridge_failed", "The total number of messages that could not be forwarded to the upstream broker.", func() float64 {
+				return float64(b.FailedBridgeMessages())
+			}),
+			gauge("bridge_offline_queued", "The number of messages in the offline queue of the bridge.", func() float64 {
+				return float64(b.OfflineQueuedBridgeMessages())
+			}),
+			gauge("bridge_offline_dropped", "The total number of messages that were dropped because the offline queue of the bridge was full.", func() float64 {
+				return float64(b.OfflineDroppedBridgeMessages())
+			}),
+		},
+		collectors: []prometheus.Collector{
+			b.publishLatency,
+		},
+	}
+}
+
+// RegisterMetrics registers the metrics of the broker at the registry.
+// The metrics are updated on scrape.
+func (b *Broker) RegisterMetrics(registry *prometheus.Registry) error {
+	return registry.Register(newBrokerMetricsCollector(b))
+}
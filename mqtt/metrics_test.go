@@ -0,0 +1,41 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetricsPublishLatencyHistogram(t *testing.T) {
+	broker, _ := newTestBroker(t)
+
+	registry := prometheus.NewRegistry()
+	if err := broker.RegisterMetrics(registry); err != nil {
+		t.Fatalf("registering metrics failed: %s", err)
+	}
+
+	if err := broker.Send("milestones", []byte("milestone")); err != nil {
+		t.Fatalf("sending message failed: %s", err)
+	}
+	if err := broker.SendWithOptions("milestones", []byte("milestone"), 1, false); err != nil {
+		t.Fatalf("sending message with options failed: %s", err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gathering metrics failed: %s", err)
+	}
+
+	for _, family := range families {
+		if family.GetName() != "iota_mqtt_broker_publish_latency_seconds" {
+			continue
+		}
+
+		if count := family.GetMetric()[0].GetHistogram().GetSampleCount(); count != 2 {
+			t.Fatalf("expected 2 publish latency samples, got %d", count)
+		}
+		return
+	}
+
+	t.Fatal("the publish latency histogram was not registered")
+}
@@ -0,0 +1,216 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// proxyProtocolHeaderTimeout is the maximum duration to receive the PROXY protocol header of a new connection.
+	proxyProtocolHeaderTimeout = 5 * time.Second
+	// proxyProtocolV1MaxHeaderLength is the maximum length of a PROXY protocol v1 header including the CRLF.
+	proxyProtocolV1MaxHeaderLength = 107
+	// proxyProtocolV2HeaderLength is the length of the fixed part of a PROXY protocol v2 header.
+	proxyProtocolV2HeaderLength = 16
+	// proxyProtocolV2CommandLocal is the v2 command of connections established by the proxy itself (e.g. health checks).
+	proxyProtocolV2CommandLocal = 0x0
+	// proxyProtocolV2CommandProxy is the v2 command of proxied connections.
+	proxyProtocolV2CommandProxy = 0x1
+	// proxyProtocolV2FamilyTCP4 is the v2 address family and protocol of TCP over IPv4.
+	proxyProtocolV2FamilyTCP4 = 0x11
+	// proxyProtocolV2FamilyTCP6 is the v2 address family and protocol of TCP over IPv6.
+	proxyProtocolV2FamilyTCP6 = 0x21
+)
+
+var (
+	// ErrInvalidProxyProtocolHeader is returned if a connection did not start with a valid PROXY protocol header.
+	ErrInvalidProxyProtocolHeader = errors.New("invalid PROXY protocol header")
+
+	proxyProtocolV1Prefix    = []byte("PROXY ")
+	proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// proxyProtocolListener wraps the accepted connections to read the PROXY protocol header (v1 or v2) sent by a load balancer.
+type proxyProtocolListener struct {
+	net.Listener
+}
+
+// Accept waits for the next connection. The header is read on the first use of the connection,
+// so slow clients don't block accepting further connections.
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &proxyProtocolConn{
+		Conn:   conn,
+		reader: bufio.NewReader(conn),
+	}, nil
+}
+
+// proxyProtocolConn is a connection that starts with a PROXY protocol header.
+// The remote address of the connection is the address of the client given in the header.
+// If the header is invalid, every read fails, so the connection is rejected.
+type proxyProtocolConn struct {
+	net.Conn
+	reader *bufio.Reader
+
+	headerOnce sync.Once
+	headerErr  error
+	// remoteAddr is the address of the client, nil if the header does not contain an address.
+	remoteAddr net.Addr
+}
+
+// ReadHeader reads the PROXY protocol header, it is only read once.
+func (c *proxyProtocolConn) ReadHeader() error {
+	c.headerOnce.Do(func() {
+		if err := c.Conn.SetReadDeadline(time.Now().Add(proxyProtocolHeaderTimeout)); err != nil {
+			c.headerErr = err
+			return
+		}
+
+		c.remoteAddr, c.headerErr = readProxyProtocolHeader(c.reader)
+		if c.headerErr != nil {
+			return
+		}
+
+		c.headerErr = c.Conn.SetReadDeadline(time.Time{})
+	})
+
+	return c.headerErr
+}
+
+// Read reads data from the connection after the PROXY protocol header.
+func (c *proxyProtocolConn) Read(p []byte) (int, error) {
+	if err := c.ReadHeader(); err != nil {
+		return 0, err
+	}
+
+	return c.reader.Read(p)
+}
+
+// RemoteAddr returns the address of the client given in the PROXY protocol header,
+// or the address of the proxy if the header does not contain an address.
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	if c.ReadHeader() == nil && c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyProtocolHeader reads a PROXY protocol v1 or v2 header and returns the source address it contains.
+func readProxyProtocolHeader(reader *bufio.Reader) (net.Addr, error) {
+	// the v1 prefix is shorter than the v2 signature, so it is peeked first
+	prefix, err := reader.Peek(len(proxyProtocolV1Prefix))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProxyProtocolHeader, err)
+	}
+
+	if bytes.Equal(prefix, proxyProtocolV1Prefix) {
+		return readProxyProtocolV1Header(reader)
+	}
+
+	signature, err := reader.Peek(len(proxyProtocolV2Signature))
+	if err != nil || !bytes.Equal(signature, proxyProtocolV2Signature) {
+		return nil, fmt.Errorf("%w: unknown signature", ErrInvalidProxyProtocolHeader)
+	}
+
+	return readProxyProtocolV2Header(reader)
+}
+
+// readProxyProtocolV1Header reads a human-readable v1 header, e.g. "PROXY TCP4 192.0.2.1 198.51.100.1 56324 1883\r\n".
+func readProxyProtocolV1Header(reader *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyProtocolV1MaxHeaderLength {
+			return nil, fmt.Errorf("%w: header too long", ErrInvalidProxyProtocolHeader)
+		}
+
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidProxyProtocolHeader, err)
+		}
+		line = append(line, b)
+	}
+
+	fields := strings.Split(strings.TrimSuffix(string(line), "\r\n"), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		// the proxy can't provide the address, the rest of the line is ignored
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("%w: malformed v1 header", ErrInvalidProxyProtocolHeader)
+	}
+
+	ip := net.ParseIP(fields[2])
+	if ip == nil || (fields[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("%w: invalid source address %s", ErrInvalidProxyProtocolHeader, fields[2])
+	}
+
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid source port %s", ErrInvalidProxyProtocolHeader, fields[4])
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyProtocolV2Header reads a binary v2 header.
+func readProxyProtocolV2Header(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, proxyProtocolV2HeaderLength)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProxyProtocolHeader, err)
+	}
+
+	if header[12]>>4 != 2 {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidProxyProtocolHeader, header[12]>>4)
+	}
+	command := header[12] & 0x0f
+	family := header[13]
+
+	// the addresses are followed by optional TLVs, which are skipped
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProxyProtocolHeader, err)
+	}
+
+	switch command {
+	case proxyProtocolV2CommandLocal:
+		// the connection was established by the proxy itself, the real connection endpoints are used
+		return nil, nil
+
+	case proxyProtocolV2CommandProxy:
+
+	default:
+		return nil, fmt.Errorf("%w: unsupported command %d", ErrInvalidProxyProtocolHeader, command)
+	}
+
+	switch family {
+	case proxyProtocolV2FamilyTCP4:
+		if len(payload) < 12 {
+			return nil, fmt.Errorf("%w: truncated IPv4 addresses", ErrInvalidProxyProtocolHeader)
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+
+	case proxyProtocolV2FamilyTCP6:
+		if len(payload) < 36 {
+			return nil, fmt.Errorf("%w: truncated IPv6 addresses", ErrInvalidProxyProtocolHeader)
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+
+	default:
+		// other protocols (e.g. UDP or unix sockets) don't provide a usable address
+		return nil, nil
+	}
+}
@@ -0,0 +1,109 @@
+package mqtt
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// coalescedPublish is the pending publish of a coalesced topic.
+type coalescedPublish struct {
+	// send publishes the latest payload of the topic.
+	send func()
+	// the timer that sends the publish after the coalesce window.
+	timer *time.Timer
+}
+
+// publishCoalescer delays the publishes on topics that represent a current state (e.g. the state of a single output)
+// by a time window, and only sends the latest payload of each topic at the end of the window.
+// The window starts with the first publish on the topic, so a topic that is published continuously is still sent once per window.
+type publishCoalescer struct {
+	window  time.Duration
+	filters []string
+
+	pending     map[string]*coalescedPublish
+	pendingLock sync.Mutex
+
+	// coalescedMessages is the amount of messages that were replaced by a newer payload on the same topic.
+	coalescedMessages uint64
+}
+
+// Matches returns true if the publishes on the topic are coalesced.
+func (c *publishCoalescer) Matches(topic string) bool {
+	for _, filter := range c.filters {
+		if topicMatchesFilter(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add replaces the pending publish of the topic with the given send function.
+// The send function of the latest publish is called at the end of the coalesce window of the topic.
+func (c *publishCoalescer) Add(topic string, send func()) {
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+
+	if publish, has := c.pending[topic]; has {
+		publish.send = send
+		atomic.AddUint64(&c.coalescedMessages, 1)
+		return
+	}
+
+	c.pending[topic] = &coalescedPublish{
+		send:  send,
+		timer: time.AfterFunc(c.window, func() { c.Flush(topic) }),
+	}
+}
+
+// Flush sends the pending publish of the topic immediately.
+func (c *publishCoalescer) Flush(topic string) {
+	c.pendingLock.Lock()
+	publish, has := c.pending[topic]
+	if !has {
+		c.pendingLock.Unlock()
+		return
+	}
+	publish.timer.Stop()
+	delete(c.pending, topic)
+	c.pendingLock.Unlock()
+
+	publish.send()
+}
+
+// FlushAll sends the pending publishes of all topics immediately.
+func (c *publishCoalescer) FlushAll() {
+	c.pendingLock.Lock()
+	pending := c.pending
+	c.pending = make(map[string]*coalescedPublish)
+	c.pendingLock.Unlock()
+
+	for _, publish := range pending {
+		publish.timer.Stop()
+		publish.send()
+	}
+}
+
+// CoalescedMessages returns the amount of messages that were replaced by a newer payload on the same topic.
+func (c *publishCoalescer) CoalescedMessages() uint64 {
+	return atomic.LoadUint64(&c.coalescedMessages)
+}
+
+// Stop drops all pending publishes.
+func (c *publishCoalescer) Stop() {
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+
+	for topic, publish := range c.pending {
+		publish.timer.Stop()
+		delete(c.pending, topic)
+	}
+}
+
+func newPublishCoalescer(window time.Duration, filters []string) *publishCoalescer {
+	return &publishCoalescer{
+		window:  window,
+		filters: filters,
+		pending: make(map[string]*coalescedPublish),
+	}
+}
@@ -0,0 +1,83 @@
+package mqtt
+
+import (
+	"container/list"
+	"sync"
+)
+
+type OnEvictRetainedHandler func(topic string)
+
+// retainedManager keeps track of the topics the broker stores a retained message for.
+// If the maximum amount of retained messages is exceeded, the least recently updated topics get evicted.
+type retainedManager struct {
+	retainedTopics     *list.List
+	retainedTopicsMap  map[string]*list.Element
+	retainedTopicsLock sync.Mutex
+
+	maxRetainedMessages int
+
+	onEvict OnEvictRetainedHandler
+}
+
+// Retain stores the retained message of the topic by calling retainFunc and marks the topic as most recently updated.
+// The topics that exceed the maximum amount of retained messages are evicted afterwards.
+func (r *retainedManager) Retain(topic string, retainFunc func() error) error {
+	r.retainedTopicsLock.Lock()
+	defer r.retainedTopicsLock.Unlock()
+
+	if err := retainFunc(); err != nil {
+		return err
+	}
+
+	if element, has := r.retainedTopicsMap[topic]; has {
+		r.retainedTopics.MoveToFront(element)
+	} else {
+		r.retainedTopicsMap[topic] = r.retainedTopics.PushFront(topic)
+	}
+
+	if r.maxRetainedMessages == 0 {
+		return nil
+	}
+
+	for r.retainedTopics.Len() > r.maxRetainedMessages {
+		oldest := r.retainedTopics.Back()
+		evictedTopic := r.retainedTopics.Remove(oldest).(string)
+		delete(r.retainedTopicsMap, evictedTopic)
+
+		if r.onEvict != nil {
+			r.onEvict(evictedTopic)
+		}
+	}
+
+	return nil
+}
+
+// Size returns the amount of topics with a retained message.
+func (r *retainedManager) Size() int {
+	r.retainedTopicsLock.Lock()
+	defer r.retainedTopicsLock.Unlock()
+
+	return r.retainedTopics.Len()
+}
+
+// Topics returns the topics with a retained message, ordered from the least to the most recently updated topic.
+func (r *retainedManager) Topics() []string {
+	r.retainedTopicsLock.Lock()
+	defer r.retainedTopicsLock.Unlock()
+
+	topics := make([]string, 0, r.retainedTopics.Len())
+	for element := r.retainedTopics.Back(); element != nil; element = element.Prev() {
+		topics = append(topics, element.Value.(string))
+	}
+
+	return topics
+}
+
+func newRetainedManager(onEvict OnEvictRetainedHandler, maxRetainedMessages int) *retainedManager {
+	return &retainedManager{
+		retainedTopics:      list.New(),
+		retainedTopicsMap:   make(map[string]*list.Element),
+		maxRetainedMessages: maxRetainedMessages,
+		onEvict:             onEvict,
+	}
+}
@@ -0,0 +1,93 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// retainedStoreFileVersion is the version of the format of the retained store file.
+	retainedStoreFileVersion = 1
+)
+
+// RetainedMessage is a retained message of a topic (without the topic prefix).
+type RetainedMessage struct {
+	Topic   string `json:"topic"`
+	Payload []byte `json:"payload"`
+}
+
+// RetainedStore persists the retained messages of the broker across restarts.
+type RetainedStore interface {
+	// Load returns the stored retained messages, ordered from the least to the most recently updated topic.
+	Load() ([]*RetainedMessage, error)
+	// Save replaces the stored retained messages, ordered from the least to the most recently updated topic.
+	Save(messages []*RetainedMessage) error
+}
+
+// retainedStoreFile is the content of the retained store file.
+type retainedStoreFile struct {
+	Version  int                `json:"version"`
+	Messages []*RetainedMessage `json:"messages"`
+}
+
+// FileRetainedStore is a RetainedStore that stores the retained messages in a JSON file.
+type FileRetainedStore struct {
+	path string
+}
+
+// Load reads the retained messages from the file. A missing file is treated as an empty store.
+func (s *FileRetainedStore) Load() ([]*RetainedMessage, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading retained store file failed: %w", err)
+	}
+
+	file := &retainedStoreFile{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("parsing retained store file failed: %w", err)
+	}
+	if file.Version != retainedStoreFileVersion {
+		return nil, fmt.Errorf("unsupported retained store file version %d", file.Version)
+	}
+
+	return file.Messages, nil
+}
+
+// Save writes the retained messages to a temporary file and replaces the file afterwards,
+// so an interrupted write never leaves a truncated file behind.
+func (s *FileRetainedStore) Save(messages []*RetainedMessage) error {
+	data, err := json.Marshal(&retainedStoreFile{
+		Version:  retainedStoreFileVersion,
+		Messages: messages,
+	})
+	if err != nil {
+		return fmt.Errorf("encoding retained messages failed: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("creating retained store directory failed: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("writing retained store file failed: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("replacing retained store file failed: %w", err)
+	}
+
+	return nil
+}
+
+// NewFileRetainedStore creates a RetainedStore that stores the retained messages in the JSON file at the given path.
+func NewFileRetainedStore(path string) *FileRetainedStore {
+	return &FileRetainedStore{
+		path: path,
+	}
+}
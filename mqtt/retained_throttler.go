@@ -0,0 +1,122 @@
+package mqtt
+
+import (
+	"sync"
+	"time"
+)
+
+type throttledTopic struct {
+	// the payload of the latest update that was not retained yet.
+	pendingPayload []byte
+	// the timer that retains the pending payload or releases the throttled topic after the interval.
+	timer *time.Timer
+}
+
+// retainedThrottler coalesces retained message updates, so that the retained message of a topic is
+// refreshed at most once per interval, while the live (non-retained) publishes still happen for every update.
+// The retained message may lag behind the live messages by up to the interval.
+type retainedThrottler struct {
+	interval time.Duration
+
+	throttledTopics     map[string]*throttledTopic
+	throttledTopicsLock sync.Mutex
+
+	// publishRetainedFunc publishes the message to the subscribers and stores it as the retained message.
+	publishRetainedFunc func(topic string, payload []byte) error
+	// publishFunc publishes the message to the subscribers without storing it as the retained message.
+	publishFunc func(topic string, payload []byte) error
+	// updateRetainedFunc stores the message as the retained message without publishing it to the subscribers.
+	updateRetainedFunc func(topic string, payload []byte) error
+}
+
+// Send publishes the message and updates the retained message of the topic if the interval since
+// the last update has passed. Otherwise the retained message is updated after the interval.
+func (t *retainedThrottler) Send(topic string, payload []byte) error {
+	t.throttledTopicsLock.Lock()
+	defer t.throttledTopicsLock.Unlock()
+
+	throttled, has := t.throttledTopics[topic]
+	if !has {
+		if err := t.publishRetainedFunc(topic, payload); err != nil {
+			return err
+		}
+
+		t.throttledTopics[topic] = &throttledTopic{
+			timer: time.AfterFunc(t.interval, func() { t.flush(topic) }),
+		}
+		return nil
+	}
+
+	if err := t.publishFunc(topic, payload); err != nil {
+		return err
+	}
+	throttled.pendingPayload = payload
+
+	return nil
+}
+
+// Retain updates the retained message of the topic without publishing it if the interval since
+// the last update has passed. Otherwise the retained message is updated after the interval.
+func (t *retainedThrottler) Retain(topic string, payload []byte) error {
+	t.throttledTopicsLock.Lock()
+	defer t.throttledTopicsLock.Unlock()
+
+	throttled, has := t.throttledTopics[topic]
+	if !has {
+		if err := t.updateRetainedFunc(topic, payload); err != nil {
+			return err
+		}
+
+		t.throttledTopics[topic] = &throttledTopic{
+			timer: time.AfterFunc(t.interval, func() { t.flush(topic) }),
+		}
+		return nil
+	}
+
+	throttled.pendingPayload = payload
+
+	return nil
+}
+
+// flush retains the pending payload of a topic, or releases the topic if there were no updates during the interval.
+func (t *retainedThrottler) flush(topic string) {
+	t.throttledTopicsLock.Lock()
+	defer t.throttledTopicsLock.Unlock()
+
+	throttled, has := t.throttledTopics[topic]
+	if !has {
+		return
+	}
+
+	if throttled.pendingPayload == nil {
+		delete(t.throttledTopics, topic)
+		return
+	}
+
+	// the error is ignored, the retained message will be refreshed on the next update
+	_ = t.updateRetainedFunc(topic, throttled.pendingPayload)
+
+	throttled.pendingPayload = nil
+	throttled.timer = time.AfterFunc(t.interval, func() { t.flush(topic) })
+}
+
+// Stop stops all pending updates of the retained messages.
+func (t *retainedThrottler) Stop() {
+	t.throttledTopicsLock.Lock()
+	defer t.throttledTopicsLock.Unlock()
+
+	for topic, throttled := range t.throttledTopics {
+		throttled.timer.Stop()
+		delete(t.throttledTopics, topic)
+	}
+}
+
+func newRetainedThrottler(interval time.Duration, publishRetainedFunc func(topic string, payload []byte) error, publishFunc func(topic string, payload []byte) error, updateRetainedFunc func(topic string, payload []byte) error) *retainedThrottler {
+	return &retainedThrottler{
+		interval:            interval,
+		throttledTopics:     make(map[string]*throttledTopic),
+		publishRetainedFunc: publishRetainedFunc,
+		publishFunc:         publishFunc,
+		updateRetainedFunc:  updateRetainedFunc,
+	}
+}
@@ -0,0 +1,120 @@
+package mqtt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// retainedPayload returns the payload of the retained message of the topic, or an empty string if there is none.
+func retainedPayload(b *Broker, topic string) string {
+	messages := b.broker.Topics.Messages(b.prefixTopic(topic))
+	if len(messages) == 0 {
+		return ""
+	}
+	return string(messages[0].Payload)
+}
+
+// waitForSysTopics waits until the underlying broker published its system topics, which are needed to create retained messages.
+func waitForSysTopics(t *testing.T, b *Broker) {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for len(b.broker.Topics.Messages(sysTopicTemplate)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the system topics were not published")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSendWithOptionsRetainUpdateInterval(t *testing.T) {
+	const interval = 200 * time.Millisecond
+
+	broker, address := newTestBroker(t, WithRetainUpdateInterval(interval))
+	waitForSysTopics(t, broker)
+
+	received := make(chan string, 10)
+	client := mustConnectTestClient(t, address, "subscriber")
+	subscribeTestClient(t, client, "milestone-info/latest", 1, func(_ paho.Client, message paho.Message) {
+		received <- string(message.Payload())
+	})
+
+	start := time.Now()
+	for _, payload := range []string{"1", "2", "3"} {
+		if err := broker.SendWithOptions("milestone-info/latest", []byte(payload), 1, true); err != nil {
+			t.Fatalf("sending %s failed: %s", payload, err)
+		}
+	}
+
+	// the live messages are published every time
+	for _, expected := range []string{"1", "2", "3"} {
+		select {
+		case payload := <-received:
+			if payload != expected {
+				t.Fatalf("received %s, expected %s", payload, expected)
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("message %s was not received", expected)
+		}
+	}
+
+	// the retained message is only refreshed once per interval
+	if payload := retainedPayload(broker, "milestone-info/latest"); payload != "1" && time.Since(start) < interval {
+		t.Fatalf("expected the first message to stay retained during the interval, got %s", payload)
+	}
+
+	deadline := time.Now().Add(testTimeout)
+	for retainedPayload(broker, "milestone-info/latest") != "3" {
+		if time.Now().After(deadline) {
+			t.Fatal("the latest message was not retained after the interval")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if time.Since(start) < interval {
+		t.Fatal("the retained message was refreshed before the interval passed")
+	}
+}
+
+func TestSendWithOptionsInvalidQoS(t *testing.T) {
+	broker, _ := newTestBroker(t)
+
+	if err := broker.SendWithOptions("milestones", nil, 3, false); !errors.Is(err, ErrInvalidQoS) {
+		t.Fatalf("expected ErrInvalidQoS, got %v", err)
+	}
+}
+
+func TestSendRetainedWithRetainPublishOptions(t *testing.T) {
+	broker, _ := newTestBroker(t,
+		WithRetainUpdateInterval(time.Minute),
+		WithTopicPublishOptions(map[string]*PublishOptions{"milestone-info/latest": {QoS: 1, Retain: true}}),
+	)
+	waitForSysTopics(t, broker)
+
+	// the live publishes of the throttler must not update the retained message through the publish options
+	done := make(chan error, 1)
+	go func() {
+		for _, payload := range []string{"1", "2"} {
+			if err := broker.SendRetained("milestone-info/latest", []byte(payload)); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("sending failed: %s", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("sending a throttled topic with retain publish options blocked")
+	}
+
+	if payload := retainedPayload(broker, "milestone-info/latest"); payload != "1" {
+		t.Fatalf("expected the first message to stay retained during the interval, got %s", payload)
+	}
+}
@@ -0,0 +1,30 @@
+package mqtt
+
+import (
+	"net"
+	"sync/atomic"
+
+	"github.com/mochi-co/mqtt/server/listeners"
+	"github.com/mochi-co/mqtt/server/listeners/auth"
+)
+
+// shutdownListener wraps a listener of the underlying broker to reject new connections while the broker is shut down gracefully.
+type shutdownListener struct {
+	listeners.Listener
+	// shuttingDown is set while the broker is shut down gracefully.
+	shuttingDown *uint32
+}
+
+// Serve starts waiting for new connections of the wrapped listener. While the broker is shutting down,
+// the CONNECT packets are answered with the return code 0x03 (server unavailable) before the clients are authenticated,
+// so reconnecting clients back off instead of treating the connection as accepted.
+func (l *shutdownListener) Serve(establish listeners.EstablishFunc) {
+	l.Listener.Serve(func(id string, conn net.Conn, ac auth.Controller) error {
+		if atomic.LoadUint32(l.shuttingDown) == 1 {
+			rejectConnection(conn)
+			return ErrShuttingDown
+		}
+
+		return establish(id, conn, ac)
+	})
+}
@@ -0,0 +1,150 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+
+	"github.com/iotaledger/hive.go/logger"
+)
+
+func TestShutdownRejectsNewConnectionsBeforeConnack(t *testing.T) {
+	broker, address := newTestBroker(t)
+
+	// the unacknowledged message keeps the shutdown waiting
+	conn := connectRawTestClient(t, address, "pending", 0)
+	subscribeRawTestClient(t, conn, "milestones", 1)
+	if err := broker.SendWithOptions("milestones", []byte("1"), 1, false); err != nil {
+		t.Fatalf("sending message failed: %s", err)
+	}
+	published, ok := readRawTestPacket(t, conn).(*packets.PublishPacket)
+	if !ok {
+		t.Fatal("expected the published message")
+	}
+
+	type shutdownResult struct {
+		undelivered int
+		err         error
+	}
+	resultChan := make(chan shutdownResult, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*testTimeout)
+		defer cancel()
+
+		undelivered, err := broker.Shutdown(ctx)
+		resultChan <- shutdownResult{undelivered: undelivered, err: err}
+	}()
+	waitFor(t, testTimeout, func() bool { return !broker.IsHealthy() }, "the shutdown did not start")
+
+	// the new client is refused with 0x03 (server unavailable) instead of being accepted and disconnected afterwards
+	if _, err := connectTestClient(t, newTestClientOptions("tcp://"+address, "late")); !errors.Is(err, packets.ErrorRefusedServerUnavailable) {
+		t.Fatalf("expected the connection to be refused as server unavailable, got %v", err)
+	}
+
+	puback := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+	puback.MessageID = published.MessageID
+	if err := puback.Write(conn); err != nil {
+		t.Fatalf("writing PUBACK failed: %s", err)
+	}
+
+	select {
+	case result := <-resultChan:
+		if result.err != nil {
+			t.Fatalf("shutting down failed: %s", result.err)
+		}
+		if result.undelivered != 0 {
+			t.Fatalf("expected no undelivered messages, got %d", result.undelivered)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("the shutdown did not finish after the message was acknowledged")
+	}
+}
+
+func TestBrokerStartStopDoesNotLeakGoroutines(t *testing.T) {
+	upstreamAddress := freeAddress(t)
+	(&upstreamBroker{}).start(t, upstreamAddress)
+
+	// startAndStop runs a broker with all background workers enabled, it is shut down gracefully or stopped
+	startAndStop := func(graceful bool) {
+		t.Helper()
+
+		address := freeAddress(t)
+		brokerOpts := &BrokerOptions{}
+		brokerOpts.ApplyOnDefault(
+			WithWebsocketEnabled(false),
+			WithTCPEnabled(true),
+			WithTCPBindAddress(address),
+			WithIdleConnectionReaperEnabled(true),
+			WithIdleConnectionTimeout(time.Minute),
+			WithMessageExpiry(map[string]time.Duration{"outputs/": time.Minute}),
+			WithAckTimeout(time.Second),
+			WithAckTimeoutMaxRetransmissions(1),
+			WithTopicHooks([]*TopicHook{{Name: "noop", Filter: "milestones", Hook: func(string, []byte) error { return nil }}}),
+			WithBridgeEnabled(true),
+			WithBridgeURL("tcp://"+upstreamAddress),
+			WithBridgeTopics([]string{"milestones"}),
+			WithPublishCoalesceWindow(100*time.Millisecond),
+			WithPublishCoalesceTopics([]string{"milestone-info/latest"}),
+		)
+
+		broker, err := NewBroker(logger.NewNopLogger(), func(string) {}, func(string) {}, brokerOpts)
+		if err != nil {
+			t.Fatalf("creating broker failed: %s", err)
+		}
+		if err := broker.Start(); err != nil {
+			t.Fatalf("starting broker failed: %s", err)
+		}
+
+		client := mustConnectTestClient(t, address, "client")
+		subscribeTestClient(t, client, "milestones", 1, func(paho.Client, paho.Message) {})
+		for _, topic := range []string{"milestones", "milestone-info/latest"} {
+			if err := broker.SendWithOptions(topic, []byte(topic), 1, false); err != nil {
+				t.Fatalf("sending %s failed: %s", topic, err)
+			}
+		}
+		client.Disconnect(0)
+		// the buffers of a disconnecting client are released by the underlying broker without synchronization
+		waitFor(t, testTimeout, func() bool {
+			existing, ok := broker.broker.Clients.Get("client")
+			return !ok || atomic.LoadUint32(&existing.State.Done) == 1
+		}, "the client was not disconnected")
+
+		if !graceful {
+			if err := broker.Stop(); err != nil {
+				t.Fatalf("stopping broker failed: %s", err)
+			}
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+		if _, err := broker.Shutdown(ctx); err != nil {
+			t.Fatalf("shutting down broker failed: %s", err)
+		}
+	}
+
+	// the first run starts the goroutines that are kept for the whole process (e.g. of the logger and the HTTP transport)
+	startAndStop(false)
+	time.Sleep(100 * time.Millisecond)
+	baseline := runtime.NumGoroutine()
+
+	for i := 0; i < 3; i++ {
+		startAndStop(i%2 == 0)
+	}
+
+	// the goroutines of the closed connections end asynchronously
+	deadline := time.Now().Add(testTimeout)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			t.Fatalf("expected at most %d goroutines after stopping the brokers, got %d:\n%s", baseline, runtime.NumGoroutine(), buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
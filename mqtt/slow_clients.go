@@ -0,0 +1,390 @@
+package mqtt
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SlowClientPolicy defines what happens to messages for a client whose outgoing buffer is saturated.
+type SlowClientPolicy string
+
+const (
+	// SlowClientPolicyBlock waits until the client read enough data to fit the message into its outgoing buffer.
+	// A single slow client delays the delivery to all other clients.
+	SlowClientPolicyBlock SlowClientPolicy = "block"
+	// SlowClientPolicyDropOldest queues the messages in a backlog of the size of the client buffer
+	// and drops the oldest queued messages if the backlog is full.
+	SlowClientPolicyDropOldest SlowClientPolicy = "drop-oldest"
+	// SlowClientPolicyDropNewest drops the messages that don't fit into the outgoing buffer of the client.
+	SlowClientPolicyDropNewest SlowClientPolicy = "drop-newest"
+	// SlowClientPolicyDisconnect disconnects the client if a message doesn't fit into its outgoing buffer.
+	SlowClientPolicyDisconnect SlowClientPolicy = "disconnect"
+)
+
+const (
+	// defaultClientBufferSize is the size of the client buffers of the underlying broker if no buffer size is configured.
+	defaultClientBufferSize = 1024 * 256
+	// publishPacketOverhead is the maximum size of a publish packet without the topic name and the payload
+	// (fixed header, topic length and packet ID).
+	publishPacketOverhead = 9
+	// slowClientBacklogFlushInterval is the interval in which the backlogs of slow clients are written
+	// to their outgoing buffers if there is enough space again.
+	slowClientBacklogFlushInterval = 50 * time.Millisecond
+)
+
+// FirehosePolicy defines what happens to messages on the firehose topics for a client under backpressure.
+type FirehosePolicy string
+
+const (
+	// FirehosePolicyNone delivers the firehose messages like the messages of all other topics.
+	FirehosePolicyNone FirehosePolicy = "none"
+	// FirehosePolicyDrop drops the firehose messages for clients under backpressure.
+	FirehosePolicyDrop FirehosePolicy = "drop"
+	// FirehosePolicySample only delivers every n-th firehose message to clients under backpressure.
+	FirehosePolicySample FirehosePolicy = "sample"
+)
+
+// validateSlowClientPolicy checks that the slow client policy is known.
+func validateSlowClientPolicy(policy SlowClientPolicy) error {
+	switch policy {
+	case SlowClientPolicyBlock, SlowClientPolicyDropOldest, SlowClientPolicyDropNewest, SlowClientPolicyDisconnect:
+		return nil
+	default:
+		return fmt.Errorf("invalid slow client policy \"%s\", allowed values: %s, %s, %s, %s", policy, SlowClientPolicyBlock, SlowClientPolicyDropOldest, SlowClientPolicyDropNewest, SlowClientPolicyDisconnect)
+	}
+}
+
+// validateFirehosePolicy checks that the firehose policy is known and its parameters are valid.
+func validateFirehosePolicy(policy FirehosePolicy, backpressureThreshold int, sampleRate int) error {
+	switch policy {
+	case FirehosePolicyNone, FirehosePolicyDrop, FirehosePolicySample:
+	default:
+		return fmt.Errorf("invalid firehose policy \"%s\", allowed values: %s, %s, %s", policy, FirehosePolicyNone, FirehosePolicyDrop, FirehosePolicySample)
+	}
+
+	if policy == FirehosePolicyNone {
+		return nil
+	}
+	if backpressureThreshold < 1 || backpressureThreshold > 100 {
+		return fmt.Errorf("invalid firehose backpressure threshold %d, must be between 1 and 100 percent", backpressureThreshold)
+	}
+	if policy == FirehosePolicySample && sampleRate < 1 {
+		return fmt.Errorf("invalid firehose sample rate %d, must be at least 1", sampleRate)
+	}
+
+	return nil
+}
+
+// firehoseGuard degrades the messages on the firehose topics for clients under backpressure.
+// A client is under backpressure if its outgoing buffer is filled above the threshold, which is lower than the buffer size,
+// so the firehose messages are dropped before the buffer is saturated and the remaining buffer is kept for the other topics.
+type firehoseGuard struct {
+	policy  FirehosePolicy
+	filters []string
+	// threshold is the amount of queued bytes of a client above which the client is under backpressure.
+	threshold  int
+	sampleRate uint64
+
+	// sampleCounters are the amount of firehose messages per client that were sent while the client was under backpressure.
+	sampleCounters     map[string]uint64
+	sampleCountersLock sync.Mutex
+
+	// droppedMessages is the amount of firehose messages that were dropped because the client was under backpressure.
+	droppedMessages uint64
+}
+
+// Matches returns true if the topic is a firehose topic.
+func (f *firehoseGuard) Matches(topic string) bool {
+	for _, filter := range f.filters {
+		if topicMatchesFilter(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allow returns false if the firehose message must be dropped for a client that has the given amount of bytes
+// in its outgoing buffer including the message.
+func (f *firehoseGuard) Allow(clientID string, queuedBytes int) bool {
+	if queuedBytes <= f.threshold {
+		return true
+	}
+
+	if f.policy == FirehosePolicySample {
+		f.sampleCountersLock.Lock()
+		counter := f.sampleCounters[clientID]
+		f.sampleCounters[clientID] = counter + 1
+		f.sampleCountersLock.Unlock()
+
+		if counter%f.sampleRate == 0 {
+			return true
+		}
+	}
+
+	atomic.AddUint64(&f.droppedMessages, 1)
+	return false
+}
+
+// Remove removes the sample counter of a disconnected client.
+func (f *firehoseGuard) Remove(clientID string) {
+	f.sampleCountersLock.Lock()
+	defer f.sampleCountersLock.Unlock()
+
+	delete(f.sampleCounters, clientID)
+}
+
+// DroppedMessages returns the amount of firehose messages that were dropped because the client was under backpressure.
+func (f *firehoseGuard) DroppedMessages() uint64 {
+	return atomic.LoadUint64(&f.droppedMessages)
+}
+
+func newFirehoseGuard(policy FirehosePolicy, filters []string, bufferSize int, backpressureThreshold int, sampleRate int) *firehoseGuard {
+	if bufferSize <= 0 {
+		bufferSize = defaultClientBufferSize
+	}
+
+	return &firehoseGuard{
+		policy:         policy,
+		filters:        filters,
+		threshold:      bufferSize * backpressureThreshold / 100,
+		sampleRate:     uint64(sampleRate),
+		sampleCounters: make(map[string]uint64),
+	}
+}
+
+// pendingWrite is a message in the backlog of a slow client.
+type pendingWrite struct {
+	size  int
+	write func() error
+}
+
+// clientBacklog are the messages that did not fit into the outgoing buffer of a client yet.
+type clientBacklog struct {
+	writes []*pendingWrite
+	size   int
+}
+
+// slowClientGuard applies the slow client policy to the messages written to clients with a saturated outgoing buffer,
+// and the firehose policy to the messages on the firehose topics written to clients under backpressure.
+// The firehose policy applies first, so the subscribers of the other topics are protected over the firehose subscribers.
+type slowClientGuard struct {
+	policy     SlowClientPolicy
+	bufferSize int
+	// firehose degrades the firehose topics for clients under backpressure (optional).
+	firehose *firehoseGuard
+
+	backlogs     map[string]*clientBacklog
+	backlogsLock sync.Mutex
+
+	// queuedBytesFunc returns the amount of bytes in the outgoing buffer of the client, false if the client is unknown.
+	queuedBytesFunc func(clientID string) (int, bool)
+	// disconnectFunc disconnects the client, it returns false if the client was already disconnected.
+	disconnectFunc func(clientID string) bool
+
+	// droppedMessages is the amount of messages that were dropped because the outgoing buffer of the client was saturated.
+	droppedMessages uint64
+	// disconnectedClients is the amount of clients that were disconnected because their outgoing buffer was saturated.
+	disconnectedClients uint64
+
+	shutdownChan chan struct{}
+	shutdownOnce sync.Once
+	shutdownWG   sync.WaitGroup
+}
+
+// Write writes a message of the given size on the topic to the client by calling writeFunc,
+// or applies the slow client policy if the message doesn't fit into the outgoing buffer of the client.
+// Messages on the firehose topics are dropped by the firehose policy first, if the client is under backpressure.
+func (g *slowClientGuard) Write(clientID string, topic string, size int, writeFunc func() error) error {
+	firehose := g.firehose != nil && g.firehose.Matches(topic)
+	if g.policy == SlowClientPolicyBlock && !firehose {
+		return writeFunc()
+	}
+
+	queuedBytes, ok := g.queuedBytesFunc(clientID)
+	if !ok {
+		return writeFunc()
+	}
+
+	if firehose && !g.firehose.Allow(clientID, queuedBytes+size) {
+		return nil
+	}
+
+	saturated := queuedBytes+size > g.bufferSize
+
+	switch g.policy {
+	case SlowClientPolicyDropNewest:
+		if saturated {
+			atomic.AddUint64(&g.droppedMessages, 1)
+			return nil
+		}
+
+	case SlowClientPolicyDisconnect:
+		if saturated {
+			if g.disconnectFunc(clientID) {
+				atomic.AddUint64(&g.disconnectedClients, 1)
+			}
+			return ErrSlowClient
+		}
+
+	case SlowClientPolicyDropOldest:
+		g.backlogsLock.Lock()
+		backlog, has := g.backlogs[clientID]
+		if !saturated && !has {
+			g.backlogsLock.Unlock()
+			break
+		}
+
+		// the message is queued behind the backlog, so the order of the messages is kept
+		if !has {
+			backlog = &clientBacklog{}
+			g.backlogs[clientID] = backlog
+		}
+		backlog.writes = append(backlog.writes, &pendingWrite{size: size, write: writeFunc})
+		backlog.size += size
+
+		for backlog.size > g.bufferSize && len(backlog.writes) > 1 {
+			backlog.size -= backlog.writes[0].size
+			backlog.writes = backlog.writes[1:]
+			atomic.AddUint64(&g.droppedMessages, 1)
+		}
+		g.backlogsLock.Unlock()
+
+		return nil
+	}
+
+	return writeFunc()
+}
+
+// Remove removes the backlog of a disconnected client.
+func (g *slowClientGuard) Remove(clientID string) {
+	g.backlogsLock.Lock()
+	defer g.backlogsLock.Unlock()
+
+	delete(g.backlogs, clientID)
+
+	if g.firehose != nil {
+		g.firehose.Remove(clientID)
+	}
+}
+
+// DroppedMessages returns the amount of messages that were dropped because the outgoing buffer of the client was saturated.
+func (g *slowClientGuard) DroppedMessages() uint64 {
+	return atomic.LoadUint64(&g.droppedMessages)
+}
+
+// DisconnectedClients returns the amount of clients that were disconnected because their outgoing buffer was saturated.
+func (g *slowClientGuard) DisconnectedClients() uint64 {
+	return atomic.LoadUint64(&g.disconnectedClients)
+}
+
+// FirehoseDroppedMessages returns the amount of firehose messages that were dropped because the client was under backpressure.
+// They are not included in the dropped messages of the slow client policy.
+func (g *slowClientGuard) FirehoseDroppedMessages() uint64 {
+	if g.firehose == nil {
+		return 0
+	}
+	return g.firehose.DroppedMessages()
+}
+
+// Start starts writing the backlogs of slow clients, backlogs are only used by the drop-oldest policy.
+func (g *slowClientGuard) Start() {
+	if g.policy != SlowClientPolicyDropOldest {
+		return
+	}
+
+	g.shutdownWG.Add(1)
+	go func() {
+		defer g.shutdownWG.Done()
+
+		ticker := time.NewTicker(slowClientBacklogFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-g.shutdownChan:
+				return
+			case <-ticker.C:
+				g.flushBacklogs()
+			}
+		}
+	}()
+}
+
+// Stop stops writing the backlogs of slow clients.
+func (g *slowClientGuard) Stop() {
+	g.shutdownOnce.Do(func() {
+		close(g.shutdownChan)
+	})
+	g.shutdownWG.Wait()
+}
+
+// flushBacklogs writes the queued messages of all backlogs that fit into the outgoing buffers of the clients.
+func (g *slowClientGuard) flushBacklogs() {
+	g.backlogsLock.Lock()
+	clientIDs := make([]string, 0, len(g.backlogs))
+	for clientID := range g.backlogs {
+		clientIDs = append(clientIDs, clientID)
+	}
+	g.backlogsLock.Unlock()
+
+	for _, clientID := range clientIDs {
+		for {
+			pending := g.nextPendingWrite(clientID)
+			if pending == nil {
+				break
+			}
+
+			// the write errors are handled by the underlying broker, which disconnects the client
+			_ = pending.write()
+		}
+	}
+}
+
+// nextPendingWrite removes the oldest message from the backlog of the client if it fits into the outgoing buffer.
+// The backlog is removed once it is empty, so new messages are written directly again.
+func (g *slowClientGuard) nextPendingWrite(clientID string) *pendingWrite {
+	g.backlogsLock.Lock()
+	defer g.backlogsLock.Unlock()
+
+	backlog, has := g.backlogs[clientID]
+	if !has {
+		return nil
+	}
+
+	queuedBytes, ok := g.queuedBytesFunc(clientID)
+	if !ok {
+		delete(g.backlogs, clientID)
+		return nil
+	}
+
+	pending := backlog.writes[0]
+	if queuedBytes+pending.size > g.bufferSize {
+		return nil
+	}
+
+	backlog.writes = backlog.writes[1:]
+	backlog.size -= pending.size
+	if len(backlog.writes) == 0 {
+		delete(g.backlogs, clientID)
+	}
+
+	return pending
+}
+
+func newSlowClientGuard(policy SlowClientPolicy, bufferSize int, firehose *firehoseGuard, queuedBytesFunc func(clientID string) (int, bool), disconnectFunc func(clientID string) bool) *slowClientGuard {
+	if bufferSize <= 0 {
+		bufferSize = defaultClientBufferSize
+	}
+
+	return &slowClientGuard{
+		policy:          policy,
+		bufferSize:      bufferSize,
+		firehose:        firehose,
+		backlogs:        make(map[string]*clientBacklog),
+		queuedBytesFunc: queuedBytesFunc,
+		disconnectFunc:  disconnectFunc,
+		shutdownChan:    make(chan struct{}),
+	}
+}
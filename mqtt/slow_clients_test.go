@@ -0,0 +1,322 @@
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// fakeClientBuffers are the outgoing buffers of fake clients for the slow client guard.
+type fakeClientBuffers struct {
+	lock         sync.Mutex
+	queuedBytes  map[string]int
+	disconnected map[string]bool
+}
+
+func newFakeClientBuffers() *fakeClientBuffers {
+	return &fakeClientBuffers{
+		queuedBytes:  make(map[string]int),
+		disconnected: make(map[string]bool),
+	}
+}
+
+func (f *fakeClientBuffers) setQueued(clientID string, queuedBytes int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.queuedBytes[clientID] = queuedBytes
+}
+
+func (f *fakeClientBuffers) queued(clientID string) (int, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.disconnected[clientID] {
+		return 0, false
+	}
+	queuedBytes, ok := f.queuedBytes[clientID]
+	return queuedBytes, ok
+}
+
+func (f *fakeClientBuffers) disconnect(clientID string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.disconnected[clientID] {
+		return false
+	}
+	f.disconnected[clientID] = true
+	return true
+}
+
+// writeRecorder records the payloads written by the slow client guard.
+type writeRecorder struct {
+	lock    sync.Mutex
+	written []string
+}
+
+func (w *writeRecorder) writeFunc(payload string) func() error {
+	return func() error {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+
+		w.written = append(w.written, payload)
+		return nil
+	}
+}
+
+func (w *writeRecorder) count() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return len(w.written)
+}
+
+func (w *writeRecorder) payloads() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return append([]string{}, w.written...)
+}
+
+func TestSlowClientGuardBlock(t *testing.T) {
+	buffers := newFakeClientBuffers()
+	guard := newSlowClientGuard(SlowClientPolicyBlock, 1000, nil, buffers.queued, buffers.disconnect)
+	recorder := &writeRecorder{}
+
+	// the message is written even if the buffer is saturated, the write blocks until the client read enough data
+	buffers.setQueued("client", 950)
+	if err := guard.Write("client", "milestones", 100, recorder.writeFunc("saturated")); err != nil {
+		t.Fatal(err)
+	}
+
+	if written := recorder.count(); written != 1 {
+		t.Fatalf("expected 1 written message, got %d", written)
+	}
+	if dropped := guard.DroppedMessages(); dropped != 0 {
+		t.Fatalf("expected no dropped messages, got %d", dropped)
+	}
+}
+
+func TestSlowClientGuardDropNewest(t *testing.T) {
+	buffers := newFakeClientBuffers()
+	guard := newSlowClientGuard(SlowClientPolicyDropNewest, 1000, nil, buffers.queued, buffers.disconnect)
+	recorder := &writeRecorder{}
+
+	buffers.setQueued("client", 900)
+	for i, size := range []int{100, 101, 1} {
+		if err := guard.Write("client", "milestones", size, recorder.writeFunc(fmt.Sprintf("message %d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the message that fits exactly is written, the following one doesn't fit anymore once the buffer filled up
+	buffers.setQueued("client", 1000)
+	if err := guard.Write("client", "milestones", 1, recorder.writeFunc("full")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, expected := fmt.Sprint(recorder.payloads()), fmt.Sprint([]string{"message 0", "message 2"}); got != expected {
+		t.Fatalf("writes %s, expected %s", got, expected)
+	}
+	if dropped := guard.DroppedMessages(); dropped != 2 {
+		t.Fatalf("expected 2 dropped messages, got %d", dropped)
+	}
+	if disconnected := guard.DisconnectedClients(); disconnected != 0 {
+		t.Fatalf("expected no disconnected clients, got %d", disconnected)
+	}
+}
+
+func TestSlowClientGuardDropOldest(t *testing.T) {
+	buffers := newFakeClientBuffers()
+	guard := newSlowClientGuard(SlowClientPolicyDropOldest, 1000, nil, buffers.queued, buffers.disconnect)
+	recorder := &writeRecorder{}
+
+	write := func(payload string, size int) {
+		t.Helper()
+
+		if err := guard.Write("client", "milestones", size, recorder.writeFunc(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectWrites := func(expected ...string) {
+		t.Helper()
+
+		if got := fmt.Sprint(recorder.payloads()); got != fmt.Sprint(expected) {
+			t.Fatalf("writes %s, expected %v", got, expected)
+		}
+	}
+
+	buffers.setQueued("client", 900)
+	write("a", 50)
+	expectWrites("a")
+
+	// the messages that don't fit are queued in the backlog, later messages are queued behind them to keep the order
+	write("b", 101)
+	write("c", 50)
+	expectWrites("a")
+
+	// the backlog exceeds the buffer size, so the oldest queued message is dropped
+	write("d", 900)
+	if dropped := guard.DroppedMessages(); dropped != 1 {
+		t.Fatalf("expected 1 dropped message, got %d", dropped)
+	}
+
+	// only the queued messages that fit into the outgoing buffer are written
+	guard.flushBacklogs()
+	expectWrites("a", "c")
+
+	buffers.setQueued("client", 0)
+	guard.flushBacklogs()
+	expectWrites("a", "c", "d")
+
+	// the backlog is removed once it is empty, so new messages are written directly again
+	write("e", 10)
+	expectWrites("a", "c", "d", "e")
+
+	// the backlog of a disconnected client is removed with its queued messages
+	buffers.setQueued("client", 1000)
+	write("f", 10)
+	guard.Remove("client")
+	buffers.setQueued("client", 0)
+	guard.flushBacklogs()
+	expectWrites("a", "c", "d", "e")
+}
+
+func TestSlowClientGuardDisconnect(t *testing.T) {
+	buffers := newFakeClientBuffers()
+	guard := newSlowClientGuard(SlowClientPolicyDisconnect, 1000, nil, buffers.queued, buffers.disconnect)
+	recorder := &writeRecorder{}
+
+	buffers.setQueued("slow", 950)
+	buffers.setQueued("fast", 0)
+
+	if err := guard.Write("fast", "milestones", 100, recorder.writeFunc("fast")); err != nil {
+		t.Fatal(err)
+	}
+	if err := guard.Write("slow", "milestones", 100, recorder.writeFunc("slow")); !errors.Is(err, ErrSlowClient) {
+		t.Fatalf("expected ErrSlowClient, got %v", err)
+	}
+
+	if got := recorder.payloads(); len(got) != 1 || got[0] != "fast" {
+		t.Fatalf("unexpected writes %v", got)
+	}
+	if _, connected := buffers.queued("slow"); connected {
+		t.Fatal("expected the slow client to be disconnected")
+	}
+	if _, connected := buffers.queued("fast"); !connected {
+		t.Fatal("expected the fast client to stay connected")
+	}
+	if disconnected := guard.DisconnectedClients(); disconnected != 1 {
+		t.Fatalf("expected 1 disconnected client, got %d", disconnected)
+	}
+	if dropped := guard.DroppedMessages(); dropped != 0 {
+		t.Fatalf("the disconnects must not be counted as dropped messages, got %d", dropped)
+	}
+}
+
+func TestFirehoseGuardDrop(t *testing.T) {
+	buffers := newFakeClientBuffers()
+	firehose := newFirehoseGuard(FirehosePolicyDrop, []string{"messages"}, 1000, 50, 1)
+	guard := newSlowClientGuard(SlowClientPolicyBlock, 1000, firehose, buffers.queued, buffers.disconnect)
+	recorder := &writeRecorder{}
+
+	buffers.setQueued("client", 400)
+	if err := guard.Write("client", "messages", 50, recorder.writeFunc("below threshold")); err != nil {
+		t.Fatal(err)
+	}
+
+	// above the threshold, the firehose is dropped while the other topics are still written
+	buffers.setQueued("client", 480)
+	if err := guard.Write("client", "messages", 50, recorder.writeFunc("above threshold")); err != nil {
+		t.Fatal(err)
+	}
+	if err := guard.Write("client", "milestones", 50, recorder.writeFunc("other topic")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := recorder.written; len(got) != 2 || got[0] != "below threshold" || got[1] != "other topic" {
+		t.Fatalf("unexpected writes %v", got)
+	}
+	if dropped := guard.FirehoseDroppedMessages(); dropped != 1 {
+		t.Fatalf("expected 1 dropped firehose message, got %d", dropped)
+	}
+	if dropped := guard.DroppedMessages(); dropped != 0 {
+		t.Fatalf("the firehose drops must not be counted as slow client drops, got %d", dropped)
+	}
+}
+
+func TestFirehoseGuardSample(t *testing.T) {
+	buffers := newFakeClientBuffers()
+	firehose := newFirehoseGuard(FirehosePolicySample, []string{"messages/#"}, 1000, 50, 3)
+	guard := newSlowClientGuard(SlowClientPolicyBlock, 1000, firehose, buffers.queued, buffers.disconnect)
+	recorder := &writeRecorder{}
+
+	buffers.setQueued("client", 600)
+	for i := 0; i < 6; i++ {
+		if err := guard.Write("client", "messages/tagged-data", 10, recorder.writeFunc("message")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// every 3rd message is delivered under backpressure
+	if written := recorder.count(); written != 2 {
+		t.Fatalf("expected 2 sampled messages, got %d", written)
+	}
+	if dropped := guard.FirehoseDroppedMessages(); dropped != 4 {
+		t.Fatalf("expected 4 dropped firehose messages, got %d", dropped)
+	}
+}
+
+func TestFirehoseGuardProtectsOtherTopics(t *testing.T) {
+	buffers := newFakeClientBuffers()
+	firehose := newFirehoseGuard(FirehosePolicyDrop, []string{"messages"}, 1000, 50, 1)
+	guard := newSlowClientGuard(SlowClientPolicyDropNewest, 1000, firehose, buffers.queued, buffers.disconnect)
+	recorder := &writeRecorder{}
+
+	// the firehose degrades before the buffer is saturated, the other topics only hit the slow client policy once it is
+	buffers.setQueued("client", 600)
+	_ = guard.Write("client", "messages", 100, recorder.writeFunc("firehose"))
+	_ = guard.Write("client", "milestones", 100, recorder.writeFunc("milestone"))
+
+	buffers.setQueued("client", 950)
+	_ = guard.Write("client", "milestones", 100, recorder.writeFunc("saturated"))
+
+	if got := recorder.written; len(got) != 1 || got[0] != "milestone" {
+		t.Fatalf("unexpected writes %v", got)
+	}
+	if dropped := guard.FirehoseDroppedMessages(); dropped != 1 {
+		t.Fatalf("expected 1 dropped firehose message, got %d", dropped)
+	}
+	if dropped := guard.DroppedMessages(); dropped != 1 {
+		t.Fatalf("expected 1 dropped message by the slow client policy, got %d", dropped)
+	}
+}
+
+func TestValidateFirehosePolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     FirehosePolicy
+		threshold  int
+		sampleRate int
+		valid      bool
+	}{
+		{"none ignores the parameters", FirehosePolicyNone, 0, 0, true},
+		{"drop", FirehosePolicyDrop, 50, 0, true},
+		{"sample", FirehosePolicySample, 100, 10, true},
+		{"unknown policy", FirehosePolicy("random"), 50, 10, false},
+		{"threshold too low", FirehosePolicyDrop, 0, 10, false},
+		{"threshold too high", FirehosePolicyDrop, 101, 10, false},
+		{"sample rate too low", FirehosePolicySample, 50, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateFirehosePolicy(test.policy, test.threshold, test.sampleRate)
+			if (err == nil) != test.valid {
+				t.Fatalf("validateFirehosePolicy() = %v, expected valid: %v", err, test.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	topicLevelSeparator   = "/"
+	topicWildcardSingle   = "+"
+	topicWildcardMultiple = "#"
+)
+
+// SubscriptionFilter defines a set of topic filters the broker subscribes to internally,
+// which keeps the underlying sources of the matching topics alive even if no client is subscribed.
+// A topic is matched if it matches at least one of the include patterns and none of the exclude patterns,
+// so exclude patterns always take precedence over include patterns.
+type SubscriptionFilter struct {
+	// Include is the list of MQTT topic filters (wildcards allowed) that are subscribed internally.
+	Include []string `json:"include"`
+	// Exclude is the list of MQTT topic filters (wildcards allowed) that are excluded from the include patterns.
+	Exclude []string `json:"exclude"`
+}
+
+// LoadSubscriptionFilterFile loads and validates a subscription filter from a JSON file.
+func LoadSubscriptionFilterFile(filePath string) (*SubscriptionFilter, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read subscription filter file (%s): %w", filePath, err)
+	}
+
+	filter := &SubscriptionFilter{}
+	if err := json.Unmarshal(data, filter); err != nil {
+		return nil, fmt.Errorf("unable to parse subscription filter file (%s): %w", filePath, err)
+	}
+
+	if err := filter.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid subscription filter file (%s): %w", filePath, err)
+	}
+
+	return filter, nil
+}
+
+// Validate checks that all include and exclude patterns are valid MQTT topic filters.
+func (f *SubscriptionFilter) Validate() error {
+	if len(f.Include) == 0 {
+		return fmt.Errorf("no include patterns given")
+	}
+
+	for _, pattern := range f.Include {
+		if err := validateTopicFilter(pattern); err != nil {
+			return fmt.Errorf("invalid include pattern \"%s\": %w", pattern, err)
+		}
+	}
+
+	for _, pattern := range f.Exclude {
+		if err := validateTopicFilter(pattern); err != nil {
+			return fmt.Errorf("invalid exclude pattern \"%s\": %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// Matches returns true if the topic matches at least one include pattern and no exclude pattern.
+func (f *SubscriptionFilter) Matches(topic string) bool {
+	for _, pattern := range f.Exclude {
+		if topicMatchesFilter(pattern, topic) {
+			return false
+		}
+	}
+
+	for _, pattern := range f.Include {
+		if topicMatchesFilter(pattern, topic) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateTopicFilter checks if the given filter is a valid MQTT topic filter.
+func validateTopicFilter(filter string) error {
+	if filter == "" {
+		return fmt.Errorf("empty topic filter")
+	}
+
+	levels := strings.Split(filter, topicLevelSeparator)
+	for i, level := range levels {
+		switch {
+		case level == topicWildcardMultiple:
+			if i != len(levels)-1 {
+				return fmt.Errorf("multi-level wildcard \"%s\" must be the last level", topicWildcardMultiple)
+			}
+		case level == topicWildcardSingle:
+		case strings.Contains(level, topicWildcardMultiple), strings.Contains(level, topicWildcardSingle):
+			return fmt.Errorf("wildcards must occupy an entire topic level")
+		}
+	}
+
+	return nil
+}
+
+// topicMatchesFilter returns true if the concrete topic matches the MQTT topic filter
+// with the single-level ("+") and multi-level ("#") wildcard semantics.
+func topicMatchesFilter(filter string, topic string) bool {
+	// wildcards at the first level must not match system topics
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, topicWildcardSingle) || strings.HasPrefix(filter, topicWildcardMultiple)) {
+		return false
+	}
+
+	filterLevels := strings.Split(filter, topicLevelSeparator)
+	topicLevels := strings.Split(topic, topicLevelSeparator)
+
+	for i, filterLevel := range filterLevels {
+		if filterLevel == topicWildcardMultiple {
+			// "#" also matches the parent level
+			return true
+		}
+
+		if i >= len(topicLevels) {
+			return false
+		}
+
+		if filterLevel != topicWildcardSingle && filterLevel != topicLevels[i] {
+			return false
+		}
+	}
+
+	return len(filterLevels) == len(topicLevels)
+}
+
+// IsWildcardTopicFilter returns true if the MQTT topic filter contains a single-level ("+") or multi-level ("#") wildcard.
+func IsWildcardTopicFilter(filter string) bool {
+	return isWildcardTopicFilter(filter)
+}
+
+// TopicFiltersOverlap returns true if at least one topic matches both MQTT topic filters.
+func TopicFiltersOverlap(filterA string, filterB string) bool {
+	return topicFiltersOverlap(filterA, filterB)
+}
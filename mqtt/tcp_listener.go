@@ -0,0 +1,136 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mochi-co/mqtt/server/listeners"
+	"github.com/mochi-co/mqtt/server/system"
+)
+
+const (
+	// tlsHandshakeTimeout is the maximum duration of the TLS handshake of a new connection.
+	tlsHandshakeTimeout = 10 * time.Second
+)
+
+// tcpListener is a TCP listener with an optional custom TLS config and optional support for the PROXY protocol.
+// The TCP listener of the underlying broker only supports a server certificate,
+// so this listener is used to support further TLS settings (e.g. reloadable certificates and client certificates).
+// The TLS handshake and the PROXY protocol header are handled before the connection is passed to the broker,
+// so connections that fail the handshake or send an invalid header never reach the broker.
+type tcpListener struct {
+	sync.RWMutex
+	id            string
+	address       string
+	tlsConfig     *tls.Config
+	proxyProtocol bool
+	listen        net.Listener
+	config        *listeners.Config
+	// ensure the close methods are only called once.
+	end uint32
+}
+
+// SetConfig sets the configuration values for the listener config.
+// The TLS settings of the config are ignored, the TLS config of the listener is used instead.
+func (l *tcpListener) SetConfig(config *listeners.Config) {
+	l.Lock()
+	defer l.Unlock()
+
+	if config != nil {
+		l.config = config
+	}
+}
+
+// ID returns the id of the listener.
+func (l *tcpListener) ID() string {
+	l.RLock()
+	defer l.RUnlock()
+
+	return l.id
+}
+
+// Listen starts listening on the listener's network address.
+func (l *tcpListener) Listen(s *system.Info) error {
+	listen, err := net.Listen("tcp", l.address)
+	if err != nil {
+		return err
+	}
+
+	// the PROXY protocol header is sent by the load balancer before the TLS handshake
+	if l.proxyProtocol {
+		listen = &proxyProtocolListener{Listener: listen}
+	}
+	if l.tlsConfig != nil {
+		listen = tls.NewListener(listen, l.tlsConfig)
+	}
+	l.listen = listen
+
+	return nil
+}
+
+// Serve starts waiting for new TCP connections, and calls the establish connection callback
+// for every connection that sent a valid PROXY protocol header and completed the TLS handshake if enabled.
+func (l *tcpListener) Serve(establish listeners.EstablishFunc) {
+	for {
+		if atomic.LoadUint32(&l.end) == 1 {
+			return
+		}
+
+		conn, err := l.listen.Accept()
+		if err != nil {
+			return
+		}
+
+		if atomic.LoadUint32(&l.end) == 0 {
+			go func() {
+				switch c := conn.(type) {
+				case *tls.Conn:
+					// the handshake also reads the PROXY protocol header of the underlying connection
+					ctx, cancel := context.WithTimeout(context.Background(), tlsHandshakeTimeout)
+					defer cancel()
+
+					if err := c.HandshakeContext(ctx); err != nil {
+						_ = conn.Close()
+						return
+					}
+
+				case *proxyProtocolConn:
+					if err := c.ReadHeader(); err != nil {
+						_ = conn.Close()
+						return
+					}
+				}
+
+				_ = establish(l.id, conn, l.config.Auth)
+			}()
+		}
+	}
+}
+
+// Close closes the listener and any client connections.
+func (l *tcpListener) Close(closeClients listeners.CloseFunc) {
+	l.Lock()
+	defer l.Unlock()
+
+	if atomic.CompareAndSwapUint32(&l.end, 0, 1) {
+		closeClients(l.id)
+	}
+
+	if l.listen != nil {
+		_ = l.listen.Close()
+	}
+}
+
+// newTCPListener creates a TCP listener, TLS is used if a TLS config is given.
+func newTCPListener(id string, address string, tlsConfig *tls.Config, proxyProtocol bool) *tcpListener {
+	return &tcpListener{
+		id:            id,
+		address:       address,
+		tlsConfig:     tlsConfig,
+		proxyProtocol: proxyProtocol,
+	}
+}
@@ -0,0 +1,179 @@
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ThroughputCategoryMilestones contains the milestone and milestone info topics.
+	ThroughputCategoryMilestones = "milestones"
+	// ThroughputCategoryMessages contains the message, message metadata and transaction topics.
+	ThroughputCategoryMessages = "messages"
+	// ThroughputCategoryOutputs contains the output topics.
+	ThroughputCategoryOutputs = "outputs"
+	// ThroughputCategoryOther contains all remaining topics (e.g. receipts and system topics).
+	ThroughputCategoryOther = "other"
+
+	// maxThroughputWindow is the largest supported throughput window.
+	// The tracker keeps one counter per second of the largest window, so this bounds the memory overhead.
+	maxThroughputWindow = 1 * time.Hour
+)
+
+var throughputCategories = []string{
+	ThroughputCategoryMilestones,
+	ThroughputCategoryMessages,
+	ThroughputCategoryOutputs,
+	ThroughputCategoryOther,
+}
+
+// throughputCategoryForTopic returns the throughput category of the topic based on its first topic level.
+func throughputCategoryForTopic(topic string) string {
+	firstLevel := topic
+	if idx := strings.Index(topic, topicLevelSeparator); idx != -1 {
+		firstLevel = topic[:idx]
+	}
+
+	switch firstLevel {
+	case "milestones", "milestone-info":
+		return ThroughputCategoryMilestones
+	case "messages", "message-metadata", "transactions":
+		return ThroughputCategoryMessages
+	case "outputs":
+		return ThroughputCategoryOutputs
+	default:
+		return ThroughputCategoryOther
+	}
+}
+
+// ThroughputWindowStats contains the publish rates of all topic categories over a time window.
+type ThroughputWindowStats struct {
+	// The length of the time window.
+	Window string `json:"window"`
+	// The published messages per second per topic category.
+	MessagesPerSecond map[string]float64 `json:"messagesPerSecond"`
+}
+
+// rateCounter counts events in a ring of per-second buckets.
+type rateCounter struct {
+	buckets []uint64
+	// the unix second of the most recent bucket.
+	lastSecond int64
+}
+
+// advance clears the buckets of the seconds that passed since the last update.
+func (c *rateCounter) advance(nowSecond int64) {
+	passed := nowSecond - c.lastSecond
+	if passed <= 0 {
+		return
+	}
+
+	if passed > int64(len(c.buckets)) {
+		passed = int64(len(c.buckets))
+	}
+	for i := int64(1); i <= passed; i++ {
+		c.buckets[(c.lastSecond+i)%int64(len(c.buckets))] = 0
+	}
+	c.lastSecond = nowSecond
+}
+
+func (c *rateCounter) increment(nowSecond int64) {
+	c.advance(nowSecond)
+	c.buckets[nowSecond%int64(len(c.buckets))]++
+}
+
+// sum returns the amount of events during the last given seconds, including the current second.
+func (c *rateCounter) sum(nowSecond int64, seconds int64) uint64 {
+	c.advance(nowSecond)
+
+	var sum uint64
+	for i := int64(0); i < seconds; i++ {
+		sum += c.buckets[(nowSecond-i)%int64(len(c.buckets))]
+	}
+	return sum
+}
+
+// throughputTracker tracks the publish rates per topic category over sliding time windows.
+// The memory overhead is fixed, one counter per second of the largest window and topic category.
+type throughputTracker struct {
+	windows []time.Duration
+	started time.Time
+
+	counters     map[string]*rateCounter
+	countersLock sync.Mutex
+}
+
+// Track counts a published message on the topic.
+func (t *throughputTracker) Track(topic string) {
+	t.countersLock.Lock()
+	defer t.countersLock.Unlock()
+
+	t.counters[throughputCategoryForTopic(topic)].increment(time.Now().Unix())
+}
+
+// Stats returns the publish rates of all topic categories over all windows.
+// Windows that are longer than the uptime of the tracker are averaged over the uptime instead.
+func (t *throughputTracker) Stats() []*ThroughputWindowStats {
+	t.countersLock.Lock()
+	defer t.countersLock.Unlock()
+
+	now := time.Now()
+	nowSecond := now.Unix()
+
+	stats := make([]*ThroughputWindowStats, 0, len(t.windows))
+	for _, window := range t.windows {
+		seconds := int64(window / time.Second)
+		if uptime := int64(now.Sub(t.started)/time.Second) + 1; uptime < seconds {
+			seconds = uptime
+		}
+
+		rates := make(map[string]float64, len(t.counters))
+		for category, counter := range t.counters {
+			rates[category] = float64(counter.sum(nowSecond, seconds)) / float64(seconds)
+		}
+
+		stats = append(stats, &ThroughputWindowStats{
+			Window:            window.String(),
+			MessagesPerSecond: rates,
+		})
+	}
+
+	return stats
+}
+
+func newThroughputTracker(windows []time.Duration) (*throughputTracker, error) {
+	if len(windows) == 0 {
+		return nil, errors.New("no throughput windows given")
+	}
+
+	maxWindow := time.Duration(0)
+	for _, window := range windows {
+		if window < time.Second || window%time.Second != 0 {
+			return nil, fmt.Errorf("throughput window %s must be a multiple of one second", window)
+		}
+		if window > maxThroughputWindow {
+			return nil, fmt.Errorf("throughput window %s exceeds the maximum of %s", window, maxThroughputWindow)
+		}
+		if window > maxWindow {
+			maxWindow = window
+		}
+	}
+
+	now := time.Now()
+	counters := make(map[string]*rateCounter, len(throughputCategories))
+	for _, category := range throughputCategories {
+		counters[category] = &rateCounter{
+			buckets:    make([]uint64, int(maxWindow/time.Second)),
+			lastSecond: now.Unix(),
+		}
+	}
+
+	return &throughputTracker{
+		windows:  windows,
+		started:  now,
+		counters: counters,
+	}, nil
+}
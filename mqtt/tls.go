@@ -2,8 +2,10 @@ package mqtt
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"os"
+	"sync/atomic"
 
 	"github.com/mochi-co/mqtt/server/listeners"
 )
@@ -13,33 +15,33 @@ func NewTLSSettings(tcpTlsCertificatePath string, tcpTlsPrivateKeyPath string) (
 	if _, err := os.Stat(tcpTlsCertificatePath); err != nil {
 		if os.IsNotExist(err) {
 			// file does not exist
-			return nil, fmt.Errorf("TCP TLS certificate file not found (%s)", tcpTlsCertificatePath)
+			return nil, fmt.Errorf("TLS certificate file not found (%s)", tcpTlsCertificatePath)
 		}
 
-		return nil, fmt.Errorf("unable to check TCP TLS certificate file (%s): %w", tcpTlsCertificatePath, err)
+		return nil, fmt.Errorf("unable to check TLS certificate file (%s): %w", tcpTlsCertificatePath, err)
 	}
 
 	if _, err := os.Stat(tcpTlsPrivateKeyPath); err != nil {
 		if os.IsNotExist(err) {
 			// file does not exist
-			return nil, fmt.Errorf("TCP TLS private key file not found (%s)", tcpTlsPrivateKeyPath)
+			return nil, fmt.Errorf("TLS private key file not found (%s)", tcpTlsPrivateKeyPath)
 		}
 
-		return nil, fmt.Errorf("unable to check TCP TLS private key file (%s): %w", tcpTlsPrivateKeyPath, err)
+		return nil, fmt.Errorf("unable to check TLS private key file (%s): %w", tcpTlsPrivateKeyPath, err)
 	}
 
 	tcpTlsCertificate, err := os.ReadFile(tcpTlsCertificatePath)
 	if err != nil {
-		return nil, fmt.Errorf("unable to read TCP TLS certificate: %w", err)
+		return nil, fmt.Errorf("unable to read TLS certificate: %w", err)
 	}
 
 	tcpTlsPrivateKey, err := os.ReadFile(tcpTlsPrivateKeyPath)
 	if err != nil {
-		return nil, fmt.Errorf("unable to read TCP TLS private key: %w", err)
+		return nil, fmt.Errorf("unable to read TLS private key: %w", err)
 	}
 
 	if _, err := tls.X509KeyPair(tcpTlsCertificate, tcpTlsPrivateKey); err != nil {
-		return nil, fmt.Errorf("loading TCP TLS configuration failed: %w", err)
+		return nil, fmt.Errorf("loading TLS configuration failed: %w", err)
 	}
 
 	return &listeners.TLS{
@@ -47,3 +49,74 @@ func NewTLSSettings(tcpTlsCertificatePath string, tcpTlsPrivateKeyPath string) (
 		PrivateKey:  tcpTlsPrivateKey,
 	}, nil
 }
+
+// tlsCertificateHolder holds the TLS certificate of a listener, which can be swapped atomically.
+// New connections use the current certificate, existing connections are not affected by a reload.
+type tlsCertificateHolder struct {
+	certificatePath string
+	privateKeyPath  string
+	certificate     atomic.Value
+}
+
+// Load loads the certificate and private key from the files.
+// If they fail to load, the previous certificate is kept.
+func (h *tlsCertificateHolder) Load() error {
+	tlsSettings, err := NewTLSSettings(h.certificatePath, h.privateKeyPath)
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.X509KeyPair(tlsSettings.Certificate, tlsSettings.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("loading TLS configuration failed: %w", err)
+	}
+
+	h.certificate.Store(&certificate)
+	return nil
+}
+
+// GetCertificate returns the current certificate for the TLS handshake.
+func (h *tlsCertificateHolder) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return h.certificate.Load().(*tls.Certificate), nil
+}
+
+func newTLSCertificateHolder(tcpTlsCertificatePath string, tcpTlsPrivateKeyPath string) (*tlsCertificateHolder, error) {
+	holder := &tlsCertificateHolder{
+		certificatePath: tcpTlsCertificatePath,
+		privateKeyPath:  tcpTlsPrivateKeyPath,
+	}
+
+	if err := holder.Load(); err != nil {
+		return nil, err
+	}
+
+	return holder, nil
+}
+
+// newTLSConfig creates a TLS config that uses the current certificate of the holder.
+// If a client CA file (x509 PEM) is given, the clients need to present a certificate that is signed by one of the CAs,
+// connections without a valid client certificate are rejected during the TLS handshake.
+func newTLSConfig(certificateHolder *tlsCertificateHolder, tcpTlsClientCAPath string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		GetCertificate: certificateHolder.GetCertificate,
+	}
+
+	if tcpTlsClientCAPath == "" {
+		return tlsConfig, nil
+	}
+
+	clientCAs, err := os.ReadFile(tcpTlsClientCAPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read TCP TLS client CA file (%s): %w", tcpTlsClientCAPath, err)
+	}
+
+	clientCAPool := x509.NewCertPool()
+	if !clientCAPool.AppendCertsFromPEM(clientCAs) {
+		return nil, fmt.Errorf("no valid certificates found in TCP TLS client CA file (%s)", tcpTlsClientCAPath)
+	}
+
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	tlsConfig.ClientCAs = clientCAPool
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,161 @@
+package mqtt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCertificate is a certificate and its private key generated for a test.
+type testCertificate struct {
+	certificate *x509.Certificate
+	privateKey  *ecdsa.PrivateKey
+	tls         tls.Certificate
+}
+
+// newTestCertificate creates a certificate from the template, signed by the parent or self-signed if the parent is nil.
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *testCertificate) *testCertificate {
+	t.Helper()
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating private key failed: %s", err)
+	}
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	signerCertificate, signerKey := template, privateKey
+	if parent != nil {
+		signerCertificate, signerKey = parent.certificate, parent.privateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCertificate, &privateKey.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("creating certificate failed: %s", err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing certificate failed: %s", err)
+	}
+
+	return &testCertificate{
+		certificate: certificate,
+		privateKey:  privateKey,
+		tls:         tls.Certificate{Certificate: [][]byte{der}, PrivateKey: privateKey},
+	}
+}
+
+func newTestCA(t *testing.T, name string) *testCertificate {
+	t.Helper()
+
+	return newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}, nil)
+}
+
+func newTestLeafCertificate(t *testing.T, name string, extKeyUsage x509.ExtKeyUsage, ca *testCertificate) *testCertificate {
+	t.Helper()
+
+	return newTestCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{extKeyUsage},
+	}, ca)
+}
+
+// writeCertificatePEM writes the certificate and its private key as PEM files and returns their paths.
+func writeCertificatePEM(t *testing.T, dir string, name string, certificate *testCertificate) (string, string) {
+	t.Helper()
+
+	certificatePath := filepath.Join(dir, name+".crt")
+	if err := os.WriteFile(certificatePath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.certificate.Raw}), 0o600); err != nil {
+		t.Fatalf("writing certificate failed: %s", err)
+	}
+
+	privateKey, err := x509.MarshalECPrivateKey(certificate.privateKey)
+	if err != nil {
+		t.Fatalf("encoding private key failed: %s", err)
+	}
+	privateKeyPath := filepath.Join(dir, name+".key")
+	if err := os.WriteFile(privateKeyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privateKey}), 0o600); err != nil {
+		t.Fatalf("writing private key failed: %s", err)
+	}
+
+	return certificatePath, privateKeyPath
+}
+
+func TestTCPTLSClientAuth(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newTestCA(t, "client and server CA")
+	otherCA := newTestCA(t, "unknown CA")
+	serverCertificate := newTestLeafCertificate(t, "broker", x509.ExtKeyUsageServerAuth, ca)
+
+	caPath, _ := writeCertificatePEM(t, dir, "ca", ca)
+	serverCertificatePath, serverPrivateKeyPath := writeCertificatePEM(t, dir, "server", serverCertificate)
+
+	_, address := newTestBroker(t,
+		WithTCPTLSEnabled(true),
+		WithTCPTLSCertificatePath(serverCertificatePath),
+		WithTCPTLSPrivateKeyPath(serverPrivateKeyPath),
+		WithTCPTLSClientAuthEnabled(true),
+		WithTCPTLSClientCAPath(caPath),
+	)
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(ca.certificate)
+
+	tests := []struct {
+		name              string
+		clientCertificate *testCertificate
+		expectConnected   bool
+	}{
+		{"certificate signed by the client CA", newTestLeafCertificate(t, "client", x509.ExtKeyUsageClientAuth, ca), true},
+		{"certificate signed by an unknown CA", newTestLeafCertificate(t, "client", x509.ExtKeyUsageClientAuth, otherCA), false},
+		{"no certificate", nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tlsConfig := &tls.Config{
+				RootCAs:    rootCAs,
+				MinVersion: tls.VersionTLS12,
+			}
+			if test.clientCertificate != nil {
+				tlsConfig.Certificates = []tls.Certificate{test.clientCertificate.tls}
+			}
+
+			client, err := connectTestClient(t, newTestClientOptions("ssl://"+address, "tls-client").SetTLSConfig(tlsConfig))
+			if !test.expectConnected {
+				if err == nil {
+					t.Fatal("expected the connection to be rejected")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("connecting failed: %s", err)
+			}
+			if !client.IsConnectionOpen() {
+				t.Fatal("expected the client to be connected")
+			}
+		})
+	}
+}
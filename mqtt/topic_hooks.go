@@ -0,0 +1,160 @@
+package mqtt
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iotaledger/hive.go/logger"
+)
+
+const (
+	// TopicHookHeaderTopic is the HTTP header that contains the topic of the published message for HTTP POST hooks.
+	TopicHookHeaderTopic = "X-MQTT-Topic"
+)
+
+// TopicHookFunc is called after a message was published on a topic that matches the filter of the hook.
+type TopicHookFunc func(topic string, payload []byte) error
+
+// TopicHook registers a hook for all topics that match the topic filter.
+type TopicHook struct {
+	// Name is the name of the hook used in the logs.
+	Name string
+	// Filter is the MQTT topic filter (wildcards allowed) of the topics the hook is called for.
+	Filter string
+	// Hook is the function that is called for the matching topics.
+	Hook TopicHookFunc
+}
+
+// NewHTTPPostTopicHook returns a hook that POSTs the payload of the published message to the given URL.
+// The topic is sent in the TopicHookHeaderTopic header, responses with a status code other than 2xx are treated as failures.
+func NewHTTPPostTopicHook(url string, timeout time.Duration) TopicHookFunc {
+	client := &http.Client{Timeout: timeout}
+
+	return func(topic string, payload []byte) error {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set(TopicHookHeaderTopic, topic)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		}
+
+		return nil
+	}
+}
+
+// topicHookInvocation is a queued call of a hook.
+type topicHookInvocation struct {
+	hook    *TopicHook
+	topic   string
+	payload []byte
+}
+
+// topicHookExecutor calls the topic hooks asynchronously on a bounded worker pool, so they never block the publish path.
+// The hooks are fire-and-forget: if the queue is full, the invocation is dropped,
+// and failed invocations are logged, but not retried. A panicking hook counts as a failed invocation and doesn't stop its worker.
+type topicHookExecutor struct {
+	log     *logger.Logger
+	hooks   []*TopicHook
+	workers int
+	queue   chan *topicHookInvocation
+
+	// droppedInvocations is the amount of invocations that were dropped because the queue was full.
+	droppedInvocations uint64
+	// failedInvocations is the amount of invocations that returned an error or panicked.
+	failedInvocations uint64
+
+	shutdownChan chan struct{}
+	shutdownOnce sync.Once
+	shutdownWG   sync.WaitGroup
+}
+
+// Fire queues the invocations of all hooks that match the topic.
+func (e *topicHookExecutor) Fire(topic string, payload []byte) {
+	for _, hook := range e.hooks {
+		if !topicMatchesFilter(hook.Filter, topic) {
+			continue
+		}
+
+		select {
+		case e.queue <- &topicHookInvocation{hook: hook, topic: topic, payload: payload}:
+		default:
+			atomic.AddUint64(&e.droppedInvocations, 1)
+		}
+	}
+}
+
+// DroppedInvocations returns the amount of invocations that were dropped because the queue was full.
+func (e *topicHookExecutor) DroppedInvocations() uint64 {
+	return atomic.LoadUint64(&e.droppedInvocations)
+}
+
+// FailedInvocations returns the amount of invocations that returned an error or panicked.
+func (e *topicHookExecutor) FailedInvocations() uint64 {
+	return atomic.LoadUint64(&e.failedInvocations)
+}
+
+// Start starts the workers.
+func (e *topicHookExecutor) Start() {
+	for i := 0; i < e.workers; i++ {
+		e.shutdownWG.Add(1)
+		go func() {
+			defer e.shutdownWG.Done()
+
+			for {
+				select {
+				case <-e.shutdownChan:
+					return
+				case invocation := <-e.queue:
+					e.invoke(invocation)
+				}
+			}
+		}()
+	}
+}
+
+// invoke calls the hook and recovers from a panic of the hook, so it doesn't crash the process.
+func (e *topicHookExecutor) invoke(invocation *topicHookInvocation) {
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddUint64(&e.failedInvocations, 1)
+			e.log.Warnf("topic hook %s panicked for topic %s: %v", invocation.hook.Name, invocation.topic, r)
+		}
+	}()
+
+	if err := invocation.hook.Hook(invocation.topic, invocation.payload); err != nil {
+		atomic.AddUint64(&e.failedInvocations, 1)
+		e.log.Debugf("topic hook %s failed for topic %s: %s", invocation.hook.Name, invocation.topic, err)
+	}
+}
+
+// Stop stops the workers and waits for running invocations to finish, queued invocations are dropped.
+func (e *topicHookExecutor) Stop() {
+	e.shutdownOnce.Do(func() {
+		close(e.shutdownChan)
+	})
+	e.shutdownWG.Wait()
+}
+
+func newTopicHookExecutor(log *logger.Logger, hooks []*TopicHook, workers int, queueSize int) *topicHookExecutor {
+	return &topicHookExecutor{
+		log:          log,
+		hooks:        hooks,
+		workers:      workers,
+		queue:        make(chan *topicHookInvocation, queueSize),
+		shutdownChan: make(chan struct{}),
+	}
+}
@@ -0,0 +1,48 @@
+package mqtt
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/iotaledger/hive.go/logger"
+)
+
+func TestTopicHookPanicCountsAsFailedInvocation(t *testing.T) {
+	var invocations uint32
+	hook := func(string, []byte) error {
+		// the first invocation panics, the worker must keep running for the next one
+		if atomic.AddUint32(&invocations, 1) == 1 {
+			panic("faulty hook")
+		}
+		return nil
+	}
+
+	broker, _ := newTestBroker(t,
+		WithTopicHooks([]*TopicHook{{Name: "faulty", Filter: "milestones", Hook: hook}}),
+		WithTopicHookWorkers(1),
+	)
+
+	for i := 0; i < 2; i++ {
+		if err := broker.Send("milestones", []byte("milestone")); err != nil {
+			t.Fatalf("sending message failed: %s", err)
+		}
+	}
+
+	waitFor(t, testTimeout, func() bool { return atomic.LoadUint32(&invocations) == 2 }, "the hook was not invoked again after the panic")
+	waitFor(t, testTimeout, func() bool { return broker.FailedTopicHookInvocations() == 1 }, "the panic was not counted as a failed invocation")
+}
+
+func TestTopicHookWithoutHookFunctionIsRejected(t *testing.T) {
+	brokerOpts := &BrokerOptions{}
+	brokerOpts.ApplyOnDefault(
+		WithWebsocketEnabled(false),
+		WithTCPEnabled(true),
+		WithTCPBindAddress(freeAddress(t)),
+		WithTopicHooks([]*TopicHook{{Name: "missing", Filter: "milestones"}}),
+	)
+
+	if _, err := NewBroker(logger.NewNopLogger(), func(string) {}, func(string) {}, brokerOpts); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("expected the topic hook without hook function to be rejected as invalid config, got %v", err)
+	}
+}
@@ -1,59 +1,123 @@
 package mqtt
 
 import (
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type OnSubscribeHandler func(topic string)
 type OnUnsubscribeHandler func(topic string)
 
+// TopicStat contains the statistics of a subscribed topic.
+type TopicStat struct {
+	// The current amount of subscribers of the topic.
+	Subscribers int `json:"subscribers"`
+	// The amount of messages published on topics matching the topic filter since it was subscribed by the first subscriber.
+	PublishedMessages uint64 `json:"publishedMessages"`
+}
+
+// subscribedTopic is the state of a subscribed topic in the topic manager.
+type subscribedTopic struct {
+	// publishedMessages is increased atomically while holding the read lock, so publishes don't block each other.
+	// It is the first field to keep it 64-bit aligned for the atomic operations on 32-bit platforms.
+	publishedMessages uint64
+	// subscribers is only changed while holding the write lock of the topic manager.
+	subscribers int
+}
+
 // topicManager keeps track of subscribed topics of the mqtt broker by subscribing to broker topic events.
-// This allows to get notified when a topic is subscribed or unsubscribed
+// This allows to get notified when the first client subscribes to a topic or the last client unsubscribes from it.
 type topicManager struct {
-	subscribedTopics        map[string]int
+	subscribedTopics        map[string]*subscribedTopic
 	subscribedTopicsLock    sync.RWMutex
 	subscribedTopicsDeleted int
+	// wildcardFilters are the subscribed topic filters that contain wildcards,
+	// they are matched against the concrete topics in hasSubscribers and Published.
+	wildcardFilters *topicFilterTrie
 
 	cleanupThreshold int
 
+	// maxSize is the maximum amount of distinct topics, subscriptions to new topics beyond are rejected (0 = unlimited).
+	maxSize int
+	// rejectedSubscriptions is the amount of subscriptions that were rejected because the maximum size was reached.
+	rejectedSubscriptions uint64
+
 	onSubscribe   OnSubscribeHandler
 	onUnsubscribe OnUnsubscribeHandler
 }
 
+// Subscribe increases the subscriber count of the topic.
+// The subscribe handler is only called if the topic had no subscribers before.
 func (t *topicManager) Subscribe(topicName string) {
 	t.subscribedTopicsLock.Lock()
 	defer t.subscribedTopicsLock.Unlock()
 
-	count, has := t.subscribedTopics[topicName]
-	if has {
-		t.subscribedTopics[topicName] = count + 1
-	} else {
-		t.subscribedTopics[topicName] = 1
+	topic, has := t.subscribedTopics[topicName]
+	if !has {
+		topic = &subscribedTopic{}
+		t.subscribedTopics[topicName] = topic
+		if isWildcardTopicFilter(topicName) {
+			t.wildcardFilters.Add(topicName)
+		}
 	}
+	topic.subscribers++
 
-	if t.onSubscribe != nil {
+	// the handlers are called while holding the lock,
+	// so the subscribe and unsubscribe events of a topic can't be reordered.
+	if topic.subscribers == 1 && t.onSubscribe != nil {
 		t.onSubscribe(topicName)
 	}
 }
 
+// Unsubscribe decreases the subscriber count of the topic.
+// The unsubscribe handler is only called if the last subscriber of the topic is gone.
 func (t *topicManager) Unsubscribe(topicName string) {
 	t.subscribedTopicsLock.Lock()
 	defer t.subscribedTopicsLock.Unlock()
 
-	count, has := t.subscribedTopics[topicName]
-	if has {
-		if count <= 1 {
-			t.deleteTopic(topicName)
-		} else {
-			t.subscribedTopics[topicName] = count - 1
-		}
+	topic, has := t.subscribedTopics[topicName]
+	if !has {
+		// the topic was never subscribed, so the subscribe handler was never called
+		return
 	}
 
+	if topic.subscribers > 1 {
+		topic.subscribers--
+		return
+	}
+
+	t.deleteTopic(topicName)
+
 	if t.onUnsubscribe != nil {
 		t.onUnsubscribe(topicName)
 	}
 }
 
+// AllowsSubscription returns false if the topic is not tracked yet and the maximum size was reached.
+// Subscriptions to topics that are already tracked are always allowed.
+// The check is not atomic with the subscription, so concurrent subscriptions may exceed the maximum size slightly.
+func (t *topicManager) AllowsSubscription(topicName string) bool {
+	if t.maxSize == 0 {
+		return true
+	}
+
+	t.subscribedTopicsLock.RLock()
+	defer t.subscribedTopicsLock.RUnlock()
+
+	if _, has := t.subscribedTopics[topicName]; has || len(t.subscribedTopics) < t.maxSize {
+		return true
+	}
+
+	atomic.AddUint64(&t.rejectedSubscriptions, 1)
+	return false
+}
+
+// RejectedSubscriptions returns the amount of subscriptions that were rejected because the maximum size was reached.
+func (t *topicManager) RejectedSubscriptions() uint64 {
+	return atomic.LoadUint64(&t.rejectedSubscriptions)
+}
+
 // Size returns the size of the underlying map of the topics manager.
 func (t *topicManager) Size() int {
 	t.subscribedTopicsLock.RLock()
@@ -62,27 +126,80 @@ func (t *topicManager) Size() int {
 	return len(t.subscribedTopics)
 }
 
+// hasSubscribers returns true if a subscribed topic filter matches the concrete topic,
+// either exactly or with the single-level ("+") and multi-level ("#") wildcards.
+// The wildcard filters are looked up by the levels of the topic, so the cost doesn't grow with the amount of filters.
 func (t *topicManager) hasSubscribers(topicName string) bool {
 	t.subscribedTopicsLock.RLock()
 	defer t.subscribedTopicsLock.RUnlock()
 
-	count, has := t.subscribedTopics[topicName]
-	return has && count > 0
+	return t.hasExactSubscribersWithoutLocking(topicName) || t.wildcardFilters.Matches(topicName)
+}
+
+// hasExactSubscribers returns true if the topic was subscribed with a topic filter that equals the topic.
+func (t *topicManager) hasExactSubscribers(topicName string) bool {
+	t.subscribedTopicsLock.RLock()
+	defer t.subscribedTopicsLock.RUnlock()
+
+	return t.hasExactSubscribersWithoutLocking(topicName)
+}
+
+func (t *topicManager) hasExactSubscribersWithoutLocking(topicName string) bool {
+	topic, has := t.subscribedTopics[topicName]
+	return has && topic.subscribers > 0
+}
+
+// Published counts a message that was published on the topic for every subscribed topic filter that matches it,
+// i.e. the filter that equals the topic and the matching filters with wildcards.
+func (t *topicManager) Published(topicName string) {
+	t.subscribedTopicsLock.RLock()
+	defer t.subscribedTopicsLock.RUnlock()
+
+	if topic, has := t.subscribedTopics[topicName]; has {
+		atomic.AddUint64(&topic.publishedMessages, 1)
+	}
+
+	for _, filter := range t.wildcardFilters.MatchingFilters(topicName) {
+		if topic, has := t.subscribedTopics[filter]; has {
+			atomic.AddUint64(&topic.publishedMessages, 1)
+		}
+	}
+}
+
+// Stats returns a snapshot of the statistics of all subscribed topics.
+// The subscriber counts are consistent with each other, the published message counters are read atomically.
+func (t *topicManager) Stats() map[string]TopicStat {
+	t.subscribedTopicsLock.RLock()
+	defer t.subscribedTopicsLock.RUnlock()
+
+	stats := make(map[string]TopicStat, len(t.subscribedTopics))
+	for topicName, topic := range t.subscribedTopics {
+		stats[topicName] = TopicStat{
+			Subscribers:       topic.subscribers,
+			PublishedMessages: atomic.LoadUint64(&topic.publishedMessages),
+		}
+	}
+
+	return stats
 }
 
 // cleanupWithoutLocking recreates the subscribedTopics map to release memory for the garbage collector.
 func (t *topicManager) cleanupWithoutLocking() {
-	subscribedTopics := make(map[string]int)
-	for topicName, count := range t.subscribedTopics {
-		subscribedTopics[topicName] = count
+	subscribedTopics := make(map[string]*subscribedTopic)
+	for topicName, topic := range t.subscribedTopics {
+		subscribedTopics[topicName] = topic
 	}
 	t.subscribedTopics = subscribedTopics
+
 	t.subscribedTopicsDeleted = 0
 }
 
 // deleteTopic deletes a topic from the manager.
 func (t *topicManager) deleteTopic(topicName string) {
 	delete(t.subscribedTopics, topicName)
+	if isWildcardTopicFilter(topicName) {
+		t.wildcardFilters.Remove(topicName)
+	}
 
 	// increase the deletion counter to trigger garbage collection
 	t.subscribedTopicsDeleted++
@@ -91,11 +208,18 @@ func (t *topicManager) deleteTopic(topicName string) {
 	}
 }
 
-func newTopicManager(onSubscribe OnSubscribeHandler, onUnsubscribe OnUnsubscribeHandler, cleanupThreshold int) *topicManager {
+func newTopicManager(onSubscribe OnSubscribeHandler, onUnsubscribe OnUnsubscribeHandler, cleanupThreshold int, maxSize int) *topicManager {
 	return &topicManager{
-		subscribedTopics: make(map[string]int),
+		subscribedTopics: make(map[string]*subscribedTopic),
+		wildcardFilters:  newTopicFilterTrie(),
 		onSubscribe:      onSubscribe,
 		onUnsubscribe:    onUnsubscribe,
 		cleanupThreshold: cleanupThreshold,
+		maxSize:          maxSize,
 	}
 }
+
+// isWildcardTopicFilter returns true if the topic filter contains a single-level ("+") or multi-level ("#") wildcard.
+func isWildcardTopicFilter(filter string) bool {
+	return strings.Contains(filter, topicWildcardSingle) || strings.Contains(filter, topicWildcardMultiple)
+}
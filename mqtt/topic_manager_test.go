@@ -0,0 +1,239 @@
+package mqtt
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// handlerEvents records the calls of the subscribe and unsubscribe handlers of a topic manager.
+type handlerEvents struct {
+	lock   sync.Mutex
+	events map[string][]string
+}
+
+func (h *handlerEvents) add(topic string, event string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.events[topic] = append(h.events[topic], event)
+}
+
+func newTestTopicManager() (*topicManager, *handlerEvents) {
+	events := &handlerEvents{events: make(map[string][]string)}
+	t := newTopicManager(
+		func(topic string) { events.add(topic, "subscribe") },
+		func(topic string) { events.add(topic, "unsubscribe") },
+		10, 0)
+
+	return t, events
+}
+
+func TestTopicManagerHandlersFirstAndLastSubscriber(t *testing.T) {
+	tm, events := newTestTopicManager()
+
+	tm.Subscribe("milestones")
+	tm.Subscribe("milestones")
+	if !tm.hasSubscribers("milestones") {
+		t.Fatal("expected subscribers")
+	}
+
+	tm.Unsubscribe("milestones")
+	if !tm.hasSubscribers("milestones") {
+		t.Fatal("expected the second subscriber to remain")
+	}
+
+	tm.Unsubscribe("milestones")
+	if tm.hasSubscribers("milestones") {
+		t.Fatal("expected no subscribers")
+	}
+
+	// the topic is not tracked anymore, so the handler must not be called again
+	tm.Unsubscribe("milestones")
+
+	if got := fmt.Sprint(events.events["milestones"]); got != "[subscribe unsubscribe]" {
+		t.Fatalf("unexpected handler calls: %s", got)
+	}
+}
+
+func TestTopicManagerConcurrentSubscribeUnsubscribe(t *testing.T) {
+	tm, events := newTestTopicManager()
+
+	const (
+		workers    = 16
+		iterations = 500
+	)
+	topics := []string{"milestones", "messages", "outputs/batched", "receipts"}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+
+			for i := 0; i < iterations; i++ {
+				topic := topics[(worker+i)%len(topics)]
+				tm.Subscribe(topic)
+				_ = tm.hasSubscribers(topic)
+				tm.Published(topic)
+				tm.Unsubscribe(topic)
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	if size := tm.Size(); size != 0 {
+		t.Fatalf("expected no tracked topics, got %d", size)
+	}
+
+	for _, topic := range topics {
+		topicEvents := events.events[topic]
+		if len(topicEvents) == 0 || len(topicEvents)%2 != 0 {
+			t.Fatalf("expected paired handler calls for %s, got %d", topic, len(topicEvents))
+		}
+
+		// the handlers are called while holding the lock, so the calls of a topic must strictly alternate
+		for i, event := range topicEvents {
+			expected := "subscribe"
+			if i%2 == 1 {
+				expected = "unsubscribe"
+			}
+			if event != expected {
+				t.Fatalf("handler call %d of %s is %s, expected %s", i, topic, event, expected)
+			}
+		}
+	}
+}
+
+func TestTopicManagerWildcardSubscribers(t *testing.T) {
+	tm, _ := newTestTopicManager()
+
+	tm.Subscribe("outputs/+")
+	tm.Subscribe("messages/#")
+	tm.Subscribe("#")
+	tm.Unsubscribe("#")
+
+	tests := []struct {
+		name     string
+		topic    string
+		expected bool
+	}{
+		{"single-level match", "outputs/0x01", true},
+		{"single-level too deep", "outputs/0x01/raw", false},
+		{"single-level missing level", "outputs", false},
+		{"multi-level match", "messages/tagged-data/0x01", true},
+		{"multi-level matches the parent level", "messages", true},
+		{"different first level", "milestones", false},
+		{"removed filter", "receipts", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := tm.hasSubscribers(test.topic); got != test.expected {
+				t.Fatalf("hasSubscribers(%s) = %v, expected %v", test.topic, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestTopicManagerPublishedCountsWildcardFilters(t *testing.T) {
+	tm, _ := newTestTopicManager()
+
+	for _, filter := range []string{"outputs/0x01", "outputs/+", "outputs/#", "#", "milestones/+"} {
+		tm.Subscribe(filter)
+	}
+
+	tm.Published("outputs/0x01")
+	tm.Published("outputs/0x02")
+	tm.Published("$SYS/node/syncstatus")
+
+	expected := map[string]uint64{
+		"outputs/0x01": 1,
+		"outputs/+":    2,
+		"outputs/#":    2,
+		// wildcards at the first level don't match system topics
+		"#":            2,
+		"milestones/+": 0,
+	}
+
+	stats := tm.Stats()
+	for filter, published := range expected {
+		if stats[filter].PublishedMessages != published {
+			t.Fatalf("expected %d published messages for %s, got %d", published, filter, stats[filter].PublishedMessages)
+		}
+	}
+}
+
+func TestTopicFilterTrieMatchingFilters(t *testing.T) {
+	trie := newTopicFilterTrie()
+	for _, filter := range []string{"a/b", "a/+", "a/#", "+/+", "#", "+/b/c"} {
+		trie.Add(filter)
+	}
+
+	filters := trie.MatchingFilters("a/b")
+	sort.Strings(filters)
+	if expected := []string{"#", "+/+", "a/#", "a/+", "a/b"}; !reflect.DeepEqual(filters, expected) {
+		t.Fatalf("MatchingFilters(a/b) = %v, expected %v", filters, expected)
+	}
+
+	if filters := trie.MatchingFilters("$SYS/b"); len(filters) != 0 {
+		t.Fatalf("expected no first level wildcard to match the system topic, got %v", filters)
+	}
+}
+
+func TestTopicFilterTrie(t *testing.T) {
+	tests := []struct {
+		name     string
+		filters  []string
+		topic    string
+		expected bool
+	}{
+		{"exact levels", []string{"a/b"}, "a/b", true},
+		{"single-level wildcard", []string{"a/+/c"}, "a/b/c", true},
+		{"single-level wildcard at the end", []string{"a/+"}, "a/b", true},
+		{"single-level wildcard doesn't match more levels", []string{"a/+"}, "a/b/c", false},
+		{"single-level wildcard doesn't match the parent", []string{"a/+"}, "a", false},
+		{"multi-level wildcard", []string{"a/#"}, "a/b/c", true},
+		{"multi-level wildcard matches the parent", []string{"a/#"}, "a", true},
+		{"multi-level wildcard after single-level wildcard", []string{"+/b/#"}, "a/b/c/d", true},
+		{"backtracking from an exact level", []string{"a/b/c", "a/+/d"}, "a/b/d", true},
+		{"no matching filter", []string{"a/+/c", "b/#"}, "a/b/d", false},
+		{"empty level", []string{"a/+/c"}, "a//c", true},
+		{"system topic with first level wildcard", []string{"#", "+/node/syncstatus"}, "$SYS/node/syncstatus", false},
+		{"system topic with later wildcard", []string{"$SYS/#"}, "$SYS/node/syncstatus", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			trie := newTopicFilterTrie()
+			for _, filter := range test.filters {
+				trie.Add(filter)
+			}
+
+			if got := trie.Matches(test.topic); got != test.expected {
+				t.Fatalf("Matches(%s) = %v, expected %v", test.topic, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestTopicFilterTrieRemove(t *testing.T) {
+	trie := newTopicFilterTrie()
+	trie.Add("a/+/c")
+	trie.Add("a/#")
+
+	trie.Remove("a/#")
+	if trie.Matches("a/b/d") {
+		t.Fatal("expected the removed filter to not match")
+	}
+	if !trie.Matches("a/b/c") {
+		t.Fatal("expected the remaining filter to match")
+	}
+
+	trie.Remove("a/+/c")
+	if len(trie.root.children) != 0 {
+		t.Fatalf("expected the unused levels to be released, got %d", len(trie.root.children))
+	}
+}
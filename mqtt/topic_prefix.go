@@ -0,0 +1,45 @@
+package mqtt
+
+import (
+	"errors"
+	"strings"
+)
+
+// validateTopicPrefix checks that the topic prefix is a sequence of non-empty topic levels without wildcards.
+func validateTopicPrefix(prefix string) error {
+	if strings.HasPrefix(prefix, "$") {
+		return errors.New("topic prefix must not start with \"$\"")
+	}
+
+	for _, level := range strings.Split(prefix, topicLevelSeparator) {
+		switch {
+		case level == "":
+			return errors.New("topic prefix must not contain empty topic levels")
+		case strings.Contains(level, topicWildcardMultiple), strings.Contains(level, topicWildcardSingle):
+			return errors.New("topic prefix must not contain wildcards")
+		}
+	}
+
+	return nil
+}
+
+// prefixTopic returns the topic (or filter) on the underlying broker for a topic of the application.
+// System topics belong to the broker itself and are never prefixed.
+func prefixTopic(topicPrefix string, topic string) string {
+	if topicPrefix == "" || strings.HasPrefix(topic, sysTopicPrefix) {
+		return topic
+	}
+	return topicPrefix + topic
+}
+
+// unprefixTopic returns the topic (or filter) of the application for a topic on the underlying broker.
+// It returns false if the topic is outside of the namespace of the prefix, in which case the topic is returned unchanged.
+func unprefixTopic(topicPrefix string, topic string) (string, bool) {
+	if topicPrefix == "" || strings.HasPrefix(topic, sysTopicPrefix) {
+		return topic, true
+	}
+	if !strings.HasPrefix(topic, topicPrefix) {
+		return topic, false
+	}
+	return strings.TrimPrefix(topic, topicPrefix), true
+}
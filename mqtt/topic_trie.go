@@ -0,0 +1,125 @@
+package mqtt
+
+import (
+	"strings"
+)
+
+// topicFilterTrieNode is a topic level of the stored topic filters.
+type topicFilterTrieNode struct {
+	children map[string]*topicFilterTrieNode
+	// end is true if a stored topic filter ends at this level.
+	end bool
+	// filter is the topic filter that ends at this level, it is only set if end is true.
+	filter string
+}
+
+// topicFilterTrie stores topic filters by their levels, so the filters that match a concrete topic are found
+// by following the levels of the topic and the "+" and "#" wildcards at each level, instead of comparing every filter.
+// It is not safe for concurrent use.
+type topicFilterTrie struct {
+	root *topicFilterTrieNode
+}
+
+// Add stores the topic filter.
+func (t *topicFilterTrie) Add(filter string) {
+	node := t.root
+	for _, level := range strings.Split(filter, topicLevelSeparator) {
+		child, has := node.children[level]
+		if !has {
+			child = &topicFilterTrieNode{children: make(map[string]*topicFilterTrieNode)}
+			node.children[level] = child
+		}
+		node = child
+	}
+	node.end = true
+	node.filter = filter
+}
+
+// Remove removes the topic filter, the levels that are not used by other filters anymore are released.
+func (t *topicFilterTrie) Remove(filter string) {
+	removeTrieLevels(t.root, strings.Split(filter, topicLevelSeparator))
+}
+
+// removeTrieLevels removes the remaining levels of a filter below the node,
+// it returns true if the node is not needed anymore.
+func removeTrieLevels(node *topicFilterTrieNode, levels []string) bool {
+	if len(levels) == 0 {
+		node.end = false
+		node.filter = ""
+	} else if child, has := node.children[levels[0]]; has && removeTrieLevels(child, levels[1:]) {
+		delete(node.children, levels[0])
+	}
+
+	return !node.end && len(node.children) == 0
+}
+
+// Matches returns true if a stored topic filter matches the concrete topic.
+// Like in topicMatchesFilter, wildcards at the first level don't match system topics.
+func (t *topicFilterTrie) Matches(topic string) bool {
+	return matchTrieLevels(t.root, strings.Split(topic, topicLevelSeparator), 0, strings.HasPrefix(topic, "$"))
+}
+
+// matchTrieLevels returns true if a stored filter below the node matches the topic levels starting at the given index.
+func matchTrieLevels(node *topicFilterTrieNode, levels []string, index int, systemTopic bool) bool {
+	wildcardsAllowed := index > 0 || !systemTopic
+
+	// "#" also matches the parent level
+	if multi, has := node.children[topicWildcardMultiple]; has && multi.end && wildcardsAllowed {
+		return true
+	}
+
+	if index == len(levels) {
+		return node.end
+	}
+
+	if child, has := node.children[levels[index]]; has && matchTrieLevels(child, levels, index+1, systemTopic) {
+		return true
+	}
+
+	if single, has := node.children[topicWildcardSingle]; has && wildcardsAllowed {
+		return matchTrieLevels(single, levels, index+1, systemTopic)
+	}
+
+	return false
+}
+
+// MatchingFilters returns all stored topic filters that match the concrete topic, in no particular order.
+// Like Matches, wildcards at the first level don't match system topics.
+func (t *topicFilterTrie) MatchingFilters(topic string) []string {
+	var filters []string
+	collectTrieMatches(t.root, strings.Split(topic, topicLevelSeparator), 0, strings.HasPrefix(topic, "$"), &filters)
+
+	return filters
+}
+
+// collectTrieMatches appends the stored filters below the node that match the topic levels starting at the given index.
+// Every filter is a distinct path in the trie, so it is appended at most once.
+func collectTrieMatches(node *topicFilterTrieNode, levels []string, index int, systemTopic bool, filters *[]string) {
+	wildcardsAllowed := index > 0 || !systemTopic
+
+	// "#" also matches the parent level
+	if multi, has := node.children[topicWildcardMultiple]; has && multi.end && wildcardsAllowed {
+		*filters = append(*filters, multi.filter)
+	}
+
+	if index == len(levels) {
+		if node.end {
+			*filters = append(*filters, node.filter)
+		}
+		return
+	}
+
+	if child, has := node.children[levels[index]]; has {
+		collectTrieMatches(child, levels, index+1, systemTopic, filters)
+	}
+
+	if single, has := node.children[topicWildcardSingle]; has && wildcardsAllowed {
+		collectTrieMatches(single, levels, index+1, systemTopic, filters)
+	}
+}
+
+func newTopicFilterTrie() *topicFilterTrie {
+	return &topicFilterTrie{
+		root: &topicFilterTrieNode{children: make(map[string]*topicFilterTrieNode)},
+	}
+}
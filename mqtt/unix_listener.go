@@ -0,0 +1,139 @@
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/mochi-co/mqtt/server/listeners"
+	"github.com/mochi-co/mqtt/server/system"
+)
+
+// unixSocketDialTimeout is the timeout for connecting to an existing socket file to check if it is still in use.
+const unixSocketDialTimeout = 1 * time.Second
+
+// unixListener is a listener for connections on a Unix domain socket.
+// A stale socket file at the path is removed before listening, and the socket file is removed on close.
+// A socket file that is still in use by another process is never removed.
+type unixListener struct {
+	sync.RWMutex
+	id     string
+	path   string
+	listen net.Listener
+	config *listeners.Config
+	// ensure the close methods are only called once.
+	end uint32
+}
+
+// SetConfig sets the configuration values for the listener config.
+func (l *unixListener) SetConfig(config *listeners.Config) {
+	l.Lock()
+	defer l.Unlock()
+
+	if config != nil {
+		l.config = config
+	}
+}
+
+// ID returns the id of the listener.
+func (l *unixListener) ID() string {
+	l.RLock()
+	defer l.RUnlock()
+
+	return l.id
+}
+
+// Listen starts listening on the socket path.
+func (l *unixListener) Listen(s *system.Info) error {
+	if err := removeStaleUnixSocket(l.path); err != nil {
+		return err
+	}
+
+	var err error
+	l.listen, err = net.Listen("unix", l.path)
+	return err
+}
+
+// Serve starts waiting for new connections, and calls the establish connection callback for every connection.
+func (l *unixListener) Serve(establish listeners.EstablishFunc) {
+	for {
+		if atomic.LoadUint32(&l.end) == 1 {
+			return
+		}
+
+		conn, err := l.listen.Accept()
+		if err != nil {
+			return
+		}
+
+		if atomic.LoadUint32(&l.end) == 0 {
+			go func() {
+				_ = establish(l.id, conn, l.config.Auth)
+			}()
+		}
+	}
+}
+
+// Close closes the listener and any client connections, and removes the socket file.
+func (l *unixListener) Close(closeClients listeners.CloseFunc) {
+	l.Lock()
+	defer l.Unlock()
+
+	if atomic.CompareAndSwapUint32(&l.end, 0, 1) {
+		closeClients(l.id)
+	}
+
+	if l.listen != nil {
+		// closing the listener also removes the socket file it created
+		_ = l.listen.Close()
+	}
+}
+
+// removeStaleUnixSocket removes the socket file at the path, e.g. left over after a crash.
+// Files at the path that are not sockets are never removed, and neither are sockets a process is still listening on,
+// so the socket is only removed if connecting to it is refused.
+func removeStaleUnixSocket(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("unable to check unix socket file (%s): %w", path, err)
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("unix socket path (%s) exists, but is not a socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, unixSocketDialTimeout)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("unix socket (%s) is already in use: %w", path, syscall.EADDRINUSE)
+	}
+	if errors.Is(err, syscall.ENOENT) {
+		// the socket file was removed in the meantime
+		return nil
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("unable to check if unix socket (%s) is in use: %w", path, err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("unable to remove stale unix socket file (%s): %w", path, err)
+	}
+
+	return nil
+}
+
+func newUnixListener(id string, path string) *unixListener {
+	return &unixListener{
+		id:   id,
+		path: path,
+	}
+}
@@ -0,0 +1,61 @@
+package mqtt
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iotaledger/hive.go/logger"
+)
+
+func TestUnixListenerRemovesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mqtt.sock")
+
+	// the socket file of a crashed process is left behind, but nobody listens on it anymore
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("creating stale socket failed: %s", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	newTestBrokerWithOptions(t, WithUnixSocketEnabled(true), WithUnixSocketPath(path))
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("connecting to the unix socket of the broker failed: %s", err)
+	}
+	_ = conn.Close()
+}
+
+func TestUnixListenerKeepsSocketInUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mqtt.sock")
+
+	live, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("creating socket failed: %s", err)
+	}
+	defer func() { _ = live.Close() }()
+
+	brokerOpts := &BrokerOptions{}
+	brokerOpts.ApplyOnDefault(
+		WithWebsocketEnabled(false),
+		WithUnixSocketEnabled(true),
+		WithUnixSocketPath(path),
+	)
+
+	if _, err := NewBroker(logger.NewNopLogger(), func(string) {}, func(string) {}, brokerOpts); !errors.Is(err, ErrBindAddressInUse) {
+		t.Fatalf("expected the socket in use to be reported as bind address in use, got %v", err)
+	}
+
+	if _, err := os.Lstat(path); err != nil {
+		t.Fatalf("expected the socket in use to be kept: %s", err)
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("connecting to the socket in use failed: %s", err)
+	}
+	_ = conn.Close()
+}
@@ -0,0 +1,179 @@
+package mqtt
+
+import (
+	"compress/flate"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mochi-co/mqtt/server/listeners"
+	"github.com/mochi-co/mqtt/server/system"
+)
+
+const (
+	// websocketShutdownTimeout is the maximum duration to wait for the HTTP server of a websocket listener to shut down.
+	websocketShutdownTimeout = 5 * time.Second
+)
+
+// websocketCompression defines the permessage-deflate compression of the websocket connections.
+type websocketCompression struct {
+	// enabled defines whether the compression is negotiated with the clients that support it.
+	enabled bool
+	// level is the flate compression level of the messages written to the clients.
+	level int
+}
+
+// validateWebsocketCompressionLevel checks that the compression level is a valid flate compression level.
+func validateWebsocketCompressionLevel(level int) error {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		return fmt.Errorf("invalid websocket compression level %d, allowed values: %d to %d", level, flate.HuffmanOnly, flate.BestCompression)
+	}
+	return nil
+}
+
+// websocketListener is a listener for websocket connections with optional TLS.
+// In contrast to the websocket listener of the underlying broker, the socket is already bound in Listen,
+// so bind failures are returned on creation instead of being lost in the serving goroutine.
+type websocketListener struct {
+	sync.RWMutex
+	id        string
+	address   string
+	tlsConfig *tls.Config
+	// upgrader upgrades the incoming HTTP connections to websocket connections using the MQTT subprotocol.
+	upgrader    *websocket.Upgrader
+	compression websocketCompression
+	listen      net.Listener
+	server      *http.Server
+	config      *listeners.Config
+	establish   listeners.EstablishFunc
+	// ensure the close methods are only called once.
+	end uint32
+}
+
+// websocketConn is a websocket connection which satisfies the net.Conn interface.
+type websocketConn struct {
+	net.Conn
+	c *websocket.Conn
+}
+
+// Read reads the next span of bytes from the websocket connection and returns the number of bytes read.
+func (ws *websocketConn) Read(p []byte) (int, error) {
+	op, r, err := ws.c.NextReader()
+	if err != nil {
+		return 0, err
+	}
+
+	if op != websocket.BinaryMessage {
+		return 0, listeners.ErrInvalidMessage
+	}
+
+	return r.Read(p)
+}
+
+// Write writes bytes to the websocket connection.
+func (ws *websocketConn) Write(p []byte) (int, error) {
+	if err := ws.c.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// SetConfig sets the configuration values for the listener config.
+func (l *websocketListener) SetConfig(config *listeners.Config) {
+	l.Lock()
+	defer l.Unlock()
+
+	if config != nil {
+		l.config = config
+	}
+}
+
+// ID returns the id of the listener.
+func (l *websocketListener) ID() string {
+	l.RLock()
+	defer l.RUnlock()
+
+	return l.id
+}
+
+// Listen binds the listener to the network address.
+func (l *websocketListener) Listen(s *system.Info) error {
+	listen, err := net.Listen("tcp", l.address)
+	if err != nil {
+		return err
+	}
+
+	if l.tlsConfig != nil {
+		listen = tls.NewListener(listen, l.tlsConfig)
+	}
+	l.listen = listen
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", l.handler)
+	l.server = &http.Server{Handler: mux}
+
+	return nil
+}
+
+func (l *websocketListener) handler(w http.ResponseWriter, r *http.Request) {
+	c, err := l.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	if l.compression.enabled {
+		// the messages are only compressed if the client negotiated the compression
+		if err := c.SetCompressionLevel(l.compression.level); err != nil {
+			return
+		}
+	}
+
+	_ = l.establish(l.id, &websocketConn{Conn: c.UnderlyingConn(), c: c}, l.config.Auth)
+}
+
+// Serve starts waiting for new websocket connections, and calls the establish connection callback for every connection.
+func (l *websocketListener) Serve(establish listeners.EstablishFunc) {
+	l.establish = establish
+
+	_ = l.server.Serve(l.listen)
+}
+
+// Close closes the listener and any client connections.
+func (l *websocketListener) Close(closeClients listeners.CloseFunc) {
+	l.Lock()
+	defer l.Unlock()
+
+	if atomic.CompareAndSwapUint32(&l.end, 0, 1) && l.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), websocketShutdownTimeout)
+		defer cancel()
+
+		_ = l.server.Shutdown(ctx)
+		// the server only closes the socket if it was already serving
+		_ = l.listen.Close()
+	}
+
+	closeClients(l.id)
+}
+
+// newWebsocketListener creates a websocket listener, TLS is used if a TLS config is given.
+func newWebsocketListener(id string, address string, tlsConfig *tls.Config, compression websocketCompression) *websocketListener {
+	return &websocketListener{
+		id:        id,
+		address:   address,
+		tlsConfig: tlsConfig,
+		upgrader: &websocket.Upgrader{
+			Subprotocols:      []string{"mqtt"},
+			CheckOrigin:       func(r *http.Request) bool { return true },
+			EnableCompression: compression.enabled,
+		},
+		compression: compression,
+	}
+}
@@ -0,0 +1,167 @@
+package mqtt
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/gorilla/websocket"
+)
+
+// countingConn counts the bytes read from the connection, i.e. the size of the messages on the wire.
+type countingConn struct {
+	net.Conn
+	readBytes uint64
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	atomic.AddUint64(&c.readBytes, uint64(n))
+	return n, err
+}
+
+// websocketStreamReader reads the binary websocket messages as a continuous stream,
+// since MQTT packets may be split across or combined into messages.
+type websocketStreamReader struct {
+	c      *websocket.Conn
+	reader io.Reader
+}
+
+func (r *websocketStreamReader) Read(p []byte) (int, error) {
+	for {
+		if r.reader == nil {
+			_, reader, err := r.c.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			r.reader = reader
+		}
+
+		n, err := r.reader.Read(p)
+		if err == io.EOF {
+			r.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// writeWebsocketPacket writes the MQTT packet as a binary websocket message.
+func writeWebsocketPacket(t *testing.T, c *websocket.Conn, packet packets.ControlPacket) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := packet.Write(&buf); err != nil {
+		t.Fatalf("encoding packet failed: %s", err)
+	}
+	if err := c.WriteMessage(websocket.BinaryMessage, buf.Bytes()); err != nil {
+		t.Fatalf("writing packet failed: %s", err)
+	}
+}
+
+func TestWebsocketCompressionRoundTrip(t *testing.T) {
+	// a compressible payload that is much larger than the packet overhead
+	payload := []byte(strings.Repeat(`{"index":1,"timestamp":1651000000}`, 500))
+
+	tests := []struct {
+		name              string
+		clientCompression bool
+	}{
+		{"client with compression", true},
+		{"client without compression", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			websocketAddress := freeAddress(t)
+			broker, _ := newTestBroker(t,
+				WithWebsocketEnabled(true),
+				WithWebsocketBindAddress(websocketAddress),
+				WithWebsocketCompressionEnabled(true),
+				WithWebsocketCompressionLevel(9),
+			)
+
+			var conn *countingConn
+			dialer := &websocket.Dialer{
+				Subprotocols:      []string{"mqtt"},
+				EnableCompression: test.clientCompression,
+				HandshakeTimeout:  testTimeout,
+				NetDialContext: func(ctx context.Context, network string, address string) (net.Conn, error) {
+					c, err := (&net.Dialer{}).DialContext(ctx, network, address)
+					if err != nil {
+						return nil, err
+					}
+					conn = &countingConn{Conn: c}
+					return conn, nil
+				},
+			}
+
+			c, response, err := dialer.Dial("ws://"+websocketAddress+"/mqtt", nil)
+			if err != nil {
+				t.Fatalf("connecting websocket failed: %s", err)
+			}
+			defer func() { _ = c.Close() }()
+			_ = response.Body.Close()
+
+			negotiated := strings.Contains(response.Header.Get("Sec-Websocket-Extensions"), "permessage-deflate")
+			if negotiated != test.clientCompression {
+				t.Fatalf("compression negotiated: %v, expected %v", negotiated, test.clientCompression)
+			}
+
+			_ = c.SetReadDeadline(time.Now().Add(testTimeout))
+			reader := &websocketStreamReader{c: c}
+
+			connect := packets.NewControlPacket(packets.Connect).(*packets.ConnectPacket)
+			connect.ProtocolName = "MQTT"
+			connect.ProtocolVersion = 4
+			connect.CleanSession = true
+			connect.ClientIdentifier = "websocket"
+			writeWebsocketPacket(t, c, connect)
+
+			if connack, err := packets.ReadPacket(reader); err != nil {
+				t.Fatalf("reading CONNACK failed: %s", err)
+			} else if connack.(*packets.ConnackPacket).ReturnCode != packets.Accepted {
+				t.Fatal("connection was not accepted")
+			}
+
+			subscribe := packets.NewControlPacket(packets.Subscribe).(*packets.SubscribePacket)
+			subscribe.MessageID = 1
+			subscribe.Topics = []string{"milestones"}
+			subscribe.Qoss = []byte{0}
+			writeWebsocketPacket(t, c, subscribe)
+
+			if _, err := packets.ReadPacket(reader); err != nil {
+				t.Fatalf("reading SUBACK failed: %s", err)
+			}
+
+			readBefore := atomic.LoadUint64(&conn.readBytes)
+			if err := broker.SendWithOptions("milestones", payload, 0, false); err != nil {
+				t.Fatalf("sending message failed: %s", err)
+			}
+
+			packet, err := packets.ReadPacket(reader)
+			if err != nil {
+				t.Fatalf("reading PUBLISH failed: %s", err)
+			}
+			published, ok := packet.(*packets.PublishPacket)
+			if !ok || published.TopicName != "milestones" || !bytes.Equal(published.Payload, payload) {
+				t.Fatal("received message doesn't match the sent message")
+			}
+
+			// the compressed message on the wire is smaller than the payload, the uncompressed one is larger
+			wireBytes := atomic.LoadUint64(&conn.readBytes) - readBefore
+			if compressed := wireBytes < uint64(len(payload)); compressed != test.clientCompression {
+				t.Fatalf("received %d bytes on the wire for a payload of %d bytes, expected compression: %v", wireBytes, len(payload), test.clientCompression)
+			}
+		})
+	}
+}
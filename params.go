@@ -1,29 +1,193 @@
 package main
 
 import (
+	"time"
+
 	flag "github.com/spf13/pflag"
+
+	"github.com/iotaledger/hive.go/logger"
+
+	"github.com/gohornet/inx-mqtt/mqtt"
 )
 
 const (
 	// CfgINXAddress the INX address to which to connect to.
 	CfgINXAddress = "inx.address"
 
+	// CfgLoggerLevel is the minimum enabled logging level.
+	CfgLoggerLevel = logger.ConfigurationKeyLevel
+	// CfgLoggerDisableCaller stops annotating logs with the calling function's file name and line number.
+	CfgLoggerDisableCaller = logger.ConfigurationKeyDisableCaller
+	// CfgLoggerEncoding is the logger's encoding ("console" or "json").
+	CfgLoggerEncoding = logger.ConfigurationKeyEncoding
+	// CfgLoggerOutputPaths is a list of URLs, file paths or stdout/stderr to write logging output to.
+	CfgLoggerOutputPaths = logger.ConfigurationKeyOutputPaths
+
 	// CfgMQTTBufferSize is the size of the client buffers in bytes.
 	CfgMQTTBufferSize = "mqtt.bufferSize"
 	// CfgMQTTBufferBlockSize is the size per client buffer R/W block in bytes.
 	CfgMQTTBufferBlockSize = "mqtt.bufferBlockSize"
+	// CfgMQTTSlowClientPolicy defines what happens to messages for a client whose outgoing buffer is saturated.
+	CfgMQTTSlowClientPolicy = "mqtt.slowClientPolicy"
+	// CfgMQTTFirehosePolicy defines what happens to messages on the firehose topics for a client under backpressure.
+	CfgMQTTFirehosePolicy = "mqtt.firehose.policy"
+	// CfgMQTTFirehoseTopics are the topic filters of the firehose topics.
+	CfgMQTTFirehoseTopics = "mqtt.firehose.topics"
+	// CfgMQTTFirehoseBackpressureThreshold is the fill level of the outgoing buffer of a client in percent above which the firehose policy applies.
+	CfgMQTTFirehoseBackpressureThreshold = "mqtt.firehose.backpressureThreshold"
+	// CfgMQTTFirehoseSampleRate is the ratio of the firehose messages that are delivered to a client under backpressure by the sample policy.
+	CfgMQTTFirehoseSampleRate = "mqtt.firehose.sampleRate"
 	// CfgMQTTTopicCleanupThreshold the number of deleted topics that trigger a garbage collection of the topic manager.
 	CfgMQTTTopicCleanupThreshold = "mqtt.topicCleanupThreshold"
+	// CfgMQTTMaxTopicManagerSize is the maximum amount of distinct subscribed topics, subscriptions to new topics beyond are rejected (0 = unlimited).
+	CfgMQTTMaxTopicManagerSize = "mqtt.maxTopicManagerSize"
+	// CfgMQTTTopicPrefix is the namespace the topics are published in, e.g. "mainnet" publishes "mainnet/milestones/latest".
+	CfgMQTTTopicPrefix = "mqtt.topicPrefix"
+
+	// CfgMQTTLimitsMaxClients is the maximum amount of concurrently connected clients across all listeners (0 = unlimited).
+	CfgMQTTLimitsMaxClients = "mqtt.limits.maxClients"
+	// CfgMQTTLimitsMaxConnectionsPerIP is the maximum amount of connections per remote IP (0 = unlimited).
+	CfgMQTTLimitsMaxConnectionsPerIP = "mqtt.limits.maxConnectionsPerIP"
+	// CfgMQTTLimitsMaxSubscriptionsPerClient is the maximum amount of subscriptions per client (0 = unlimited).
+	CfgMQTTLimitsMaxSubscriptionsPerClient = "mqtt.limits.maxSubscriptionsPerClient"
+	// CfgMQTTLimitsMaxMessagesPerSecondPerClient is the maximum rate of messages a client can publish (0 = unlimited).
+	CfgMQTTLimitsMaxMessagesPerSecondPerClient = "mqtt.limits.maxMessagesPerSecondPerClient"
+	// CfgMQTTMaxRetainedMessages is the maximum amount of retained messages the broker stores (0 = unlimited).
+	CfgMQTTMaxRetainedMessages = "mqtt.maxRetainedMessages"
+	// CfgMQTTPublishQoS is the QoS per topic the messages are published with (e.g. "milestone-info/latest": "1").
+	CfgMQTTPublishQoS = "mqtt.publishOptions.qos"
+	// CfgMQTTPublishRetainedTopics are the topics the last message is stored as retained message for.
+	CfgMQTTPublishRetainedTopics = "mqtt.publishOptions.retainedTopics"
+	// CfgMQTTRetainUpdateInterval is the minimum interval between updates of the retained message of a topic (0 = disabled).
+	CfgMQTTRetainUpdateInterval = "mqtt.retainUpdateInterval"
+	// CfgMQTTRetainedStorePath is the path to the file the retained messages are persisted in across restarts (empty = disabled).
+	CfgMQTTRetainedStorePath = "mqtt.retainedStorePath"
+	// CfgMQTTSubscriptionFilterFilePath is the path to a JSON file with include and exclude topic filters that are subscribed internally.
+	CfgMQTTSubscriptionFilterFilePath = "mqtt.subscriptionFilterFilePath"
+	// CfgMQTTOutputTopicGranularity defines on which output topics the outputs are published ("id", "address" or "type").
+	// "id" publishes on all output topics, which results in one topic per output.
+	// "address" suppresses the per-ID topics (outputs/{outputId}, outputs/nfts/{nftId}, outputs/aliases/{aliasId}, outputs/foundries/{foundryId}).
+	// "type" additionally suppresses the unlock condition address topics (outputs/unlock/...), only outputs/type/{outputType} is published.
+	CfgMQTTOutputTopicGranularity = "mqtt.outputTopicGranularity"
+	// CfgMQTTTransactionBalanceEnabled defines whether the output payloads are enriched with the balance effects of the transaction.
+	CfgMQTTTransactionBalanceEnabled = "mqtt.transactionBalanceEnabled"
+	// CfgMQTTMonotonicMilestoneTimestamps defines whether the milestone info payloads contain a monotonic corrected timestamp.
+	CfgMQTTMonotonicMilestoneTimestamps = "mqtt.monotonicMilestoneTimestamps"
+	// CfgMQTTVerifyMilestoneSignatures defines whether the signatures of milestones are verified before publishing.
+	CfgMQTTVerifyMilestoneSignatures = "mqtt.verifyMilestoneSignatures"
+	// CfgMQTTPayloadFormat defines the encoding of the output and message metadata payloads on the raw topics ("json" or "cbor").
+	// "json" disables the raw topics, "cbor" additionally publishes the payloads CBOR encoded on the topics with the "/raw" suffix.
+	CfgMQTTPayloadFormat = "mqtt.payloadFormat"
+	// CfgMQTTDeduplicateOutputs defines whether a client receives an output event at most once, even if several of its subscriptions match.
+	CfgMQTTDeduplicateOutputs = "mqtt.deduplicateOutputs"
+	// CfgMQTTDecodeOutputs defines whether the output payloads contain the decoded output type and owner address.
+	CfgMQTTDecodeOutputs = "mqtt.decodeOutputs"
+	// CfgMQTTPublishTimestamps defines whether the payloads contain the unix time in milliseconds at which the broker published them.
+	CfgMQTTPublishTimestamps = "mqtt.publishTimestamps"
+	// CfgMQTTPublishFilterIncludedOnly defines whether the message metadata is only published for messages that are included in the ledger.
+	CfgMQTTPublishFilterIncludedOnly = "mqtt.publishFilter.includedOnly"
+	// CfgMQTTMessageExpiry is the expiry per topic prefix of the messages that are queued for clients (e.g. "outputs/": "1m").
+	CfgMQTTMessageExpiry = "mqtt.messageExpiry"
+	// CfgMQTTAckTimeout is the duration after which a QoS message that was not acknowledged by a connected client is retransmitted.
+	CfgMQTTAckTimeout = "mqtt.ackTimeout.timeout"
+	// CfgMQTTAckTimeoutMaxRetransmissions is the amount of retransmissions after which a client that does not acknowledge a message is disconnected (0 = disabled).
+	CfgMQTTAckTimeoutMaxRetransmissions = "mqtt.ackTimeout.maxRetransmissions"
+	// CfgMQTTThroughputWindows are the sliding time windows over which the publish rates per topic category are tracked.
+	CfgMQTTThroughputWindows = "mqtt.throughputWindows"
+	// CfgMQTTClientEventLogSampleRate defines that only one out of every N successful connects and regular disconnects is logged.
+	// Disconnects with errors, client errors and evictions are never sampled out and are logged as warnings.
+	CfgMQTTClientEventLogSampleRate = "mqtt.clientEventLogSampleRate"
+	// CfgMQTTShutdownTimeout is the maximum duration to wait for the queued messages to be delivered on shutdown (0 = close immediately).
+	CfgMQTTShutdownTimeout = "mqtt.shutdownTimeout"
+
+	// CfgMQTTOutputBatchingEnabled defines whether clients can receive their output events coalesced into batches on "outputs/batched".
+	CfgMQTTOutputBatchingEnabled = "mqtt.outputBatching.enabled"
+	// CfgMQTTOutputBatchingWindow is the time window in which the output events for a client are coalesced into one batch.
+	CfgMQTTOutputBatchingWindow = "mqtt.outputBatching.window"
+	// CfgMQTTOutputBatchingMaxSize is the maximum amount of output events in a batch.
+	CfgMQTTOutputBatchingMaxSize = "mqtt.outputBatching.maxSize"
+
+	// CfgMQTTPublishCoalesceWindow is the time window in which only the latest message per coalesced topic is published (0 = disabled).
+	CfgMQTTPublishCoalesceWindow = "mqtt.publishCoalesce.window"
+	// CfgMQTTPublishCoalesceTopics are the topic filters of the topics that represent a current state and are coalesced.
+	CfgMQTTPublishCoalesceTopics = "mqtt.publishCoalesce.topics"
+
+	// CfgMQTTTopicHooksHTTPPost maps MQTT topic filters to URLs the payloads of the matching published messages are POSTed to.
+	CfgMQTTTopicHooksHTTPPost = "mqtt.topicHooks.httpPost"
+	// CfgMQTTTopicHooksHTTPTimeout is the timeout of the HTTP POST topic hooks.
+	CfgMQTTTopicHooksHTTPTimeout = "mqtt.topicHooks.httpTimeout"
+	// CfgMQTTTopicHooksWorkers is the amount of workers that call the topic hooks.
+	CfgMQTTTopicHooksWorkers = "mqtt.topicHooks.workers"
+	// CfgMQTTTopicHooksQueueSize is the maximum amount of queued topic hook invocations.
+	CfgMQTTTopicHooksQueueSize = "mqtt.topicHooks.queueSize"
+
+	// CfgMQTTBridgeEnabled defines whether to forward the messages published on the bridge topics to an upstream MQTT broker.
+	CfgMQTTBridgeEnabled = "mqtt.bridge.enabled"
+	// CfgMQTTBridgeURL is the URL of the upstream MQTT broker.
+	CfgMQTTBridgeURL = "mqtt.bridge.url"
+	// CfgMQTTBridgeUsername is the username used to connect to the upstream MQTT broker.
+	CfgMQTTBridgeUsername = "mqtt.bridge.username"
+	// CfgMQTTBridgePassword is the password used to connect to the upstream MQTT broker.
+	CfgMQTTBridgePassword = "mqtt.bridge.password"
+	// CfgMQTTBridgeTopics are the MQTT topic filters of the topics that are forwarded to the upstream MQTT broker.
+	CfgMQTTBridgeTopics = "mqtt.bridge.topics"
+	// CfgMQTTBridgeOfflineQueuePath is the directory of the offline queue of the bridge.
+	CfgMQTTBridgeOfflineQueuePath = "mqtt.bridge.offlineQueue.path"
+	// CfgMQTTBridgeOfflineQueueMaxSize is the maximum size of the messages in the offline queue of the bridge in bytes.
+	CfgMQTTBridgeOfflineQueueMaxSize = "mqtt.bridge.offlineQueue.maxSize"
+	// CfgMQTTBridgeOfflineQueueOverflowPolicy defines which messages are dropped if the offline queue of the bridge is full.
+	CfgMQTTBridgeOfflineQueueOverflowPolicy = "mqtt.bridge.offlineQueue.overflowPolicy"
+
+	// CfgMQTTHealthBindAddress is the bind address of the HTTP server for liveness and readiness probes ("" = disabled).
+	CfgMQTTHealthBindAddress = "mqtt.health.bindAddress"
+
+	// CfgMQTTIdleConnectionReaperEnabled defines whether to disconnect clients without subscriptions that are idle for too long.
+	CfgMQTTIdleConnectionReaperEnabled = "mqtt.idleConnectionReaper.enabled"
+	// CfgMQTTIdleConnectionReaperTimeout is the duration after which an idle client without subscriptions is disconnected.
+	CfgMQTTIdleConnectionReaperTimeout = "mqtt.idleConnectionReaper.timeout"
+	// CfgMQTTIdleConnectionReaperCheckInterval is the interval in which the connections are checked for being idle.
+	CfgMQTTIdleConnectionReaperCheckInterval = "mqtt.idleConnectionReaper.checkInterval"
+	// CfgMQTTKeepAliveMax is the maximum keep-alive of the clients (0 = no limit).
+	CfgMQTTKeepAliveMax = "mqtt.keepAlive.max"
+	// CfgMQTTKeepAliveReadIdleTimeout is the maximum duration without any incoming traffic from a client (0 = no limit).
+	CfgMQTTKeepAliveReadIdleTimeout = "mqtt.keepAlive.readIdleTimeout"
+
+	// CfgMQTTCloseEndedTopicsEnabled defines whether the clients are unsubscribed from output topics of spent outputs.
+	CfgMQTTCloseEndedTopicsEnabled = "mqtt.closeEndedTopics.enabled"
+	// CfgMQTTCloseEndedTopicsMessage is the message that is sent to the unsubscribed clients on the ended topic ("" = no message).
+	CfgMQTTCloseEndedTopicsMessage = "mqtt.closeEndedTopics.message"
 
 	// CfgMQTTWebsocketEnabled defines whether to enable the websocket connection of the MQTT broker.
 	CfgMQTTWebsocketEnabled = "mqtt.websocket.enabled"
 	// CfgMQTTWebsocketBindAddress the websocket bind address on which the MQTT broker listens on.
 	CfgMQTTWebsocketBindAddress = "mqtt.websocket.bindAddress"
 
+	// CfgMQTTWebsocketTLSEnabled defines whether to enable the secure websocket (wss) connection of the MQTT broker.
+	CfgMQTTWebsocketTLSEnabled = "mqtt.websocket.tls.enabled"
+	// CfgMQTTWebsocketTLSBindAddress the secure websocket bind address on which the MQTT broker listens on.
+	CfgMQTTWebsocketTLSBindAddress = "mqtt.websocket.tls.bindAddress"
+	// CfgMQTTWebsocketTLSCertificatePath is the path to the certificate file (x509 PEM) for secure websocket connections.
+	CfgMQTTWebsocketTLSCertificatePath = "mqtt.websocket.tls.certificatePath"
+	// CfgMQTTWebsocketTLSPrivateKeyPath is the path to the private key file (x509 PEM) for secure websocket connections.
+	CfgMQTTWebsocketTLSPrivateKeyPath = "mqtt.websocket.tls.privateKeyPath"
+	// CfgMQTTWebsocketCompressionEnabled defines whether the permessage-deflate compression is negotiated with the websocket clients.
+	CfgMQTTWebsocketCompressionEnabled = "mqtt.websocket.compression.enabled"
+	// CfgMQTTWebsocketCompressionLevel is the flate compression level of the messages written to the websocket clients.
+	CfgMQTTWebsocketCompressionLevel = "mqtt.websocket.compression.level"
+
 	// CfgMQTTTCPEnabled defines whether to enable the TCP connection of the MQTT broker.
 	CfgMQTTTCPEnabled = "mqtt.tcp.enabled"
 	// CfgMQTTTCPBindAddress the TCP bind address on which the MQTT broker listens on.
 	CfgMQTTTCPBindAddress = "mqtt.tcp.bindAddress"
+	// CfgMQTTTCPProxyProtocolEnabled defines whether the TCP connections start with a PROXY protocol header that contains the real client address.
+	CfgMQTTTCPProxyProtocolEnabled = "mqtt.tcp.proxyProtocolEnabled"
+	// CfgMQTTTCPAdditionalListeners maps the bind addresses of additional TCP listeners to the features they enable ("auth", "tls" and "proxyProtocol").
+	CfgMQTTTCPAdditionalListeners = "mqtt.tcp.additionalListeners"
+
+	// CfgMQTTUnixSocketEnabled defines whether to enable the unix socket connection of the MQTT broker.
+	CfgMQTTUnixSocketEnabled = "mqtt.unixSocket.enabled"
+	// CfgMQTTUnixSocketPath is the path of the unix socket on which the MQTT broker listens on.
+	CfgMQTTUnixSocketPath = "mqtt.unixSocket.path"
 
 	// CfgMQTTTCPAuthEnabled defines whether to enable auth for TCP connections.
 	CfgMQTTTCPAuthEnabled = "mqtt.tcp.auth.enabled"
@@ -31,6 +195,16 @@ const (
 	CfgMQTTTCPAuthPasswordSalt = "mqtt.tcp.auth.passwordSalt"
 	// CfgMQTTTCPAuthUsers is the list of allowed users with their password+salt as a scrypt hash.
 	CfgMQTTTCPAuthUsers = "mqtt.tcp.auth.users"
+	// CfgMQTTTCPAuthReloadOnSIGHUP defines whether the users and the password salt are reloaded from the config file on SIGHUP.
+	CfgMQTTTCPAuthReloadOnSIGHUP = "mqtt.tcp.auth.reloadOnSIGHUP"
+	// CfgMQTTTCPAuthACLFilePath is the path to a JSON file with the per-user permissions to subscribe to and publish on topics.
+	CfgMQTTTCPAuthACLFilePath = "mqtt.tcp.auth.aclFilePath"
+	// CfgMQTTTCPAuthJWTKeyPath is the path to a file with the PEM encoded public key or the HMAC secret the JWTs of the clients are verified with.
+	CfgMQTTTCPAuthJWTKeyPath = "mqtt.tcp.auth.jwt.keyPath"
+	// CfgMQTTTCPAuthJWTIssuer is the issuer that must match the "iss" claim of the JWTs.
+	CfgMQTTTCPAuthJWTIssuer = "mqtt.tcp.auth.jwt.issuer"
+	// CfgMQTTTCPAuthJWTAudience is the audience that must be contained in the "aud" claim of the JWTs.
+	CfgMQTTTCPAuthJWTAudience = "mqtt.tcp.auth.jwt.audience"
 
 	// CfgMQTTTCPTLSEnabled defines whether to enable TLS for TCP connections.
 	CfgMQTTTCPTLSEnabled = "mqtt.tcp.tls.enabled"
@@ -38,6 +212,12 @@ const (
 	CfgMQTTTCPTLSCertificatePath = "mqtt.tcp.tls.certificatePath"
 	// CfgMQTTTCPTLSPrivateKeyPath is the path to the private key file (x509 PEM) for TCP connections with TLS.
 	CfgMQTTTCPTLSPrivateKeyPath = "mqtt.tcp.tls.privateKeyPath"
+	// CfgMQTTTCPTLSReloadOnSIGHUP defines whether the TLS certificate and private key are reloaded from the files on SIGHUP.
+	CfgMQTTTCPTLSReloadOnSIGHUP = "mqtt.tcp.tls.reloadOnSIGHUP"
+	// CfgMQTTTCPTLSClientAuthEnabled defines whether TCP clients with TLS need to present a valid client certificate.
+	CfgMQTTTCPTLSClientAuthEnabled = "mqtt.tcp.tls.clientAuth.enabled"
+	// CfgMQTTTCPTLSClientCAPath is the path to the file with the CA certificates (x509 PEM) the client certificates are verified with.
+	CfgMQTTTCPTLSClientCAPath = "mqtt.tcp.tls.clientAuth.caPath"
 
 	// CfgPrometheusEnabled defines whether to enable the prometheus metrics.
 	CfgPrometheusEnabled = "prometheus.enabled"
@@ -47,33 +227,138 @@ const (
 	CfgPrometheusProcessMetrics = "prometheus.processMetrics"
 	// CfgPrometheusBindAddress bind address on which the Prometheus HTTP server listens.
 	CfgPrometheusBindAddress = "prometheus.bindAddress"
+
+	// CfgAdminEnabled defines whether to enable the admin HTTP server.
+	CfgAdminEnabled = "admin.enabled"
+	// CfgAdminBindAddress bind address on which the admin HTTP server listens.
+	CfgAdminBindAddress = "admin.bindAddress"
+	// CfgAdminAuthEnabled defines whether the admin HTTP server requires basic auth.
+	CfgAdminAuthEnabled = "admin.auth.enabled"
+	// CfgAdminAuthPasswordSalt is the auth salt used for hashing the passwords of the admin users.
+	CfgAdminAuthPasswordSalt = "admin.auth.passwordSalt"
+	// CfgAdminAuthUsers is the list of admin users with their password+salt as a scrypt hash.
+	CfgAdminAuthUsers = "admin.auth.users"
 )
 
 func flagSet() *flag.FlagSet {
 	fs := flag.NewFlagSet("", flag.ContinueOnError)
 	fs.String(CfgINXAddress, "localhost:9029", "the INX address to which to connect to")
 
+	fs.String(CfgLoggerLevel, "info", "the minimum enabled logging level")
+	fs.Bool(CfgLoggerDisableCaller, true, "stops annotating logs with the calling function's file name and line number")
+	fs.String(CfgLoggerEncoding, "console", "the logger's encoding (console or json)")
+	fs.StringSlice(CfgLoggerOutputPaths, []string{"stdout"}, "a list of URLs, file paths or stdout/stderr to write logging output to")
+
 	fs.Int(CfgMQTTBufferSize, 0, "the size of the client buffers in bytes")
 	fs.Int(CfgMQTTBufferBlockSize, 0, "the size per client buffer R/W block in bytes")
+	fs.String(CfgMQTTSlowClientPolicy, string(mqtt.SlowClientPolicyBlock), "defines what happens to messages for a client whose outgoing buffer is saturated (block, drop-oldest, drop-newest or disconnect). Dropped QoS 0 messages are lost, dropped QoS 1 and 2 messages are redelivered")
+	fs.String(CfgMQTTFirehosePolicy, string(mqtt.FirehosePolicyNone), "defines what happens to messages on the firehose topics for a client under backpressure (none, drop or sample). The firehose is degraded first, so the subscribers of the other topics stay fully served")
+	fs.StringSlice(CfgMQTTFirehoseTopics, []string{topicMessages}, "the MQTT topic filters (wildcards allowed) of the firehose topics")
+	fs.Int(CfgMQTTFirehoseBackpressureThreshold, 50, "the fill level of the outgoing buffer of a client in percent, above which the client is under backpressure and the firehose policy applies")
+	fs.Int(CfgMQTTFirehoseSampleRate, 10, "the ratio of the firehose messages that are delivered to a client under backpressure by the sample policy, e.g. 10 delivers every 10th message")
 	fs.Int(CfgMQTTTopicCleanupThreshold, 10000, "the number of deleted topics that trigger a garbage collection of the topic manager")
+	fs.Int(CfgMQTTMaxTopicManagerSize, 0, "the maximum amount of distinct subscribed topics, subscriptions to new topics beyond are rejected with a SUBACK failure (0 = unlimited). This is a last-resort guard against running out of memory")
+	fs.String(CfgMQTTTopicPrefix, "", "the namespace the topics are published in, e.g. \"mainnet\" publishes \"mainnet/milestones/latest\" (empty = no prefix, system topics are never prefixed)")
+	fs.Int(CfgMQTTLimitsMaxClients, 0, "the maximum amount of concurrently connected clients across all listeners, connections beyond are refused with the CONNACK return code 0x03 (0 = unlimited)")
+	fs.Int(CfgMQTTLimitsMaxConnectionsPerIP, 0, "the maximum amount of connections per remote IP, connections beyond are refused with the CONNACK return code 0x03 (0 = unlimited)")
+	fs.Int(CfgMQTTLimitsMaxSubscriptionsPerClient, 0, "the maximum amount of subscriptions per client, subscriptions beyond are refused with a SUBACK failure (0 = unlimited)")
+	fs.Int(CfgMQTTLimitsMaxMessagesPerSecondPerClient, 0, "the maximum rate of messages a client can publish, excess messages are dropped (0 = unlimited)")
+	fs.Int(CfgMQTTMaxRetainedMessages, 10000, "the maximum amount of retained messages the broker stores (0 = unlimited)")
+	fs.StringToString(CfgMQTTPublishQoS, map[string]string{"milestone-info/latest": "1", "milestone-info/confirmed": "1"}, "the QoS per topic the messages are published with (0, 1 or 2). Subscribers receive the messages with the lower QoS of the topic and their subscription, topics without QoS are delivered with the QoS of the subscription")
+	fs.StringSlice(CfgMQTTPublishRetainedTopics, []string{"milestone-info/latest", "milestone-info/confirmed", "protocol-parameters"}, "the topics the last message is stored as retained message for, so new subscribers immediately receive it")
+	fs.Duration(CfgMQTTRetainUpdateInterval, 0, "the minimum interval between updates of the retained message of a topic (0 = disabled). The retained message may lag behind the live messages by up to the interval")
+	fs.String(CfgMQTTRetainedStorePath, "", "the path to the file the retained messages are persisted in across restarts, they are saved on shutdown and restored on startup (empty = disabled)")
+	fs.String(CfgMQTTSubscriptionFilterFilePath, "", "the path to a JSON file with include and exclude topic filters that are subscribed internally (exclude patterns take precedence)")
+	fs.String(CfgMQTTOutputTopicGranularity, string(OutputTopicGranularityID), "defines on which output topics the outputs are published (id, address or type). Coarser granularities reduce the topic cardinality, but suppress the more specific output topics")
+	fs.Bool(CfgMQTTTransactionBalanceEnabled, false, "whether the output payloads are enriched with the consumed and created amounts, the returned storage deposit and the net balance changes of the transaction (requires to process all outputs of a ledger update)")
+	fs.Bool(CfgMQTTVerifyMilestoneSignatures, false, "whether the signatures of milestones are verified against the milestone public keys of the node before publishing, milestones with invalid signatures are dropped (costs a full deserialization and the signature checks per milestone)")
+	fs.Bool(CfgMQTTMonotonicMilestoneTimestamps, false, "whether the milestone info payloads contain a \"monotonicTimestamp\" in addition to the raw timestamp, clamped to at least the timestamp of the previous milestone (it only differs if the milestone timestamps are not strictly increasing)")
+	fs.StringToString(CfgMQTTMessageExpiry, map[string]string{}, "the expiry per topic prefix of the messages that are queued for clients (e.g. outputs/=1m). It only applies to the inflight list of persistent sessions (QoS 1 and 2 messages that were not acknowledged yet), expired messages are dropped by the broker and not redelivered")
+	fs.Duration(CfgMQTTAckTimeout, 10*time.Second, "the duration after which a QoS message that was not acknowledged by a connected client is retransmitted")
+	fs.Int(CfgMQTTAckTimeoutMaxRetransmissions, 0, "the amount of retransmissions of an unacknowledged message after which the client is treated as dead and disconnected (0 = disabled)")
+	fs.StringSlice(CfgMQTTThroughputWindows, []string{"1m", "5m", "15m"}, "the sliding time windows over which the publish rates per topic category are tracked (multiples of one second, at most 1h)")
+	fs.String(CfgMQTTPayloadFormat, string(PayloadFormatJSON), "the encoding of the output and message metadata payloads on the raw topics (json or cbor). With cbor, the payloads are additionally published CBOR encoded on the topics with the \"/raw\" suffix, the regular topics always carry JSON")
+	fs.Bool(CfgMQTTDeduplicateOutputs, false, "whether a client receives an output event at most once, even if several of its subscriptions match the output topics (strict MQTT delivers the event per matching subscription)")
+	fs.Bool(CfgMQTTDecodeOutputs, false, "whether the output payloads additionally contain the decoded output type (\"outputType\") and the bech32 address of the owner (\"ownerAddress\")")
+	fs.Bool(CfgMQTTPublishTimestamps, false, "whether the milestone info, message metadata and output payloads additionally contain the unix time in milliseconds at which the broker published them (\"brokerTimestamp\")")
+	fs.Bool(CfgMQTTPublishFilterIncludedOnly, false, "whether the message metadata is only published for messages that are included in the ledger (not referenced, conflicting and messages without a transaction are dropped)")
+	fs.Int(CfgMQTTClientEventLogSampleRate, 1, "only one out of every N successful connects and regular disconnects of clients is logged as info (1 = log every event). Failures and evictions are never sampled out and are logged as warnings")
+	fs.Duration(CfgMQTTShutdownTimeout, 0, "the maximum duration to wait on shutdown for the queued messages to be written to the clients and the in-flight messages to be acknowledged, new connections are rejected meanwhile (0 = close immediately)")
+
+	fs.Bool(CfgMQTTOutputBatchingEnabled, false, "whether clients can subscribe to \"outputs/batched\" to receive the output events of their other output subscriptions coalesced into JSON arrays (every event is delivered at most once per batch subscriber, clients need to support batches)")
+	fs.Duration(CfgMQTTOutputBatchingWindow, 1*time.Second, "the time window in which the output events for a client are coalesced into one batch")
+	fs.Int(CfgMQTTOutputBatchingMaxSize, 100, "the maximum amount of output events in a batch, full batches are delivered immediately")
+
+	fs.Duration(CfgMQTTPublishCoalesceWindow, 0, "the time window in which the messages on a coalesced topic are buffered, only the latest message of the window is published (0 = disabled)")
+	fs.StringSlice(CfgMQTTPublishCoalesceTopics, []string{"outputs/+", "outputs/+/raw", "outputs/nfts/+", "outputs/nfts/+/raw", "outputs/aliases/+", "outputs/aliases/+/raw", "outputs/foundries/+", "outputs/foundries/+/raw"}, "the MQTT topic filters (wildcards allowed) of the topics that represent a current state and are coalesced, messages on other topics are published immediately")
+
+	fs.StringToString(CfgMQTTTopicHooksHTTPPost, map[string]string{}, "maps MQTT topic filters to URLs the payloads of the matching published messages are POSTed to (e.g. milestone-info/latest=http://localhost:8080/hook). The hooks are fire-and-forget: invocations are dropped if the queue is full and not retried on failure")
+	fs.Duration(CfgMQTTTopicHooksHTTPTimeout, 5*time.Second, "the timeout of the HTTP POST topic hooks")
+	fs.Int(CfgMQTTTopicHooksWorkers, 4, "the amount of workers that call the topic hooks")
+	fs.Int(CfgMQTTTopicHooksQueueSize, 1000, "the maximum amount of queued topic hook invocations")
+
+	fs.Bool(CfgMQTTBridgeEnabled, false, "whether to forward the messages published on the bridge topics to an upstream MQTT broker, the bridge reconnects automatically with backoff and preserves QoS and retain")
+	fs.String(CfgMQTTBridgeURL, "", "the URL of the upstream MQTT broker (e.g. tcp://example.com:1883, ssl://example.com:8883 or ws://example.com:1888)")
+	fs.String(CfgMQTTBridgeUsername, "", "the username used to connect to the upstream MQTT broker (optional)")
+	fs.String(CfgMQTTBridgePassword, "", "the password used to connect to the upstream MQTT broker (optional)")
+	fs.StringSlice(CfgMQTTBridgeTopics, []string{}, "the MQTT topic filters (wildcards allowed) of the topics that are forwarded to the upstream MQTT broker, they are subscribed internally")
+	fs.String(CfgMQTTBridgeOfflineQueuePath, "", "the directory of the offline queue, which keeps the forwarded messages on disk while the upstream MQTT broker is unreachable and flushes them in order after reconnecting, with at-least-once delivery of QoS 1 and 2 messages (empty = disabled)")
+	fs.Int64(CfgMQTTBridgeOfflineQueueMaxSize, 64*1024*1024, "the maximum size of the messages in the offline queue of the bridge in bytes")
+	fs.String(CfgMQTTBridgeOfflineQueueOverflowPolicy, string(mqtt.BridgeOverflowPolicyDropOldest), "defines which messages are dropped if the offline queue of the bridge is full (drop-oldest or drop-newest)")
+
+	fs.String(CfgMQTTHealthBindAddress, "", "the bind address of the HTTP server for liveness (\"/health\", 200 while the broker is serving) and readiness (\"/ready\", 200 while the broker is serving and connected to INX) probes (\"\" = disabled)")
+
+	fs.Bool(CfgMQTTIdleConnectionReaperEnabled, false, "whether to disconnect clients without subscriptions that are idle for too long")
+	fs.Duration(CfgMQTTIdleConnectionReaperTimeout, 5*time.Minute, "the duration after which a client without subscriptions and without activity (connect, subscribe, unsubscribe, publish) is disconnected")
+	fs.Duration(CfgMQTTIdleConnectionReaperCheckInterval, 30*time.Second, "the interval in which the connections are checked for being idle")
+	fs.Duration(CfgMQTTKeepAliveMax, 0, "the maximum keep-alive of the clients, clients that negotiated a longer or no keep-alive are disconnected after 1.5 times this duration without incoming traffic (0 = no limit)")
+	fs.Duration(CfgMQTTKeepAliveReadIdleTimeout, 0, "the maximum duration without any incoming traffic (including pings) after which a client is disconnected, regardless of its keep-alive and subscriptions (0 = no limit)")
+
+	fs.Bool(CfgMQTTCloseEndedTopicsEnabled, false, "whether the clients subscribed to the topic of a single output (\"outputs/{outputId}\") are unsubscribed from it once the output was spent (wildcard subscriptions are kept)")
+	fs.String(CfgMQTTCloseEndedTopicsMessage, "", "the message that is sent to the unsubscribed clients on the ended topic after the spent output (\"\" = no message)")
 
 	fs.Bool(CfgMQTTWebsocketEnabled, true, "whether to enable the websocket connection of the MQTT broker")
 	fs.String(CfgMQTTWebsocketBindAddress, "localhost:1888", "the websocket bind address on which the MQTT broker listens on")
+	fs.Bool(CfgMQTTWebsocketTLSEnabled, false, "whether to enable the secure websocket (wss) connection of the MQTT broker, it can be used in addition to the plain websocket connection")
+	fs.String(CfgMQTTWebsocketTLSBindAddress, "localhost:1889", "the secure websocket bind address on which the MQTT broker listens on")
+	fs.String(CfgMQTTWebsocketTLSCertificatePath, "", "the path to the certificate file (x509 PEM) for secure websocket connections")
+	fs.String(CfgMQTTWebsocketTLSPrivateKeyPath, "", "the path to the private key file (x509 PEM) for secure websocket connections")
+	fs.Bool(CfgMQTTWebsocketCompressionEnabled, false, "whether the permessage-deflate compression is negotiated with the websocket clients, clients without support receive uncompressed messages")
+	fs.Int(CfgMQTTWebsocketCompressionLevel, 1, "the flate compression level of the messages written to the websocket clients (-2 = huffman only, 0 = no compression, 1 = best speed to 9 = best compression)")
 
 	fs.Bool(CfgMQTTTCPEnabled, false, "whether to enable the TCP connection of the MQTT broker")
 	fs.String(CfgMQTTTCPBindAddress, "localhost:1883", "the TCP bind address on which the MQTT broker listens on")
+	fs.Bool(CfgMQTTTCPProxyProtocolEnabled, false, "whether the TCP connections start with a PROXY protocol (v1 or v2) header that contains the real client address, e.g. behind a load balancer. Connections without a valid header are rejected")
+	fs.StringToString(CfgMQTTTCPAdditionalListeners, map[string]string{}, "maps the bind addresses of additional TCP listeners to the features they enable, separated by \"+\" (auth, tls and proxyProtocol, e.g. 0.0.0.0:8883=tls+auth). The features use the TCP auth and TLS settings, an empty value enables none")
+
+	fs.Bool(CfgMQTTUnixSocketEnabled, false, "whether to enable the unix socket connection of the MQTT broker (uses the same auth settings as the TCP connection)")
+	fs.String(CfgMQTTUnixSocketPath, "mqtt.sock", "the path of the unix socket on which the MQTT broker listens on")
 
 	fs.Bool(CfgMQTTTCPAuthEnabled, false, "whether to enable auth for TCP connections")
 	fs.String(CfgMQTTTCPAuthPasswordSalt, "0000000000000000000000000000000000000000000000000000000000000000", "the auth salt used for hashing the passwords of the users")
 	fs.StringToString(CfgMQTTTCPAuthUsers, map[string]string{}, "the list of allowed users with their password+salt as a scrypt hash")
+	fs.Bool(CfgMQTTTCPAuthReloadOnSIGHUP, false, "whether the users and the password salt are reloaded from the config file on SIGHUP without dropping existing connections (if the new users are invalid, the previous ones are kept)")
+	fs.String(CfgMQTTTCPAuthACLFilePath, "", "the path to a JSON file with the per-user allow and deny rules to subscribe to and publish on topics (empty = authenticated users may subscribe to all topics and never publish)")
+	fs.String(CfgMQTTTCPAuthJWTKeyPath, "", "the path to a file with the PEM encoded public key or the HMAC secret the JWTs of the clients are verified with (empty = users are used instead of JWTs)")
+	fs.String(CfgMQTTTCPAuthJWTIssuer, "", "the issuer that must match the \"iss\" claim of the JWTs")
+	fs.String(CfgMQTTTCPAuthJWTAudience, "", "the audience that must be contained in the \"aud\" claim of the JWTs")
 
 	fs.Bool(CfgMQTTTCPTLSEnabled, false, "whether to enable TLS for TCP connections")
 	fs.String(CfgMQTTTCPTLSCertificatePath, "", "the path to the certificate file (x509 PEM) for TCP connections with TLS")
 	fs.String(CfgMQTTTCPTLSPrivateKeyPath, "", "the path to the private key file (x509 PEM) for TCP connections with TLS")
+	fs.Bool(CfgMQTTTCPTLSReloadOnSIGHUP, false, "whether the TLS certificate and private key are reloaded from the files on SIGHUP without dropping existing connections (if the new certificate fails to load, the previous one is kept)")
+	fs.Bool(CfgMQTTTCPTLSClientAuthEnabled, false, "whether TCP clients with TLS need to present a certificate signed by one of the client CAs, connections without a valid certificate are rejected during the TLS handshake")
+	fs.String(CfgMQTTTCPTLSClientCAPath, "", "the path to the file with the CA certificates (x509 PEM) the client certificates are verified with")
 
 	fs.Bool(CfgPrometheusEnabled, false, "whether to enable the prometheus metrics")
 	fs.Bool(CfgPrometheusGoMetrics, false, "whether to include go metrics")
 	fs.Bool(CfgPrometheusProcessMetrics, false, "whether to include process metrics")
 	fs.String(CfgPrometheusBindAddress, "localhost:9312", "bind address on which the Prometheus HTTP server listens.")
+
+	fs.Bool(CfgAdminEnabled, false, "whether to enable the admin HTTP server")
+	fs.String(CfgAdminBindAddress, "localhost:9313", "bind address on which the admin HTTP server listens.")
+	fs.Bool(CfgAdminAuthEnabled, false, "whether the admin HTTP server requires basic auth")
+	fs.String(CfgAdminAuthPasswordSalt, "0000000000000000000000000000000000000000000000000000000000000000", "the auth salt used for hashing the passwords of the admin users")
+	fs.StringToString(CfgAdminAuthUsers, map[string]string{}, "the list of admin users with their password+salt as a scrypt hash")
 	return fs
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// payloadEncoder serializes the payload structs of the topics.
+type payloadEncoder interface {
+	Encode(payload interface{}) ([]byte, error)
+}
+
+// jsonPayloadEncoder serializes the payloads as JSON.
+type jsonPayloadEncoder struct{}
+
+func (jsonPayloadEncoder) Encode(payload interface{}) ([]byte, error) {
+	return json.Marshal(payload)
+}
+
+// cborPayloadEncoder serializes the payloads as CBOR (RFC 8949).
+// The map keys are the JSON field names of the payload structs,
+// embedded JSON (e.g. the output of outputPayload) is encoded as a byte string.
+type cborPayloadEncoder struct {
+	encMode cbor.EncMode
+}
+
+func (e *cborPayloadEncoder) Encode(payload interface{}) ([]byte, error) {
+	return e.encMode.Marshal(payload)
+}
+
+func newCBORPayloadEncoder() (*cborPayloadEncoder, error) {
+	encMode, err := cbor.CoreDetEncOptions().EncMode()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cborPayloadEncoder{encMode: encMode}, nil
+}
+
+// newRawPayloadEncoder returns the encoder for the raw topics, or nil if the raw topics are disabled.
+func newRawPayloadEncoder(payloadFormat PayloadFormat) (payloadEncoder, error) {
+	switch payloadFormat {
+	case PayloadFormatCBOR:
+		return newCBORPayloadEncoder()
+	default:
+		return nil, nil
+	}
+}
+
+// rawTopic returns the raw topic of the given topic.
+func rawTopic(topic string) string {
+	return topic + topicSuffixRaw
+}
+
+// trimRawTopicSuffix returns the topic without the raw suffix, if the raw topics are enabled.
+func (s *Server) trimRawTopicSuffix(topic string) string {
+	if s.rawPayloadEncoder == nil {
+		return topic
+	}
+
+	return strings.TrimSuffix(topic, topicSuffixRaw)
+}
+
+// publishRawEncoded encodes the payload with the raw payload encoder and publishes it on the given raw topics.
+// If deduplicate is set, every client receives the message at most once.
+func (s *Server) publishRawEncoded(rawTopics []string, payload interface{}, deduplicate bool) {
+	rawTopics = s.filterPublishTopics(rawTopics, payload)
+	if len(rawTopics) == 0 {
+		return
+	}
+
+	rawPayload, err := s.rawPayloadEncoder.Encode(payload)
+	if err != nil {
+		return
+	}
+
+	if deduplicate {
+		s.MQTTBroker.SendDeduplicated(rawTopics, rawPayload)
+		return
+	}
+
+	for _, topic := range rawTopics {
+		s.MQTTBroker.Send(topic, rawPayload)
+	}
+}
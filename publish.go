@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"strings"
+	"time"
 
 	"github.com/iotaledger/hive.go/serializer/v2"
 	inx "github.com/iotaledger/inx/go"
@@ -29,6 +30,10 @@ func (s *Server) PublishOnTopicIfSubscribed(topic string, payload interface{}) {
 }
 
 func (s *Server) PublishOnTopic(topic string, payload interface{}) {
+	if !s.isPublishAllowed(topic, payload) {
+		return
+	}
+
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
 		return
@@ -37,14 +42,61 @@ func (s *Server) PublishOnTopic(topic string, payload interface{}) {
 	s.MQTTBroker.Send(topic, jsonPayload)
 }
 
-func (s *Server) PublishMilestoneOnTopic(topic string, milestoneInfo *inx.MilestoneInfo) {
+func (s *Server) PublishMilestoneOnTopic(topic string, milestone *inx.Milestone) {
+	milestoneInfo := milestone.GetMilestoneInfo()
 	milestoneID := milestoneInfo.GetMilestoneId().Unwrap()
 
-	s.PublishOnTopicIfSubscribed(topic, &milestoneInfoPayload{
-		Index:       milestoneInfo.GetMilestoneIndex(),
-		Time:        milestoneInfo.GetMilestoneTimestamp(),
-		MilestoneID: iotago.EncodeHex(milestoneID[:]),
-	})
+	payload := &milestoneInfoPayload{
+		Index:           milestoneInfo.GetMilestoneIndex(),
+		Time:            milestoneInfo.GetMilestoneTimestamp(),
+		MilestoneID:     iotago.EncodeHex(milestoneID[:]),
+		BrokerTimestamp: s.brokerTimestamp(),
+	}
+	if milestonePayload := milestoneFromRawMilestone(milestone.GetMilestone()); milestonePayload != nil {
+		var emptyMilestoneID iotago.MilestoneID
+		if milestonePayload.PreviousMilestoneID != emptyMilestoneID {
+			payload.PreviousMilestoneID = iotago.EncodeHex(milestonePayload.PreviousMilestoneID[:])
+		}
+	}
+	if s.monotonicMilestoneTimestamps != nil {
+		payload.MonotonicTime = s.monotonicMilestoneTimestamps.Correct(topic, payload.Index, payload.Time)
+	}
+
+	s.PublishOnTopicIfSubscribed(topic, payload)
+}
+
+// milestoneFromRawMilestone deserializes the milestone payload, it returns nil if the payload is not available or invalid.
+func milestoneFromRawMilestone(rawMilestone *inx.RawMilestone) *iotago.Milestone {
+	if len(rawMilestone.GetData()) == 0 {
+		return nil
+	}
+
+	milestone := &iotago.Milestone{}
+	if _, err := milestone.Deserialize(rawMilestone.GetData(), serializer.DeSeriModeNoValidation, nil); err != nil {
+		return nil
+	}
+	return milestone
+}
+
+func payloadForNodeStatus(status *inx.NodeStatus) *nodeSyncStatusPayload {
+	latestMilestoneIndex := status.GetLatestMilestone().GetMilestoneIndex()
+	confirmedMilestoneIndex := status.GetConfirmedMilestone().GetMilestoneIndex()
+
+	return &nodeSyncStatusPayload{
+		IsHealthy:               status.GetIsHealthy(),
+		IsSynced:                latestMilestoneIndex > 0 && confirmedMilestoneIndex == latestMilestoneIndex,
+		LatestMilestoneIndex:    latestMilestoneIndex,
+		ConfirmedMilestoneIndex: confirmedMilestoneIndex,
+		PruningIndex:            status.GetPruningIndex(),
+	}
+}
+
+func (s *Server) PublishNodeSyncStatus(payload *nodeSyncStatusPayload) {
+	s.PublishOnTopicIfSubscribed(topicNodeSyncStatus, payload)
+}
+
+func (s *Server) PublishProtocolParameters(protocolParameters *iotago.ProtocolParameters) {
+	s.PublishOnTopicIfSubscribed(topicProtocolParameters, protocolParameters)
 }
 
 func (s *Server) PublishReceipt(r *inx.RawReceipt) {
@@ -55,14 +107,23 @@ func (s *Server) PublishReceipt(r *inx.RawReceipt) {
 	s.PublishOnTopicIfSubscribed(topicReceipts, receipt)
 }
 
-func (s *Server) PublishMessage(msg *inx.RawMessage) {
+func (s *Server) PublishMessage(messageID iotago.MessageID, msg *inx.RawMessage) {
 
 	message, err := msg.UnwrapMessage(serializer.DeSeriModeNoValidation, nil)
 	if err != nil {
 		return
 	}
 
+	if _, isMilestone := message.Payload.(*iotago.Milestone); isMilestone && s.milestoneSignatureVerifier != nil {
+		if err := s.milestoneSignatureVerifier.Verify(msg); err != nil {
+			// the message is dropped on all topics, so clients never receive an invalid milestone
+			s.log.Errorf("dropping milestone message: %s", err)
+			return
+		}
+	}
+
 	s.PublishRawOnTopicIfSubscribed(topicMessages, msg.GetData())
+	s.PublishMessageOnMessageIDTopic(messageID, msg)
 
 	switch payload := message.Payload.(type) {
 	case *iotago.Transaction:
@@ -93,6 +154,12 @@ func (s *Server) PublishMessage(msg *inx.RawMessage) {
 	}
 }
 
+// PublishMessageOnMessageIDTopic publishes the serialized message as received from INX on the topic of its message ID.
+func (s *Server) PublishMessageOnMessageIDTopic(messageID iotago.MessageID, msg *inx.RawMessage) {
+	messageTopic := strings.ReplaceAll(topicMessagesMessageID, parameterMessageID, iotago.MessageIDToHexString(messageID))
+	s.PublishRawOnTopicIfSubscribed(messageTopic, msg.GetData())
+}
+
 func (s *Server) hasSubscriberForTransactionIncludedMessage(transactionID *iotago.TransactionID) bool {
 	transactionTopic := strings.ReplaceAll(topicTransactionsIncludedMessage, parameterTransactionID, transactionID.ToHex())
 	return s.MQTTBroker.HasSubscribers(transactionTopic)
@@ -119,14 +186,21 @@ func (s *Server) PublishMessageMetadata(metadata *inx.MessageMetadata) {
 	hasSingleMessageTopicSubscriber := s.MQTTBroker.HasSubscribers(singleMessageTopic)
 	hasAllMessagesTopicSubscriber := s.MQTTBroker.HasSubscribers(topicMessageMetadataReferenced)
 
-	if !hasSingleMessageTopicSubscriber && !hasAllMessagesTopicSubscriber {
+	var hasSingleMessageRawTopicSubscriber, hasAllMessagesRawTopicSubscriber bool
+	if s.rawPayloadEncoder != nil {
+		hasSingleMessageRawTopicSubscriber = s.MQTTBroker.HasSubscribers(rawTopic(singleMessageTopic))
+		hasAllMessagesRawTopicSubscriber = s.MQTTBroker.HasSubscribers(rawTopic(topicMessageMetadataReferenced))
+	}
+
+	if !hasSingleMessageTopicSubscriber && !hasAllMessagesTopicSubscriber && !hasSingleMessageRawTopicSubscriber && !hasAllMessagesRawTopicSubscriber {
 		return
 	}
 
 	response := &messageMetadataPayload{
-		MessageID: messageID,
-		Parents:   hexEncodedMessageIDsFromINXMessageIDs(metadata.GetParents()),
-		Solid:     metadata.GetSolid(),
+		MessageID:       messageID,
+		Parents:         hexEncodedMessageIDsFromINXMessageIDs(metadata.GetParents()),
+		Solid:           metadata.GetSolid(),
+		BrokerTimestamp: s.brokerTimestamp(),
 	}
 
 	referencedByIndex := metadata.GetReferencedByMilestoneIndex()
@@ -160,18 +234,32 @@ func (s *Server) PublishMessageMetadata(metadata *inx.MessageMetadata) {
 		response.ShouldReattach = &shouldReattach
 	}
 
-	// Serialize here instead of using publishOnTopic to avoid double JSON marshaling
-	jsonPayload, err := json.Marshal(response)
-	if err != nil {
-		return
+	hasSingleMessageTopicSubscriber = hasSingleMessageTopicSubscriber && s.isPublishAllowed(singleMessageTopic, response)
+	hasAllMessagesTopicSubscriber = hasAllMessagesTopicSubscriber && s.isPublishAllowed(topicMessageMetadataReferenced, response)
+
+	if hasSingleMessageTopicSubscriber || (referenced && hasAllMessagesTopicSubscriber) {
+		// Serialize here instead of using publishOnTopic to avoid double JSON marshaling
+		jsonPayload, err := json.Marshal(response)
+		if err != nil {
+			return
+		}
+
+		if hasSingleMessageTopicSubscriber {
+			s.MQTTBroker.Send(singleMessageTopic, jsonPayload)
+		}
+		if referenced && hasAllMessagesTopicSubscriber {
+			s.MQTTBroker.Send(topicMessageMetadataReferenced, jsonPayload)
+		}
 	}
 
-	if hasSingleMessageTopicSubscriber {
-		s.MQTTBroker.Send(singleMessageTopic, jsonPayload)
+	var rawTopics []string
+	if hasSingleMessageRawTopicSubscriber {
+		rawTopics = append(rawTopics, rawTopic(singleMessageTopic))
 	}
-	if referenced && hasAllMessagesTopicSubscriber {
-		s.MQTTBroker.Send(topicMessageMetadataReferenced, jsonPayload)
+	if referenced && hasAllMessagesRawTopicSubscriber {
+		rawTopics = append(rawTopics, rawTopic(topicMessageMetadataReferenced))
 	}
+	s.publishRawEncoded(rawTopics, response, false)
 }
 
 func payloadForOutput(ledgerIndex uint32, output *inx.LedgerOutput, iotaOutput iotago.Output) *outputPayload {
@@ -207,7 +295,27 @@ func payloadForSpent(ledgerIndex uint32, spent *inx.LedgerSpent, iotaOutput iota
 	return payload
 }
 
-func (s *Server) PublishOnUnlockConditionTopics(baseTopic string, output iotago.Output, payloadFunc func() interface{}) {
+// brokerTimestamp returns the current unix time in milliseconds if the publish timestamps are enabled, 0 otherwise.
+func (s *Server) brokerTimestamp() int64 {
+	if !s.serverOptions.PublishTimestamps {
+		return 0
+	}
+	return time.Now().UnixMilli()
+}
+
+// addDecodedOutputFields adds the type and the owner address of the output to the payload if the decoding of outputs is enabled.
+func (s *Server) addDecodedOutputFields(payload *outputPayload, iotaOutput iotago.Output) {
+	if !s.serverOptions.DecodeOutputs {
+		return
+	}
+
+	payload.OutputType = iotaOutput.Type()
+	if owner := ownerAddressOfOutput(iotaOutput); owner != nil {
+		payload.OwnerAddress = owner.Bech32(s.ProtocolParameters.Bech32HRP)
+	}
+}
+
+func (s *Server) PublishOnUnlockConditionTopics(baseTopic string, output iotago.Output, publishFunc func(topic string)) {
 
 	topicFunc := func(condition unlockCondition, addressString string) string {
 		topic := strings.ReplaceAll(baseTopic, parameterCondition, string(condition))
@@ -223,54 +331,63 @@ func (s *Server) PublishOnUnlockConditionTopics(baseTopic string, output iotago.
 	// so that after checking all conditions we can see if anyone is subscribed to the wildcard
 	addressesToPublishForAny := make(map[string]struct{})
 
+	// the wildcard subscribers receive the output once per address on the literal "any" topic below,
+	// so the condition topics are only published for the subscribers of exactly these topics,
+	// otherwise the wildcard subscribers would receive the output once more per matching condition.
+	publishConditionFunc := func(topic string) {
+		if s.MQTTBroker.HasExactSubscribers(topic) || (s.rawPayloadEncoder != nil && s.MQTTBroker.HasExactSubscribers(rawTopic(topic))) {
+			publishFunc(topic)
+		}
+	}
+
 	address := unlockConditions.Address()
 	if address != nil {
 		addr := address.Address.Bech32(s.ProtocolParameters.Bech32HRP)
-		s.PublishPayloadFuncOnTopicIfSubscribed(topicFunc(unlockConditionAddress, addr), payloadFunc)
+		publishConditionFunc(topicFunc(unlockConditionAddress, addr))
 		addressesToPublishForAny[addr] = struct{}{}
 	}
 
 	storageReturn := unlockConditions.StorageDepositReturn()
 	if storageReturn != nil {
 		addr := storageReturn.ReturnAddress.Bech32(s.ProtocolParameters.Bech32HRP)
-		s.PublishPayloadFuncOnTopicIfSubscribed(topicFunc(unlockConditionStorageReturn, addr), payloadFunc)
+		publishConditionFunc(topicFunc(unlockConditionStorageReturn, addr))
 		addressesToPublishForAny[addr] = struct{}{}
 	}
 
 	expiration := unlockConditions.Expiration()
 	if expiration != nil {
 		addr := expiration.ReturnAddress.Bech32(s.ProtocolParameters.Bech32HRP)
-		s.PublishPayloadFuncOnTopicIfSubscribed(topicFunc(unlockConditionExpiration, addr), payloadFunc)
+		publishConditionFunc(topicFunc(unlockConditionExpiration, addr))
 		addressesToPublishForAny[addr] = struct{}{}
 	}
 
 	stateController := unlockConditions.StateControllerAddress()
 	if stateController != nil {
 		addr := stateController.Address.Bech32(s.ProtocolParameters.Bech32HRP)
-		s.PublishPayloadFuncOnTopicIfSubscribed(topicFunc(unlockConditionStateController, addr), payloadFunc)
+		publishConditionFunc(topicFunc(unlockConditionStateController, addr))
 		addressesToPublishForAny[addr] = struct{}{}
 	}
 
 	governor := unlockConditions.GovernorAddress()
 	if governor != nil {
 		addr := governor.Address.Bech32(s.ProtocolParameters.Bech32HRP)
-		s.PublishPayloadFuncOnTopicIfSubscribed(topicFunc(unlockConditionGovernor, addr), payloadFunc)
+		publishConditionFunc(topicFunc(unlockConditionGovernor, addr))
 		addressesToPublishForAny[addr] = struct{}{}
 	}
 
 	immutableAlias := unlockConditions.ImmutableAlias()
 	if immutableAlias != nil {
 		addr := immutableAlias.Address.Bech32(s.ProtocolParameters.Bech32HRP)
-		s.PublishPayloadFuncOnTopicIfSubscribed(topicFunc(unlockConditionImmutableAlias, addr), payloadFunc)
+		publishConditionFunc(topicFunc(unlockConditionImmutableAlias, addr))
 		addressesToPublishForAny[addr] = struct{}{}
 	}
 
 	for addr := range addressesToPublishForAny {
-		s.PublishPayloadFuncOnTopicIfSubscribed(topicFunc(unlockConditionAny, addr), payloadFunc)
+		publishFunc(topicFunc(unlockConditionAny, addr))
 	}
 }
 
-func (s *Server) PublishOnOutputChainTopics(outputID *iotago.OutputID, output iotago.Output, payloadFunc func() interface{}) {
+func (s *Server) PublishOnOutputChainTopics(outputID *iotago.OutputID, output iotago.Output, publishFunc func(topic string)) {
 
 	switch o := output.(type) {
 	case *iotago.NFTOutput:
@@ -281,7 +398,7 @@ func (s *Server) PublishOnOutputChainTopics(outputID *iotago.OutputID, output io
 			nftID = nftAddr.NFTID()
 		}
 		topic := strings.ReplaceAll(topicNFTOutputs, parameterNFTID, nftID.String())
-		s.PublishPayloadFuncOnTopicIfSubscribed(topic, payloadFunc)
+		publishFunc(topic)
 
 	case *iotago.AliasOutput:
 		aliasID := o.AliasID
@@ -290,7 +407,7 @@ func (s *Server) PublishOnOutputChainTopics(outputID *iotago.OutputID, output io
 			aliasID = iotago.AliasIDFromOutputID(*outputID)
 		}
 		topic := strings.ReplaceAll(topicAliasOutputs, parameterAliasID, aliasID.String())
-		s.PublishPayloadFuncOnTopicIfSubscribed(topic, payloadFunc)
+		publishFunc(topic)
 
 	case *iotago.FoundryOutput:
 		foundryID, err := o.ID()
@@ -298,13 +415,105 @@ func (s *Server) PublishOnOutputChainTopics(outputID *iotago.OutputID, output io
 			return
 		}
 		topic := strings.ReplaceAll(topicFoundryOutputs, parameterFoundryID, foundryID.String())
-		s.PublishPayloadFuncOnTopicIfSubscribed(topic, payloadFunc)
+		publishFunc(topic)
 
 	default:
 	}
 }
 
-func (s *Server) PublishOutput(ledgerIndex uint32, output *inx.LedgerOutput) {
+func outputTypeNameForOutput(output iotago.Output) (outputTypeName, bool) {
+	switch output.Type() {
+	case iotago.OutputTreasury:
+		return outputTypeNameTreasury, true
+	case iotago.OutputBasic:
+		return outputTypeNameBasic, true
+	case iotago.OutputAlias:
+		return outputTypeNameAlias, true
+	case iotago.OutputFoundry:
+		return outputTypeNameFoundry, true
+	case iotago.OutputNFT:
+		return outputTypeNameNFT, true
+	default:
+		return "", false
+	}
+}
+
+func (s *Server) PublishOnOutputTypeTopic(baseTopic string, output iotago.Output, publishFunc func(topic string)) {
+	typeName, ok := outputTypeNameForOutput(output)
+	if !ok {
+		return
+	}
+
+	topic := strings.ReplaceAll(baseTopic, parameterOutputType, string(typeName))
+	publishFunc(topic)
+}
+
+// outputPublishFuncs returns the function that publishes an output event on a topic and the function
+// that has to be called after the event was published on all topics.
+// If the deduplication or batching of outputs is enabled, the topics are collected and the event is sent to every client
+// at most once, even if several of its subscriptions match the topics.
+// If the raw topics are enabled, the event is additionally published on the raw topics of the matching topics.
+func (s *Server) outputPublishFuncs(payloadFunc func() interface{}) (func(topic string), func()) {
+	publishFunc, flushFunc := s.jsonOutputPublishFuncs(payloadFunc)
+	if s.rawPayloadEncoder == nil {
+		return publishFunc, flushFunc
+	}
+
+	// the raw payloads are never batched, because the batches are JSON arrays
+	var rawTopics []string
+	publishWithRawFunc := func(topic string) {
+		publishFunc(topic)
+		if s.MQTTBroker.HasSubscribers(rawTopic(topic)) {
+			rawTopics = append(rawTopics, rawTopic(topic))
+		}
+	}
+	flushWithRawFunc := func() {
+		flushFunc()
+		s.publishRawEncoded(rawTopics, payloadFunc(), s.serverOptions.DeduplicateOutputs)
+	}
+
+	return publishWithRawFunc, flushWithRawFunc
+}
+
+// jsonOutputPublishFuncs returns the publish and flush functions of outputPublishFuncs for the regular JSON topics.
+func (s *Server) jsonOutputPublishFuncs(payloadFunc func() interface{}) (func(topic string), func()) {
+	if !s.serverOptions.DeduplicateOutputs && !s.serverOptions.OutputBatchingEnabled {
+		return func(topic string) {
+			s.PublishPayloadFuncOnTopicIfSubscribed(topic, payloadFunc)
+		}, func() {}
+	}
+
+	var topics []string
+	publishFunc := func(topic string) {
+		if s.MQTTBroker.HasSubscribers(topic) {
+			topics = append(topics, topic)
+		}
+	}
+	flushFunc := func() {
+		if len(topics) == 0 {
+			return
+		}
+
+		payload := payloadFunc()
+		topics = s.filterPublishTopics(topics, payload)
+		if len(topics) == 0 {
+			return
+		}
+
+		jsonPayload, err := json.Marshal(payload)
+		if err != nil {
+			return
+		}
+
+		s.MQTTBroker.SendBatchable(topics, jsonPayload, s.serverOptions.DeduplicateOutputs)
+	}
+
+	return publishFunc, flushFunc
+}
+
+// PublishOutput publishes a created output on the output topics.
+// The transaction balance is optional and added to the payload if given.
+func (s *Server) PublishOutput(ledgerIndex uint32, output *inx.LedgerOutput, transactionBalance *transactionBalancePayload) {
 
 	iotaOutput, err := output.UnwrapOutput(serializer.DeSeriModeNoValidation, nil)
 	if err != nil {
@@ -315,13 +524,23 @@ func (s *Server) PublishOutput(ledgerIndex uint32, output *inx.LedgerOutput) {
 	payloadFunc := func() interface{} {
 		if payload == nil {
 			payload = payloadForOutput(ledgerIndex, output, iotaOutput)
+			if payload != nil {
+				payload.TransactionBalance = transactionBalance
+				payload.BrokerTimestamp = s.brokerTimestamp()
+				s.addDecodedOutputFields(payload, iotaOutput)
+			}
 		}
 		return payload
 	}
 
+	publishFunc, flushFunc := s.outputPublishFuncs(payloadFunc)
+	defer flushFunc()
+
 	outputID := output.GetOutputId().Unwrap()
-	outputsTopic := strings.ReplaceAll(topicOutputs, parameterOutputID, outputID.ToHex())
-	s.PublishPayloadFuncOnTopicIfSubscribed(outputsTopic, payloadFunc)
+	if s.publishOnOutputIDTopics() {
+		outputsTopic := strings.ReplaceAll(topicOutputs, parameterOutputID, outputID.ToHex())
+		publishFunc(outputsTopic)
+	}
 
 	// If this is the first output in a transaction (index 0), then check if someone is observing the transaction that generated this output
 	if outputID.Index() == 0 {
@@ -331,11 +550,18 @@ func (s *Server) PublishOutput(ledgerIndex uint32, output *inx.LedgerOutput) {
 		}
 	}
 
-	s.PublishOnOutputChainTopics(outputID, iotaOutput, payloadFunc)
-	s.PublishOnUnlockConditionTopics(topicOutputsByUnlockConditionAndAddress, iotaOutput, payloadFunc)
+	if s.publishOnOutputIDTopics() {
+		s.PublishOnOutputChainTopics(outputID, iotaOutput, publishFunc)
+	}
+	if s.publishOnOutputAddressTopics() {
+		s.PublishOnUnlockConditionTopics(topicOutputsByUnlockConditionAndAddress, iotaOutput, publishFunc)
+	}
+	s.PublishOnOutputTypeTopic(topicOutputsByType, iotaOutput, publishFunc)
 }
 
-func (s *Server) PublishSpent(ledgerIndex uint32, spent *inx.LedgerSpent) {
+// PublishSpent publishes a spent output on the output topics.
+// The transaction balance is optional and added to the payload if given.
+func (s *Server) PublishSpent(ledgerIndex uint32, spent *inx.LedgerSpent, transactionBalance *transactionBalancePayload) {
 
 	iotaOutput, err := spent.GetOutput().UnwrapOutput(serializer.DeSeriModeNoValidation, nil)
 	if err != nil {
@@ -346,14 +572,36 @@ func (s *Server) PublishSpent(ledgerIndex uint32, spent *inx.LedgerSpent) {
 	payloadFunc := func() interface{} {
 		if payload == nil {
 			payload = payloadForSpent(ledgerIndex, spent, iotaOutput)
+			if payload != nil {
+				payload.TransactionBalance = transactionBalance
+				payload.BrokerTimestamp = s.brokerTimestamp()
+				s.addDecodedOutputFields(payload, iotaOutput)
+			}
 		}
 		return payload
 	}
 
-	outputsTopic := strings.ReplaceAll(topicOutputs, parameterOutputID, spent.GetOutput().GetOutputId().Unwrap().ToHex())
-	s.PublishPayloadFuncOnTopicIfSubscribed(outputsTopic, payloadFunc)
+	publishFunc, flushFunc := s.outputPublishFuncs(payloadFunc)
+
+	var outputsTopic string
+	if s.publishOnOutputIDTopics() {
+		outputsTopic = strings.ReplaceAll(topicOutputs, parameterOutputID, spent.GetOutput().GetOutputId().Unwrap().ToHex())
+		publishFunc(outputsTopic)
+	}
+	if s.publishOnOutputAddressTopics() {
+		s.PublishOnUnlockConditionTopics(topicSpentOutputsByUnlockConditionAndAddress, iotaOutput, publishFunc)
+	}
+	s.PublishOnOutputTypeTopic(topicSpentOutputsByType, iotaOutput, publishFunc)
+
+	flushFunc()
 
-	s.PublishOnUnlockConditionTopics(topicSpentOutputsByUnlockConditionAndAddress, iotaOutput, payloadFunc)
+	if outputsTopic != "" {
+		// a spent output never changes again, so nothing will be published on its topic anymore
+		s.MQTTBroker.OnSourceEnded(outputsTopic)
+		if s.rawPayloadEncoder != nil {
+			s.MQTTBroker.OnSourceEnded(rawTopic(outputsTopic))
+		}
+	}
 }
 
 func messageIDFromMessageMetadataTopic(topicName string) *iotago.MessageID {
@@ -368,6 +616,22 @@ func messageIDFromMessageMetadataTopic(topicName string) *iotago.MessageID {
 	return nil
 }
 
+func messageIDFromMessagesTopic(topicName string) *iotago.MessageID {
+	if strings.HasPrefix(topicName, "messages/") {
+		// the other message topics like "messages/transaction" are no valid message IDs
+		messageIDHex := strings.Replace(topicName, "messages/", "", 1)
+
+		decoded, err := iotago.DecodeHex(messageIDHex)
+		if err != nil || len(decoded) != iotago.MessageIDLength {
+			return nil
+		}
+		messageID := iotago.MessageID{}
+		copy(messageID[:], decoded)
+		return &messageID
+	}
+	return nil
+}
+
 func transactionIDFromTransactionsIncludedMessageTopic(topicName string) *iotago.TransactionID {
 	if strings.HasPrefix(topicName, "transactions/") && strings.HasSuffix(topicName, "/included-message") {
 		transactionIDHex := strings.Replace(topicName, "transactions/", "", 1)
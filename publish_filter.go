@@ -0,0 +1,44 @@
+package main
+
+// PublishFilter decides whether a message is published on a topic, based on its decoded payload
+// (e.g. *messageMetadataPayload or *outputPayload). It returns false to drop the message on that topic.
+// The filter is called before the payload is sent to the broker, so it needs to be fast.
+type PublishFilter func(topic string, payload interface{}) bool
+
+const (
+	// ledgerInclusionStateIncluded is the ledger inclusion state of messages with a transaction that was applied to the ledger.
+	ledgerInclusionStateIncluded = "included"
+)
+
+// PublishFilterIncludedOnly drops the message metadata of messages that are not included in the ledger,
+// i.e. messages that are not referenced yet, conflicting transactions and messages without a transaction.
+// Outputs are only published once their ledger update was confirmed, so the output topics are not affected.
+func PublishFilterIncludedOnly(_ string, payload interface{}) bool {
+	metadata, ok := payload.(*messageMetadataPayload)
+	if !ok {
+		return true
+	}
+
+	return metadata.LedgerInclusionState != nil && *metadata.LedgerInclusionState == ledgerInclusionStateIncluded
+}
+
+// isPublishAllowed returns false if the publish filter drops the payload on the topic.
+func (s *Server) isPublishAllowed(topic string, payload interface{}) bool {
+	return s.serverOptions.PublishFilter == nil || s.serverOptions.PublishFilter(topic, payload)
+}
+
+// filterPublishTopics returns the topics the publish filter allows the payload to be published on.
+func (s *Server) filterPublishTopics(topics []string, payload interface{}) []string {
+	if s.serverOptions.PublishFilter == nil {
+		return topics
+	}
+
+	allowed := topics[:0]
+	for _, topic := range topics {
+		if s.serverOptions.PublishFilter(topic, payload) {
+			allowed = append(allowed, topic)
+		}
+	}
+
+	return allowed
+}
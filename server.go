@@ -7,13 +7,16 @@ import (
 	"math/rand"
 	"strings"
 	"sync"
+	"time"
 
 	grpc_retry "github.com/grpc-ecosystem/go-grpc-middleware/retry"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	"github.com/gohornet/inx-mqtt/mqtt"
+	"github.com/iotaledger/hive.go/logger"
 	inx "github.com/iotaledger/inx/go"
 	iotago "github.com/iotaledger/iota.go/v3"
 )
@@ -26,6 +29,15 @@ const (
 	grpcListenToReferencedMessages = "INX.ListenToReferencedMessages"
 	grpcListenToLedgerUpdates      = "INX.ListenToLedgerUpdates"
 	grpcListenToMigrationReceipts  = "INX.ListenToMigrationReceipts"
+	grpcReadNodeStatus             = "INX.ReadNodeStatus"
+	grpcReadNodeConfiguration      = "INX.ReadNodeConfiguration"
+)
+
+const (
+	// nodeStatusPollingInterval is the interval in which the node status is polled to detect sync status changes.
+	nodeStatusPollingInterval = 1 * time.Second
+	// protocolParametersPollingInterval is the interval in which the node configuration is polled to detect protocol parameter changes.
+	protocolParametersPollingInterval = 1 * time.Minute
 )
 
 type topicSubcription struct {
@@ -35,38 +47,87 @@ type topicSubcription struct {
 }
 
 type Server struct {
+	log                *logger.Logger
 	MQTTBroker         *mqtt.Broker
 	Client             inx.INXClient
 	ProtocolParameters *iotago.ProtocolParameters
+	serverOptions      *ServerOptions
 	brokerOptions      *mqtt.BrokerOptions
 
+	// monotonicMilestoneTimestamps corrects the milestone timestamps (optional).
+	monotonicMilestoneTimestamps *monotonicMilestoneTimestamps
+	// milestoneSignatureVerifier drops milestones with invalid signatures (optional).
+	milestoneSignatureVerifier *milestoneSignatureVerifier
+	// rawPayloadEncoder encodes the payloads of the raw topics (nil if the raw topics are disabled).
+	rawPayloadEncoder payloadEncoder
+
+	// metricsRegistry is the registry the broker metrics are registered at (optional).
+	metricsRegistry *prometheus.Registry
+
 	grpcSubscriptionsLock sync.Mutex
 	grpcSubscriptions     map[string]*topicSubcription
+
+	// readyChan is closed once the broker was started and all listeners are serving.
+	readyChan chan struct{}
 }
 
-func NewServer(client inx.INXClient, brokerOpts ...mqtt.BrokerOption) (*Server, error) {
+func NewServer(log *logger.Logger, client inx.INXClient, serverOpts []ServerOption, brokerOpts ...mqtt.BrokerOption) (*Server, error) {
+
+	serverOptions := &ServerOptions{}
+	serverOptions.ApplyOnDefault(serverOpts...)
+	if err := serverOptions.Validate(); err != nil {
+		return nil, err
+	}
+
+	rawPayloadEncoder, err := newRawPayloadEncoder(serverOptions.PayloadFormat)
+	if err != nil {
+		return nil, err
+	}
 
 	opts := &mqtt.BrokerOptions{}
 	opts.ApplyOnDefault(brokerOpts...)
+	if serverOptions.OutputBatchingEnabled {
+		opts.BatchDeliveryTopic = topicOutputsBatched
+	}
 
-	fmt.Println("Connecting to node and reading node configuration...")
+	log.Info("Connecting to node and reading node configuration...")
 	nodeConfig, err := client.ReadNodeConfiguration(context.Background(), &inx.NoParams{}, grpc_retry.WithMax(10), grpc_retry.WithBackoff(retryBackoff))
 	if err != nil {
 		return nil, err
 	}
 
 	s := &Server{
+		log:                log,
 		Client:             client,
 		ProtocolParameters: nodeConfig.UnwrapProtocolParameters(),
+		serverOptions:      serverOptions,
 		brokerOptions:      opts,
 		grpcSubscriptions:  make(map[string]*topicSubcription),
+		rawPayloadEncoder:  rawPayloadEncoder,
+		readyChan:          make(chan struct{}),
+	}
+
+	// malformed subscriptions are rejected before they trigger any INX calls
+	opts.SubscriptionValidator = s.validateSubscriptionTopic
+
+	if serverOptions.MonotonicMilestoneTimestamps {
+		s.monotonicMilestoneTimestamps = newMonotonicMilestoneTimestamps()
+	}
+	if serverOptions.VerifyMilestoneSignatures {
+		s.milestoneSignatureVerifier = newMilestoneSignatureVerifier(nodeConfig)
 	}
 
 	return s, nil
 }
 
 func (s *Server) Start(ctx context.Context) error {
+	// the current state is published for every new subscriber, not only for the first subscriber of a topic
+	s.brokerOptions.OnClientSubscribe = func(topicName string) {
+		s.onClientSubscribeTopic(ctx, topicName)
+	}
+
 	broker, err := mqtt.NewBroker(
+		s.log.Named("Broker"),
 		func(topicName string) {
 			s.onSubscribeTopic(ctx, topicName)
 		}, func(topicName string) {
@@ -77,83 +138,118 @@ func (s *Server) Start(ctx context.Context) error {
 		return err
 	}
 
+	if s.metricsRegistry != nil {
+		if err := broker.RegisterMetrics(s.metricsRegistry); err != nil {
+			return fmt.Errorf("registering broker metrics failed: %w", err)
+		}
+	}
+
 	s.MQTTBroker = broker
-	return broker.Start()
+	if err := broker.Start(); err != nil {
+		return err
+	}
+	close(s.readyChan)
+
+	return nil
+}
+
+// Ready returns a channel that is closed once the broker was started and all listeners are serving.
+// If Start fails, the channel is never closed.
+func (s *Server) Ready() <-chan struct{} {
+	return s.readyChan
 }
 
 func (s *Server) Close() error {
 	return s.MQTTBroker.Stop()
 }
 
-func (s *Server) onSubscribeTopic(ctx context.Context, topic string) {
-	switch topic {
-	case topicMilestoneInfoLatest:
-		s.startListenIfNeeded(ctx, grpcListenToLatestMilestone, s.listenToLatestMilestone)
-		go s.fetchAndPublishMilestoneTopics(ctx)
+// Shutdown stops the broker gracefully, see Broker.Shutdown.
+func (s *Server) Shutdown(ctx context.Context) (int, error) {
+	return s.MQTTBroker.Shutdown(ctx)
+}
 
-	case topicMilestoneInfoConfirmed:
-		s.startListenIfNeeded(ctx, grpcListenToConfirmedMilestone, s.listenToConfirmedMilestone)
-		go s.fetchAndPublishMilestoneTopics(ctx)
+// publishOnOutputIDTopics returns true if outputs are published on the per-ID output topics.
+func (s *Server) publishOnOutputIDTopics() bool {
+	return s.serverOptions.OutputTopicGranularity == OutputTopicGranularityID
+}
 
-	case topicMessages, topicMessagesTransaction, topicMessagesTransactionTaggedData, topicMessagesTaggedData, topicMilestones:
-		s.startListenIfNeeded(ctx, grpcListenToMessages, s.listenToMessages)
+// publishOnOutputAddressTopics returns true if outputs are published on the unlock condition address topics.
+func (s *Server) publishOnOutputAddressTopics() bool {
+	return s.serverOptions.OutputTopicGranularity != OutputTopicGranularityType
+}
 
-	case topicReceipts:
-		s.startListenIfNeeded(ctx, grpcListenToMigrationReceipts, s.listenToMigrationReceipts)
+// isSuppressedOutputTopic returns true if nothing is published on the given output topic
+// because of the configured output topic granularity.
+func (s *Server) isSuppressedOutputTopic(topic string) bool {
+	if !strings.HasPrefix(topic, "outputs/") || strings.HasPrefix(topic, "outputs/type/") {
+		return false
+	}
 
-	default:
-		if strings.HasPrefix(topic, "message-metadata/") {
-			s.startListenIfNeeded(ctx, grpcListenToSolidMessages, s.listenToSolidMessages)
-			s.startListenIfNeeded(ctx, grpcListenToReferencedMessages, s.listenToReferencedMessages)
+	if strings.HasPrefix(topic, "outputs/unlock/") {
+		return !s.publishOnOutputAddressTopics()
+	}
 
-			if messageID := messageIDFromMessageMetadataTopic(topic); messageID != nil {
-				go s.fetchAndPublishMessageMetadata(ctx, *messageID)
-			}
+	return !s.publishOnOutputIDTopics()
+}
 
-		} else if strings.HasPrefix(topic, "messages/") && strings.Contains(topic, "tagged-data") {
-			s.startListenIfNeeded(ctx, grpcListenToMessages, s.listenToMessages)
+func (s *Server) onSubscribeTopic(ctx context.Context, topic string) {
+	for _, grpcCall := range s.topicGRPCCalls(topic) {
+		s.startListenIfNeeded(ctx, grpcCall, s.listenFuncForGRPCCall(grpcCall))
+	}
+}
 
-		} else if strings.HasPrefix(topic, "outputs/") || strings.HasPrefix(topic, "transactions/") {
-			s.startListenIfNeeded(ctx, grpcListenToLedgerUpdates, s.listenToLedgerUpdates)
+// onClientSubscribeTopic publishes the current state of the topic for a new subscriber.
+// It is called for every subscription, while the streams are only started for the first subscriber of a topic.
+func (s *Server) onClientSubscribeTopic(ctx context.Context, topic string) {
+	topic = s.trimRawTopicSuffix(topic)
 
-			if transactionID := transactionIDFromTransactionsIncludedMessageTopic(topic); transactionID != nil {
-				go s.fetchAndPublishTransactionInclusion(ctx, transactionID)
-			}
-			if outputID := outputIDFromOutputsTopic(topic); outputID != nil {
-				go s.fetchAndPublishOutput(ctx, outputID)
-			}
+	if mqtt.IsWildcardTopicFilter(topic) {
+		// only the topics without parameters have a current state that can be published for a wildcard filter
+		if mqtt.TopicFiltersOverlap(topic, topicMilestoneInfoLatest) || mqtt.TopicFiltersOverlap(topic, topicMilestoneInfoConfirmed) {
+			go s.fetchAndPublishMilestoneTopics(ctx)
+		}
+		if mqtt.TopicFiltersOverlap(topic, topicNodeSyncStatus) {
+			go s.fetchAndPublishNodeSyncStatus(ctx)
 		}
+		if mqtt.TopicFiltersOverlap(topic, topicProtocolParameters) {
+			go s.fetchAndPublishProtocolParameters(ctx)
+		}
+		return
 	}
-}
 
-func (s *Server) onUnsubscribeTopic(topic string) {
-	switch topic {
-	case topicMilestoneInfoLatest:
-		s.stopListenIfNeeded(grpcListenToLatestMilestone)
+	if s.isSuppressedOutputTopic(topic) {
+		return
+	}
 
-	case topicMilestoneInfoConfirmed:
-		s.stopListenIfNeeded(grpcListenToConfirmedMilestone)
+	switch topic {
+	case topicMilestoneInfoLatest, topicMilestoneInfoConfirmed:
+		go s.fetchAndPublishMilestoneTopics(ctx)
 
-	case topicMessages, topicMessagesTransaction, topicMessagesTransactionTaggedData, topicMessagesTaggedData, topicMilestones:
-		s.stopListenIfNeeded(grpcListenToMessages)
+	case topicNodeSyncStatus:
+		go s.fetchAndPublishNodeSyncStatus(ctx)
 
-	case topicReceipts:
-		s.stopListenIfNeeded(grpcListenToMigrationReceipts)
+	case topicProtocolParameters:
+		go s.fetchAndPublishProtocolParameters(ctx)
 
 	default:
-		if strings.HasPrefix(topic, "message-metadata/") {
-			s.stopListenIfNeeded(grpcListenToSolidMessages)
-			s.stopListenIfNeeded(grpcListenToReferencedMessages)
-
-		} else if strings.HasPrefix(topic, "messages/") && strings.Contains(topic, "tagged-data") {
-			s.stopListenIfNeeded(grpcListenToMessages)
-
-		} else if strings.HasPrefix(topic, "outputs/") || strings.HasPrefix(topic, "transactions/") {
-			s.stopListenIfNeeded(grpcListenToLedgerUpdates)
+		if messageID := messageIDFromMessageMetadataTopic(topic); messageID != nil {
+			go s.fetchAndPublishMessageMetadata(ctx, *messageID)
+		} else if messageID := messageIDFromMessagesTopic(topic); messageID != nil {
+			go s.fetchAndPublishMessage(ctx, *messageID)
+		} else if transactionID := transactionIDFromTransactionsIncludedMessageTopic(topic); transactionID != nil {
+			go s.fetchAndPublishTransactionInclusion(ctx, transactionID)
+		} else if outputID := outputIDFromOutputsTopic(topic); outputID != nil {
+			go s.fetchAndPublishOutput(ctx, outputID)
 		}
 	}
 }
 
+func (s *Server) onUnsubscribeTopic(topic string) {
+	for _, grpcCall := range s.topicGRPCCalls(topic) {
+		s.stopListenIfNeeded(grpcCall)
+	}
+}
+
 func (s *Server) stopListenIfNeeded(grpcCall string) {
 	s.grpcSubscriptionsLock.Lock()
 	defer s.grpcSubscriptionsLock.Unlock()
@@ -192,12 +288,12 @@ func (s *Server) startListenIfNeeded(ctx context.Context, grpcCall string, liste
 		Identifier: subscriptionIdentifier,
 	}
 	go func() {
-		fmt.Printf("Listen to %s\n", grpcCall)
+		s.log.Infof("Listen to %s", grpcCall)
 		err := listenFunc(c)
 		if err != nil && !errors.Is(err, context.Canceled) {
-			fmt.Printf("Finished listen to %s with error: %s\n", grpcCall, err.Error())
+			s.log.Warnf("Finished listen to %s with error: %s", grpcCall, err.Error())
 		} else {
-			fmt.Printf("Finished listen to %s\n", grpcCall)
+			s.log.Infof("Finished listen to %s", grpcCall)
 		}
 		s.grpcSubscriptionsLock.Lock()
 		sub, ok := s.grpcSubscriptions[grpcCall]
@@ -222,13 +318,13 @@ func (s *Server) listenToLatestMilestone(ctx context.Context) error {
 			if err == io.EOF || status.Code(err) == codes.Canceled {
 				break
 			}
-			fmt.Printf("listenToLatestMilestone: %s\n", err.Error())
+			s.log.Warnf("listenToLatestMilestone: %s", err.Error())
 			break
 		}
 		if c.Err() != nil {
 			break
 		}
-		s.PublishMilestoneOnTopic(topicMilestoneInfoLatest, milestone.GetMilestoneInfo())
+		s.PublishMilestoneOnTopic(topicMilestoneInfoLatest, milestone)
 	}
 	return nil
 }
@@ -246,13 +342,13 @@ func (s *Server) listenToConfirmedMilestone(ctx context.Context) error {
 			if err == io.EOF || status.Code(err) == codes.Canceled {
 				break
 			}
-			fmt.Printf("listenToConfirmedMilestone: %s\n", err.Error())
+			s.log.Warnf("listenToConfirmedMilestone: %s", err.Error())
 			break
 		}
 		if c.Err() != nil {
 			break
 		}
-		s.PublishMilestoneOnTopic(topicMilestoneInfoConfirmed, milestone.GetMilestoneInfo())
+		s.PublishMilestoneOnTopic(topicMilestoneInfoConfirmed, milestone)
 	}
 	return nil
 }
@@ -271,13 +367,13 @@ func (s *Server) listenToMessages(ctx context.Context) error {
 			if err == io.EOF || status.Code(err) == codes.Canceled {
 				break
 			}
-			fmt.Printf("listenToMessages: %s\n", err.Error())
+			s.log.Warnf("listenToMessages: %s", err.Error())
 			break
 		}
 		if c.Err() != nil {
 			break
 		}
-		s.PublishMessage(message.GetMessage())
+		s.PublishMessage(message.UnwrapMessageID(), message.GetMessage())
 	}
 	return nil
 }
@@ -296,7 +392,7 @@ func (s *Server) listenToSolidMessages(ctx context.Context) error {
 			if err == io.EOF || status.Code(err) == codes.Canceled {
 				break
 			}
-			fmt.Printf("listenToSolidMessages: %s\n", err.Error())
+			s.log.Warnf("listenToSolidMessages: %s", err.Error())
 			break
 		}
 		if c.Err() != nil {
@@ -321,7 +417,7 @@ func (s *Server) listenToReferencedMessages(ctx context.Context) error {
 			if err == io.EOF || status.Code(err) == codes.Canceled {
 				break
 			}
-			fmt.Printf("listenToReferencedMessages: %s\n", err.Error())
+			s.log.Warnf("listenToReferencedMessages: %s", err.Error())
 			break
 		}
 		if c.Err() != nil {
@@ -346,7 +442,7 @@ func (s *Server) listenToLedgerUpdates(ctx context.Context) error {
 			if err == io.EOF || status.Code(err) == codes.Canceled {
 				break
 			}
-			fmt.Printf("listenToLedgerUpdates: %s\n", err.Error())
+			s.log.Warnf("listenToLedgerUpdates: %s", err.Error())
 			break
 		}
 		if c.Err() != nil {
@@ -355,11 +451,17 @@ func (s *Server) listenToLedgerUpdates(ctx context.Context) error {
 		index := ledgerUpdate.GetMilestoneIndex()
 		created := ledgerUpdate.GetCreated()
 		consumed := ledgerUpdate.GetConsumed()
+
+		var transactionBalances map[iotago.TransactionID]*transactionBalancePayload
+		if s.serverOptions.TransactionBalanceEnabled {
+			transactionBalances = s.transactionBalancesForLedgerUpdate(created, consumed)
+		}
+
 		for _, o := range created {
-			s.PublishOutput(index, o)
+			s.PublishOutput(index, o, transactionBalances[o.GetOutputId().Unwrap().TransactionID()])
 		}
 		for _, o := range consumed {
-			s.PublishSpent(index, o)
+			s.PublishSpent(index, o, transactionBalances[*o.UnwrapTransactionIDSpent()])
 		}
 	}
 	return nil
@@ -378,7 +480,7 @@ func (s *Server) listenToMigrationReceipts(ctx context.Context) error {
 			if err == io.EOF || status.Code(err) == codes.Canceled {
 				break
 			}
-			fmt.Printf("listenToMigrationReceipts: %s\n", err.Error())
+			s.log.Warnf("listenToMigrationReceipts: %s", err.Error())
 			break
 		}
 		if c.Err() != nil {
@@ -389,18 +491,137 @@ func (s *Server) listenToMigrationReceipts(ctx context.Context) error {
 	return nil
 }
 
+// listenToNodeStatus polls the node status, since INX does not stream it,
+// and publishes the sync status whenever it changed.
+func (s *Server) listenToNodeStatus(ctx context.Context) error {
+	ticker := time.NewTicker(nodeStatusPollingInterval)
+	defer ticker.Stop()
+
+	var lastSyncStatus *nodeSyncStatusPayload
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		nodeStatus, err := s.Client.ReadNodeStatus(ctx, &inx.NoParams{})
+		if err != nil {
+			if ctx.Err() != nil || status.Code(err) == codes.Canceled {
+				return nil
+			}
+			s.log.Warnf("listenToNodeStatus: %s", err.Error())
+			continue
+		}
+
+		syncStatus := payloadForNodeStatus(nodeStatus)
+		if lastSyncStatus == nil {
+			// the initial sync status is published by fetchAndPublishNodeSyncStatus on subscription
+			lastSyncStatus = syncStatus
+			continue
+		}
+
+		if *lastSyncStatus == *syncStatus {
+			// only publish on change
+			continue
+		}
+		lastSyncStatus = syncStatus
+
+		s.PublishNodeSyncStatus(syncStatus)
+	}
+}
+
+// listenToProtocolParameters polls the node configuration, since INX does not stream it,
+// and publishes the protocol parameters whenever they changed.
+func (s *Server) listenToProtocolParameters(ctx context.Context) error {
+	ticker := time.NewTicker(protocolParametersPollingInterval)
+	defer ticker.Stop()
+
+	var lastProtocolParameters *iotago.ProtocolParameters
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		nodeConfig, err := s.Client.ReadNodeConfiguration(ctx, &inx.NoParams{})
+		if err != nil {
+			if ctx.Err() != nil || status.Code(err) == codes.Canceled {
+				return nil
+			}
+			s.log.Warnf("listenToProtocolParameters: %s", err.Error())
+			continue
+		}
+
+		protocolParameters := nodeConfig.UnwrapProtocolParameters()
+		if lastProtocolParameters == nil {
+			// the initial protocol parameters are published by fetchAndPublishProtocolParameters on subscription
+			lastProtocolParameters = protocolParameters
+			continue
+		}
+
+		if *lastProtocolParameters == *protocolParameters {
+			// only publish on change
+			continue
+		}
+		lastProtocolParameters = protocolParameters
+
+		s.PublishProtocolParameters(protocolParameters)
+	}
+}
+
+func (s *Server) fetchAndPublishProtocolParameters(ctx context.Context) {
+	s.log.Debug("fetchAndPublishProtocolParameters")
+	resp, err := s.Client.ReadNodeConfiguration(ctx, &inx.NoParams{})
+	if err != nil {
+		return
+	}
+	s.PublishProtocolParameters(resp.UnwrapProtocolParameters())
+}
+
+func (s *Server) fetchAndPublishNodeSyncStatus(ctx context.Context) {
+	s.log.Debug("fetchAndPublishNodeSyncStatus")
+	resp, err := s.Client.ReadNodeStatus(ctx, &inx.NoParams{})
+	if err != nil {
+		return
+	}
+	s.PublishNodeSyncStatus(payloadForNodeStatus(resp))
+}
+
 func (s *Server) fetchAndPublishMilestoneTopics(ctx context.Context) {
-	fmt.Println("fetchAndPublishMilestoneTopics")
+	s.log.Debug("fetchAndPublishMilestoneTopics")
 	resp, err := s.Client.ReadNodeStatus(ctx, &inx.NoParams{})
 	if err != nil {
 		return
 	}
-	s.PublishMilestoneOnTopic(topicMilestoneInfoLatest, resp.GetLatestMilestone())
-	s.PublishMilestoneOnTopic(topicMilestoneInfoConfirmed, resp.GetConfirmedMilestone())
+	s.PublishMilestoneOnTopic(topicMilestoneInfoLatest, s.readMilestone(ctx, resp.GetLatestMilestone()))
+	s.PublishMilestoneOnTopic(topicMilestoneInfoConfirmed, s.readMilestone(ctx, resp.GetConfirmedMilestone()))
+}
+
+// readMilestone reads the milestone payload of the milestone info.
+// If the milestone can't be read, only the milestone info is returned.
+func (s *Server) readMilestone(ctx context.Context, milestoneInfo *inx.MilestoneInfo) *inx.Milestone {
+	milestone, err := s.Client.ReadMilestone(ctx, &inx.MilestoneRequest{MilestoneIndex: milestoneInfo.GetMilestoneIndex()})
+	if err != nil {
+		s.log.Debugf("reading milestone %d failed: %s", milestoneInfo.GetMilestoneIndex(), err)
+		return &inx.Milestone{MilestoneInfo: milestoneInfo}
+	}
+	return milestone
+}
+
+func (s *Server) fetchAndPublishMessage(ctx context.Context, messageID iotago.MessageID) {
+	s.log.Debugf("fetchAndPublishMessage: %s", iotago.MessageIDToHexString(messageID))
+	resp, err := s.Client.ReadMessage(ctx, inx.NewMessageId(messageID))
+	if err != nil {
+		return
+	}
+	// only the message ID topic is published, the subscribers of the live topics already received the message
+	s.PublishMessageOnMessageIDTopic(messageID, resp)
 }
 
 func (s *Server) fetchAndPublishMessageMetadata(ctx context.Context, messageID iotago.MessageID) {
-	fmt.Printf("fetchAndPublishMessageMetadata: %s\n", iotago.MessageIDToHexString(messageID))
+	s.log.Debugf("fetchAndPublishMessageMetadata: %s", iotago.MessageIDToHexString(messageID))
 	resp, err := s.Client.ReadMessageMetadata(ctx, inx.NewMessageId(messageID))
 	if err != nil {
 		return
@@ -409,16 +630,16 @@ func (s *Server) fetchAndPublishMessageMetadata(ctx context.Context, messageID i
 }
 
 func (s *Server) fetchAndPublishOutput(ctx context.Context, outputID *iotago.OutputID) {
-	fmt.Printf("fetchAndPublishOutput: %s\n", outputID.ToHex())
+	s.log.Debugf("fetchAndPublishOutput: %s", outputID.ToHex())
 	resp, err := s.Client.ReadOutput(ctx, inx.NewOutputId(outputID))
 	if err != nil {
 		return
 	}
-	s.PublishOutput(resp.GetLedgerIndex(), resp.GetOutput())
+	s.PublishOutput(resp.GetLedgerIndex(), resp.GetOutput(), nil)
 }
 
 func (s *Server) fetchAndPublishTransactionInclusion(ctx context.Context, transactionID *iotago.TransactionID) {
-	fmt.Printf("fetchAndPublishTransactionInclusion: %s\n", transactionID.ToHex())
+	s.log.Debugf("fetchAndPublishTransactionInclusion: %s", transactionID.ToHex())
 	outputID := &iotago.OutputID{}
 	copy(outputID[:], transactionID[:])
 
@@ -436,3 +657,11 @@ func (s *Server) fetchAndPublishTransactionInclusionWithMessage(ctx context.Cont
 	}
 	s.PublishTransactionIncludedMessage(transactionID, resp)
 }
+
+// MilestoneSignatureVerificationFailures returns the amount of milestones that were dropped because of invalid signatures.
+func (s *Server) MilestoneSignatureVerificationFailures() uint64 {
+	if s.milestoneSignatureVerifier == nil {
+		return 0
+	}
+	return s.milestoneSignatureVerifier.VerificationFailures()
+}
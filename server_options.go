@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+)
+
+// OutputTopicGranularity defines on which output topics the outputs are published.
+type OutputTopicGranularity string
+
+const (
+	// OutputTopicGranularityID publishes the outputs on all output topics,
+	// including the per-ID topics ("outputs/{outputId}", "outputs/nfts/{nftId}", "outputs/aliases/{aliasId}", "outputs/foundries/{foundryId}").
+	// This results in one topic per output in the topic manager.
+	OutputTopicGranularityID OutputTopicGranularity = "id"
+	// OutputTopicGranularityAddress suppresses the per-ID topics,
+	// the outputs are only published on the unlock condition address topics and the output type topics.
+	// The topic cardinality is bounded by the amount of addresses.
+	OutputTopicGranularityAddress OutputTopicGranularity = "address"
+	// OutputTopicGranularityType suppresses the per-ID and the address topics,
+	// the outputs are only published on the output type topics ("outputs/type/{outputType}").
+	// The topic cardinality is bounded by the amount of output types.
+	OutputTopicGranularityType OutputTopicGranularity = "type"
+)
+
+// PayloadFormat defines the encoding of the output and message metadata payloads on the raw topics.
+type PayloadFormat string
+
+const (
+	// PayloadFormatJSON only publishes the JSON payloads on the regular topics, the raw topics are disabled.
+	PayloadFormatJSON PayloadFormat = "json"
+	// PayloadFormatCBOR additionally publishes the output and message metadata payloads encoded as CBOR
+	// on the raw topics, which are the regular topics with the "/raw" suffix (e.g. "outputs/{outputId}/raw").
+	PayloadFormatCBOR PayloadFormat = "cbor"
+)
+
+// ServerOptions are options around the server.
+type ServerOptions struct {
+	// OutputTopicGranularity defines on which output topics the outputs are published.
+	OutputTopicGranularity OutputTopicGranularity
+	// TransactionBalanceEnabled defines whether the output payloads are enriched with the balance effects
+	// of the transaction that created or spent the output. This requires to process all outputs of a ledger update.
+	TransactionBalanceEnabled bool
+	// DeduplicateOutputs defines whether a client receives an output event at most once,
+	// even if several of its subscriptions match the output topics of the event.
+	// Strict MQTT delivers a message per matching subscription, so this is disabled by default.
+	DeduplicateOutputs bool
+	// OutputBatchingEnabled defines whether clients can subscribe to the batched output topic ("outputs/batched")
+	// to receive the output events of their other output subscriptions coalesced into JSON arrays.
+	// Every output event is delivered at most once per batch subscriber, so clients need to support this.
+	OutputBatchingEnabled bool
+	// MonotonicMilestoneTimestamps defines whether the milestone info payloads contain a timestamp
+	// that is corrected to be monotonically increasing, in addition to the raw milestone timestamp.
+	MonotonicMilestoneTimestamps bool
+	// VerifyMilestoneSignatures defines whether the signatures of milestones are verified against the milestone public keys
+	// of the node before publishing. Milestones with invalid signatures are dropped.
+	// This guards against a compromised or buggy node, but costs a full deserialization and the signature checks per milestone.
+	VerifyMilestoneSignatures bool
+	// PayloadFormat defines the encoding of the output and message metadata payloads on the raw topics.
+	// The regular topics always carry JSON payloads.
+	PayloadFormat PayloadFormat
+	// DecodeOutputs defines whether the output payloads contain the decoded output type and owner address,
+	// so consumers can route on them without deserializing the raw output. The raw output is always contained.
+	DecodeOutputs bool
+	// PublishTimestamps defines whether the milestone info, message metadata and output payloads contain
+	// the unix time in milliseconds at which the broker published them, to measure the delivery latency.
+	PublishTimestamps bool
+	// PublishFilter decides whether a message is published on a topic, based on its decoded payload.
+	// If no filter is set, all messages are published.
+	PublishFilter PublishFilter
+}
+
+var defaultServerOpts = []ServerOption{
+	WithOutputTopicGranularity(OutputTopicGranularityID),
+	WithTransactionBalanceEnabled(false),
+	WithDeduplicateOutputs(false),
+	WithOutputBatchingEnabled(false),
+	WithMonotonicMilestoneTimestamps(false),
+	WithVerifyMilestoneSignatures(false),
+	WithPayloadFormat(PayloadFormatJSON),
+	WithDecodeOutputs(false),
+	WithPublishTimestamps(false),
+	WithPublishFilter(nil),
+}
+
+// applies the given ServerOption.
+func (so *ServerOptions) apply(opts ...ServerOption) {
+	for _, opt := range opts {
+		opt(so)
+	}
+}
+
+// ApplyOnDefault applies the given options on top of the default options.
+func (so *ServerOptions) ApplyOnDefault(opts ...ServerOption) {
+	so.apply(defaultServerOpts...)
+	so.apply(opts...)
+}
+
+// Validate checks the options for invalid values.
+func (so *ServerOptions) Validate() error {
+	switch so.OutputTopicGranularity {
+	case OutputTopicGranularityID, OutputTopicGranularityAddress, OutputTopicGranularityType:
+	default:
+		return fmt.Errorf("invalid output topic granularity \"%s\", allowed values: %s, %s, %s", so.OutputTopicGranularity, OutputTopicGranularityID, OutputTopicGranularityAddress, OutputTopicGranularityType)
+	}
+
+	switch so.PayloadFormat {
+	case PayloadFormatJSON, PayloadFormatCBOR:
+	default:
+		return fmt.Errorf("invalid payload format \"%s\", allowed values: %s, %s", so.PayloadFormat, PayloadFormatJSON, PayloadFormatCBOR)
+	}
+
+	return nil
+}
+
+// ServerOption is a function which sets an option on a ServerOptions instance.
+type ServerOption func(options *ServerOptions)
+
+// WithOutputTopicGranularity sets on which output topics the outputs are published.
+func WithOutputTopicGranularity(outputTopicGranularity OutputTopicGranularity) ServerOption {
+	return func(options *ServerOptions) {
+		options.OutputTopicGranularity = outputTopicGranularity
+	}
+}
+
+// WithTransactionBalanceEnabled sets whether the output payloads are enriched with the balance effects of the transaction.
+func WithTransactionBalanceEnabled(transactionBalanceEnabled bool) ServerOption {
+	return func(options *ServerOptions) {
+		options.TransactionBalanceEnabled = transactionBalanceEnabled
+	}
+}
+
+// WithDeduplicateOutputs sets whether a client receives an output event at most once, even if several of its subscriptions match.
+func WithDeduplicateOutputs(deduplicateOutputs bool) ServerOption {
+	return func(options *ServerOptions) {
+		options.DeduplicateOutputs = deduplicateOutputs
+	}
+}
+
+// WithOutputBatchingEnabled sets whether clients can receive their output events coalesced into batches.
+func WithOutputBatchingEnabled(outputBatchingEnabled bool) ServerOption {
+	return func(options *ServerOptions) {
+		options.OutputBatchingEnabled = outputBatchingEnabled
+	}
+}
+
+// WithMonotonicMilestoneTimestamps sets whether the milestone info payloads contain a monotonic corrected timestamp.
+func WithMonotonicMilestoneTimestamps(monotonicMilestoneTimestamps bool) ServerOption {
+	return func(options *ServerOptions) {
+		options.MonotonicMilestoneTimestamps = monotonicMilestoneTimestamps
+	}
+}
+
+// WithVerifyMilestoneSignatures sets whether the signatures of milestones are verified before publishing.
+func WithVerifyMilestoneSignatures(verifyMilestoneSignatures bool) ServerOption {
+	return func(options *ServerOptions) {
+		options.VerifyMilestoneSignatures = verifyMilestoneSignatures
+	}
+}
+
+// WithPayloadFormat sets the encoding of the output and message metadata payloads on the raw topics.
+func WithPayloadFormat(payloadFormat PayloadFormat) ServerOption {
+	return func(options *ServerOptions) {
+		options.PayloadFormat = payloadFormat
+	}
+}
+
+// WithDecodeOutputs sets whether the output payloads contain the decoded output type and owner address.
+func WithDecodeOutputs(decodeOutputs bool) ServerOption {
+	return func(options *ServerOptions) {
+		options.DecodeOutputs = decodeOutputs
+	}
+}
+
+// WithPublishTimestamps sets whether the payloads contain the unix time in milliseconds at which the broker published them.
+func WithPublishTimestamps(publishTimestamps bool) ServerOption {
+	return func(options *ServerOptions) {
+		options.PublishTimestamps = publishTimestamps
+	}
+}
+
+// WithPublishFilter sets the filter that decides whether a message is published on a topic.
+func WithPublishFilter(publishFilter PublishFilter) ServerOption {
+	return func(options *ServerOptions) {
+		options.PublishFilter = publishFilter
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gohornet/inx-mqtt/mqtt"
+)
+
+// topicTemplate is a known topic and the INX streams that are needed to publish on it.
+type topicTemplate struct {
+	topic     string
+	grpcCalls []string
+}
+
+// topicTemplates are the known topics that are matched against the wildcard filters of subscriptions.
+var topicTemplates = []topicTemplate{
+	{topic: topicMilestoneInfoLatest, grpcCalls: []string{grpcListenToLatestMilestone}},
+	{topic: topicMilestoneInfoConfirmed, grpcCalls: []string{grpcListenToConfirmedMilestone}},
+	{topic: topicMilestones, grpcCalls: []string{grpcListenToMessages}},
+	{topic: topicMessages, grpcCalls: []string{grpcListenToMessages}},
+	{topic: topicMessagesMessageID, grpcCalls: []string{grpcListenToMessages}},
+	{topic: topicMessagesTransaction, grpcCalls: []string{grpcListenToMessages}},
+	{topic: topicMessagesTransactionTaggedData, grpcCalls: []string{grpcListenToMessages}},
+	{topic: topicMessagesTransactionTaggedDataTag, grpcCalls: []string{grpcListenToMessages}},
+	{topic: topicMessagesTaggedData, grpcCalls: []string{grpcListenToMessages}},
+	{topic: topicMessagesTaggedDataTag, grpcCalls: []string{grpcListenToMessages}},
+	{topic: topicTransactionsIncludedMessage, grpcCalls: []string{grpcListenToLedgerUpdates}},
+	{topic: topicMessageMetadata, grpcCalls: []string{grpcListenToSolidMessages, grpcListenToReferencedMessages}},
+	{topic: topicMessageMetadataReferenced, grpcCalls: []string{grpcListenToSolidMessages, grpcListenToReferencedMessages}},
+	// nothing is published on the batched output topic directly, the batches contain the events of the other subscriptions
+	{topic: topicOutputsBatched},
+	{topic: topicOutputs, grpcCalls: []string{grpcListenToLedgerUpdates}},
+	{topic: topicNFTOutputs, grpcCalls: []string{grpcListenToLedgerUpdates}},
+	{topic: topicAliasOutputs, grpcCalls: []string{grpcListenToLedgerUpdates}},
+	{topic: topicFoundryOutputs, grpcCalls: []string{grpcListenToLedgerUpdates}},
+	{topic: topicOutputsByUnlockConditionAndAddress, grpcCalls: []string{grpcListenToLedgerUpdates}},
+	{topic: topicSpentOutputsByUnlockConditionAndAddress, grpcCalls: []string{grpcListenToLedgerUpdates}},
+	{topic: topicOutputsByType, grpcCalls: []string{grpcListenToLedgerUpdates}},
+	{topic: topicSpentOutputsByType, grpcCalls: []string{grpcListenToLedgerUpdates}},
+	{topic: topicReceipts, grpcCalls: []string{grpcListenToMigrationReceipts}},
+	{topic: topicNodeSyncStatus, grpcCalls: []string{grpcReadNodeStatus}},
+	{topic: topicProtocolParameters, grpcCalls: []string{grpcReadNodeConfiguration}},
+}
+
+// filter returns the topic of the template as a topic filter, with a single-level wildcard in place of every parameter.
+func (t topicTemplate) filter() string {
+	levels := strings.Split(t.topic, "/")
+	for i, level := range levels {
+		if strings.HasPrefix(level, "{") {
+			levels[i] = "+"
+		}
+	}
+	return strings.Join(levels, "/")
+}
+
+// matchesKnownTopic returns true if at least one known topic matches the topic filter.
+func matchesKnownTopic(topicFilter string) bool {
+	for _, template := range topicTemplates {
+		if mqtt.TopicFiltersOverlap(topicFilter, template.filter()) {
+			return true
+		}
+	}
+	return false
+}
+
+// topicGRPCCalls returns the INX streams that are needed to publish on the topics that match the topic filter.
+// The result only depends on the topic filter and the configuration, so the streams started for a subscription
+// are the same that are stopped once the last client unsubscribed.
+func (s *Server) topicGRPCCalls(topic string) []string {
+	// the raw topics are published from the same sources as the regular topics
+	topic = s.trimRawTopicSuffix(topic)
+
+	if mqtt.IsWildcardTopicFilter(topic) {
+		return s.wildcardTopicGRPCCalls(topic)
+	}
+
+	if s.isSuppressedOutputTopic(topic) {
+		// no need to listen to the ledger updates, nothing will be published on this topic
+		return nil
+	}
+
+	switch topic {
+	case topicOutputsBatched:
+		// nothing is published on the batched output topic directly, the batches contain the events of the other subscriptions
+		return nil
+
+	case topicMilestoneInfoLatest:
+		return []string{grpcListenToLatestMilestone}
+
+	case topicMilestoneInfoConfirmed:
+		return []string{grpcListenToConfirmedMilestone}
+
+	case topicMessages, topicMessagesTransaction, topicMessagesTransactionTaggedData, topicMessagesTaggedData, topicMilestones:
+		return []string{grpcListenToMessages}
+
+	case topicReceipts:
+		return []string{grpcListenToMigrationReceipts}
+
+	case topicNodeSyncStatus:
+		return []string{grpcReadNodeStatus}
+
+	case topicProtocolParameters:
+		return []string{grpcReadNodeConfiguration}
+
+	default:
+		if strings.HasPrefix(topic, "message-metadata/") {
+			return []string{grpcListenToSolidMessages, grpcListenToReferencedMessages}
+
+		} else if strings.HasPrefix(topic, "messages/") && strings.Contains(topic, "tagged-data") {
+			return []string{grpcListenToMessages}
+
+		} else if messageID := messageIDFromMessagesTopic(topic); messageID != nil {
+			return []string{grpcListenToMessages}
+
+		} else if strings.HasPrefix(topic, "outputs/") || strings.HasPrefix(topic, "transactions/") {
+			return []string{grpcListenToLedgerUpdates}
+		}
+	}
+
+	return nil
+}
+
+// wildcardTopicGRPCCalls returns the union of the INX streams of all known topics that match the wildcard filter,
+// e.g. "outputs/#" needs the ledger updates and "#" needs every stream.
+// Output topics that are suppressed by the configuration don't need a stream, even if the wildcard filter matches them.
+func (s *Server) wildcardTopicGRPCCalls(topicFilter string) []string {
+	var grpcCalls []string
+	added := make(map[string]struct{})
+
+	for _, template := range topicTemplates {
+		if len(template.grpcCalls) == 0 || s.isSuppressedOutputTopic(template.topic) {
+			continue
+		}
+
+		if !mqtt.TopicFiltersOverlap(topicFilter, template.filter()) {
+			continue
+		}
+
+		for _, grpcCall := range template.grpcCalls {
+			if _, has := added[grpcCall]; has {
+				continue
+			}
+			added[grpcCall] = struct{}{}
+			grpcCalls = append(grpcCalls, grpcCall)
+		}
+	}
+
+	return grpcCalls
+}
+
+// listenFuncForGRPCCall returns the function that listens to the INX stream.
+func (s *Server) listenFuncForGRPCCall(grpcCall string) func(context.Context) error {
+	switch grpcCall {
+	case grpcListenToLatestMilestone:
+		return s.listenToLatestMilestone
+	case grpcListenToConfirmedMilestone:
+		return s.listenToConfirmedMilestone
+	case grpcListenToMessages:
+		return s.listenToMessages
+	case grpcListenToSolidMessages:
+		return s.listenToSolidMessages
+	case grpcListenToReferencedMessages:
+		return s.listenToReferencedMessages
+	case grpcListenToLedgerUpdates:
+		return s.listenToLedgerUpdates
+	case grpcListenToMigrationReceipts:
+		return s.listenToMigrationReceipts
+	case grpcReadNodeStatus:
+		return s.listenToNodeStatus
+	case grpcReadNodeConfiguration:
+		return s.listenToProtocolParameters
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTopicGRPCCalls(t *testing.T) {
+	tests := []struct {
+		name        string
+		granularity OutputTopicGranularity
+		topic       string
+		expected    []string
+	}{
+		{"exact topic", OutputTopicGranularityID, topicMilestones, []string{grpcListenToMessages}},
+		{"unlock condition any", OutputTopicGranularityID, "outputs/unlock/+/iota1qp", []string{grpcListenToLedgerUpdates}},
+		{"outputs wildcard", OutputTopicGranularityID, "outputs/#", []string{grpcListenToLedgerUpdates}},
+		{"outputs wildcard with suppressed topics", OutputTopicGranularityType, "outputs/+", nil},
+		{"outputs type wildcard", OutputTopicGranularityType, "outputs/type/#", []string{grpcListenToLedgerUpdates}},
+		{"message metadata wildcard", OutputTopicGranularityID, "message-metadata/+", []string{grpcListenToSolidMessages, grpcListenToReferencedMessages}},
+		{"milestone info wildcard", OutputTopicGranularityID, "milestone-info/+", []string{grpcListenToLatestMilestone, grpcListenToConfirmedMilestone}},
+		{"single level wildcard", OutputTopicGranularityID, "+", []string{grpcListenToMessages, grpcListenToMigrationReceipts, grpcReadNodeConfiguration}},
+		{"batched outputs have no stream", OutputTopicGranularityID, "outputs/batched", nil},
+		{"unknown wildcard", OutputTopicGranularityID, "unknown/#", nil},
+		{"all topics", OutputTopicGranularityID, "#", []string{
+			grpcListenToLatestMilestone, grpcListenToConfirmedMilestone, grpcListenToMessages, grpcListenToLedgerUpdates,
+			grpcListenToSolidMessages, grpcListenToReferencedMessages, grpcListenToMigrationReceipts, grpcReadNodeConfiguration,
+		}},
+		{"protocol parameters", OutputTopicGranularityID, topicProtocolParameters, []string{grpcReadNodeConfiguration}},
+		{"system topics wildcard", OutputTopicGranularityID, "$SYS/#", []string{grpcReadNodeStatus}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &Server{serverOptions: &ServerOptions{OutputTopicGranularity: test.granularity}}
+
+			if got, expected := fmt.Sprint(s.topicGRPCCalls(test.topic)), fmt.Sprint(test.expected); got != expected {
+				t.Fatalf("topicGRPCCalls(%s) = %s, expected %s", test.topic, got, expected)
+			}
+		})
+	}
+}
+
+func TestListenFuncForEveryTopicStream(t *testing.T) {
+	s := &Server{serverOptions: &ServerOptions{}}
+
+	for _, template := range topicTemplates {
+		for _, grpcCall := range template.grpcCalls {
+			if s.listenFuncForGRPCCall(grpcCall) == nil {
+				t.Fatalf("no listen function for %s", grpcCall)
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gohornet/inx-mqtt/mqtt"
+	iotago "github.com/iotaledger/iota.go/v3"
+)
+
+// staticTopics are the topics without parameters clients can subscribe to.
+var staticTopics = map[string]struct{}{
+	topicMilestoneInfoLatest:           {},
+	topicMilestoneInfoConfirmed:        {},
+	topicMilestones:                    {},
+	topicMessages:                      {},
+	topicMessagesTransaction:           {},
+	topicMessagesTransactionTaggedData: {},
+	topicMessagesTaggedData:            {},
+	topicMessageMetadataReferenced:     {},
+	topicOutputsBatched:                {},
+	topicReceipts:                      {},
+	topicProtocolParameters:            {},
+}
+
+// validateSubscriptionTopic checks the topic filter of a subscription against the known topics and their parameters,
+// so subscriptions that can never receive a message don't cause pointless INX calls.
+// Wildcard levels are accepted in place of the parameters, other wildcard filters are accepted if they match at least one known topic.
+// System topics are provided by the broker and not validated.
+func (s *Server) validateSubscriptionTopic(topic string) error {
+	if strings.HasPrefix(topic, "$SYS/") {
+		return nil
+	}
+
+	// the raw topics have the same parameters as the regular topics
+	topic = s.trimRawTopicSuffix(topic)
+
+	if _, has := staticTopics[topic]; has {
+		return nil
+	}
+
+	levels := strings.Split(topic, "/")
+	switch {
+	case len(levels) == 2 && levels[0] == "messages":
+		return validateHexParameter(parameterMessageID, levels[1], iotago.MessageIDLength, iotago.MessageIDLength)
+
+	case len(levels) == 3 && levels[0] == "messages" && levels[1] == "tagged-data":
+		return validateHexParameter(parameterTag, levels[2], 0, iotago.MaxTagLength)
+
+	case len(levels) == 4 && levels[0] == "messages" && levels[1] == "transaction" && levels[2] == "tagged-data":
+		return validateHexParameter(parameterTag, levels[3], 0, iotago.MaxTagLength)
+
+	case len(levels) == 3 && levels[0] == "transactions" && levels[2] == "included-message":
+		return validateHexParameter(parameterTransactionID, levels[1], iotago.TransactionIDLength, iotago.TransactionIDLength)
+
+	case len(levels) == 2 && levels[0] == "message-metadata":
+		return validateHexParameter(parameterMessageID, levels[1], iotago.MessageIDLength, iotago.MessageIDLength)
+
+	case len(levels) == 2 && levels[0] == "outputs":
+		return validateHexParameter(parameterOutputID, levels[1], iotago.OutputIDLength, iotago.OutputIDLength)
+
+	case len(levels) == 3 && levels[0] == "outputs" && levels[1] == "nfts":
+		return validateHexParameter(parameterNFTID, levels[2], iotago.NFTIDLength, iotago.NFTIDLength)
+
+	case len(levels) == 3 && levels[0] == "outputs" && levels[1] == "aliases":
+		return validateHexParameter(parameterAliasID, levels[2], iotago.AliasIDLength, iotago.AliasIDLength)
+
+	case len(levels) == 3 && levels[0] == "outputs" && levels[1] == "foundries":
+		return validateHexParameter(parameterFoundryID, levels[2], iotago.FoundryIDLength, iotago.FoundryIDLength)
+
+	case (len(levels) == 4 || (len(levels) == 5 && levels[4] == "spent")) && levels[0] == "outputs" && levels[1] == "unlock":
+		if err := validateUnlockCondition(levels[2]); err != nil {
+			return err
+		}
+		return s.validateAddressParameter(levels[3])
+
+	case (len(levels) == 3 || (len(levels) == 4 && levels[3] == "spent")) && levels[0] == "outputs" && levels[1] == "type":
+		return validateOutputTypeName(levels[2])
+
+	default:
+		if mqtt.IsWildcardTopicFilter(topic) && matchesKnownTopic(topic) {
+			return nil
+		}
+		return fmt.Errorf("unknown topic \"%s\"", topic)
+	}
+}
+
+// validateHexParameter checks that the topic parameter is a hex string with a decoded length within the given bounds.
+func validateHexParameter(parameter string, value string, minLength int, maxLength int) error {
+	if isWildcardLevel(value) {
+		return nil
+	}
+
+	decoded, err := iotago.DecodeHex(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s \"%s\": %w", parameter, value, err)
+	}
+
+	if len(decoded) < minLength || len(decoded) > maxLength {
+		if minLength == maxLength {
+			return fmt.Errorf("invalid %s \"%s\": length must be %d bytes", parameter, value, maxLength)
+		}
+		return fmt.Errorf("invalid %s \"%s\": length must be between %d and %d bytes", parameter, value, minLength, maxLength)
+	}
+
+	return nil
+}
+
+// validateAddressParameter checks that the topic parameter is a bech32 address of the network of the node.
+func (s *Server) validateAddressParameter(value string) error {
+	if isWildcardLevel(value) {
+		return nil
+	}
+
+	hrp, _, err := iotago.ParseBech32(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s \"%s\": %w", parameterAddress, value, err)
+	}
+
+	if s.ProtocolParameters != nil && hrp != s.ProtocolParameters.Bech32HRP {
+		return fmt.Errorf("invalid %s \"%s\": expected network prefix %s", parameterAddress, value, s.ProtocolParameters.Bech32HRP)
+	}
+
+	return nil
+}
+
+// validateUnlockCondition checks that the topic parameter is a known unlock condition.
+func validateUnlockCondition(value string) error {
+	switch unlockCondition(value) {
+	case unlockConditionAny, unlockConditionAddress, unlockConditionStorageReturn, unlockConditionExpiration,
+		unlockConditionStateController, unlockConditionGovernor, unlockConditionImmutableAlias:
+		return nil
+	default:
+		return fmt.Errorf("invalid %s \"%s\"", parameterCondition, value)
+	}
+}
+
+// validateOutputTypeName checks that the topic parameter is a known output type name.
+func validateOutputTypeName(value string) error {
+	if isWildcardLevel(value) {
+		return nil
+	}
+
+	switch outputTypeName(value) {
+	case outputTypeNameTreasury, outputTypeNameBasic, outputTypeNameAlias, outputTypeNameFoundry, outputTypeNameNFT:
+		return nil
+	default:
+		return fmt.Errorf("invalid %s \"%s\"", parameterOutputType, value)
+	}
+}
+
+// isWildcardLevel returns true if the topic level is a single-level ("+") or multi-level ("#") wildcard.
+func isWildcardLevel(value string) bool {
+	return value == "+" || value == "#"
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	iotago "github.com/iotaledger/iota.go/v3"
+)
+
+func TestValidateSubscriptionTopic(t *testing.T) {
+	const hrp = iotago.PrefixTestnet
+
+	rawEncoder, err := newRawPayloadEncoder(PayloadFormatCBOR)
+	if err != nil {
+		t.Fatalf("creating raw payload encoder failed: %s", err)
+	}
+
+	address := (&iotago.Ed25519Address{}).Bech32(hrp)
+	otherNetworkAddress := (&iotago.Ed25519Address{}).Bech32(iotago.PrefixMainnet)
+
+	messageID := iotago.EncodeHex(make([]byte, iotago.MessageIDLength))
+	transactionID := iotago.EncodeHex(make([]byte, iotago.TransactionIDLength))
+	outputID := iotago.EncodeHex(make([]byte, iotago.OutputIDLength))
+	nftID := iotago.EncodeHex(make([]byte, iotago.NFTIDLength))
+	aliasID := iotago.EncodeHex(make([]byte, iotago.AliasIDLength))
+	foundryID := iotago.EncodeHex(make([]byte, iotago.FoundryIDLength))
+	tooLongTag := iotago.EncodeHex(make([]byte, iotago.MaxTagLength+1))
+
+	tests := []struct {
+		name       string
+		rawEnabled bool
+		topic      string
+		// expectedError is a part of the expected error message, empty if the topic is valid.
+		expectedError string
+	}{
+		{"static topic", false, topicMilestones, ""},
+		{"protocol parameters", false, topicProtocolParameters, ""},
+		{"system topics are not validated", false, "$SYS/unknown", ""},
+		{"unknown topic", false, "unknown", "unknown topic"},
+		{"message ID", false, "messages/" + messageID, ""},
+		{"message ID too short", false, "messages/0x01", "length must be 32 bytes"},
+		{"message ID not hex", false, "messages/0xzz", "invalid {messageId}"},
+		{"message ID without prefix", false, "messages/" + strings.TrimPrefix(messageID, "0x"), "invalid {messageId}"},
+		{"message ID wildcard", false, "messages/+", ""},
+		{"tag", false, "messages/tagged-data/0x0102", ""},
+		{"transaction tag", false, "messages/transaction/tagged-data/0x0102", ""},
+		{"tag too long", false, "messages/tagged-data/" + tooLongTag, "length must be between 0 and 64 bytes"},
+		{"transaction included message", false, "transactions/" + transactionID + "/included-message", ""},
+		{"transaction ID too short", false, "transactions/0x01/included-message", "invalid {transactionId}"},
+		{"message metadata", false, "message-metadata/" + messageID, ""},
+		{"message metadata wildcard", false, "message-metadata/#", ""},
+		{"output ID", false, "outputs/" + outputID, ""},
+		{"output ID with message ID length", false, "outputs/" + messageID, "invalid {outputId}"},
+		{"NFT ID", false, "outputs/nfts/" + nftID, ""},
+		{"alias ID", false, "outputs/aliases/" + aliasID, ""},
+		{"foundry ID", false, "outputs/foundries/" + foundryID, ""},
+		{"foundry ID with alias ID length", false, "outputs/foundries/" + aliasID, "invalid {foundryId}"},
+		{"unlock condition and address", false, "outputs/unlock/address/" + address, ""},
+		{"spent unlock condition and address", false, "outputs/unlock/expiration/" + address + "/spent", ""},
+		{"any unlock condition", false, "outputs/unlock/+/" + address, ""},
+		{"unknown unlock condition", false, "outputs/unlock/owner/" + address, "invalid {condition}"},
+		{"address of another network", false, "outputs/unlock/address/" + otherNetworkAddress, "expected network prefix " + string(hrp)},
+		{"invalid address", false, "outputs/unlock/address/atoi1invalid", "invalid {address}"},
+		{"address wildcard", false, "outputs/unlock/address/+", ""},
+		{"output type", false, "outputs/type/nft", ""},
+		{"spent output type", false, "outputs/type/basic/spent", ""},
+		{"unknown output type", false, "outputs/type/token", "invalid {outputType}"},
+		{"output type wildcard", false, "outputs/type/+/spent", ""},
+		{"wildcard matching known topics", false, "outputs/#", ""},
+		{"wildcard matching all topics", false, "#", ""},
+		{"wildcard matching no known topic", false, "unknown/+", "unknown topic"},
+		{"raw topic with raw topics enabled", true, "outputs/" + outputID + "/raw", ""},
+		{"raw topic with raw topics disabled", false, "outputs/" + outputID + "/raw", "unknown topic"},
+		{"raw topic keeps the parameter validation", true, "outputs/0x01/raw", "invalid {outputId}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &Server{
+				ProtocolParameters: &iotago.ProtocolParameters{Bech32HRP: hrp},
+				serverOptions:      &ServerOptions{},
+			}
+			if test.rawEnabled {
+				s.rawPayloadEncoder = rawEncoder
+			}
+
+			err := s.validateSubscriptionTopic(test.topic)
+			if test.expectedError == "" {
+				if err != nil {
+					t.Fatalf("expected %s to be valid, got %s", test.topic, err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("expected %s to be invalid", test.topic)
+			}
+			if !strings.Contains(err.Error(), test.expectedError) {
+				t.Fatalf("error %q doesn't contain %q", err, test.expectedError)
+			}
+		})
+	}
+}
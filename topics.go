@@ -11,12 +11,14 @@ const (
 	parameterFoundryID     = "{foundryId}"
 	parameterCondition     = "{condition}"
 	parameterAddress       = "{address}"
+	parameterOutputType    = "{outputType}"
 
 	topicMilestoneInfoLatest    = "milestone-info/latest"    // milestoneInfoPayload
 	topicMilestoneInfoConfirmed = "milestone-info/confirmed" // milestoneInfoPayload
 	topicMilestones             = "milestones"               // iotago.Milestone serialized => []bytes
 
 	topicMessages                         = "messages"                                         // iotago.Message serialized => []bytes
+	topicMessagesMessageID                = "messages/" + parameterMessageID                   // iotago.Message serialized => []bytes
 	topicMessagesTransaction              = "messages/transaction"                             // iotago.Message serialized => []bytes
 	topicMessagesTransactionTaggedData    = "messages/transaction/tagged-data"                 // iotago.Message serialized => []bytes
 	topicMessagesTransactionTaggedDataTag = "messages/transaction/tagged-data/" + parameterTag // iotago.Message serialized => []bytes
@@ -28,14 +30,24 @@ const (
 	topicMessageMetadata           = "message-metadata/" + parameterMessageID // messageMetadataPayload	// renotify if "reattach" or "promote" changes? => add new INX event?
 	topicMessageMetadataReferenced = "message-metadata/referenced"            // messageMetadataPayload
 
+	topicOutputsBatched                          = "outputs/batched"                                                          // []outputPayload, delivers the events of the other output subscriptions
 	topicOutputs                                 = "outputs/" + parameterOutputID                                             // outputPayload
 	topicNFTOutputs                              = "outputs/nfts/" + parameterNFTID                                           // outputPayload
 	topicAliasOutputs                            = "outputs/aliases/" + parameterAliasID                                      // outputPayload
 	topicFoundryOutputs                          = "outputs/foundries/" + parameterFoundryID                                  // outputPayload
 	topicOutputsByUnlockConditionAndAddress      = "outputs/unlock/" + parameterCondition + "/" + parameterAddress            // outputPayload
 	topicSpentOutputsByUnlockConditionAndAddress = "outputs/unlock/" + parameterCondition + "/" + parameterAddress + "/spent" // outputPayload
+	topicOutputsByType                           = "outputs/type/" + parameterOutputType                                      // outputPayload
+	topicSpentOutputsByType                      = "outputs/type/" + parameterOutputType + "/spent"                           // outputPayload
 
 	topicReceipts = "receipts"
+
+	topicNodeSyncStatus = "$SYS/node/syncstatus" // nodeSyncStatusPayload
+
+	topicProtocolParameters = "protocol-parameters" // iotago.ProtocolParameters
+
+	// topicSuffixRaw is appended to the output and message metadata topics to receive the payloads in the configured binary payload format.
+	topicSuffixRaw = "/raw"
 )
 
 type unlockCondition string
@@ -49,3 +61,13 @@ const (
 	unlockConditionGovernor        unlockCondition = "governor"
 	unlockConditionImmutableAlias  unlockCondition = "immutable-alias"
 )
+
+type outputTypeName string
+
+const (
+	outputTypeNameTreasury outputTypeName = "treasury"
+	outputTypeNameBasic    outputTypeName = "basic"
+	outputTypeNameAlias    outputTypeName = "alias"
+	outputTypeNameFoundry  outputTypeName = "foundry"
+	outputTypeNameNFT      outputTypeName = "nft"
+)
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/iotaledger/hive.go/serializer/v2"
+	inx "github.com/iotaledger/inx/go"
+	iotago "github.com/iotaledger/iota.go/v3"
+)
+
+// transactionBalance accumulates the balance effects of a transaction.
+type transactionBalance struct {
+	consumedAmount         uint64
+	createdAmount          uint64
+	storageDepositReturned uint64
+	balanceChanges         map[string]int64
+}
+
+// ownerAddressOfOutput returns the address that owns the output.
+// This is the address unlock condition for basic and NFT outputs,
+// the state controller for alias outputs and the immutable alias for foundry outputs.
+func ownerAddressOfOutput(output iotago.Output) iotago.Address {
+	unlockConditions, err := output.UnlockConditions().Set()
+	if err != nil {
+		return nil
+	}
+
+	if address := unlockConditions.Address(); address != nil {
+		return address.Address
+	}
+	if stateController := unlockConditions.StateControllerAddress(); stateController != nil {
+		return stateController.Address
+	}
+	if immutableAlias := unlockConditions.ImmutableAlias(); immutableAlias != nil {
+		return immutableAlias.Address
+	}
+
+	return nil
+}
+
+// transactionBalancesForLedgerUpdate derives the balance effects of all transactions of a ledger update
+// from the outputs they consumed and created:
+//   - the consumed and created amounts are the sums of the deposits of the consumed and created outputs.
+//   - the storage deposit returned is the sum of the amounts of the storage deposit return unlock conditions
+//     of the consumed outputs, which the transaction had to return to the return addresses.
+//   - the net balance change per owner address is the deposit of the created outputs owned by the address
+//     minus the deposit of the consumed outputs owned by the address.
+//
+// Outputs without a transaction that consumed them (e.g. migrated funds) only contribute to the created amounts.
+func (s *Server) transactionBalancesForLedgerUpdate(created []*inx.LedgerOutput, consumed []*inx.LedgerSpent) map[iotago.TransactionID]*transactionBalancePayload {
+	balances := make(map[iotago.TransactionID]*transactionBalance)
+
+	balanceForTransaction := func(transactionID iotago.TransactionID) *transactionBalance {
+		balance, has := balances[transactionID]
+		if !has {
+			balance = &transactionBalance{
+				balanceChanges: make(map[string]int64),
+			}
+			balances[transactionID] = balance
+		}
+		return balance
+	}
+
+	for _, spent := range consumed {
+		iotaOutput, err := spent.GetOutput().UnwrapOutput(serializer.DeSeriModeNoValidation, nil)
+		if err != nil {
+			continue
+		}
+
+		balance := balanceForTransaction(*spent.UnwrapTransactionIDSpent())
+		balance.consumedAmount += iotaOutput.Deposit()
+
+		if unlockConditions, err := iotaOutput.UnlockConditions().Set(); err == nil {
+			if storageReturn := unlockConditions.StorageDepositReturn(); storageReturn != nil {
+				balance.storageDepositReturned += storageReturn.Amount
+			}
+		}
+
+		if owner := ownerAddressOfOutput(iotaOutput); owner != nil {
+			balance.balanceChanges[owner.Bech32(s.ProtocolParameters.Bech32HRP)] -= int64(iotaOutput.Deposit())
+		}
+	}
+
+	for _, output := range created {
+		iotaOutput, err := output.UnwrapOutput(serializer.DeSeriModeNoValidation, nil)
+		if err != nil {
+			continue
+		}
+
+		balance := balanceForTransaction(output.GetOutputId().Unwrap().TransactionID())
+		balance.createdAmount += iotaOutput.Deposit()
+
+		if owner := ownerAddressOfOutput(iotaOutput); owner != nil {
+			balance.balanceChanges[owner.Bech32(s.ProtocolParameters.Bech32HRP)] += int64(iotaOutput.Deposit())
+		}
+	}
+
+	payloads := make(map[iotago.TransactionID]*transactionBalancePayload, len(balances))
+	for transactionID, balance := range balances {
+		balanceChanges := make(map[string]string, len(balance.balanceChanges))
+		for address, change := range balance.balanceChanges {
+			balanceChanges[address] = strconv.FormatInt(change, 10)
+		}
+
+		payloads[transactionID] = &transactionBalancePayload{
+			TransactionID:          transactionID.ToHex(),
+			ConsumedAmount:         strconv.FormatUint(balance.consumedAmount, 10),
+			CreatedAmount:          strconv.FormatUint(balance.createdAmount, 10),
+			StorageDepositReturned: strconv.FormatUint(balance.storageDepositReturned, 10),
+			BalanceChanges:         balanceChanges,
+		}
+	}
+
+	return payloads
+}
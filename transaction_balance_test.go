@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/iotaledger/hive.go/serializer/v2"
+	inx "github.com/iotaledger/inx/go"
+	iotago "github.com/iotaledger/iota.go/v3"
+)
+
+// testAddress returns an Ed25519 address filled with the given byte.
+func testAddress(b byte) *iotago.Ed25519Address {
+	address := &iotago.Ed25519Address{}
+	for i := range address {
+		address[i] = b
+	}
+	return address
+}
+
+// testLedgerOutput serializes a basic output with the given owner, deposit and optional storage deposit return.
+func testLedgerOutput(t *testing.T, transactionID iotago.TransactionID, index uint16, owner iotago.Address, deposit uint64, storageDepositReturn uint64, returnAddress iotago.Address) *inx.LedgerOutput {
+	t.Helper()
+
+	conditions := iotago.UnlockConditions{&iotago.AddressUnlockCondition{Address: owner}}
+	if storageDepositReturn > 0 {
+		conditions = append(conditions, &iotago.StorageDepositReturnUnlockCondition{ReturnAddress: returnAddress, Amount: storageDepositReturn})
+	}
+
+	output := &iotago.BasicOutput{Amount: deposit, Conditions: conditions}
+	data, err := output.Serialize(serializer.DeSeriModeNoValidation, nil)
+	if err != nil {
+		t.Fatalf("serializing output failed: %s", err)
+	}
+
+	outputID := iotago.OutputIDFromTransactionIDAndIndex(transactionID, index)
+	return &inx.LedgerOutput{OutputId: inx.NewOutputId(&outputID), Output: data}
+}
+
+func testLedgerSpent(output *inx.LedgerOutput, spendingTransactionID iotago.TransactionID) *inx.LedgerSpent {
+	return &inx.LedgerSpent{Output: output, TransactionIdSpent: spendingTransactionID[:]}
+}
+
+func TestTransactionBalancesForLedgerUpdate(t *testing.T) {
+	const hrp = iotago.PrefixTestnet
+	s := &Server{ProtocolParameters: &iotago.ProtocolParameters{Bech32HRP: hrp}}
+
+	alice, bob, carol := testAddress(1), testAddress(2), testAddress(3)
+	previousTransactionID := iotago.TransactionID{0x01}
+	transferTransactionID := iotago.TransactionID{0x02}
+	migrationTransactionID := iotago.TransactionID{0x03}
+
+	// alice sends 700 to bob and returns the storage deposit of 100 to carol, the remainder of 700 goes back to alice
+	consumed := []*inx.LedgerSpent{
+		testLedgerSpent(testLedgerOutput(t, previousTransactionID, 0, alice, 1000, 0, nil), transferTransactionID),
+		testLedgerSpent(testLedgerOutput(t, previousTransactionID, 1, alice, 500, 100, carol), transferTransactionID),
+	}
+	created := []*inx.LedgerOutput{
+		testLedgerOutput(t, transferTransactionID, 0, bob, 700, 0, nil),
+		testLedgerOutput(t, transferTransactionID, 1, alice, 700, 0, nil),
+		testLedgerOutput(t, transferTransactionID, 2, carol, 100, 0, nil),
+		// an output without consumed outputs, e.g. migrated funds
+		testLedgerOutput(t, migrationTransactionID, 0, bob, 50, 0, nil),
+	}
+
+	balances := s.transactionBalancesForLedgerUpdate(created, consumed)
+	if len(balances) != 2 {
+		t.Fatalf("expected the balances of 2 transactions, got %d", len(balances))
+	}
+
+	transfer, has := balances[transferTransactionID]
+	if !has {
+		t.Fatal("no balance for the transfer")
+	}
+	if transfer.TransactionID != transferTransactionID.ToHex() {
+		t.Fatalf("unexpected transaction ID %s", transfer.TransactionID)
+	}
+	if transfer.ConsumedAmount != "1500" || transfer.CreatedAmount != "1500" {
+		t.Fatalf("unexpected amounts: consumed %s, created %s", transfer.ConsumedAmount, transfer.CreatedAmount)
+	}
+	if transfer.StorageDepositReturned != "100" {
+		t.Fatalf("unexpected storage deposit returned %s", transfer.StorageDepositReturned)
+	}
+
+	expectedChanges := map[string]string{
+		alice.Bech32(hrp): "-800",
+		bob.Bech32(hrp):   "700",
+		carol.Bech32(hrp): "100",
+	}
+	if got, expected := fmt.Sprint(transfer.BalanceChanges), fmt.Sprint(expectedChanges); got != expected {
+		t.Fatalf("balance changes %s, expected %s", got, expected)
+	}
+
+	migration, has := balances[migrationTransactionID]
+	if !has {
+		t.Fatal("no balance for the migration")
+	}
+	if migration.ConsumedAmount != "0" || migration.CreatedAmount != "50" || migration.StorageDepositReturned != "0" {
+		t.Fatalf("unexpected migration balance %+v", migration)
+	}
+	if got := migration.BalanceChanges[bob.Bech32(hrp)]; got != "50" {
+		t.Fatalf("unexpected balance change of the migration %s", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	inx "github.com/iotaledger/inx/go"
+	iotago "github.com/iotaledger/iota.go/v3"
 )
 
 // milestoneInfoPayload defines the payload of the milestone latest and confirmed topics
@@ -12,8 +13,30 @@ type milestoneInfoPayload struct {
 	Index uint32 `json:"index"`
 	// The unix time of the milestone payload.
 	Time uint32 `json:"timestamp"`
+	// The unix time of the milestone payload, clamped to at least the monotonic timestamp of the previous milestone (optional).
+	// It only differs from the raw timestamp if the milestone timestamps are not strictly increasing.
+	MonotonicTime uint32 `json:"monotonicTimestamp,omitempty"`
 	// The ID of the milestone.
 	MilestoneID string `json:"milestoneId"`
+	// The ID of the previous milestone (optional).
+	// It is omitted for the first milestone and if the milestone payload is not available.
+	PreviousMilestoneID string `json:"previousMilestoneId,omitempty"`
+	// The unix time in milliseconds at which the broker published the payload (only set if the publish timestamps are enabled).
+	BrokerTimestamp int64 `json:"brokerTimestamp,omitempty"`
+}
+
+// nodeSyncStatusPayload defines the payload of the node sync status topic
+type nodeSyncStatusPayload struct {
+	// Whether the node is healthy.
+	IsHealthy bool `json:"isHealthy"`
+	// Whether the node is synced (the confirmed milestone is the latest milestone).
+	IsSynced bool `json:"isSynced"`
+	// The index of the latest milestone.
+	LatestMilestoneIndex uint32 `json:"latestMilestoneIndex"`
+	// The index of the confirmed milestone.
+	ConfirmedMilestoneIndex uint32 `json:"confirmedMilestoneIndex"`
+	// The pruning index of the node.
+	PruningIndex uint32 `json:"pruningIndex"`
 }
 
 // messageMetadataPayload defines the payload of the message metadata topic
@@ -36,6 +59,8 @@ type messageMetadataPayload struct {
 	ShouldPromote *bool `json:"shouldPromote,omitempty"`
 	// Whether the message should be reattached.
 	ShouldReattach *bool `json:"shouldReattach,omitempty"`
+	// The unix time in milliseconds at which the broker published the payload (only set if the publish timestamps are enabled).
+	BrokerTimestamp int64 `json:"brokerTimestamp,omitempty"`
 }
 
 // outputPayload defines the payload of the output topics
@@ -62,4 +87,26 @@ type outputPayload struct {
 	LedgerIndex uint32 `json:"ledgerIndex"`
 	// The output in its serialized form.
 	RawOutput *json.RawMessage `json:"output"`
+	// The type of the output (only set if the decoding of outputs is enabled).
+	OutputType iotago.OutputType `json:"outputType,omitempty"`
+	// The bech32 encoded address that owns the output (only set if the decoding of outputs is enabled and the output has an owner).
+	OwnerAddress string `json:"ownerAddress,omitempty"`
+	// The unix time in milliseconds at which the broker published the payload (only set if the publish timestamps are enabled).
+	BrokerTimestamp int64 `json:"brokerTimestamp,omitempty"`
+	// The balance effects of the transaction that created or spent this output (optional).
+	TransactionBalance *transactionBalancePayload `json:"transactionBalance,omitempty"`
+}
+
+// transactionBalancePayload defines the balance effects of a transaction
+type transactionBalancePayload struct {
+	// The hex encoded transaction ID.
+	TransactionID string `json:"transactionId"`
+	// The sum of the deposits of the outputs consumed by the transaction.
+	ConsumedAmount string `json:"consumedAmount"`
+	// The sum of the deposits of the outputs created by the transaction.
+	CreatedAmount string `json:"createdAmount"`
+	// The sum of the storage deposits the transaction returned because of storage deposit return unlock conditions.
+	StorageDepositReturned string `json:"storageDepositReturned"`
+	// The net balance change per bech32 encoded owner address.
+	BalanceChanges map[string]string `json:"balanceChanges"`
 }